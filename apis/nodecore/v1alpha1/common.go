@@ -16,15 +16,20 @@ package v1alpha1
 
 const (
 	//PhaseReady   Phase = "Ready"
-	PhaseSolved   Phase = "Solved"
-	PhaseFailed   Phase = "Failed"
-	PhaseRunning  Phase = "Running"
-	PhaseIdle     Phase = "Idle"
-	PhaseTimeout  Phase = "Timed Out"
-	PhaseBackoff  Phase = "Backoff"
-	PhaseActive   Phase = "Active"
-	PhasePending  Phase = "Pending"
-	PhaseInactive Phase = "Inactive"
+	PhaseSolved     Phase = "Solved"
+	PhaseFailed     Phase = "Failed"
+	PhaseRunning    Phase = "Running"
+	PhaseIdle       Phase = "Idle"
+	PhaseTimeout    Phase = "Timed Out"
+	PhaseBackoff    Phase = "Backoff"
+	PhaseActive     Phase = "Active"
+	PhasePending    Phase = "Pending"
+	PhaseInactive   Phase = "Inactive"
+	PhaseTerminated Phase = "Terminated"
+	PhaseReserved   Phase = "Reserved"
+	PhasePurchased  Phase = "Purchased"
+	PhaseExpired    Phase = "Expired"
+	PhaseCancelled  Phase = "Cancelled"
 )
 
 // GenericRef represents a reference to a generic Kubernetes resource,
@@ -42,6 +47,8 @@ type NodeIdentity struct {
 	Domain string `json:"domain"`
 	NodeID string `json:"nodeID"`
 	IP     string `json:"ip"`
+	// PublicKey is the base64 encoded ed25519 key the FLUIDOS Node signs REAR messages and Contracts with.
+	PublicKey string `json:"publicKey,omitempty"`
 }
 
 // toString() returns a string representation of the GenericRef.
@@ -39,11 +39,36 @@ type Characteristics struct {
 	// GPU is the number of GPU cores of the Flavour.
 	Gpu resource.Quantity `json:"gpu,omitempty"`
 
+	// GpuDetails contains the vendor, model and memory of the GPUs of the Flavour, if known.
+	GpuDetails *GPU `json:"gpu-details,omitempty"`
+
 	// EphemeralStorage is the amount of ephemeral storage of the Flavour.
 	EphemeralStorage resource.Quantity `json:"ephemeral-storage,omitempty"`
 
 	// PersistentStorage is the amount of persistent storage of the Flavour.
 	PersistentStorage resource.Quantity `json:"persistent-storage,omitempty"`
+
+	// Pods is the maximum number of pods that can run on the Flavour.
+	Pods resource.Quantity `json:"pods,omitempty"`
+
+	// ExtendedResources contains the extended resources of the Flavour (e.g. example.com/fpga), keyed by resource name.
+	ExtendedResources map[string]resource.Quantity `json:"extended-resources,omitempty"`
+}
+
+// GPU represents the details of the GPUs of a Flavour.
+type GPU struct {
+
+	// Vendor is the vendor of the GPUs (e.g. nvidia.com, amd.com).
+	Vendor string `json:"vendor,omitempty"`
+
+	// Model is the product name of the GPUs.
+	Model string `json:"model,omitempty"`
+
+	// Memory is the amount of memory of each GPU.
+	Memory resource.Quantity `json:"memory,omitempty"`
+
+	// ResourceName is the extended resource name under which the GPUs are allocated (e.g. nvidia.com/gpu).
+	ResourceName string `json:"resourceName,omitempty"`
 }
 
 type Policy struct {
@@ -79,16 +104,108 @@ type Aggregatable struct {
 	MaxCount int `json:"maxCount"`
 }
 
+// Price is the price of a Flavour over a period. Without unit rates, the Amount is the price of the whole Flavour
+// and a partition costs the share of the Flavour it takes; with unit rates, the Amount is a base fee to which the
+// rates of the resources bought are added. Volume and commitment tiers then apply their discounts.
 type Price struct {
 
-	// Amount is the amount of the price.
+	// Amount is the decimal amount of the price.
+	//+kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
 	Amount string `json:"amount"`
 
-	// Currency is the currency of the price.
+	// Currency is the ISO-4217 code of the currency of the price.
+	//+kubebuilder:validation:Pattern=`^[A-Z]{3}$`
 	Currency string `json:"currency"`
 
-	// Period is the period of the price.
+	// Period is the ISO-8601 duration the price refers to (e.g. PT1H, P1M).
+	//+kubebuilder:validation:Pattern=`^P(\d+Y)?(\d+M)?(\d+W)?(\d+D)?(T(\d+H)?(\d+M)?(\d+S)?)?$`
 	Period string `json:"period"`
+
+	// UnitRates are the optional prices per unit of the resources bought.
+	UnitRates *UnitRates `json:"unitRates,omitempty"`
+
+	// VolumeTiers are the discounts applied when the price of the resources bought reaches a minimum amount.
+	VolumeTiers []VolumeTier `json:"volumeTiers,omitempty"`
+
+	// CommitmentTiers are the discounts applied when the resources are bought for a minimum duration.
+	CommitmentTiers []CommitmentTier `json:"commitmentTiers,omitempty"`
+}
+
+// UnitRates are the decimal prices, per period, of a unit of each resource.
+type UnitRates struct {
+
+	// Cpu is the price of a CPU core.
+	//+kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Cpu string `json:"cpu,omitempty"`
+
+	// Memory is the price of a GiB of memory.
+	//+kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Memory string `json:"memory,omitempty"`
+
+	// Gpu is the price of a GPU.
+	//+kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Gpu string `json:"gpu,omitempty"`
+
+	// Storage is the price of a GiB of persistent storage.
+	//+kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Storage string `json:"storage,omitempty"`
+}
+
+// VolumeTier is a discount applied when the price, before discounts, reaches MinAmount.
+type VolumeTier struct {
+
+	// MinAmount is the decimal amount from which the tier applies.
+	//+kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	MinAmount string `json:"minAmount"`
+
+	// Discount is the decimal percentage of the discount.
+	//+kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Discount string `json:"discount"`
+}
+
+// CommitmentTier is a discount applied when the resources are bought for at least MinDuration.
+type CommitmentTier struct {
+
+	// MinDuration is the ISO-8601 duration from which the tier applies.
+	//+kubebuilder:validation:Pattern=`^P(\d+Y)?(\d+M)?(\d+W)?(\d+D)?(T(\d+H)?(\d+M)?(\d+S)?)?$`
+	MinDuration string `json:"minDuration"`
+
+	// Discount is the decimal percentage of the discount.
+	//+kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Discount string `json:"discount"`
+}
+
+// SLA contains the service level terms offered with a Flavour.
+// The availability is measured by the buyer over each calendar month.
+type SLA struct {
+
+	// Availability is the decimal percentage of time (e.g. 99.9) the purchased resources are available in each month.
+	//+kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Availability string `json:"availability,omitempty"`
+
+	// MaxTimeToRestore is the ISO-8601 maximum duration of an outage.
+	//+kubebuilder:validation:Pattern=`^P(\d+Y)?(\d+M)?(\d+W)?(\d+D)?(T(\d+H)?(\d+M)?(\d+S)?)?$`
+	MaxTimeToRestore string `json:"maxTimeToRestore,omitempty"`
+
+	// AvailabilityCredits are the credits granted when the monthly availability falls below a threshold.
+	// The credit of the lowest threshold crossed applies.
+	AvailabilityCredits []SLACredit `json:"availabilityCredits,omitempty"`
+
+	// RestoreCredit is the decimal percentage of the monthly cost credited for each outage longer than MaxTimeToRestore.
+	//+kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	RestoreCredit string `json:"restoreCredit,omitempty"`
+}
+
+// SLACredit is a credit granted when the monthly availability falls below a threshold
+type SLACredit struct {
+
+	// BelowAvailability is the decimal availability percentage under which the credit is granted.
+	//+kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	BelowAvailability string `json:"belowAvailability"`
+
+	// Credit is the decimal percentage of the monthly cost credited.
+	//+kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Credit string `json:"credit"`
 }
 
 type OptionalFields struct {
@@ -128,6 +245,9 @@ type FlavourSpec struct {
 	// Price contains the price model of the Flavour.
 	Price Price `json:"price"`
 
+	// SLA contains the service level terms offered with the Flavour, if any.
+	SLA *SLA `json:"sla,omitempty"`
+
 	// This field is used to specify the optional fields that can be retrieved from the Flavour.
 	// In the future it will be expanded to include more optional fields defined in the REAR Protocol or custom ones.
 	OptionalFields OptionalFields `json:"optionalFields"`
@@ -144,6 +264,35 @@ type FlavourStatus struct {
 
 	// This field represents the last update time of the Flavour.
 	LastUpdateTime string `json:"lastUpdateTime"`
+
+	// PoolAllocations contains, for a pooled Flavour, how the partition sold by each active Contract is spread across the nodes of the pool.
+	PoolAllocations []PoolAllocation `json:"poolAllocations,omitempty"`
+}
+
+// PoolAllocation represents the spread of the partition sold by a Contract across the nodes of a pooled Flavour.
+type PoolAllocation struct {
+
+	// Contract is the name of the Contract that bought the partition.
+	Contract string `json:"contract"`
+
+	// Nodes contains the share of the partition placed on each node of the pool.
+	Nodes []NodeShare `json:"nodes"`
+}
+
+// NodeShare represents the resources of a partition placed on a node of a pool.
+type NodeShare struct {
+
+	// Node is the name of the node.
+	Node string `json:"node"`
+
+	// CPU is the amount of CPU placed on the node.
+	Cpu resource.Quantity `json:"cpu"`
+
+	// Memory is the amount of memory placed on the node.
+	Memory resource.Quantity `json:"memory"`
+
+	// GPU is the number of GPUs placed on the node.
+	Gpu resource.Quantity `json:"gpu,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -59,6 +59,8 @@ type MatchSelector struct {
 	Storage          resource.Quantity `json:"storage,omitempty"`
 	EphemeralStorage resource.Quantity `json:"ephemeralStorage,omitempty"`
 	Gpu              resource.Quantity `json:"gpu,omitempty"`
+	GpuModel         string            `json:"gpuModel,omitempty"`
+	GpuMemory        resource.Quantity `json:"gpuMemory,omitempty"`
 }
 
 // RangeSelector represents the criteria for selecting Flavours through a range.
@@ -73,6 +75,11 @@ type RangeSelector struct {
 	MaxEph     resource.Quantity `json:"MaxEph,omitempty"`
 	MaxStorage resource.Quantity `json:"MaxStorage,omitempty"`
 	MaxGpu     resource.Quantity `json:"MaxGpu,omitempty"`
+
+	// GpuModel, MinGpuMemory and MaxGpuMemory select the Flavours by the details of their GPUs.
+	GpuModel     string            `json:"gpuModel,omitempty"`
+	MinGpuMemory resource.Quantity `json:"minGpuMemory,omitempty"`
+	MaxGpuMemory resource.Quantity `json:"maxGpuMemory,omitempty"`
 }
 
 // SolverSpec defines the desired state of Solver
@@ -19,6 +19,7 @@
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -136,8 +137,21 @@ func (in *Characteristics) DeepCopyInto(out *Characteristics) {
 	out.Cpu = in.Cpu.DeepCopy()
 	out.Memory = in.Memory.DeepCopy()
 	out.Gpu = in.Gpu.DeepCopy()
+	if in.GpuDetails != nil {
+		in, out := &in.GpuDetails, &out.GpuDetails
+		*out = new(GPU)
+		(*in).DeepCopyInto(*out)
+	}
 	out.EphemeralStorage = in.EphemeralStorage.DeepCopy()
 	out.PersistentStorage = in.PersistentStorage.DeepCopy()
+	out.Pods = in.Pods.DeepCopy()
+	if in.ExtendedResources != nil {
+		in, out := &in.ExtendedResources, &out.ExtendedResources
+		*out = make(map[string]resource.Quantity, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Characteristics.
@@ -150,13 +164,28 @@ func (in *Characteristics) DeepCopy() *Characteristics {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CommitmentTier) DeepCopyInto(out *CommitmentTier) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CommitmentTier.
+func (in *CommitmentTier) DeepCopy() *CommitmentTier {
+	if in == nil {
+		return nil
+	}
+	out := new(CommitmentTier)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Flavour) DeepCopyInto(out *Flavour) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Flavour.
@@ -240,7 +269,12 @@ func (in *FlavourSpec) DeepCopyInto(out *FlavourSpec) {
 	in.Characteristics.DeepCopyInto(&out.Characteristics)
 	in.Policy.DeepCopyInto(&out.Policy)
 	out.Owner = in.Owner
-	out.Price = in.Price
+	in.Price.DeepCopyInto(&out.Price)
+	if in.SLA != nil {
+		in, out := &in.SLA, &out.SLA
+		*out = new(SLA)
+		(*in).DeepCopyInto(*out)
+	}
 	out.OptionalFields = in.OptionalFields
 }
 
@@ -257,6 +291,13 @@ func (in *FlavourSpec) DeepCopy() *FlavourSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FlavourStatus) DeepCopyInto(out *FlavourStatus) {
 	*out = *in
+	if in.PoolAllocations != nil {
+		in, out := &in.PoolAllocations, &out.PoolAllocations
+		*out = make([]PoolAllocation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlavourStatus.
@@ -269,6 +310,22 @@ func (in *FlavourStatus) DeepCopy() *FlavourStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPU) DeepCopyInto(out *GPU) {
+	*out = *in
+	out.Memory = in.Memory.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPU.
+func (in *GPU) DeepCopy() *GPU {
+	if in == nil {
+		return nil
+	}
+	out := new(GPU)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GenericRef) DeepCopyInto(out *GenericRef) {
 	*out = *in
@@ -292,6 +349,7 @@ func (in *MatchSelector) DeepCopyInto(out *MatchSelector) {
 	out.Storage = in.Storage.DeepCopy()
 	out.EphemeralStorage = in.EphemeralStorage.DeepCopy()
 	out.Gpu = in.Gpu.DeepCopy()
+	out.GpuMemory = in.GpuMemory.DeepCopy()
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatchSelector.
@@ -319,6 +377,24 @@ func (in *NodeIdentity) DeepCopy() *NodeIdentity {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeShare) DeepCopyInto(out *NodeShare) {
+	*out = *in
+	out.Cpu = in.Cpu.DeepCopy()
+	out.Memory = in.Memory.DeepCopy()
+	out.Gpu = in.Gpu.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeShare.
+func (in *NodeShare) DeepCopy() *NodeShare {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeShare)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OptionalFields) DeepCopyInto(out *OptionalFields) {
 	*out = *in
@@ -393,9 +469,46 @@ func (in *Policy) DeepCopy() *Policy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PoolAllocation) DeepCopyInto(out *PoolAllocation) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeShare, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PoolAllocation.
+func (in *PoolAllocation) DeepCopy() *PoolAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(PoolAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Price) DeepCopyInto(out *Price) {
 	*out = *in
+	if in.UnitRates != nil {
+		in, out := &in.UnitRates, &out.UnitRates
+		*out = new(UnitRates)
+		**out = **in
+	}
+	if in.VolumeTiers != nil {
+		in, out := &in.VolumeTiers, &out.VolumeTiers
+		*out = make([]VolumeTier, len(*in))
+		copy(*out, *in)
+	}
+	if in.CommitmentTiers != nil {
+		in, out := &in.CommitmentTiers, &out.CommitmentTiers
+		*out = make([]CommitmentTier, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Price.
@@ -421,6 +534,8 @@ func (in *RangeSelector) DeepCopyInto(out *RangeSelector) {
 	out.MaxEph = in.MaxEph.DeepCopy()
 	out.MaxStorage = in.MaxStorage.DeepCopy()
 	out.MaxGpu = in.MaxGpu.DeepCopy()
+	out.MinGpuMemory = in.MinGpuMemory.DeepCopy()
+	out.MaxGpuMemory = in.MaxGpuMemory.DeepCopy()
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RangeSelector.
@@ -433,6 +548,41 @@ func (in *RangeSelector) DeepCopy() *RangeSelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SLA) DeepCopyInto(out *SLA) {
+	*out = *in
+	if in.AvailabilityCredits != nil {
+		in, out := &in.AvailabilityCredits, &out.AvailabilityCredits
+		*out = make([]SLACredit, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SLA.
+func (in *SLA) DeepCopy() *SLA {
+	if in == nil {
+		return nil
+	}
+	out := new(SLA)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SLACredit) DeepCopyInto(out *SLACredit) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SLACredit.
+func (in *SLACredit) DeepCopy() *SLACredit {
+	if in == nil {
+		return nil
+	}
+	out := new(SLACredit)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Solver) DeepCopyInto(out *Solver) {
 	*out = *in
@@ -529,3 +679,33 @@ func (in *SolverStatus) DeepCopy() *SolverStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UnitRates) DeepCopyInto(out *UnitRates) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UnitRates.
+func (in *UnitRates) DeepCopy() *UnitRates {
+	if in == nil {
+		return nil
+	}
+	out := new(UnitRates)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeTier) DeepCopyInto(out *VolumeTier) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeTier.
+func (in *VolumeTier) DeepCopy() *VolumeTier {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeTier)
+	in.DeepCopyInto(out)
+	return out
+}
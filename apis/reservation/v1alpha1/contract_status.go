@@ -0,0 +1,80 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/tools"
+)
+
+const (
+	// ContractConditionExpiring is true when the contract is going to expire soon
+	ContractConditionExpiring = "Expiring"
+	// ContractConditionExpired is true when the contract has expired
+	ContractConditionExpired = "Expired"
+
+	// ContractPartyBuyer identifies the buyer of the contract
+	ContractPartyBuyer = "buyer"
+	// ContractPartySeller identifies the seller of the contract
+	ContractPartySeller = "seller"
+)
+
+// SetPhase sets the phase of the contract
+func (c *Contract) SetPhase(phase nodecorev1alpha1.Phase, msg string) {
+	if c.Status.Phase.StartTime == "" {
+		c.Status.Phase.StartTime = tools.GetTimeNow()
+	}
+	c.Status.Phase.Phase = phase
+	c.Status.Phase.LastChangeTime = tools.GetTimeNow()
+	c.Status.Phase.Message = msg
+}
+
+// Terminate moves the contract to the Terminated phase, recording who terminated it, why and when
+func (c *Contract) Terminate(by, reason string) {
+	message := "Contract terminated by the " + by
+	if reason != "" {
+		message += ": " + reason
+	}
+	c.SetPhase(nodecorev1alpha1.PhaseTerminated, message)
+	c.Status.Phase.EndTime = c.Status.Phase.LastChangeTime
+	c.Status.TerminatedBy = by
+	c.Status.TerminationReason = reason
+	c.Status.TerminationTime = c.Status.Phase.LastChangeTime
+}
+
+// SetCondition sets a condition of the contract, returning true if it has changed
+func (c *Contract) SetCondition(conditionType string, status metav1.ConditionStatus, reason, msg string) bool {
+	existing := meta.FindStatusCondition(c.Status.Conditions, conditionType)
+	changed := existing == nil || existing.Status != status || existing.Reason != reason || existing.Message != msg
+	meta.SetStatusCondition(&c.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            msg,
+		ObservedGeneration: c.Generation,
+	})
+	return changed
+}
+
+// IsActive returns true if the contract has not been terminated, deactivated or expired
+func (c *Contract) IsActive() bool {
+	if c.Status.Phase.Phase == nodecorev1alpha1.PhaseTerminated || c.Status.Phase.Phase == nodecorev1alpha1.PhaseInactive {
+		return false
+	}
+	return !tools.IsExpired(c.Spec.ExpirationTime)
+}
@@ -21,11 +21,34 @@ import (
 )
 
 // LiqoCredentials contains the credentials of a Liqo cluster to enstablish a peering.
+// The token is stored in a Secret owned by the Contract and only referenced here.
 type LiqoCredentials struct {
 	ClusterID   string `json:"clusterID"`
 	ClusterName string `json:"clusterName"`
-	Token       string `json:"token"`
-	Endpoint    string `json:"endpoint"`
+
+	// Token is the Liqo token in plain text.
+	// Deprecated: the token is stored in the Secret referenced by TokenSecretRef. It is only read to migrate the existing contracts.
+	Token string `json:"token,omitempty"`
+
+	Endpoint string `json:"endpoint"`
+
+	// TokenSecretRef references the Secret, owned by the contract, that contains the Liqo token.
+	TokenSecretRef *nodecorev1alpha1.GenericRef `json:"tokenSecretRef,omitempty"`
+
+	// TokenID identifies the Liqo token issued by the seller for the contract: it is the fingerprint of the token.
+	TokenID string `json:"tokenID,omitempty"`
+}
+
+// Signature contains the signature of a Contract and the identifier of the key used to produce it.
+type Signature struct {
+	// KeyID is the identifier of the signing key, computed as the fingerprint of the public key.
+	KeyID string `json:"keyID"`
+
+	// PublicKey is the base64 encoded public key of the signer.
+	PublicKey string `json:"publicKey"`
+
+	// Value is the base64 encoded signature of the canonicalised contract.
+	Value string `json:"value"`
 }
 
 // ContractSpec defines the desired state of Contract
@@ -58,8 +81,20 @@ type ContractSpec struct {
 	// This is the expiration time of the contract. It can be empty if the contract is not time limited.
 	ExpirationTime string `json:"expirationTime,omitempty"`
 
+	// AgreedPrice is the price negotiated by the buyer and the seller. If empty, the price of the Flavour applies.
+	AgreedPrice *Offer `json:"agreedPrice,omitempty"`
+
+	// SLA contains the service level terms of the Flavour the contract commits the seller to.
+	SLA *nodecorev1alpha1.SLA `json:"sla,omitempty"`
+
 	// This contains additional information about the contract if needed.
 	ExtraInformation map[string]string `json:"extraInformation,omitempty"`
+
+	// SellerSignature is the signature of the contract produced by the seller FLUIDOS Node.
+	SellerSignature *Signature `json:"sellerSignature,omitempty"`
+
+	// BuyerSignature is the counter-signature of the contract produced by the buyer FLUIDOS Node.
+	BuyerSignature *Signature `json:"buyerSignature,omitempty"`
 }
 
 // ContractStatus defines the observed state of Contract
@@ -67,6 +102,100 @@ type ContractStatus struct {
 
 	// This is the status of the contract.
 	Phase nodecorev1alpha1.PhaseStatus `json:"phase"`
+
+	// Conditions describe the lifecycle of the contract, such as its upcoming expiration.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// TerminationReason is the reason given by the party that terminated the contract before its expiration.
+	TerminationReason string `json:"terminationReason,omitempty"`
+
+	// TerminationTime is the time at which the contract has been terminated.
+	TerminationTime string `json:"terminationTime,omitempty"`
+
+	// TerminatedBy is the party that terminated the contract, either the buyer or the seller.
+	TerminatedBy string `json:"terminatedBy,omitempty"`
+
+	// TokenRevocationTime is the time at which the seller revoked the Liqo token issued for the contract.
+	TokenRevocationTime string `json:"tokenRevocationTime,omitempty"`
+
+	// SLA is the compliance of the contract with its SLA terms, as observed by the buyer.
+	SLA *SLAStatus `json:"sla,omitempty"`
+}
+
+// SLAViolationType is the SLA term a violation refers to
+type SLAViolationType string
+
+const (
+	// SLAViolationAvailability means the monthly availability fell below the target
+	SLAViolationAvailability SLAViolationType = "Availability"
+	// SLAViolationTimeToRestore means an outage lasted longer than the maximum time to restore
+	SLAViolationTimeToRestore SLAViolationType = "TimeToRestore"
+)
+
+// SLAViolation is a violation of the SLA terms of a contract
+type SLAViolation struct {
+
+	// Type is the SLA term violated.
+	Type SLAViolationType `json:"type"`
+
+	// Period is the month (YYYY-MM) the violation belongs to.
+	Period string `json:"period"`
+
+	// StartTime is the time at which the violation started.
+	StartTime string `json:"startTime"`
+
+	// Message describes the violation.
+	Message string `json:"message,omitempty"`
+
+	// Credit is the decimal percentage of the monthly cost credited for the violation.
+	Credit string `json:"credit,omitempty"`
+}
+
+// SLACreditNote is the credit owed by the seller for the violations of a month
+type SLACreditNote struct {
+
+	// Period is the month (YYYY-MM) of the credit.
+	Period string `json:"period"`
+
+	// Percentage is the decimal percentage of the cost of the month credited, capped at 100.
+	Percentage string `json:"percentage"`
+
+	// Amount is the credited amount, computed on the cost metered in the month.
+	Amount Cost `json:"amount"`
+}
+
+// SLAStatus is the compliance of a contract with its SLA terms
+type SLAStatus struct {
+
+	// Period is the month (YYYY-MM) the availability is being measured on.
+	Period string `json:"period"`
+
+	// ObservedSeconds is the time observed in the period.
+	ObservedSeconds int64 `json:"observedSeconds"`
+
+	// DownSeconds is the time the resources were unavailable in the period.
+	DownSeconds int64 `json:"downSeconds"`
+
+	// Availability is the decimal percentage of availability measured in the period.
+	Availability string `json:"availability,omitempty"`
+
+	// Healthy tells whether the last probe found the seller's Gateway and the peering healthy.
+	Healthy bool `json:"healthy"`
+
+	// LastProbeTime is the time of the last probe.
+	LastProbeTime string `json:"lastProbeTime,omitempty"`
+
+	// LastError is the failure found by the last probe, if any.
+	LastError string `json:"lastError,omitempty"`
+
+	// DownSince is the start time of the current outage, if any.
+	DownSince string `json:"downSince,omitempty"`
+
+	// Violations are the violations of the SLA terms.
+	Violations []SLAViolation `json:"violations,omitempty"`
+
+	// Credits are the credits computed for each month with violations.
+	Credits []SLACreditNote `json:"credits,omitempty"`
 }
 
 //+kubebuilder:object:root=true
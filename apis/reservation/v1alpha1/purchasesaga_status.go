@@ -0,0 +1,47 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/tools"
+)
+
+// SetPhase sets the phase of the saga
+func (s *PurchaseSaga) SetPhase(phase nodecorev1alpha1.Phase, msg string) {
+	if s.Status.Phase.StartTime == "" {
+		s.Status.Phase.StartTime = tools.GetTimeNow()
+	}
+	s.Status.Phase.Phase = phase
+	s.Status.Phase.LastChangeTime = tools.GetTimeNow()
+	s.Status.Phase.Message = msg
+}
+
+// SetStep sets the step of the saga
+func (s *PurchaseSaga) SetStep(step SagaStep, msg string) {
+	s.Status.Step = step
+	s.SetPhase(s.Status.Phase.Phase, msg)
+}
+
+// GetItemStatus returns the status of the item with the given name, adding it if missing
+func (s *PurchaseSaga) GetItemStatus(name string) *PurchaseSagaItemStatus {
+	for i := range s.Status.Items {
+		if s.Status.Items[i].Name == name {
+			return &s.Status.Items[i]
+		}
+	}
+	s.Status.Items = append(s.Status.Items, PurchaseSagaItemStatus{Name: name})
+	return &s.Status.Items[len(s.Status.Items)-1]
+}
@@ -0,0 +1,127 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+)
+
+// SagaStep is the step a PurchaseSaga is performing
+type SagaStep string
+
+const (
+	// SagaStepReserve reserves the Flavours from every seller
+	SagaStepReserve SagaStep = "Reserve"
+	// SagaStepPurchase purchases the Flavours once every reserve has succeeded
+	SagaStepPurchase SagaStep = "Purchase"
+	// SagaStepCompensate cancels the transactions and terminates the contracts already obtained
+	SagaStepCompensate SagaStep = "Compensate"
+	// SagaStepDone is reached when the saga has completed or has been rolled back
+	SagaStepDone SagaStep = "Done"
+)
+
+// PurchaseSagaItem is a Flavour to be bought from a seller as part of a PurchaseSaga
+type PurchaseSagaItem struct {
+	// Name identifies the item inside the PurchaseSaga
+	Name string `json:"name"`
+
+	// This is the Node identity of the seller FLUIDOS Node.
+	Seller nodecorev1alpha1.NodeIdentity `json:"seller"`
+
+	// PeeringCandidate is the reference to the PeeringCandidate of the Flavour to buy
+	PeeringCandidate nodecorev1alpha1.GenericRef `json:"peeringCandidate"`
+
+	// Partition is the partition of the flavour to buy
+	Partition *Partition `json:"partition,omitempty"`
+}
+
+// PurchaseSagaSpec defines the desired state of PurchaseSaga
+type PurchaseSagaSpec struct {
+	// SolverID is the ID of the solver that asks for the purchase
+	SolverID string `json:"solverID"`
+
+	// This is the Node identity of the buyer FLUIDOS Node.
+	Buyer nodecorev1alpha1.NodeIdentity `json:"buyer"`
+
+	// BuyerClusterID is the Liqo ClusterID used by the sellers to search a contract and the related resources during the peering phase.
+	BuyerClusterID string `json:"buyerClusterID"`
+
+	// Items are the Flavours to buy: either all of them are bought or none
+	Items []PurchaseSagaItem `json:"items"`
+}
+
+// PurchaseSagaItemStatus is the progress of an item of the PurchaseSaga
+type PurchaseSagaItemStatus struct {
+	// Name is the name of the item
+	Name string `json:"name"`
+
+	// Reservation is the reference to the Reservation that reserves and purchases the item
+	Reservation nodecorev1alpha1.GenericRef `json:"reservation,omitempty"`
+
+	// TransactionID is the ID of the transaction opened with the seller
+	TransactionID string `json:"transactionID,omitempty"`
+
+	// Contract is the reference to the Contract of the item
+	Contract nodecorev1alpha1.GenericRef `json:"contract,omitempty"`
+
+	// Compensated is set once the transaction has been cancelled or the contract terminated
+	Compensated bool `json:"compensated,omitempty"`
+
+	// Message describes the last event of the item
+	Message string `json:"message,omitempty"`
+}
+
+// PurchaseSagaStatus defines the observed state of PurchaseSaga
+type PurchaseSagaStatus struct {
+	// This is the current phase of the saga
+	Phase nodecorev1alpha1.PhaseStatus `json:"phase"`
+
+	// Step is the step the saga is performing
+	Step SagaStep `json:"step,omitempty"`
+
+	// Items contains the progress of each item
+	Items []PurchaseSagaItemStatus `json:"items,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// +kubebuilder:printcolumn:name="Solver ID",type=string,JSONPath=`.spec.solverID`
+// +kubebuilder:printcolumn:name="Step",type=string,JSONPath=`.status.step`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase.phase`
+// +kubebuilder:printcolumn:name="Message",type=string,priority=1,JSONPath=`.status.phase.message`
+// PurchaseSaga is the Schema for the purchasesagas API
+type PurchaseSaga struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PurchaseSagaSpec   `json:"spec,omitempty"`
+	Status PurchaseSagaStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PurchaseSagaList contains a list of PurchaseSaga
+type PurchaseSagaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PurchaseSaga `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PurchaseSaga{}, &PurchaseSagaList{})
+}
@@ -0,0 +1,36 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/tools"
+)
+
+// SetPhase sets the phase of the request
+func (rfq *RequestForQuote) SetPhase(phase nodecorev1alpha1.Phase, msg string) {
+	if rfq.Status.Phase.StartTime == "" {
+		rfq.Status.Phase.StartTime = tools.GetTimeNow()
+	}
+	rfq.Status.Phase.Phase = phase
+	rfq.Status.Phase.LastChangeTime = tools.GetTimeNow()
+	rfq.Status.Phase.Message = msg
+}
+
+// SetStep sets the step of the request
+func (rfq *RequestForQuote) SetStep(step RFQStep, msg string) {
+	rfq.Status.Step = step
+	rfq.SetPhase(rfq.Status.Phase.Phase, msg)
+}
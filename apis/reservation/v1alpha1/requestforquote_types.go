@@ -0,0 +1,160 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+)
+
+// RFQStep is the step a RequestForQuote is performing
+type RFQStep string
+
+const (
+	// RFQStepBidding collects the sealed bids of the providers
+	RFQStepBidding RFQStep = "Bidding"
+	// RFQStepAwarding awards the best bid and turns it into a Reservation
+	RFQStepAwarding RFQStep = "Awarding"
+	// RFQStepDone is reached when a bid has been awarded or no bid could be
+	RFQStepDone RFQStep = "Done"
+)
+
+// BidState is the outcome of a bid
+type BidState string
+
+const (
+	// BidReceived means the bid has been received and not evaluated yet
+	BidReceived BidState = "Received"
+	// BidAwarded means the bid won the auction
+	BidAwarded BidState = "Awarded"
+	// BidLost means a better bid won the auction
+	BidLost BidState = "Lost"
+	// BidInvalid means the bid cannot be awarded (wrong signature or currency, expired)
+	BidInvalid BidState = "Invalid"
+	// BidMissing means the provider did not bid before the deadline
+	BidMissing BidState = "Missing"
+)
+
+// RequestForQuoteSpec defines the desired state of RequestForQuote
+type RequestForQuoteSpec struct {
+	// SolverID is the ID of the solver that asks for the quotes
+	SolverID string `json:"solverID"`
+
+	// Selector is the selector of the Flavour requested to the providers
+	Selector *nodecorev1alpha1.FlavourSelector `json:"selector,omitempty"`
+
+	// Duration is the ISO-8601 duration of the Contract requested to the providers
+	Duration string `json:"duration"`
+
+	// Currency is the ISO-4217 code of the currency the bids must be expressed in
+	//+kubebuilder:default=EUR
+	//+kubebuilder:validation:Pattern=`^[A-Z]{3}$`
+	Currency string `json:"currency,omitempty"`
+
+	// BiddingWindow is the time the providers are given to bid (e.g. 30s). If empty, --rfq-bidding-window applies.
+	BiddingWindow string `json:"biddingWindow,omitempty"`
+
+	// Purchase tells whether the Reservation of the awarded bid purchases the Flavour too
+	Purchase bool `json:"purchase,omitempty"`
+}
+
+// Bid is a sealed bid received from a provider
+type Bid struct {
+	// Provider is the address of the provider the request was sent to
+	Provider string `json:"provider"`
+
+	// Seller is the Node identity of the provider FLUIDOS Node
+	Seller nodecorev1alpha1.NodeIdentity `json:"seller,omitempty"`
+
+	// FlavourID is the ID of the Flavour offered
+	FlavourID string `json:"flavourID,omitempty"`
+
+	// Partition is the partition of the Flavour offered
+	Partition *Partition `json:"partition,omitempty"`
+
+	// Price is the price offered for the requested duration
+	Price *Offer `json:"price,omitempty"`
+
+	// TotalCost is the decimal cost of the bid over the whole duration, used to compare the bids
+	TotalCost string `json:"totalCost,omitempty"`
+
+	// ValidUntil is the time after which the provider no longer honours the bid
+	ValidUntil string `json:"validUntil,omitempty"`
+
+	// ReceivedTime is the time the bid was received
+	ReceivedTime string `json:"receivedTime,omitempty"`
+
+	// Signature is the signature of the bid by the provider
+	Signature *Signature `json:"signature,omitempty"`
+
+	// State is the outcome of the bid
+	State BidState `json:"state"`
+
+	// Message describes the outcome of the bid
+	Message string `json:"message,omitempty"`
+}
+
+// RequestForQuoteStatus defines the observed state of RequestForQuote
+type RequestForQuoteStatus struct {
+	// This is the current phase of the request
+	Phase nodecorev1alpha1.PhaseStatus `json:"phase"`
+
+	// Step is the step the request is performing
+	Step RFQStep `json:"step,omitempty"`
+
+	// Deadline is the time after which no bid is accepted
+	Deadline string `json:"deadline,omitempty"`
+
+	// Bids is the history of the bids received, kept for audit
+	Bids []Bid `json:"bids,omitempty"`
+
+	// PeeringCandidate is the reference to the PeeringCandidate of the awarded bid
+	PeeringCandidate nodecorev1alpha1.GenericRef `json:"peeringCandidate,omitempty"`
+
+	// Reservation is the reference to the Reservation of the awarded bid
+	Reservation nodecorev1alpha1.GenericRef `json:"reservation,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// +kubebuilder:printcolumn:name="Solver ID",type=string,JSONPath=`.spec.solverID`
+// +kubebuilder:printcolumn:name="Duration",type=string,JSONPath=`.spec.duration`
+// +kubebuilder:printcolumn:name="Step",type=string,JSONPath=`.status.step`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase.phase`
+// +kubebuilder:printcolumn:name="Reservation",type=string,JSONPath=`.status.reservation.name`
+// +kubebuilder:printcolumn:name="Message",type=string,priority=1,JSONPath=`.status.phase.message`
+// RequestForQuote is the Schema for the requestforquotes API
+type RequestForQuote struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RequestForQuoteSpec   `json:"spec,omitempty"`
+	Status RequestForQuoteStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// RequestForQuoteList contains a list of RequestForQuote
+type RequestForQuoteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RequestForQuote `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RequestForQuote{}, &RequestForQuoteList{})
+}
@@ -56,6 +56,13 @@ type ReservationSpec struct {
 
 	// PeeringCandidate is the reference to the PeeringCandidate of the Reservation
 	PeeringCandidate nodecorev1alpha1.GenericRef `json:"peeringCandidate,omitempty"`
+
+	// Offer is the price, and the terms, offered to the seller to open a negotiation. If empty, the advertised price is accepted.
+	Offer *Offer `json:"offer,omitempty"`
+
+	// MaxPrice is the decimal amount, in the currency and per the period of the Offer, up to which the buyer accepts
+	// the counter-offers of the seller. If empty, only counter-offers not above the Offer are accepted.
+	MaxPrice string `json:"maxPrice,omitempty"`
 }
 
 // ReservationStatus defines the observed state of Reservation
@@ -74,6 +81,12 @@ type ReservationStatus struct {
 
 	// Contract is the reference to the Contract of the Reservation
 	Contract nodecorev1alpha1.GenericRef `json:"contract,omitempty"`
+
+	// Offer is the last offer sent to the seller during the negotiation
+	Offer *Offer `json:"offer,omitempty"`
+
+	// NegotiationRound is the number of negotiation rounds played with the seller
+	NegotiationRound int `json:"negotiationRound,omitempty"`
 }
 
 //+kubebuilder:object:root=true
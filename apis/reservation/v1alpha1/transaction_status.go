@@ -0,0 +1,40 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/tools"
+)
+
+// SetPhase sets the phase of the transaction
+func (t *Transaction) SetPhase(phase nodecorev1alpha1.Phase, msg string) {
+	if t.Status.Phase.StartTime == "" {
+		t.Status.Phase.StartTime = tools.GetTimeNow()
+	}
+	t.Status.Phase.Phase = phase
+	t.Status.Phase.LastChangeTime = tools.GetTimeNow()
+	t.Status.Phase.Message = msg
+}
+
+// IsFinished returns true if the transaction has been purchased, has expired or has been cancelled
+func (t *Transaction) IsFinished() bool {
+	switch t.Status.Phase.Phase {
+	case nodecorev1alpha1.PhasePurchased, nodecorev1alpha1.PhaseExpired, nodecorev1alpha1.PhaseCancelled:
+		return true
+	default:
+		return false
+	}
+}
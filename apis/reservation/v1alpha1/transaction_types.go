@@ -20,6 +20,46 @@ import (
 	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
 )
 
+// Offer is a price, and the terms it refers to, proposed during the negotiation of a Flavour
+type Offer struct {
+	// Amount is the decimal amount offered.
+	Amount string `json:"amount"`
+
+	// Currency is the ISO-4217 code of the currency of the amount.
+	Currency string `json:"currency"`
+
+	// Period is the ISO-8601 duration the amount refers to. If empty, the period of the price of the Flavour.
+	Period string `json:"period,omitempty"`
+
+	// Duration is the ISO-8601 duration of the Contract the offer refers to. If empty, the Contract lasts the default duration.
+	Duration string `json:"duration,omitempty"`
+}
+
+// NegotiationState is the outcome of a round of the price negotiation
+type NegotiationState string
+
+const (
+	// NegotiationAccepted means the seller accepted the offer of the buyer
+	NegotiationAccepted NegotiationState = "Accepted"
+	// NegotiationCountered means the seller made a counter-offer, that the buyer accepts by purchasing the transaction
+	NegotiationCountered NegotiationState = "Countered"
+)
+
+// Negotiation is the state of the price negotiation of a Transaction
+type Negotiation struct {
+	// State is the outcome of the last round.
+	State NegotiationState `json:"state"`
+
+	// Round is the number of rounds played so far.
+	Round int `json:"round"`
+
+	// Deadline is the time after which the negotiation can no longer continue.
+	Deadline string `json:"deadline"`
+
+	// RoundsLeft is the number of further rounds the seller accepts after a counter-offer.
+	RoundsLeft int `json:"roundsLeft,omitempty"`
+}
+
 // TransactionSpec defines the desired state of Transaction
 type TransactionSpec struct {
 	// FlavourID is the ID of the flavour that is being reserved
@@ -36,16 +76,29 @@ type TransactionSpec struct {
 
 	// StartTime is the time at which the reservation should start
 	StartTime string `json:"startTime,omitempty"`
+
+	// ExpirationTime is the time, set by the seller, after which the reservation can no longer be purchased
+	ExpirationTime string `json:"expirationTime,omitempty"`
+
+	// Offer is the price agreed with the seller or, while the negotiation is countered, the counter-offer of the seller
+	Offer *Offer `json:"offer,omitempty"`
+
+	// Negotiation is the state of the price negotiation, if the buyer made an offer
+	Negotiation *Negotiation `json:"negotiation,omitempty"`
 }
 
 // TransactionStatus defines the observed state of Transaction
 type TransactionStatus struct {
-	// This is the current phase of the reservation
+	// This is the current phase of the reservation: Reserved, then Purchased, Expired or Cancelled
 	Phase nodecorev1alpha1.PhaseStatus `json:"phase"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Flavour ID",type=string,JSONPath=`.spec.flavourID`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase.phase`
+//+kubebuilder:printcolumn:name="Expiration Time",type=string,JSONPath=`.spec.expirationTime`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Transaction is the Schema for the transactions API
 type Transaction struct {
@@ -0,0 +1,89 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+)
+
+// UsageRecordSpec defines the usage of a Contract over a metering window.
+// UsageRecords are append-only: a new record is created for every metering window and never updated.
+type UsageRecordSpec struct {
+	// ContractID is the name of the Contract being metered.
+	ContractID string `json:"contractID"`
+
+	// FlavourID is the ID of the Flavour sold by the Contract.
+	FlavourID string `json:"flavourID"`
+
+	// Buyer is the identity of the buyer of the Contract.
+	Buyer nodecorev1alpha1.NodeIdentity `json:"buyer"`
+
+	// Seller is the identity of the seller of the Contract.
+	Seller nodecorev1alpha1.NodeIdentity `json:"seller"`
+
+	// StartTime is the beginning of the metering window.
+	StartTime string `json:"startTime"`
+
+	// EndTime is the end of the metering window.
+	EndTime string `json:"endTime"`
+
+	// ActiveSeconds is the time, within the window, during which the Contract was active.
+	ActiveSeconds int64 `json:"activeSeconds"`
+
+	// Share is the share of the Flavour bought by the Contract, as a decimal between 0 and 1.
+	Share string `json:"share"`
+
+	// Cost is the cost accrued by the Contract within the window.
+	Cost Cost `json:"cost"`
+}
+
+// Cost is an amount of money in a given currency.
+type Cost struct {
+	// Amount is the decimal amount of the cost.
+	Amount string `json:"amount"`
+
+	// Currency is the currency of the cost.
+	Currency string `json:"currency"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Contract",type=string,JSONPath=`.spec.contractID`
+//+kubebuilder:printcolumn:name="Start Time",type=string,JSONPath=`.spec.startTime`
+//+kubebuilder:printcolumn:name="End Time",type=string,JSONPath=`.spec.endTime`
+//+kubebuilder:printcolumn:name="Amount",type=string,JSONPath=`.spec.cost.amount`
+//+kubebuilder:printcolumn:name="Currency",type=string,JSONPath=`.spec.cost.currency`
+
+// UsageRecord is the Schema for the usagerecords API
+type UsageRecord struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec UsageRecordSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// UsageRecordList contains a list of UsageRecord
+type UsageRecordList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []UsageRecord `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&UsageRecord{}, &UsageRecordList{})
+}
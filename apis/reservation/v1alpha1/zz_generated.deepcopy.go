@@ -19,16 +19,49 @@
 package v1alpha1
 
 import (
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Bid) DeepCopyInto(out *Bid) {
+	*out = *in
+	out.Seller = in.Seller
+	if in.Partition != nil {
+		in, out := &in.Partition, &out.Partition
+		*out = new(Partition)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Price != nil {
+		in, out := &in.Price, &out.Price
+		*out = new(Offer)
+		**out = **in
+	}
+	if in.Signature != nil {
+		in, out := &in.Signature, &out.Signature
+		*out = new(Signature)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Bid.
+func (in *Bid) DeepCopy() *Bid {
+	if in == nil {
+		return nil
+	}
+	out := new(Bid)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Contract) DeepCopyInto(out *Contract) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Contract.
@@ -92,7 +125,17 @@ func (in *ContractSpec) DeepCopyInto(out *ContractSpec) {
 	}
 	out.Buyer = in.Buyer
 	out.Seller = in.Seller
-	out.SellerCredentials = in.SellerCredentials
+	in.SellerCredentials.DeepCopyInto(&out.SellerCredentials)
+	if in.AgreedPrice != nil {
+		in, out := &in.AgreedPrice, &out.AgreedPrice
+		*out = new(Offer)
+		**out = **in
+	}
+	if in.SLA != nil {
+		in, out := &in.SLA, &out.SLA
+		*out = new(nodecorev1alpha1.SLA)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ExtraInformation != nil {
 		in, out := &in.ExtraInformation, &out.ExtraInformation
 		*out = make(map[string]string, len(*in))
@@ -100,6 +143,16 @@ func (in *ContractSpec) DeepCopyInto(out *ContractSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.SellerSignature != nil {
+		in, out := &in.SellerSignature, &out.SellerSignature
+		*out = new(Signature)
+		**out = **in
+	}
+	if in.BuyerSignature != nil {
+		in, out := &in.BuyerSignature, &out.BuyerSignature
+		*out = new(Signature)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractSpec.
@@ -116,6 +169,18 @@ func (in *ContractSpec) DeepCopy() *ContractSpec {
 func (in *ContractStatus) DeepCopyInto(out *ContractStatus) {
 	*out = *in
 	out.Phase = in.Phase
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SLA != nil {
+		in, out := &in.SLA, &out.SLA
+		*out = new(SLAStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContractStatus.
@@ -128,9 +193,29 @@ func (in *ContractStatus) DeepCopy() *ContractStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Cost) DeepCopyInto(out *Cost) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Cost.
+func (in *Cost) DeepCopy() *Cost {
+	if in == nil {
+		return nil
+	}
+	out := new(Cost)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LiqoCredentials) DeepCopyInto(out *LiqoCredentials) {
 	*out = *in
+	if in.TokenSecretRef != nil {
+		in, out := &in.TokenSecretRef, &out.TokenSecretRef
+		*out = new(nodecorev1alpha1.GenericRef)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LiqoCredentials.
@@ -143,6 +228,36 @@ func (in *LiqoCredentials) DeepCopy() *LiqoCredentials {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Negotiation) DeepCopyInto(out *Negotiation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Negotiation.
+func (in *Negotiation) DeepCopy() *Negotiation {
+	if in == nil {
+		return nil
+	}
+	out := new(Negotiation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Offer) DeepCopyInto(out *Offer) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Offer.
+func (in *Offer) DeepCopy() *Offer {
+	if in == nil {
+		return nil
+	}
+	out := new(Offer)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Partition) DeepCopyInto(out *Partition) {
 	*out = *in
@@ -163,13 +278,259 @@ func (in *Partition) DeepCopy() *Partition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PurchaseSaga) DeepCopyInto(out *PurchaseSaga) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PurchaseSaga.
+func (in *PurchaseSaga) DeepCopy() *PurchaseSaga {
+	if in == nil {
+		return nil
+	}
+	out := new(PurchaseSaga)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PurchaseSaga) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PurchaseSagaItem) DeepCopyInto(out *PurchaseSagaItem) {
+	*out = *in
+	out.Seller = in.Seller
+	out.PeeringCandidate = in.PeeringCandidate
+	if in.Partition != nil {
+		in, out := &in.Partition, &out.Partition
+		*out = new(Partition)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PurchaseSagaItem.
+func (in *PurchaseSagaItem) DeepCopy() *PurchaseSagaItem {
+	if in == nil {
+		return nil
+	}
+	out := new(PurchaseSagaItem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PurchaseSagaItemStatus) DeepCopyInto(out *PurchaseSagaItemStatus) {
+	*out = *in
+	out.Reservation = in.Reservation
+	out.Contract = in.Contract
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PurchaseSagaItemStatus.
+func (in *PurchaseSagaItemStatus) DeepCopy() *PurchaseSagaItemStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PurchaseSagaItemStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PurchaseSagaList) DeepCopyInto(out *PurchaseSagaList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PurchaseSaga, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PurchaseSagaList.
+func (in *PurchaseSagaList) DeepCopy() *PurchaseSagaList {
+	if in == nil {
+		return nil
+	}
+	out := new(PurchaseSagaList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PurchaseSagaList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PurchaseSagaSpec) DeepCopyInto(out *PurchaseSagaSpec) {
+	*out = *in
+	out.Buyer = in.Buyer
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PurchaseSagaItem, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PurchaseSagaSpec.
+func (in *PurchaseSagaSpec) DeepCopy() *PurchaseSagaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PurchaseSagaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PurchaseSagaStatus) DeepCopyInto(out *PurchaseSagaStatus) {
+	*out = *in
+	out.Phase = in.Phase
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PurchaseSagaItemStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PurchaseSagaStatus.
+func (in *PurchaseSagaStatus) DeepCopy() *PurchaseSagaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PurchaseSagaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequestForQuote) DeepCopyInto(out *RequestForQuote) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestForQuote.
+func (in *RequestForQuote) DeepCopy() *RequestForQuote {
+	if in == nil {
+		return nil
+	}
+	out := new(RequestForQuote)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RequestForQuote) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequestForQuoteList) DeepCopyInto(out *RequestForQuoteList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RequestForQuote, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestForQuoteList.
+func (in *RequestForQuoteList) DeepCopy() *RequestForQuoteList {
+	if in == nil {
+		return nil
+	}
+	out := new(RequestForQuoteList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RequestForQuoteList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequestForQuoteSpec) DeepCopyInto(out *RequestForQuoteSpec) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(nodecorev1alpha1.FlavourSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestForQuoteSpec.
+func (in *RequestForQuoteSpec) DeepCopy() *RequestForQuoteSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RequestForQuoteSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequestForQuoteStatus) DeepCopyInto(out *RequestForQuoteStatus) {
+	*out = *in
+	out.Phase = in.Phase
+	if in.Bids != nil {
+		in, out := &in.Bids, &out.Bids
+		*out = make([]Bid, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.PeeringCandidate = in.PeeringCandidate
+	out.Reservation = in.Reservation
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestForQuoteStatus.
+func (in *RequestForQuoteStatus) DeepCopy() *RequestForQuoteStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RequestForQuoteStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Reservation) DeepCopyInto(out *Reservation) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Reservation.
@@ -233,6 +594,11 @@ func (in *ReservationSpec) DeepCopyInto(out *ReservationSpec) {
 		(*in).DeepCopyInto(*out)
 	}
 	out.PeeringCandidate = in.PeeringCandidate
+	if in.Offer != nil {
+		in, out := &in.Offer, &out.Offer
+		*out = new(Offer)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReservationSpec.
@@ -250,6 +616,11 @@ func (in *ReservationStatus) DeepCopyInto(out *ReservationStatus) {
 	*out = *in
 	out.Phase = in.Phase
 	out.Contract = in.Contract
+	if in.Offer != nil {
+		in, out := &in.Offer, &out.Offer
+		*out = new(Offer)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReservationStatus.
@@ -262,6 +633,77 @@ func (in *ReservationStatus) DeepCopy() *ReservationStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SLACreditNote) DeepCopyInto(out *SLACreditNote) {
+	*out = *in
+	out.Amount = in.Amount
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SLACreditNote.
+func (in *SLACreditNote) DeepCopy() *SLACreditNote {
+	if in == nil {
+		return nil
+	}
+	out := new(SLACreditNote)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SLAStatus) DeepCopyInto(out *SLAStatus) {
+	*out = *in
+	if in.Violations != nil {
+		in, out := &in.Violations, &out.Violations
+		*out = make([]SLAViolation, len(*in))
+		copy(*out, *in)
+	}
+	if in.Credits != nil {
+		in, out := &in.Credits, &out.Credits
+		*out = make([]SLACreditNote, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SLAStatus.
+func (in *SLAStatus) DeepCopy() *SLAStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SLAStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SLAViolation) DeepCopyInto(out *SLAViolation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SLAViolation.
+func (in *SLAViolation) DeepCopy() *SLAViolation {
+	if in == nil {
+		return nil
+	}
+	out := new(SLAViolation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Signature) DeepCopyInto(out *Signature) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Signature.
+func (in *Signature) DeepCopy() *Signature {
+	if in == nil {
+		return nil
+	}
+	out := new(Signature)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Transaction) DeepCopyInto(out *Transaction) {
 	*out = *in
@@ -330,6 +772,16 @@ func (in *TransactionSpec) DeepCopyInto(out *TransactionSpec) {
 		*out = new(Partition)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Offer != nil {
+		in, out := &in.Offer, &out.Offer
+		*out = new(Offer)
+		**out = **in
+	}
+	if in.Negotiation != nil {
+		in, out := &in.Negotiation, &out.Negotiation
+		*out = new(Negotiation)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransactionSpec.
@@ -357,3 +809,79 @@ func (in *TransactionStatus) DeepCopy() *TransactionStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UsageRecord) DeepCopyInto(out *UsageRecord) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UsageRecord.
+func (in *UsageRecord) DeepCopy() *UsageRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(UsageRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *UsageRecord) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UsageRecordList) DeepCopyInto(out *UsageRecordList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]UsageRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UsageRecordList.
+func (in *UsageRecordList) DeepCopy() *UsageRecordList {
+	if in == nil {
+		return nil
+	}
+	out := new(UsageRecordList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *UsageRecordList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UsageRecordSpec) DeepCopyInto(out *UsageRecordSpec) {
+	*out = *in
+	out.Buyer = in.Buyer
+	out.Seller = in.Seller
+	out.Cost = in.Cost
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UsageRecordSpec.
+func (in *UsageRecordSpec) DeepCopy() *UsageRecordSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(UsageRecordSpec)
+	in.DeepCopyInto(out)
+	return out
+}
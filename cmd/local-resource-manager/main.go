@@ -15,10 +15,9 @@
 package main
 
 import (
-	"context"
 	"flag"
-	"net/http"
 	"os"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -26,8 +25,11 @@ import (
 	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
 	localResourceManager "github.com/fluidos-project/node/pkg/local-resource-manager"
 	"github.com/fluidos-project/node/pkg/utils/flags"
 )
@@ -41,15 +43,28 @@ func init() {
 	utilruntime.Must(metricsv1beta1.AddToScheme(scheme))
 	utilruntime.Must(corev1.AddToScheme(scheme))
 	utilruntime.Must(nodecorev1alpha1.AddToScheme(scheme))
+	utilruntime.Must(reservationv1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
 func main() {
+	var metricsAddr string
+	var enableLeaderElection bool
 	var probeAddr string
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
-	flag.StringVar(&flags.AMOUNT, "amount", "", "Amount of money set for the flavours of this node")
-	flag.StringVar(&flags.CURRENCY, "currency", "", "Currency of the money set for the flavours of this node")
-	flag.StringVar(&flags.PERIOD, "period", "", "Period set for the flavours of this node")
+	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&flags.AMOUNT, "amount", "0", "Decimal amount of money set for the flavours of this node")
+	flag.StringVar(&flags.CURRENCY, "currency", "EUR", "ISO-4217 currency of the money set for the flavours of this node")
+	flag.StringVar(&flags.PERIOD, "period", "PT1H", "ISO-8601 period set for the flavours of this node (e.g. PT1H, P1M)")
+	flag.StringVar(&flags.CPU_RATE, "cpu-rate", "", "Decimal price of a CPU core of the flavours of this node")
+	flag.StringVar(&flags.MEMORY_RATE, "memory-rate", "", "Decimal price of a GiB of memory of the flavours of this node")
+	flag.StringVar(&flags.GPU_RATE, "gpu-rate", "", "Decimal price of a GPU of the flavours of this node")
+	flag.StringVar(&flags.STORAGE_RATE, "storage-rate", "", "Decimal price of a GiB of persistent storage of the flavours of this node")
+	flag.StringVar(&flags.SLA_AVAILABILITY, "sla-availability", "", "Monthly availability percentage offered with the flavours of this node (empty for no SLA)")
+	flag.StringVar(&flags.SLA_MAX_TIME_TO_RESTORE, "sla-max-time-to-restore", "", "Maximum duration of an outage offered with the flavours of this node (e.g. PT1H)")
+	flag.StringVar(&flags.SLA_RESTORE_CREDIT, "sla-restore-credit", "", "Percentage of the monthly cost credited for each outage longer than the maximum time to restore")
+	flag.StringVar(&flags.SLA_CREDITS, "sla-credits", "", "Comma-separated availability=credit percentages credited when the monthly availability falls below the availability (e.g. 99.9=10,99=25)")
 	flag.StringVar(&flags.RESOURCE_TYPE, "resources-types", "k8s-fluidos", "Type of the Flavour related to k8s resources")
 	flag.StringVar(&flags.CPU_MIN, "cpu-min", "0", "Minimum CPU value")
 	flag.StringVar(&flags.MEMORY_MIN, "memory-min", "0", "Minimum memory value")
@@ -58,39 +73,65 @@ func main() {
 	flag.Int64Var(&flags.MIN_COUNT, "min-count", 0, "Minimum number of flavours")
 	flag.Int64Var(&flags.MAX_COUNT, "max-count", 0, "Maximum number of flavours")
 	flag.StringVar(&flags.RESOURCE_NODE_LABEL, "node-resource-label", "node-role.fluidos.eu/resources", "Label used to filter the k8s nodes from which create flavours")
+	flag.DurationVar(&flags.NODE_METRICS_REFRESH_INTERVAL, "node-metrics-refresh-interval", time.Minute, "Interval at which the metrics of the nodes are polled to update their Flavours")
+	flag.StringVar(&flags.POOL_NODE_LABEL, "pool-node-label", "", "Label grouping the nodes into pools, each offered by a pooled Flavour in addition to the per-node Flavours (empty to disable pooling)")
+	flag.Float64Var(&flags.FLAVOUR_UPDATE_THRESHOLD, "flavour-update-threshold", 10, "Change (percentage) of the available CPU, memory or ephemeral storage of a node that updates its Flavour")
 
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	cfg := ctrl.GetConfigOrDie()
-	cl, err := client.New(cfg, client.Options{Scheme: scheme})
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme: scheme,
+		// The metrics API cannot be watched, so the NodeMetrics are always read from the API server.
+		// The pods are read from the API server too, filtered by node, rather than caching all the pods of the cluster.
+		Client: client.Options{
+			Cache: &client.CacheOptions{DisableFor: []client.Object{&metricsv1beta1.NodeMetrics{}, &corev1.Pod{}}},
+		},
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: probeAddr,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       "d1e3b0a7.fluidos.eu",
+	})
 	if err != nil {
-		setupLog.Error(err, "Unable to create client")
+		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	err = localResourceManager.Start(context.Background(), cl)
-	if err != nil {
-		setupLog.Error(err, "Unable to start LocalResourceManager")
+	if err = (&localResourceManager.NodeReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Node")
 		os.Exit(1)
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/healthz", healthHandler) // health check endpoint
-	mux.HandleFunc("/readyz", healthHandler)  // readiness check endpoint
-
-	server := &http.Server{
-		Addr:    probeAddr,
-		Handler: mux,
+	if flags.POOL_NODE_LABEL != "" {
+		if err = (&localResourceManager.PoolReconciler{
+			Client: mgr.GetClient(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Pool")
+			os.Exit(1)
+		}
 	}
+	//+kubebuilder:scaffold:builder
 
-	setupLog.Info("Starting server", "address", probeAddr)
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
-		setupLog.Error(err, "Server stopped unexpectedly")
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
-}
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+	setupLog.Info("starting manager")
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		setupLog.Error(err, "problem running manager")
+		os.Exit(1)
+	}
 }
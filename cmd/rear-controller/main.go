@@ -20,7 +20,10 @@ import (
 	"context"
 	"flag"
 	"os"
+	"time"
 
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	virtualkubeletv1alpha1 "github.com/liqotech/liqo/apis/virtualkubelet/v1alpha1"
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	"k8s.io/apimachinery/pkg/runtime"
@@ -41,6 +44,8 @@ import (
 	discoverymanager "github.com/fluidos-project/node/pkg/rear-controller/discovery-manager"
 	gateway "github.com/fluidos-project/node/pkg/rear-controller/gateway"
 	"github.com/fluidos-project/node/pkg/rear-controller/grpc"
+	"github.com/fluidos-project/node/pkg/rear-controller/metering"
+	"github.com/fluidos-project/node/pkg/rear-controller/sla"
 	"github.com/fluidos-project/node/pkg/utils/flags"
 )
 
@@ -54,6 +59,8 @@ func init() {
 	utilruntime.Must(advertisementv1alpha1.AddToScheme(scheme))
 	utilruntime.Must(reservationv1alpha1.AddToScheme(scheme))
 	utilruntime.Must(nodecorev1alpha1.AddToScheme(scheme))
+	utilruntime.Must(discoveryv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(virtualkubeletv1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -65,6 +72,29 @@ func main() {
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.StringVar(&flags.GRPC_PORT, "grpc-port", "2710", "Port of the HTTP server")
 	flag.StringVar(&flags.HTTP_PORT, "http-port", "3004", "Port of the HTTP server")
+	flag.StringVar(&flags.AUTH_MODE, "auth-mode", "none", "Comma-separated authentication methods of the REAR Gateway (none, token, mtls)")
+	flag.StringVar(&flags.TLS_CERT_FILE, "tls-cert-file", "", "Certificate used by the REAR Gateway to serve HTTPS and to authenticate as client")
+	flag.StringVar(&flags.TLS_KEY_FILE, "tls-key-file", "", "Private key of the REAR Gateway certificate")
+	flag.StringVar(&flags.TLS_CA_FILE, "tls-ca-file", "", "CA bundle used to verify the certificates of the other FLUIDOS Nodes")
+	flag.Float64Var(&flags.RATE_LIMIT, "rate-limit", 0, "Requests per second allowed to each buyer NodeID and source IP on each REAR Gateway endpoint (0 to disable)")
+	flag.IntVar(&flags.RATE_BURST, "rate-burst", 10, "Burst of requests allowed by the REAR Gateway rate limiter")
+	flag.IntVar(&flags.MAX_OPEN_TRANSACTIONS, "max-open-transactions", 0, "Maximum number of open transactions per buyer (0 to disable)")
+	flag.IntVar(&flags.MAX_ACTIVE_CONTRACTS, "max-active-contracts", 0, "Maximum number of active contracts per buyer (0 to disable)")
+	flag.StringVar(&flags.MAX_CPU_PER_DOMAIN, "max-cpu-per-domain", "", "Maximum amount of CPU sold to a single buyer domain")
+	flag.StringVar(&flags.MAX_MEMORY_PER_DOMAIN, "max-memory-per-domain", "", "Maximum amount of memory sold to a single buyer domain")
+	flag.StringVar(&flags.LIQO_RESOURCE_NAMES, "liqo-resource-names", "", "Comma-separated characteristic=name pairs overriding the resource names offered to Liqo (e.g. gpu=amd.com/gpu,persistent-storage=)")
+	flag.StringVar(&flags.TRANSACTION_STORE, "transaction-store", "crd", "Backend of the REAR Gateway transaction store (crd, memory)")
+	flag.DurationVar(&flags.CONTRACT_EXPIRATION_WARNING, "contract-expiration-warning", 24*time.Hour, "Time before the expiration of a Contract at which it is reported as expiring (and renewed, if enabled)")
+	flag.BoolVar(&flags.AUTO_RENEW_CONTRACTS, "auto-renew-contracts", false, "Renew the bought Contracts through the seller's REAR Gateway before they expire")
+	flag.StringVar(&flags.CREDENTIALS_READER_ROLE, "credentials-reader-role", "", "Role scoped to the Secrets storing the Liqo credentials of the Contracts (empty to disable)")
+	flag.DurationVar(&flags.METERING_INTERVAL, "metering-interval", time.Hour, "Interval at which the cost accrued by the Contracts is recorded in UsageRecords")
+	flag.DurationVar(&flags.USAGE_RECORD_RETENTION, "usage-record-retention", 90*24*time.Hour, "Time the UsageRecords are kept after the end of their billing period (0 to keep them forever)")
+	flag.DurationVar(&flags.SLA_PROBE_INTERVAL, "sla-probe-interval", time.Minute, "Interval at which the sellers of the Contracts with SLA terms are probed")
+	flag.StringVar(&flags.MAX_DISCOUNT, "max-discount", "0", "Maximum discount (percentage) on the list price the REAR Gateway accepts when negotiating")
+	flag.IntVar(&flags.MAX_NEGOTIATION_ROUNDS, "max-negotiation-rounds", 3, "Maximum number of rounds of a price negotiation")
+	flag.DurationVar(&flags.NEGOTIATION_TIMEOUT, "negotiation-timeout", time.Minute, "Maximum duration of a price negotiation")
+	flag.DurationVar(&flags.BID_VALIDITY, "bid-validity", 5*time.Minute, "Time the bids sent in response to a request for quote are valid")
+	flag.DurationVar(&flags.RFQ_BIDDING_WINDOW, "rfq-bidding-window", 30*time.Second, "Default time the providers are given to bid on a request for quote")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
 	opts := zap.Options{
 		Development: true,
@@ -131,6 +161,49 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Reservation")
 		os.Exit(1)
 	}
+
+	if err = (&contractmanager.ContractReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Gateway:  gw,
+		Recorder: mgr.GetEventRecorderFor("contract-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Contract")
+		os.Exit(1)
+	}
+
+	if flags.CREDENTIALS_READER_ROLE != "" {
+		if err = (&contractmanager.CredentialsReaderReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "CredentialsReader")
+			os.Exit(1)
+		}
+	}
+
+	// Notify Liqo of the changes of the resources offered to the buyer clusters
+	if err = grpcServer.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ContractWatcher")
+		os.Exit(1)
+	}
+
+	if err = (&contractmanager.PurchaseSagaReconciler{
+		Client:  mgr.GetClient(),
+		Scheme:  mgr.GetScheme(),
+		Gateway: gw,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PurchaseSaga")
+		os.Exit(1)
+	}
+	if err = (&contractmanager.RequestForQuoteReconciler{
+		Client:  mgr.GetClient(),
+		Scheme:  mgr.GetScheme(),
+		Gateway: gw,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RequestForQuote")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -154,6 +227,25 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Periodically reload the Gateway API tokens and authorization policy
+	if err := mgr.Add(manager.RunnableFunc(gw.AuthRefresher(flags.REFRESH_AUTH_INTERVAL))); err != nil {
+		klog.Errorf("Unable to set up Gateway authorization refresher: %s", err)
+		os.Exit(1)
+	}
+
+	// Periodically record the cost accrued by the Contracts
+	if err := mgr.Add(manager.RunnableFunc(metering.NewMeter(mgr.GetClient()).Metering(flags.METERING_INTERVAL))); err != nil {
+		klog.Errorf("Unable to set up Contract metering: %s", err)
+		os.Exit(1)
+	}
+
+	// Periodically probe the sellers of the Contracts with SLA terms
+	slaMonitor := sla.NewMonitor(mgr.GetClient(), gw, mgr.GetEventRecorderFor("sla-monitor"))
+	if err := mgr.Add(manager.RunnableFunc(slaMonitor.Monitoring(flags.SLA_PROBE_INTERVAL))); err != nil {
+		klog.Errorf("Unable to set up SLA monitoring: %s", err)
+		os.Exit(1)
+	}
+
 	// Start the REAR Gateway HTTP server
 	if err := mgr.Add(manager.RunnableFunc(gw.Start)); err != nil {
 		klog.Errorf("Unable to set up Gateway HTTP server: %s", err)
@@ -17,11 +17,23 @@ package localResourceManager
 import (
 	"context"
 	"fmt"
-	"log"
+	"math"
+	"sync"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/klog/v2"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
 	"github.com/fluidos-project/node/pkg/utils/getters"
 	"github.com/fluidos-project/node/pkg/utils/resourceforge"
 )
@@ -29,7 +41,9 @@ import (
 // clusterRole
 //+kubebuilder:rbac:groups=nodecore.fluidos.eu,resources=flavours,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=nodes/finalizers,verbs=update
 //+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
 //+kubebuilder:rbac:groups=metrics.k8s.io,resources=pods,verbs=get;list;watch
 //+kubebuilder:rbac:groups=metrics.k8s.io,resources=nodes,verbs=get;list;watch
 
@@ -37,36 +51,198 @@ import (
 // ensure to check and subtract the already allocated resources from the node
 // resources calculation.
 
-// Start starts the controller
-func Start(ctx context.Context, cl client.Client) error {
+// NodeReconciler keeps a Flavour for each node labelled with the resource node label.
+// The Flavour of a node is created when its metrics are available, updated when its capacity changes,
+// made unavailable while the node is cordoned or not ready and deleted when the node is removed or unlabelled.
+type NodeReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
 
-	klog.Info("Getting FLUIDOS Node identity...")
+	// legacyRetired are the UIDs of the nodes whose Flavours created before the node label have been retired
+	legacyRetired sync.Map
+}
+
+// Reconcile reconciles the Flavour of a node
+func (r *NodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx, "node", req.Name)
+	ctx = ctrl.LoggerInto(ctx, log)
 
-	nodeIdentity := getters.GetNodeIdentity(ctx, cl)
+	var node corev1.Node
+	if err := r.Get(ctx, req.NamespacedName, &node); client.IgnoreNotFound(err) != nil {
+		klog.Errorf("Error when getting Node %s before reconcile: %s", req.Name, err)
+		return ctrl.Result{}, err
+	} else if err == nil {
+		if err := r.retireLegacyFlavours(ctx, &node); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if node.Name == "" || node.Labels[flags.RESOURCE_NODE_LABEL] != "true" {
+		// The node is gone or no longer offers its resources
+		return ctrl.Result{}, r.retireFlavours(ctx, req.Name)
+	}
+
+	// The metrics API cannot be watched: the metrics of the node are polled every NODE_METRICS_REFRESH_INTERVAL
+	var metrics metricsv1beta1.NodeMetrics
+	if err := r.Get(ctx, client.ObjectKey{Name: node.Name}, &metrics); apierrors.IsNotFound(err) {
+		klog.Infof("Metrics of Node %s not available yet", node.Name)
+		return ctrl.Result{RequeueAfter: flags.NODE_METRICS_REFRESH_INTERVAL}, nil
+	} else if err != nil {
+		klog.Errorf("Error when getting the metrics of Node %s: %s", node.Name, err)
+		return ctrl.Result{}, err
+	}
+
+	nodeIdentity := getters.GetNodeIdentity(ctx, r.Client)
 	if nodeIdentity == nil {
-		klog.Info("Error getting FLUIDOS Node identity")
-		return fmt.Errorf("Error getting FLUIDOS Node identity")
+		return ctrl.Result{}, fmt.Errorf("error getting FLUIDOS Node identity")
 	}
 
-	klog.Info("Getting nodes resources...")
-	nodes, err := GetNodesResources(ctx, cl)
+	// The capacity placed on the node by the partitions sold from the pooled Flavours is not offered again
+	shares, err := poolShares(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	pods, err := nodePods(ctx, r.Client, node.Name)
 	if err != nil {
-		log.Printf("Error getting nodes resources: %v", err)
+		return ctrl.Result{}, err
+	}
+	nodeInfo := forgeNodeInfo(&node, forgeResourceMetrics(&metrics, &node, pods))
+	subtractHold(&nodeInfo.ResourceMetrics, shares[node.Name])
+
+	flavour := resourceforge.ForgeFlavourFromMetrics(*nodeInfo, *nodeIdentity)
+	flavour.Spec.OptionalFields.Availability = isSchedulable(&node)
+	// The Flavour of a node shares its capacity with the pooled Flavour of the node's pool
+	if pool := node.Labels[flags.POOL_NODE_LABEL]; flags.POOL_NODE_LABEL != "" && pool != "" {
+		flavour.Labels[consts.FLAVOUR_NODE_POOL_LABEL] = pool
+	}
+	if err := controllerutil.SetControllerReference(&node, flavour, r.Scheme); err != nil {
+		klog.Errorf("Error when setting the owner of Flavour %s: %s", flavour.Name, err)
+		return ctrl.Result{}, err
+	}
+
+	var current nodecorev1alpha1.Flavour
+	err = r.Get(ctx, client.ObjectKeyFromObject(flavour), &current)
+	switch {
+	case apierrors.IsNotFound(err):
+		if err := r.Create(ctx, flavour); err != nil {
+			klog.Errorf("Error when creating Flavour %s: %s", flavour.Name, err)
+			return ctrl.Result{}, err
+		}
+		klog.Infof("Flavour %s created for Node %s", flavour.Name, node.Name)
+	case err != nil:
+		klog.Errorf("Error when getting Flavour %s: %s", flavour.Name, err)
+		return ctrl.Result{}, err
+	case flavourChanged(&current.Spec, &flavour.Spec) || !equality.Semantic.DeepEqual(current.Labels, flavour.Labels):
+		current.Spec = flavour.Spec
+		current.Labels = flavour.Labels
+		current.OwnerReferences = flavour.OwnerReferences
+		if err := r.Update(ctx, &current); err != nil {
+			klog.Errorf("Error when updating Flavour %s: %s", current.Name, err)
+			return ctrl.Result{}, err
+		}
+		klog.Infof("Flavour %s updated for Node %s", current.Name, node.Name)
+	}
+
+	return ctrl.Result{RequeueAfter: flags.NODE_METRICS_REFRESH_INTERVAL}, nil
+}
+
+// retireFlavours deletes the Flavours of a node
+func (r *NodeReconciler) retireFlavours(ctx context.Context, nodeName string) error {
+	var flavours nodecorev1alpha1.FlavourList
+	if err := r.List(ctx, &flavours, client.InNamespace(flags.FLUIDOS_NAMESPACE),
+		client.MatchingLabels{consts.FLAVOUR_NODE_LABEL: nodeName}); err != nil {
+		klog.Errorf("Error when listing the Flavours of Node %s: %s", nodeName, err)
 		return err
 	}
 
-	klog.Infof("Creating Flavours: found %d nodes", len(*nodes))
+	for i := range flavours.Items {
+		if err := r.Delete(ctx, &flavours.Items[i]); client.IgnoreNotFound(err) != nil {
+			klog.Errorf("Error when deleting Flavour %s: %s", flavours.Items[i].Name, err)
+			return err
+		}
+		klog.Infof("Flavour %s of Node %s retired", flavours.Items[i].Name, nodeName)
+	}
+	return nil
+}
+
+// retireLegacyFlavours deletes, on the first reconcile of a node, the Flavours created for it before the node label:
+// they are named after the node UID and would offer the node twice alongside its labelled Flavour.
+func (r *NodeReconciler) retireLegacyFlavours(ctx context.Context, node *corev1.Node) error {
+	if _, ok := r.legacyRetired.Load(node.UID); ok {
+		return nil
+	}
+
+	nodeIdentity := getters.GetNodeIdentity(ctx, r.Client)
+	if nodeIdentity == nil {
+		return fmt.Errorf("error getting FLUIDOS Node identity")
+	}
 
-	// For each node create a Flavour
-	for _, node := range *nodes {
-		flavour := resourceforge.ForgeFlavourFromMetrics(node, *nodeIdentity)
-		err := cl.Create(ctx, flavour)
-		if err != nil {
-			log.Printf("Error creating Flavour: %v", err)
+	var flavours nodecorev1alpha1.FlavourList
+	if err := r.List(ctx, &flavours, client.InNamespace(flags.FLUIDOS_NAMESPACE)); err != nil {
+		klog.Errorf("Error when listing the Flavours of Node %s: %s", node.Name, err)
+		return err
+	}
+
+	for i := range flavours.Items {
+		flavour := &flavours.Items[i]
+		if _, ok := flavour.Labels[consts.FLAVOUR_NODE_LABEL]; ok || flavour.Spec.ProviderID != nodeIdentity.NodeID ||
+			flavour.Spec.OptionalFields.WorkerID != string(node.UID) {
+			continue
+		}
+		if err := r.Delete(ctx, flavour); client.IgnoreNotFound(err) != nil {
+			klog.Errorf("Error when deleting Flavour %s: %s", flavour.Name, err)
 			return err
 		}
-		klog.Infof("Flavour created: %s", flavour.Name)
+		klog.Infof("Legacy Flavour %s of Node %s retired", flavour.Name, node.Name)
 	}
 
+	r.legacyRetired.Store(node.UID, struct{}{})
 	return nil
 }
+
+// isSchedulable reports whether the node is ready and not cordoned
+func isSchedulable(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// flavourChanged reports whether the Flavour must be updated to the desired spec.
+// The CPU, memory and ephemeral storage follow the usage of the node:
+// they are updated only when they change by more than FLAVOUR_UPDATE_THRESHOLD percent.
+func flavourChanged(current, desired *nodecorev1alpha1.FlavourSpec) bool {
+	cc, dc := &current.Characteristics, &desired.Characteristics
+	if exceedsThreshold(cc.Cpu, dc.Cpu) || exceedsThreshold(cc.Memory, dc.Memory) ||
+		exceedsThreshold(cc.EphemeralStorage, dc.EphemeralStorage) {
+		return true
+	}
+
+	spec := desired.DeepCopy()
+	spec.Characteristics.Cpu = cc.Cpu
+	spec.Characteristics.Memory = cc.Memory
+	spec.Characteristics.EphemeralStorage = cc.EphemeralStorage
+	return !equality.Semantic.DeepEqual(current, spec)
+}
+
+// exceedsThreshold reports whether the desired quantity differs from the current one by more than FLAVOUR_UPDATE_THRESHOLD percent
+func exceedsThreshold(current, desired resource.Quantity) bool {
+	c, d := current.AsApproximateFloat64(), desired.AsApproximateFloat64()
+	if c == 0 {
+		return d != 0
+	}
+	return math.Abs(d-c) > math.Abs(c)*flags.FLAVOUR_UPDATE_THRESHOLD/100
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NodeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("node-flavour").
+		For(&corev1.Node{}).
+		Owns(&nodecorev1alpha1.Flavour{}).
+		Complete(r)
+}
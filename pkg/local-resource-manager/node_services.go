@@ -16,8 +16,11 @@ package localResourceManager
 
 import (
 	"context"
+	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/klog/v2"
 	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
@@ -60,7 +63,11 @@ func GetNodesResources(ctx context.Context, cl client.Client) (*[]models.NodeInf
 				// So that we can select just the nodes that we want
 				continue
 			}
-			metricsStruct := forgeResourceMetrics(&metrics, &node)
+			pods, err := nodePods(ctx, cl, node.Name)
+			if err != nil {
+				return nil, err
+			}
+			metricsStruct := forgeResourceMetrics(&metrics, &node, pods)
 			nodeInfo := forgeNodeInfo(&node, metricsStruct)
 			nodesInfo = append(nodesInfo, *nodeInfo)
 		}
@@ -69,14 +76,26 @@ func GetNodesResources(ctx context.Context, cl client.Client) (*[]models.NodeInf
 	return &nodesInfo, nil
 }
 
+// nodePods returns the pods scheduled on a node
+func nodePods(ctx context.Context, cl client.Client, nodeName string) ([]corev1.Pod, error) {
+	var pods corev1.PodList
+	if err := cl.List(ctx, &pods, client.MatchingFields{"spec.nodeName": nodeName}); err != nil {
+		klog.Errorf("Error when listing the pods of node %s: %s", nodeName, err)
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
 // forgeResourceMetrics creates from params a new ResourceMetrics Struct
-func forgeResourceMetrics(nodeMetrics *metricsv1beta1.NodeMetrics, node *corev1.Node) *models.ResourceMetrics {
+func forgeResourceMetrics(nodeMetrics *metricsv1beta1.NodeMetrics, node *corev1.Node, pods []corev1.Pod) *models.ResourceMetrics {
 	// Get the total and used resources
 	cpuTotal := node.Status.Allocatable.Cpu()
 	cpuUsed := nodeMetrics.Usage.Cpu()
 	memoryTotal := node.Status.Allocatable.Memory()
 	memoryUsed := nodeMetrics.Usage.Memory()
 	ephemeralStorage := nodeMetrics.Usage.StorageEphemeral()
+	maxPods := node.Status.Allocatable.Pods()
+	gpu, gpuDetails := forgeGPU(node, pods)
 
 	// Compute the available resources
 	cpuAvail := cpuTotal.DeepCopy()
@@ -85,12 +104,141 @@ func forgeResourceMetrics(nodeMetrics *metricsv1beta1.NodeMetrics, node *corev1.
 	memAvail.Sub(*memoryUsed)
 
 	return &models.ResourceMetrics{
-		CPUTotal:         *cpuTotal,
-		CPUAvailable:     cpuAvail,
-		MemoryTotal:      *memoryTotal,
-		MemoryAvailable:  memAvail,
-		EphemeralStorage: *ephemeralStorage,
+		CPUTotal:          *cpuTotal,
+		CPUAvailable:      cpuAvail,
+		MemoryTotal:       *memoryTotal,
+		MemoryAvailable:   memAvail,
+		EphemeralStorage:  *ephemeralStorage,
+		Pods:              *maxPods,
+		GPU:               gpu,
+		GPUDetails:        gpuDetails,
+		ExtendedResources: forgeExtendedResources(node.Status.Allocatable),
+	}
+}
+
+// forgeExtendedResources returns the extended resources (e.g. example.com/fpga) of the allocatable resources of a node
+func forgeExtendedResources(allocatable corev1.ResourceList) map[string]resource.Quantity {
+	var extended map[string]resource.Quantity
+	for name, quantity := range allocatable {
+		// Extended resources are domain-prefixed, outside of the kubernetes.io domain
+		if !strings.Contains(string(name), "/") || strings.HasPrefix(string(name), corev1.ResourceDefaultNamespacePrefix) {
+			continue
+		}
+		// The GPUs are offered as the GPU characteristic
+		if isGPUResource(name) {
+			continue
+		}
+		if extended == nil {
+			extended = make(map[string]resource.Quantity)
+		}
+		extended[string(name)] = quantity.DeepCopy()
+	}
+	return extended
+}
+
+// gpuVendor describes the extended resource and the well-known node labels of the GPUs of a vendor
+type gpuVendor struct {
+	vendor      string
+	resource    corev1.ResourceName
+	modelLabel  string
+	memoryLabel string
+}
+
+// gpuVendors are the GPU vendors discovered on the nodes, in order of preference.
+// The labels are set by the NVIDIA GPU Feature Discovery and by the AMD GPU node labeller.
+var gpuVendors = []gpuVendor{
+	{
+		vendor:      "nvidia.com",
+		resource:    "nvidia.com/gpu",
+		modelLabel:  "nvidia.com/gpu.product",
+		memoryLabel: "nvidia.com/gpu.memory",
+	},
+	{
+		vendor:      "amd.com",
+		resource:    "amd.com/gpu",
+		modelLabel:  "amd.com/gpu.product-name",
+		memoryLabel: "amd.com/gpu.vram",
+	},
+}
+
+// isGPUResource reports whether the resource is the extended resource of a GPU vendor
+func isGPUResource(name corev1.ResourceName) bool {
+	for i := range gpuVendors {
+		if gpuVendors[i].resource == name {
+			return true
+		}
+	}
+	return false
+}
+
+// forgeGPU returns the number of free GPUs of a node and their details.
+// The GPUs are counted from the allocatable resources of the node, net of the GPUs requested by its pods,
+// while their model and memory are read from the labels of the vendor.
+func forgeGPU(node *corev1.Node, pods []corev1.Pod) (resource.Quantity, *models.GPU) {
+	for i := range gpuVendors {
+		vendor := &gpuVendors[i]
+
+		allocatable, ok := node.Status.Allocatable[vendor.resource]
+		if !ok || allocatable.IsZero() {
+			continue
+		}
+		count := allocatable.DeepCopy()
+		for j := range pods {
+			requested := podRequest(&pods[j], vendor.resource)
+			count.Sub(requested)
+		}
+		if count.Sign() <= 0 {
+			return resource.Quantity{}, nil
+		}
+
+		gpu := &models.GPU{
+			Vendor:       vendor.vendor,
+			Model:        node.Labels[vendor.modelLabel],
+			ResourceName: string(vendor.resource),
+		}
+		if value, found := node.Labels[vendor.memoryLabel]; found {
+			memory, err := parseGPUMemory(value)
+			if err != nil {
+				klog.Errorf("Error when parsing the label %s of node %s: %s", vendor.memoryLabel, node.Name, err)
+			} else {
+				gpu.Memory = memory
+			}
+		}
+		return count, gpu
+	}
+	return resource.Quantity{}, nil
+}
+
+// podRequest returns the quantity of a resource requested by a pod that has not terminated:
+// the largest of the sum of the requests of its containers and of the request of each init container
+func podRequest(pod *corev1.Pod, name corev1.ResourceName) resource.Quantity {
+	var request resource.Quantity
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return request
+	}
+	for i := range pod.Spec.Containers {
+		if quantity, ok := pod.Spec.Containers[i].Resources.Requests[name]; ok {
+			request.Add(quantity)
+		}
+	}
+	for i := range pod.Spec.InitContainers {
+		if quantity, ok := pod.Spec.InitContainers[i].Resources.Requests[name]; ok && quantity.Cmp(request) > 0 {
+			request = quantity.DeepCopy()
+		}
+	}
+	return request
+}
+
+// parseGPUMemory parses the memory of a GPU from a node label: a plain number is in MiB (e.g. 40960),
+// while the decimal suffixes are read as binary ones (e.g. 16G is 16Gi)
+func parseGPUMemory(value string) (resource.Quantity, error) {
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return resource.ParseQuantity(value + "Mi")
+	}
+	if strings.HasSuffix(value, "K") || strings.HasSuffix(value, "M") || strings.HasSuffix(value, "G") || strings.HasSuffix(value, "T") {
+		value += "i"
 	}
+	return resource.ParseQuantity(value)
 }
 
 // forgeNodeInfo creates from params a new NodeInfo Struct
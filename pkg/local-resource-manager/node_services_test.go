@@ -0,0 +1,178 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localResourceManager
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func gpuPod(phase corev1.PodPhase, init string, containers ...string) corev1.Pod {
+	pod := corev1.Pod{Status: corev1.PodStatus{Phase: phase}}
+	for _, request := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse(request)}}})
+	}
+	if init != "" {
+		pod.Spec.InitContainers = []corev1.Container{{Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse(init)}}}}
+	}
+	return pod
+}
+
+func TestForgeGPU(t *testing.T) {
+	nvidiaLabels := map[string]string{
+		"nvidia.com/gpu.product": "NVIDIA-A100-SXM4-80GB",
+		"nvidia.com/gpu.memory":  "81920",
+	}
+
+	tests := []struct {
+		name        string
+		allocatable corev1.ResourceList
+		labels      map[string]string
+		pods        []corev1.Pod
+		wantCount   string
+		wantVendor  string
+		wantModel   string
+		wantMemory  string
+	}{
+		{name: "no GPUs", wantCount: "0"},
+		{
+			name:      "count label without device plugin",
+			labels:    map[string]string{"nvidia.com/gpu.count": "4", "nvidia.com/gpu.product": "Tesla-T4"},
+			wantCount: "0",
+		},
+		{
+			name:        "zero allocatable",
+			allocatable: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("0")},
+			labels:      nvidiaLabels,
+			wantCount:   "0",
+		},
+		{
+			name:        "all free",
+			allocatable: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("4")},
+			labels:      nvidiaLabels,
+			wantCount:   "4", wantVendor: "nvidia.com", wantModel: "NVIDIA-A100-SXM4-80GB", wantMemory: "80Gi",
+		},
+		{
+			name:        "requested by pods",
+			allocatable: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("4")},
+			labels:      nvidiaLabels,
+			pods:        []corev1.Pod{gpuPod(corev1.PodRunning, "", "1", "1"), gpuPod(corev1.PodPending, "", "1")},
+			wantCount:   "1", wantVendor: "nvidia.com", wantModel: "NVIDIA-A100-SXM4-80GB", wantMemory: "80Gi",
+		},
+		{
+			name:        "terminated pods release their GPUs",
+			allocatable: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("4")},
+			pods:        []corev1.Pod{gpuPod(corev1.PodSucceeded, "", "2"), gpuPod(corev1.PodFailed, "", "2")},
+			wantCount:   "4", wantVendor: "nvidia.com",
+		},
+		{
+			name:        "init container requesting more",
+			allocatable: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("4")},
+			pods:        []corev1.Pod{gpuPod(corev1.PodRunning, "3", "1")},
+			wantCount:   "1", wantVendor: "nvidia.com",
+		},
+		{
+			name:        "all requested",
+			allocatable: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("2")},
+			labels:      nvidiaLabels,
+			pods:        []corev1.Pod{gpuPod(corev1.PodRunning, "", "2")},
+			wantCount:   "0",
+		},
+		{
+			name:        "other vendor",
+			allocatable: corev1.ResourceList{"amd.com/gpu": resource.MustParse("2")},
+			labels:      map[string]string{"amd.com/gpu.product-name": "Instinct-MI210", "amd.com/gpu.vram": "64G"},
+			pods:        []corev1.Pod{gpuPod(corev1.PodRunning, "", "1")},
+			wantCount:   "2", wantVendor: "amd.com", wantModel: "Instinct-MI210", wantMemory: "64Gi",
+		},
+		{
+			name:        "invalid memory label",
+			allocatable: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")},
+			labels:      map[string]string{"nvidia.com/gpu.memory": "lots"},
+			wantCount:   "1", wantVendor: "nvidia.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: tt.labels},
+				Status:     corev1.NodeStatus{Allocatable: tt.allocatable},
+			}
+			count, gpu := forgeGPU(node, tt.pods)
+			if count.Cmp(resource.MustParse(tt.wantCount)) != 0 {
+				t.Errorf("forgeGPU() count = %s, want %s", count.String(), tt.wantCount)
+			}
+			if tt.wantVendor == "" {
+				if gpu != nil {
+					t.Errorf("forgeGPU() details = %+v, want nil", gpu)
+				}
+				return
+			}
+			if gpu == nil {
+				t.Fatalf("forgeGPU() details = nil, want vendor %s", tt.wantVendor)
+			}
+			if gpu.Vendor != tt.wantVendor || gpu.Model != tt.wantModel {
+				t.Errorf("forgeGPU() details = %s %s, want %s %s", gpu.Vendor, gpu.Model, tt.wantVendor, tt.wantModel)
+			}
+			wantMemory := resource.Quantity{}
+			if tt.wantMemory != "" {
+				wantMemory = resource.MustParse(tt.wantMemory)
+			}
+			if gpu.Memory.Cmp(wantMemory) != 0 {
+				t.Errorf("forgeGPU() memory = %s, want %s", gpu.Memory.String(), wantMemory.String())
+			}
+		})
+	}
+}
+
+func TestParseGPUMemory(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "40960", want: "40Gi"},
+		{value: "16G", want: "16Gi"},
+		{value: "512M", want: "512Mi"},
+		{value: "1T", want: "1Ti"},
+		{value: "24Gi", want: "24Gi"},
+		{value: "lots", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseGPUMemory(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseGPUMemory(%q) = %s, want an error", tt.value, got.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGPUMemory(%q) error = %s", tt.value, err)
+			}
+			if got.Cmp(resource.MustParse(tt.want)) != 0 {
+				t.Errorf("parseGPUMemory(%q) = %s, want %s", tt.value, got.String(), tt.want)
+			}
+		})
+	}
+}
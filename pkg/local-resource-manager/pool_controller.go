@@ -0,0 +1,444 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localResourceManager
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/getters"
+	"github.com/fluidos-project/node/pkg/utils/models"
+	"github.com/fluidos-project/node/pkg/utils/namings"
+	"github.com/fluidos-project/node/pkg/utils/resourceforge"
+)
+
+// clusterRole
+//+kubebuilder:rbac:groups=nodecore.fluidos.eu,resources=flavours/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=contracts,verbs=get;list;watch
+
+// PoolReconciler keeps a pooled Flavour for each group of nodes sharing the value of the POOL_NODE_LABEL label.
+// The Flavour offers the combined capacity of the ready, uncordoned nodes of the pool, net of the capacity sold
+// through their per-node Flavours, and records in its status how each sold partition is spread across the nodes.
+// The reconcile requests are named after the pool.
+type PoolReconciler struct {
+	client.Client
+}
+
+// Reconcile reconciles the Flavour of a pool
+func (r *PoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx, "pool", req.Name)
+	ctx = ctrl.LoggerInto(ctx, log)
+	pool := req.Name
+
+	nodeIdentity := getters.GetNodeIdentity(ctx, r.Client)
+	if nodeIdentity == nil {
+		return ctrl.Result{}, fmt.Errorf("error getting FLUIDOS Node identity")
+	}
+	name := types.NamespacedName{Name: namings.ForgePoolFlavourName(pool, nodeIdentity.Domain), Namespace: flags.FLUIDOS_NAMESPACE}
+
+	var contracts reservationv1alpha1.ContractList
+	if err := r.List(ctx, &contracts, client.InNamespace(flags.FLUIDOS_NAMESPACE)); err != nil {
+		klog.Errorf("Error when listing Contracts: %s", err)
+		return ctrl.Result{}, err
+	}
+	sold := nodeContractHolds(contracts.Items, nodeIdentity.NodeID)
+
+	nodes, err := r.poolNodes(ctx, pool, sold)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var current nodecorev1alpha1.Flavour
+	err = r.Get(ctx, name, &current)
+	if client.IgnoreNotFound(err) != nil {
+		klog.Errorf("Error when getting Flavour %s: %s", name.Name, err)
+		return ctrl.Result{}, err
+	}
+	exists := err == nil
+
+	if len(nodes) == 0 {
+		if !exists {
+			return ctrl.Result{}, nil
+		}
+		// No node is left in the pool: its Flavour is kept unavailable, with its allocations, while partitions sold from it are active
+		if len(poolContracts(contracts.Items, current.Name, nodeIdentity.NodeID)) > 0 {
+			if current.Spec.OptionalFields.Availability {
+				current.Spec.OptionalFields.Availability = false
+				if err := r.Update(ctx, &current); err != nil {
+					klog.Errorf("Error when updating Flavour %s: %s", current.Name, err)
+					return ctrl.Result{}, err
+				}
+				klog.Infof("Flavour %s of pool %s made unavailable", current.Name, pool)
+			}
+			return ctrl.Result{RequeueAfter: flags.NODE_METRICS_REFRESH_INTERVAL}, nil
+		}
+		// Nothing is allocated from the pool: its Flavour is retired
+		if err := r.Delete(ctx, &current); client.IgnoreNotFound(err) != nil {
+			klog.Errorf("Error when deleting Flavour %s: %s", current.Name, err)
+			return ctrl.Result{}, err
+		}
+		klog.Infof("Flavour %s of pool %s retired", current.Name, pool)
+		return ctrl.Result{}, nil
+	}
+
+	flavour := resourceforge.ForgeFlavourFromPool(pool, nodes, *nodeIdentity)
+	switch {
+	case !exists:
+		if err := r.Create(ctx, flavour); err != nil {
+			klog.Errorf("Error when creating Flavour %s: %s", flavour.Name, err)
+			return ctrl.Result{}, err
+		}
+		klog.Infof("Flavour %s created for pool %s with %d nodes", flavour.Name, pool, len(nodes))
+		current = *flavour
+	case flavourChanged(&current.Spec, &flavour.Spec):
+		current.Spec = flavour.Spec
+		current.Labels = flavour.Labels
+		if err := r.Update(ctx, &current); err != nil {
+			klog.Errorf("Error when updating Flavour %s: %s", current.Name, err)
+			return ctrl.Result{}, err
+		}
+		klog.Infof("Flavour %s updated for pool %s with %d nodes", current.Name, pool, len(nodes))
+	}
+
+	allocations := allocatePool(&current, poolContracts(contracts.Items, current.Name, nodeIdentity.NodeID), nodes)
+	if !equality.Semantic.DeepEqual(current.Status.PoolAllocations, allocations) {
+		current.Status.PoolAllocations = allocations
+		if err := r.Status().Update(ctx, &current); err != nil {
+			klog.Errorf("Error when updating the allocations of Flavour %s: %s", current.Name, err)
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: flags.NODE_METRICS_REFRESH_INTERVAL}, nil
+}
+
+// poolNodes returns the ready, uncordoned nodes of the pool sharing the architecture of the first one,
+// with their available resources net of the capacity sold through their per-node Flavours
+func (r *PoolReconciler) poolNodes(ctx context.Context, pool string, sold map[string]corev1.ResourceList) ([]models.NodeInfo, error) {
+	var nodeList corev1.NodeList
+	if err := r.List(ctx, &nodeList, client.MatchingLabels{flags.RESOURCE_NODE_LABEL: "true", flags.POOL_NODE_LABEL: pool}); err != nil {
+		klog.Errorf("Error when listing the nodes of pool %s: %s", pool, err)
+		return nil, err
+	}
+	sort.Slice(nodeList.Items, func(i, j int) bool { return nodeList.Items[i].Name < nodeList.Items[j].Name })
+
+	var nodes []models.NodeInfo
+	for i := range nodeList.Items {
+		node := &nodeList.Items[i]
+		if !isSchedulable(node) {
+			continue
+		}
+		if len(nodes) > 0 && node.Status.NodeInfo.Architecture != nodes[0].Architecture {
+			klog.Warningf("Node %s of pool %s has architecture %s instead of %s: skipped", node.Name, pool,
+				node.Status.NodeInfo.Architecture, nodes[0].Architecture)
+			continue
+		}
+
+		var metrics metricsv1beta1.NodeMetrics
+		if err := r.Get(ctx, client.ObjectKey{Name: node.Name}, &metrics); apierrors.IsNotFound(err) {
+			klog.Infof("Metrics of Node %s not available yet", node.Name)
+			continue
+		} else if err != nil {
+			klog.Errorf("Error when getting the metrics of Node %s: %s", node.Name, err)
+			return nil, err
+		}
+
+		pods, err := nodePods(ctx, r.Client, node.Name)
+		if err != nil {
+			return nil, err
+		}
+		info := forgeNodeInfo(node, forgeResourceMetrics(&metrics, node, pods))
+		subtractHold(&info.ResourceMetrics, sold[node.Name])
+		nodes = append(nodes, *info)
+	}
+	return nodes, nil
+}
+
+// nodeContractHolds returns, for each node, the resources sold by the active Contracts of its per-node Flavour
+func nodeContractHolds(contracts []reservationv1alpha1.Contract, nodeID string) map[string]corev1.ResourceList {
+	holds := make(map[string]corev1.ResourceList)
+	for i := range contracts {
+		contract := &contracts[i]
+		node, ok := contract.Spec.Flavour.Labels[consts.FLAVOUR_NODE_LABEL]
+		if !ok || contract.Spec.Seller.NodeID != nodeID || !contract.IsActive() {
+			continue
+		}
+		if holds[node] == nil {
+			holds[node] = corev1.ResourceList{}
+		}
+		addResources(holds[node], contractResources(contract))
+	}
+	return holds
+}
+
+// poolContracts returns the active Contracts sold from the pooled Flavour, sorted by name
+func poolContracts(contracts []reservationv1alpha1.Contract, flavourName, nodeID string) []*reservationv1alpha1.Contract {
+	var sold []*reservationv1alpha1.Contract
+	for i := range contracts {
+		contract := &contracts[i]
+		if contract.Spec.Flavour.Name == flavourName && contract.Spec.Seller.NodeID == nodeID && contract.IsActive() {
+			sold = append(sold, contract)
+		}
+	}
+	sort.Slice(sold, func(i, j int) bool { return sold[i].Name < sold[j].Name })
+	return sold
+}
+
+// contractResources returns the resources sold by a contract: its partition, or the whole flavour if it has no partition
+func contractResources(contract *reservationv1alpha1.Contract) corev1.ResourceList {
+	if partition := contract.Spec.Partition; partition != nil {
+		return corev1.ResourceList{
+			corev1.ResourceCPU:        partition.Cpu,
+			corev1.ResourceMemory:     partition.Memory,
+			consts.CHARACTERISTIC_GPU: partition.Gpu,
+		}
+	}
+	characteristics := &contract.Spec.Flavour.Spec.Characteristics
+	return corev1.ResourceList{
+		corev1.ResourceCPU:        characteristics.Cpu,
+		corev1.ResourceMemory:     characteristics.Memory,
+		consts.CHARACTERISTIC_GPU: characteristics.Gpu,
+	}
+}
+
+// allocatePool spreads the partitions of the Contracts across the nodes of the pool.
+// The allocations recorded for the Contracts still active are kept, the new partitions are spread over the capacity left.
+func allocatePool(flavour *nodecorev1alpha1.Flavour, contracts []*reservationv1alpha1.Contract, nodes []models.NodeInfo) []nodecorev1alpha1.PoolAllocation {
+	free := make(map[string]corev1.ResourceList, len(nodes))
+	for i := range nodes {
+		free[nodes[i].Name] = corev1.ResourceList{
+			corev1.ResourceCPU:    nodes[i].ResourceMetrics.CPUAvailable.DeepCopy(),
+			corev1.ResourceMemory: nodes[i].ResourceMetrics.MemoryAvailable.DeepCopy(),
+		}
+	}
+
+	recorded := make(map[string]nodecorev1alpha1.PoolAllocation, len(flavour.Status.PoolAllocations))
+	for _, allocation := range flavour.Status.PoolAllocations {
+		recorded[allocation.Contract] = allocation
+	}
+
+	var allocations []nodecorev1alpha1.PoolAllocation
+	var pending []*reservationv1alpha1.Contract
+	for _, contract := range contracts {
+		allocation, ok := recorded[contract.Name]
+		if !ok {
+			pending = append(pending, contract)
+			continue
+		}
+		for _, share := range allocation.Nodes {
+			if f, ok := free[share.Node]; ok {
+				subtractResources(f, shareResources(&share))
+			}
+		}
+		allocations = append(allocations, allocation)
+	}
+
+	for _, contract := range pending {
+		shares := spreadPartition(contractResources(contract), free)
+		allocations = append(allocations, nodecorev1alpha1.PoolAllocation{Contract: contract.Name, Nodes: shares})
+	}
+
+	sort.Slice(allocations, func(i, j int) bool { return allocations[i].Contract < allocations[j].Contract })
+	return allocations
+}
+
+// spreadPartition places a partition on the nodes with the most capacity left, taking from each node the same fraction
+// of every requested resource, and subtracts the placed shares from the free capacity of the nodes
+func spreadPartition(request corev1.ResourceList, free map[string]corev1.ResourceList) []nodecorev1alpha1.NodeShare {
+	// fit returns the fraction of the request the node can host
+	fit := func(node string) float64 {
+		fraction := math.Inf(1)
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			requested := request[name]
+			if requested.IsZero() {
+				continue
+			}
+			available := free[node][name]
+			fraction = math.Min(fraction, math.Max(available.AsApproximateFloat64(), 0)/requested.AsApproximateFloat64())
+		}
+		return fraction
+	}
+
+	nodes := make([]string, 0, len(free))
+	for node := range free {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		fi, fj := fit(nodes[i]), fit(nodes[j])
+		if fi != fj {
+			return fi > fj
+		}
+		return nodes[i] < nodes[j]
+	})
+
+	var shares []nodecorev1alpha1.NodeShare
+	left := request.DeepCopy()
+	remaining := 1.0
+	for _, node := range nodes {
+		fraction := math.Min(fit(node), remaining)
+		if fraction <= 0 {
+			break
+		}
+		remaining -= fraction
+
+		var share nodecorev1alpha1.NodeShare
+		if remaining <= 1e-9 {
+			// The last share takes whatever is left, so that the shares add up to the request
+			share = nodecorev1alpha1.NodeShare{Node: node, Cpu: left[corev1.ResourceCPU], Memory: left[corev1.ResourceMemory], Gpu: left[consts.CHARACTERISTIC_GPU]}
+		} else {
+			cpu, memory, gpu := request[corev1.ResourceCPU], request[corev1.ResourceMemory], request[consts.CHARACTERISTIC_GPU]
+			share = nodecorev1alpha1.NodeShare{
+				Node:   node,
+				Cpu:    *resource.NewMilliQuantity(int64(float64(cpu.MilliValue())*fraction), resource.DecimalSI),
+				Memory: *resource.NewQuantity(int64(float64(memory.Value())*fraction), resource.BinarySI),
+				Gpu:    *resource.NewQuantity(int64(float64(gpu.Value())*fraction), resource.DecimalSI),
+			}
+		}
+		subtractResources(left, shareResources(&share))
+		subtractResources(free[node], shareResources(&share))
+		shares = append(shares, share)
+
+		if remaining <= 1e-9 {
+			return shares
+		}
+	}
+
+	klog.Warningf("The nodes of the pool have not enough capacity left to place %.0f%% of the partition", remaining*100)
+	return shares
+}
+
+// shareResources returns the resources of a node share
+func shareResources(share *nodecorev1alpha1.NodeShare) corev1.ResourceList {
+	return corev1.ResourceList{
+		corev1.ResourceCPU:        share.Cpu,
+		corev1.ResourceMemory:     share.Memory,
+		consts.CHARACTERISTIC_GPU: share.Gpu,
+	}
+}
+
+// poolShares returns, for each node, the resources placed on it by the partitions sold from the pooled Flavours
+func poolShares(ctx context.Context, cl client.Client) (map[string]corev1.ResourceList, error) {
+	var flavours nodecorev1alpha1.FlavourList
+	if err := cl.List(ctx, &flavours, client.InNamespace(flags.FLUIDOS_NAMESPACE), client.HasLabels{consts.FLAVOUR_POOL_LABEL}); err != nil {
+		klog.Errorf("Error when listing the pooled Flavours: %s", err)
+		return nil, err
+	}
+
+	shares := make(map[string]corev1.ResourceList)
+	for i := range flavours.Items {
+		for _, allocation := range flavours.Items[i].Status.PoolAllocations {
+			for j := range allocation.Nodes {
+				share := &allocation.Nodes[j]
+				if shares[share.Node] == nil {
+					shares[share.Node] = corev1.ResourceList{}
+				}
+				addResources(shares[share.Node], shareResources(share))
+			}
+		}
+	}
+	return shares, nil
+}
+
+// addResources adds the resources to the total
+func addResources(total, added corev1.ResourceList) {
+	for name, quantity := range added {
+		q := total[name]
+		q.Add(quantity)
+		total[name] = q
+	}
+}
+
+// subtractResources subtracts the resources from the total
+func subtractResources(total, subtracted corev1.ResourceList) {
+	for name, quantity := range subtracted {
+		if q, ok := total[name]; ok {
+			q.Sub(quantity)
+			total[name] = q
+		}
+	}
+}
+
+// subtractHold subtracts the CPU, memory and GPUs held on a node from its available resources
+func subtractHold(metrics *models.ResourceMetrics, hold corev1.ResourceList) {
+	if cpu, ok := hold[corev1.ResourceCPU]; ok {
+		metrics.CPUAvailable.Sub(cpu)
+	}
+	if memory, ok := hold[corev1.ResourceMemory]; ok {
+		metrics.MemoryAvailable.Sub(memory)
+	}
+	if gpu, ok := hold[consts.CHARACTERISTIC_GPU]; ok && !metrics.GPU.IsZero() {
+		metrics.GPU.Sub(gpu)
+	}
+}
+
+// nodeToPool maps a node to the reconcile request of its pool
+func nodeToPool(_ context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetLabels()[flags.RESOURCE_NODE_LABEL] != "true" {
+		return nil
+	}
+	if pool, ok := obj.GetLabels()[flags.POOL_NODE_LABEL]; ok && pool != "" {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: pool}}}
+	}
+	return nil
+}
+
+// flavourToPool maps a pooled Flavour to the reconcile request of its pool
+func flavourToPool(_ context.Context, obj client.Object) []reconcile.Request {
+	if pool, ok := obj.GetLabels()[consts.FLAVOUR_POOL_LABEL]; ok && pool != "" {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: pool}}}
+	}
+	return nil
+}
+
+// contractToPool maps a Contract of a pooled Flavour to the reconcile request of its pool
+func (r *PoolReconciler) contractToPool(ctx context.Context, obj client.Object) []reconcile.Request {
+	contract, ok := obj.(*reservationv1alpha1.Contract)
+	if !ok {
+		return nil
+	}
+	var flavour nodecorev1alpha1.Flavour
+	if err := r.Get(ctx, types.NamespacedName{Name: contract.Spec.Flavour.Name, Namespace: flags.FLUIDOS_NAMESPACE}, &flavour); err != nil {
+		return nil
+	}
+	return flavourToPool(ctx, &flavour)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pool-flavour").
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(nodeToPool)).
+		Watches(&nodecorev1alpha1.Flavour{}, handler.EnqueueRequestsFromMapFunc(flavourToPool)).
+		Watches(&reservationv1alpha1.Contract{}, handler.EnqueueRequestsFromMapFunc(r.contractToPool)).
+		Complete(r)
+}
@@ -0,0 +1,187 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localResourceManager
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+func resources(cpu, memory string) corev1.ResourceList {
+	return corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu), corev1.ResourceMemory: resource.MustParse(memory)}
+}
+
+// sumShares returns the resources placed by the shares
+func sumShares(shares []nodecorev1alpha1.NodeShare) corev1.ResourceList {
+	total := corev1.ResourceList{}
+	for i := range shares {
+		addResources(total, shareResources(&shares[i]))
+	}
+	return total
+}
+
+func TestSpreadPartition(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   corev1.ResourceList
+		free      map[string]corev1.ResourceList
+		wantNodes []string
+		wantPlace corev1.ResourceList
+	}{
+		{
+			name:      "fits on the node with the most capacity",
+			request:   resources("2", "4Gi"),
+			free:      map[string]corev1.ResourceList{"node-1": resources("2", "4Gi"), "node-2": resources("4", "8Gi")},
+			wantNodes: []string{"node-2"},
+			wantPlace: resources("2", "4Gi"),
+		},
+		{
+			name:      "ties broken by name",
+			request:   resources("1", "1Gi"),
+			free:      map[string]corev1.ResourceList{"node-2": resources("4", "8Gi"), "node-1": resources("4", "8Gi")},
+			wantNodes: []string{"node-1"},
+			wantPlace: resources("1", "1Gi"),
+		},
+		{
+			name:      "spread across nodes",
+			request:   resources("6", "12Gi"),
+			free:      map[string]corev1.ResourceList{"node-1": resources("2", "4Gi"), "node-2": resources("4", "8Gi")},
+			wantNodes: []string{"node-2", "node-1"},
+			wantPlace: resources("6", "12Gi"),
+		},
+		{
+			name:      "fraction bound by the scarcest resource",
+			request:   resources("4", "4Gi"),
+			free:      map[string]corev1.ResourceList{"node-1": resources("8", "2Gi"), "node-2": resources("2", "8Gi")},
+			wantNodes: []string{"node-1", "node-2"},
+			wantPlace: resources("4", "4Gi"),
+		},
+		{
+			name:      "not enough capacity",
+			request:   resources("8", "8Gi"),
+			free:      map[string]corev1.ResourceList{"node-1": resources("2", "2Gi"), "node-2": resources("2", "2Gi"), "node-3": resources("0", "4Gi")},
+			wantNodes: []string{"node-1", "node-2"},
+			wantPlace: resources("4", "4Gi"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			free := make(map[string]corev1.ResourceList, len(tt.free))
+			for node, list := range tt.free {
+				free[node] = list.DeepCopy()
+			}
+
+			shares := spreadPartition(tt.request, free)
+
+			if len(shares) != len(tt.wantNodes) {
+				t.Fatalf("spreadPartition() = %+v, want shares on %v", shares, tt.wantNodes)
+			}
+			for i := range shares {
+				if shares[i].Node != tt.wantNodes[i] {
+					t.Errorf("spreadPartition() share %d on %s, want %s", i, shares[i].Node, tt.wantNodes[i])
+				}
+			}
+			placed := sumShares(shares)
+			for name, quantity := range tt.wantPlace {
+				if q := placed[name]; q.Cmp(quantity) != 0 {
+					t.Errorf("spreadPartition() placed %s %s, want %s", q.String(), name, quantity.String())
+				}
+			}
+			for node, list := range free {
+				for name, quantity := range list {
+					if initial := tt.free[node][name]; quantity.Sign() < 0 && initial.Sign() >= 0 {
+						t.Errorf("spreadPartition() overcommitted %s of %s: %s left", name, node, quantity.String())
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAllocatePool(t *testing.T) {
+	node := func(name, cpu, memory string) models.NodeInfo {
+		return models.NodeInfo{Name: name, ResourceMetrics: models.ResourceMetrics{
+			CPUAvailable: resource.MustParse(cpu), MemoryAvailable: resource.MustParse(memory)}}
+	}
+	contract := func(name, cpu, memory string) *reservationv1alpha1.Contract {
+		return &reservationv1alpha1.Contract{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: reservationv1alpha1.ContractSpec{Partition: &reservationv1alpha1.Partition{
+				Cpu: resource.MustParse(cpu), Memory: resource.MustParse(memory)}},
+		}
+	}
+	share := func(node, cpu, memory string) nodecorev1alpha1.NodeShare {
+		return nodecorev1alpha1.NodeShare{Node: node, Cpu: resource.MustParse(cpu), Memory: resource.MustParse(memory)}
+	}
+
+	flavour := &nodecorev1alpha1.Flavour{Status: nodecorev1alpha1.FlavourStatus{PoolAllocations: []nodecorev1alpha1.PoolAllocation{
+		{Contract: "c-kept", Nodes: []nodecorev1alpha1.NodeShare{share("node-1", "3", "6Gi")}},
+		{Contract: "c-ended", Nodes: []nodecorev1alpha1.NodeShare{share("node-2", "4", "8Gi")}},
+	}}}
+	nodes := []models.NodeInfo{node("node-1", "4", "8Gi"), node("node-2", "4", "8Gi")}
+
+	allocations := allocatePool(flavour, []*reservationv1alpha1.Contract{contract("c-new", "2", "2Gi"), contract("c-kept", "3", "6Gi")}, nodes)
+
+	if len(allocations) != 2 || allocations[0].Contract != "c-kept" || allocations[1].Contract != "c-new" {
+		t.Fatalf("allocatePool() = %+v, want the allocations of c-kept and c-new", allocations)
+	}
+	if len(allocations[0].Nodes) != 1 || allocations[0].Nodes[0].Node != "node-1" {
+		t.Errorf("allocatePool() moved the recorded allocation of c-kept: %+v", allocations[0].Nodes)
+	}
+	// The capacity of the Contract no longer active is released, while node-1 only has 1 CPU left
+	if len(allocations[1].Nodes) != 1 || allocations[1].Nodes[0].Node != "node-2" {
+		t.Errorf("allocatePool() placed c-new on %+v, want node-2", allocations[1].Nodes)
+	}
+}
+
+func TestNodeContractHolds(t *testing.T) {
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	contract := func(seller, node, cpu string, phase nodecorev1alpha1.Phase) reservationv1alpha1.Contract {
+		var c reservationv1alpha1.Contract
+		c.Spec.Seller.NodeID = seller
+		c.Spec.ExpirationTime = future
+		c.Status.Phase.Phase = phase
+		if node != "" {
+			c.Spec.Flavour.Labels = map[string]string{consts.FLAVOUR_NODE_LABEL: node}
+		}
+		c.Spec.Partition = &reservationv1alpha1.Partition{Cpu: resource.MustParse(cpu), Memory: resource.MustParse("1Gi")}
+		return c
+	}
+
+	holds := nodeContractHolds([]reservationv1alpha1.Contract{
+		contract("seller", "node-1", "1", nodecorev1alpha1.PhaseActive),
+		contract("seller", "node-1", "2", nodecorev1alpha1.PhaseActive),
+		contract("seller", "node-2", "4", nodecorev1alpha1.PhaseTerminated),
+		contract("other", "node-2", "4", nodecorev1alpha1.PhaseActive),
+		contract("seller", "", "4", nodecorev1alpha1.PhaseActive),
+	}, "seller")
+
+	if len(holds) != 1 {
+		t.Fatalf("nodeContractHolds() = %v, want holds on node-1 only", holds)
+	}
+	if cpu := holds["node-1"][corev1.ResourceCPU]; cpu.Cmp(resource.MustParse("3")) != 0 {
+		t.Errorf("nodeContractHolds() cpu of node-1 = %s, want 3", cpu.String())
+	}
+}
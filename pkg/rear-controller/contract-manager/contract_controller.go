@@ -0,0 +1,379 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractmanager
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	discoveryv1alpha1 "github.com/liqotech/liqo/apis/discovery/v1alpha1"
+	virtualkubeletv1alpha1 "github.com/liqotech/liqo/apis/virtualkubelet/v1alpha1"
+	foreigncluster "github.com/liqotech/liqo/pkg/utils/foreignCluster"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/rear-controller/gateway"
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/getters"
+	"github.com/fluidos-project/node/pkg/utils/parseutil"
+	"github.com/fluidos-project/node/pkg/utils/resourceforge"
+)
+
+// ContractReconciler reconciles a Contract object, on both the buyer and the seller FLUIDOS Node
+type ContractReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Gateway  *gateway.Gateway
+	Recorder record.EventRecorder
+}
+
+// clusterRole
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=contracts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=contracts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=nodecore.fluidos.eu,resources=allocations,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=nodecore.fluidos.eu,resources=allocations/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=discovery.liqo.io,resources=foreignclusters,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=virtualkubelet.liqo.io,resources=virtualnodes,verbs=get;list;watch;delete
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;patch;delete
+
+// Reconcile moves the Contract to Inactive once expired, warns ahead of its expiration and, on the buyer,
+// renews it through the seller's Gateway if AUTO_RENEW_CONTRACTS is set.
+// A Contract annotated with CONTRACT_TERMINATE_ANNOTATION is terminated early, on both parties.
+// A Contract still holding the Liqo token in its spec is migrated first, moving the token to a Secret.
+func (r *ContractReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx, "contract", req.NamespacedName)
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	var contract reservationv1alpha1.Contract
+	if err := r.Get(ctx, req.NamespacedName, &contract); client.IgnoreNotFound(err) != nil {
+		klog.Errorf("Error when getting Contract %s before reconcile: %s", req.NamespacedName, err)
+		return ctrl.Result{}, err
+	} else if err != nil {
+		klog.Infof("Contract %s not found, probably deleted", req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	if contract.Spec.SellerCredentials.Token != "" {
+		return ctrl.Result{}, r.migrateCredentials(ctx, &contract)
+	}
+
+	if contract.Status.Phase.Phase == nodecorev1alpha1.PhaseTerminated || contract.Status.Phase.Phase == nodecorev1alpha1.PhaseInactive {
+		return ctrl.Result{}, r.release(ctx, &contract)
+	}
+
+	if reason, ok := contract.Annotations[consts.CONTRACT_TERMINATE_ANNOTATION]; ok && contract.IsActive() {
+		return r.terminate(ctx, &contract, reason)
+	}
+
+	if contract.Spec.ExpirationTime == "" {
+		return ctrl.Result{}, nil
+	}
+
+	expiration, err := time.Parse(time.RFC3339, contract.Spec.ExpirationTime)
+	if err != nil {
+		klog.Errorf("Error parsing the expiration time of Contract %s: %s", contract.Name, err)
+		return ctrl.Result{}, nil
+	}
+
+	remaining := time.Until(expiration)
+
+	if remaining <= 0 {
+		klog.Infof("Contract %s expired", contract.Name)
+		contract.SetPhase(nodecorev1alpha1.PhaseInactive, "Contract expired")
+		contract.SetCondition(reservationv1alpha1.ContractConditionExpiring, metav1.ConditionFalse, "Expired", "The contract has expired")
+		contract.SetCondition(reservationv1alpha1.ContractConditionExpired, metav1.ConditionTrue, "Expired", "The contract expired at "+contract.Spec.ExpirationTime)
+		if err := r.Status().Update(ctx, &contract); err != nil {
+			klog.Errorf("Error when updating Contract %s status: %s", contract.Name, err)
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Event(&contract, corev1.EventTypeWarning, "Expired", "The contract has expired")
+		return ctrl.Result{}, r.release(ctx, &contract)
+	}
+
+	if remaining > flags.CONTRACT_EXPIRATION_WARNING {
+		if contract.SetCondition(reservationv1alpha1.ContractConditionExpiring, metav1.ConditionFalse, "Valid", "The contract expires at "+contract.Spec.ExpirationTime) {
+			if err := r.Status().Update(ctx, &contract); err != nil {
+				klog.Errorf("Error when updating Contract %s status: %s", contract.Name, err)
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: remaining - flags.CONTRACT_EXPIRATION_WARNING}, nil
+	}
+
+	if r.isBuyer(ctx, &contract) && flags.AUTO_RENEW_CONTRACTS {
+		if err := r.renew(ctx, &contract); err != nil {
+			klog.Errorf("Error when renewing Contract %s: %s", contract.Name, err)
+			r.Recorder.Event(&contract, corev1.EventTypeWarning, "RenewFailed", "The contract could not be renewed: "+err.Error())
+			return ctrl.Result{RequeueAfter: minDuration(flags.RETRY_RESERVATION_INTERVAL, remaining)}, nil
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if contract.SetCondition(reservationv1alpha1.ContractConditionExpiring, metav1.ConditionTrue, "ExpiringSoon", "The contract expires at "+contract.Spec.ExpirationTime) {
+		if err := r.Status().Update(ctx, &contract); err != nil {
+			klog.Errorf("Error when updating Contract %s status: %s", contract.Name, err)
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Event(&contract, corev1.EventTypeWarning, "ExpiringSoon", "The contract expires at "+contract.Spec.ExpirationTime)
+	}
+
+	return ctrl.Result{RequeueAfter: remaining}, nil
+}
+
+// renew asks the seller to extend the Contract by EXPIRATION_CONTRACT and stores the renewed terms
+func (r *ContractReconciler) renew(ctx context.Context, contract *reservationv1alpha1.Contract) error {
+	expirationTime := time.Now().Add(flags.EXPIRATION_CONTRACT).Format(time.RFC3339)
+
+	klog.Infof("Renewing Contract %s until %s", contract.Name, expirationTime)
+	renewed, err := r.Gateway.RenewContract(ctx, contract.Name, expirationTime, contract.Spec.Seller)
+	if err != nil {
+		return err
+	}
+
+	contract.Spec.ExpirationTime = renewed.Contract.ExpirationTime
+	contract.Spec.SellerSignature = parseutil.ParseSignatureFromObj(renewed.Contract.SellerSignature)
+	contract.Spec.BuyerSignature = parseutil.ParseSignatureFromObj(renewed.Contract.BuyerSignature)
+	if err := r.Update(ctx, contract); err != nil {
+		klog.Errorf("Error when updating Contract %s: %s", contract.Name, err)
+		return err
+	}
+
+	r.Recorder.Event(contract, corev1.EventTypeNormal, "Renewed", "The contract has been renewed until "+contract.Spec.ExpirationTime)
+	return nil
+}
+
+// terminate asks the other party to terminate the Contract and then terminates the local one.
+// If the other party cannot be reached the termination is retried, if it rejects the request
+// the Contract is terminated locally anyway.
+func (r *ContractReconciler) terminate(ctx context.Context, contract *reservationv1alpha1.Contract, reason string) (ctrl.Result, error) {
+	by, other, party := reservationv1alpha1.ContractPartySeller, reservationv1alpha1.ContractPartyBuyer, contract.Spec.Buyer
+	if r.isBuyer(ctx, contract) {
+		by, other, party = reservationv1alpha1.ContractPartyBuyer, reservationv1alpha1.ContractPartySeller, contract.Spec.Seller
+	}
+
+	klog.Infof("Terminating Contract %s with the %s", contract.Name, other)
+	if _, err := r.Gateway.TerminateContract(ctx, contract.Name, reason, party); err != nil {
+		var problem *gateway.ProblemError
+		if !errors.As(err, &problem) || problem.Retryable() {
+			klog.Errorf("Error when terminating Contract %s with the %s: %s", contract.Name, other, err)
+			r.Recorder.Event(contract, corev1.EventTypeWarning, "TerminationFailed", "The contract could not be terminated: "+err.Error())
+			return ctrl.Result{RequeueAfter: flags.RETRY_RESERVATION_INTERVAL}, nil
+		}
+		klog.Infof("The %s rejected the termination of Contract %s, terminating it locally: %s", other, contract.Name, err)
+		r.Recorder.Event(contract, corev1.EventTypeWarning, "TerminationRejected", "The "+other+" rejected the termination: "+err.Error())
+	}
+
+	contract.Terminate(by, reason)
+	if err := r.Status().Update(ctx, contract); err != nil {
+		klog.Errorf("Error when updating Contract %s status: %s", contract.Name, err)
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Event(contract, corev1.EventTypeNormal, "Terminated", contract.Status.Phase.Message)
+
+	return ctrl.Result{}, r.release(ctx, contract)
+}
+
+// release releases the Allocations of the Contract and stops the resource sharing:
+// the seller stops offering its resources to the buyer cluster and revokes its Liqo token, the buyer tears down the peering
+// with the seller cluster
+func (r *ContractReconciler) release(ctx context.Context, contract *reservationv1alpha1.Contract) error {
+	var allocations nodecorev1alpha1.AllocationList
+	if err := r.List(ctx, &allocations, client.MatchingLabels{consts.CONTRACT_LABEL: contract.Name}); err != nil {
+		klog.Errorf("Error when listing the Allocations of Contract %s: %s", contract.Name, err)
+		return err
+	}
+
+	for i := range allocations.Items {
+		allocation := &allocations.Items[i]
+		if allocation.Status.Status == nodecorev1alpha1.Released {
+			continue
+		}
+		klog.Infof("Releasing Allocation %s of Contract %s", allocation.Name, contract.Name)
+		allocation.Status.Status = nodecorev1alpha1.Released
+		allocation.Status.LastUpdateTime = metav1.Now()
+		if err := r.Status().Update(ctx, allocation); err != nil {
+			klog.Errorf("Error when updating Allocation %s status: %s", allocation.Name, err)
+			return err
+		}
+	}
+
+	if r.isBuyer(ctx, contract) {
+		return r.teardownPeering(ctx, contract)
+	}
+
+	return r.revokeToken(ctx, contract)
+}
+
+// revokeToken revokes the Liqo token issued for the Contract, deleting its Secret: the Gateway no longer accepts it
+// from the buyer cluster. The Liqo identity already obtained with the token is not revoked: the buyer cluster can still
+// authenticate with it, but the seller no longer offers it the resources of the Contract.
+func (r *ContractReconciler) revokeToken(ctx context.Context, contract *reservationv1alpha1.Contract) error {
+	if contract.Spec.SellerCredentials.TokenID == "" || contract.Status.TokenRevocationTime != "" {
+		return nil
+	}
+
+	if ref := contract.Spec.SellerCredentials.TokenSecretRef; ref != nil {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: ref.Name, Namespace: contract.Namespace}}
+		if err := r.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+			klog.Errorf("Error when deleting the credentials Secret of Contract %s: %s", contract.Name, err)
+			return err
+		}
+	}
+
+	contract.Status.TokenRevocationTime = time.Now().Format(time.RFC3339)
+	if err := r.Status().Update(ctx, contract); err != nil {
+		klog.Errorf("Error when updating Contract %s status: %s", contract.Name, err)
+		return err
+	}
+	r.Recorder.Event(contract, corev1.EventTypeNormal, "TokenRevoked", "The Liqo token "+contract.Spec.SellerCredentials.TokenID+" has been revoked")
+	return nil
+}
+
+// teardownPeering disables the outgoing Liqo peering with the seller cluster and deletes its virtual nodes,
+// unless other active Contracts are still bought from the same cluster
+func (r *ContractReconciler) teardownPeering(ctx context.Context, contract *reservationv1alpha1.Contract) error {
+	clusterID := contract.Spec.SellerCredentials.ClusterID
+	if clusterID == "" {
+		return nil
+	}
+
+	var contracts reservationv1alpha1.ContractList
+	if err := r.List(ctx, &contracts); err != nil {
+		klog.Errorf("Error when listing Contracts: %s", err)
+		return err
+	}
+	for i := range contracts.Items {
+		other := &contracts.Items[i]
+		if other.Name != contract.Name && other.Spec.SellerCredentials.ClusterID == clusterID &&
+			other.Spec.Buyer.NodeID == contract.Spec.Buyer.NodeID && other.IsActive() {
+			klog.Infof("Keeping the peering with cluster %s, still used by Contract %s", clusterID, other.Name)
+			return nil
+		}
+	}
+
+	fc, err := foreigncluster.GetForeignClusterByID(ctx, r.Client, clusterID)
+	if apierrors.IsNotFound(err) {
+		klog.Infof("No ForeignCluster found for cluster %s, no peering to tear down", clusterID)
+	} else if err != nil {
+		klog.Errorf("Error when getting the ForeignCluster of cluster %s: %s", clusterID, err)
+		return err
+	} else if fc.Spec.OutgoingPeeringEnabled != discoveryv1alpha1.PeeringEnabledNo {
+		klog.Infof("Disabling the outgoing peering with cluster %s", clusterID)
+		fc.Spec.OutgoingPeeringEnabled = discoveryv1alpha1.PeeringEnabledNo
+		if err := r.Update(ctx, fc); err != nil {
+			klog.Errorf("Error when updating ForeignCluster %s: %s", fc.Name, err)
+			return err
+		}
+		r.Recorder.Event(contract, corev1.EventTypeNormal, "PeeringDisabled", "The peering with cluster "+clusterID+" has been disabled")
+	}
+
+	var virtualNodes virtualkubeletv1alpha1.VirtualNodeList
+	if err := r.List(ctx, &virtualNodes); err != nil {
+		klog.Errorf("Error when listing VirtualNodes: %s", err)
+		return err
+	}
+	for i := range virtualNodes.Items {
+		vn := &virtualNodes.Items[i]
+		if vn.Spec.ClusterIdentity == nil || vn.Spec.ClusterIdentity.ClusterID != clusterID {
+			continue
+		}
+		klog.Infof("Deleting VirtualNode %s/%s of cluster %s", vn.Namespace, vn.Name, clusterID)
+		if err := r.Delete(ctx, vn); client.IgnoreNotFound(err) != nil {
+			klog.Errorf("Error when deleting VirtualNode %s/%s: %s", vn.Namespace, vn.Name, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateCredentials moves the Liqo token from the spec of the Contract to a Secret owned by the Contract.
+// On the seller, the token of the local cluster is replaced by a token issued for the Contract alone, accepted by the
+// Liqo authentication proxy of the Gateway: the buyer retrieves it through the credentials endpoint.
+func (r *ContractReconciler) migrateCredentials(ctx context.Context, contract *reservationv1alpha1.Contract) error {
+	klog.Infof("Moving the Liqo token of Contract %s to a Secret", contract.Name)
+	token := contract.Spec.SellerCredentials.Token
+	if !r.isBuyer(ctx, contract) {
+		var tokenID string
+		var err error
+		token, tokenID, err = gateway.IssueContractToken()
+		if err != nil {
+			klog.Errorf("Error when issuing the Liqo token of Contract %s: %s", contract.Name, err)
+			return err
+		}
+		contract.Spec.SellerCredentials.TokenID = tokenID
+		if r.Gateway != nil && r.Gateway.ID != nil {
+			contract.Spec.SellerCredentials.Endpoint = r.Gateway.LiqoAuthEndpoint()
+		}
+	}
+
+	secret := resourceforge.ForgeCredentialsSecret(contract, token)
+	if err := r.Create(ctx, secret); apierrors.IsAlreadyExists(err) {
+		// A previous migration may have stored a different token, the Secret must match the TokenID of the Contract
+		existing := &corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(secret), existing); err != nil {
+			klog.Errorf("Error when getting the credentials Secret of Contract %s: %s", contract.Name, err)
+			return err
+		}
+		patch := client.MergeFrom(existing.DeepCopy())
+		existing.Data = secret.Data
+		if err := r.Patch(ctx, existing, patch); err != nil {
+			klog.Errorf("Error when updating the credentials Secret of Contract %s: %s", contract.Name, err)
+			return err
+		}
+	} else if err != nil {
+		klog.Errorf("Error when creating the credentials Secret of Contract %s: %s", contract.Name, err)
+		return err
+	}
+
+	contract.Spec.SellerCredentials.Token = ""
+	contract.Spec.SellerCredentials.TokenSecretRef = &nodecorev1alpha1.GenericRef{Name: secret.Name, Namespace: secret.Namespace}
+	if err := r.Update(ctx, contract); err != nil {
+		klog.Errorf("Error when updating Contract %s: %s", contract.Name, err)
+		return err
+	}
+	return nil
+}
+
+// isBuyer returns true if the local FLUIDOS Node is the buyer of the Contract
+func (r *ContractReconciler) isBuyer(ctx context.Context, contract *reservationv1alpha1.Contract) bool {
+	identity := getters.GetNodeIdentity(ctx, r.Client)
+	return identity != nil && contract.Spec.Buyer.NodeID == identity.NodeID
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ContractReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&reservationv1alpha1.Contract{}).
+		Complete(r)
+}
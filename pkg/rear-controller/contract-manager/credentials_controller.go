@@ -0,0 +1,117 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractmanager
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+)
+
+// CredentialsReaderReconciler keeps the Role of the readers of the Liqo credentials of the Contracts
+// scoped to the credentials Secrets, so that the Role does not grant any other Secret of the namespace
+type CredentialsReaderReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// clusterRole
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+
+// Reconcile sets the resourceNames of the credentials reader Role to the names of the credentials Secrets
+func (r *CredentialsReaderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var role rbacv1.Role
+	if err := r.Get(ctx, req.NamespacedName, &role); client.IgnoreNotFound(err) != nil {
+		klog.Errorf("Error when getting Role %s before reconcile: %s", req.NamespacedName, err)
+		return ctrl.Result{}, err
+	} else if err != nil {
+		klog.Infof("Role %s not found, probably not deployed", req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	var secrets corev1.SecretList
+	if err := r.List(ctx, &secrets, client.InNamespace(role.Namespace), client.HasLabels{consts.CONTRACT_LABEL}); err != nil {
+		klog.Errorf("Error when listing the credentials Secrets: %s", err)
+		return ctrl.Result{}, err
+	}
+
+	names := make([]string, 0, len(secrets.Items))
+	for i := range secrets.Items {
+		if secrets.Items[i].DeletionTimestamp.IsZero() {
+			names = append(names, secrets.Items[i].Name)
+		}
+	}
+	sort.Strings(names)
+
+	// A rule with no resourceNames grants every Secret, hence no rule is left when there are no credentials
+	var rules []rbacv1.PolicyRule
+	if len(names) > 0 {
+		rules = []rbacv1.PolicyRule{{
+			APIGroups:     []string{""},
+			Resources:     []string{"secrets"},
+			Verbs:         []string{"get"},
+			ResourceNames: names,
+		}}
+	}
+	if reflect.DeepEqual(role.Rules, rules) {
+		return ctrl.Result{}, nil
+	}
+
+	klog.Infof("Granting the readers of Role %s access to %d credentials Secrets", req.NamespacedName, len(names))
+	role.Rules = rules
+	if err := r.Update(ctx, &role); err != nil {
+		klog.Errorf("Error when updating Role %s: %s", req.NamespacedName, err)
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CredentialsReaderReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	roleKey := types.NamespacedName{Name: flags.CREDENTIALS_READER_ROLE, Namespace: flags.FLUIDOS_NAMESPACE}
+	isRole := predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return o.GetName() == roleKey.Name && o.GetNamespace() == roleKey.Namespace
+	})
+	isCredentials := predicate.NewPredicateFuncs(func(o client.Object) bool {
+		_, ok := o.GetLabels()[consts.CONTRACT_LABEL]
+		return ok && o.GetNamespace() == roleKey.Namespace
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("credentials-reader").
+		For(&rbacv1.Role{}, builder.WithPredicates(isRole)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(
+			func(context.Context, client.Object) []reconcile.Request {
+				return []reconcile.Request{{NamespacedName: roleKey}}
+			},
+		), builder.WithPredicates(isCredentials)).
+		Complete(r)
+}
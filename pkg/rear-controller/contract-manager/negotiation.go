@@ -0,0 +1,89 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractmanager
+
+import (
+	"fmt"
+	"math/big"
+
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/models"
+	"github.com/fluidos-project/node/pkg/utils/pricing"
+)
+
+var bigTwo = big.NewRat(2, 1)
+
+// evaluateCounterOffer compares the counter-offer of the seller with the maximum price of the Reservation.
+// It returns the next offer to send to the seller, or nil if the counter-offer is accepted.
+// An error is returned if the counter-offer is not acceptable and no further round is allowed.
+func evaluateCounterOffer(reservation *reservationv1alpha1.Reservation, transaction *models.Transaction) (*reservationv1alpha1.Offer, error) {
+	last := reservation.Spec.Offer
+	if reservation.Status.Offer != nil {
+		last = reservation.Status.Offer
+	}
+	if last == nil || transaction.Offer == nil {
+		return nil, fmt.Errorf("unexpected counter-offer for transaction %s", transaction.TransactionID)
+	}
+	if transaction.Offer.Currency != last.Currency {
+		return nil, fmt.Errorf("counter-offer in %s instead of %s", transaction.Offer.Currency, last.Currency)
+	}
+
+	counter, err := pricing.ParseAmount(transaction.Offer.Amount)
+	if err != nil {
+		return nil, err
+	}
+	// Compare the prices over the period of the offer of the buyer
+	if last.Period != "" {
+		if counter, err = pricing.Rescale(counter, transaction.Offer.Period, last.Period); err != nil {
+			return nil, err
+		}
+	}
+
+	maxPrice := reservation.Spec.MaxPrice
+	if maxPrice == "" {
+		maxPrice = reservation.Spec.Offer.Amount
+	}
+	limit, err := pricing.ParseAmount(maxPrice)
+	if err != nil {
+		return nil, err
+	}
+	if counter.Cmp(limit) <= 0 {
+		return nil, nil
+	}
+
+	// The seller tells how many further rounds it accepts
+	if transaction.Negotiation.RoundsLeft <= 0 {
+		return nil, fmt.Errorf("counter-offer of %s %s above the maximum price %s after %d rounds",
+			pricing.FormatAmount(counter), last.Currency, maxPrice, transaction.Negotiation.Round)
+	}
+
+	// Meet the seller halfway, without exceeding the maximum price
+	offered, err := pricing.ParseAmount(last.Amount)
+	if err != nil {
+		return nil, err
+	}
+	next := offered.Add(offered, counter)
+	next.Quo(next, bigTwo)
+	if next.Cmp(limit) > 0 {
+		next = limit
+	}
+
+	return &reservationv1alpha1.Offer{
+		Amount:   pricing.FormatAmount(next),
+		Currency: last.Currency,
+		Period:   last.Period,
+		Duration: last.Duration,
+	}, nil
+}
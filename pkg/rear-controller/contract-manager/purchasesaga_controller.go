@@ -0,0 +1,342 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractmanager
+
+import (
+	"context"
+	goerrors "errors"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	advertisementv1alpha1 "github.com/fluidos-project/node/apis/advertisement/v1alpha1"
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/rear-controller/gateway"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+// PurchaseSagaReconciler reconciles a PurchaseSaga object.
+// Each item of the saga is reserved through its own Reservation; the Reservations are allowed to purchase
+// only once every reserve has succeeded. If any step fails, the transactions already opened are cancelled
+// and the contracts already bought are terminated.
+type PurchaseSagaReconciler struct {
+	client.Client
+	Scheme  *runtime.Scheme
+	Gateway *gateway.Gateway
+}
+
+// clusterRole
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=purchasesagas,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=purchasesagas/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=purchasesagas/finalizers,verbs=update
+
+// Reconcile drives the PurchaseSaga through its steps. The progress is recorded in the PurchaseSaga status
+// and in the child Reservations, so that a restart resumes the saga or rolls it back.
+func (r *PurchaseSagaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx, "purchasesaga", req.NamespacedName)
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	var saga reservationv1alpha1.PurchaseSaga
+	if err := r.Get(ctx, req.NamespacedName, &saga); client.IgnoreNotFound(err) != nil {
+		klog.Errorf("Error when getting PurchaseSaga %s before reconcile: %s", req.NamespacedName, err)
+		return ctrl.Result{}, err
+	} else if err != nil {
+		klog.Infof("PurchaseSaga %s not found, probably deleted", req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	switch saga.Status.Step {
+	case "":
+		klog.Infof("PurchaseSaga %s started", saga.Name)
+		saga.SetPhase(nodecorev1alpha1.PhaseRunning, "PurchaseSaga started")
+		saga.SetStep(reservationv1alpha1.SagaStepReserve, "Reserving the Flavours")
+		for i := range saga.Spec.Items {
+			saga.GetItemStatus(saga.Spec.Items[i].Name)
+		}
+		return ctrl.Result{}, r.Status().Update(ctx, &saga)
+	case reservationv1alpha1.SagaStepReserve:
+		return r.reserve(ctx, &saga)
+	case reservationv1alpha1.SagaStepPurchase:
+		return r.purchase(ctx, &saga)
+	case reservationv1alpha1.SagaStepCompensate:
+		return r.compensate(ctx, &saga)
+	default:
+		return ctrl.Result{}, nil
+	}
+}
+
+// reserve creates a Reservation for each item and waits for all the reserves to succeed
+func (r *PurchaseSagaReconciler) reserve(ctx context.Context, saga *reservationv1alpha1.PurchaseSaga) (ctrl.Result, error) {
+	reserved := 0
+	for i := range saga.Spec.Items {
+		item := &saga.Spec.Items[i]
+		itemStatus := saga.GetItemStatus(item.Name)
+
+		reservation, err := r.getOrCreateReservation(ctx, saga, item)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		itemStatus.Reservation = nodecorev1alpha1.GenericRef{Name: reservation.Name, Namespace: reservation.Namespace}
+
+		switch {
+		case reservation.Status.ReservePhase == nodecorev1alpha1.PhaseSolved:
+			itemStatus.TransactionID = reservation.Status.TransactionID
+			itemStatus.Message = "Reserved"
+			reserved++
+		case reservation.Status.ReservePhase == nodecorev1alpha1.PhaseFailed || reservation.Status.Phase.Phase == nodecorev1alpha1.PhaseFailed:
+			itemStatus.Message = reservation.Status.Phase.Message
+			klog.Infof("PurchaseSaga %s: reserve of %s failed, rolling back", saga.Name, item.Name)
+			saga.SetStep(reservationv1alpha1.SagaStepCompensate, "Reserve of "+item.Name+" failed: "+reservation.Status.Phase.Message)
+			return ctrl.Result{}, r.Status().Update(ctx, saga)
+		}
+	}
+
+	if reserved < len(saga.Spec.Items) {
+		return ctrl.Result{}, r.Status().Update(ctx, saga)
+	}
+
+	klog.Infof("PurchaseSaga %s: every Flavour reserved, purchasing", saga.Name)
+	saga.SetStep(reservationv1alpha1.SagaStepPurchase, "Purchasing the Flavours")
+	return ctrl.Result{}, r.Status().Update(ctx, saga)
+}
+
+// purchase lets every Reservation purchase its Flavour and waits for all the contracts
+func (r *PurchaseSagaReconciler) purchase(ctx context.Context, saga *reservationv1alpha1.PurchaseSaga) (ctrl.Result, error) {
+	purchased := 0
+	for i := range saga.Spec.Items {
+		item := &saga.Spec.Items[i]
+		itemStatus := saga.GetItemStatus(item.Name)
+
+		var reservation reservationv1alpha1.Reservation
+		if err := r.Get(ctx, client.ObjectKey{Name: itemStatus.Reservation.Name, Namespace: itemStatus.Reservation.Namespace}, &reservation); err != nil {
+			if errors.IsNotFound(err) {
+				saga.SetStep(reservationv1alpha1.SagaStepCompensate, "Reservation of "+item.Name+" not found")
+				return ctrl.Result{}, r.Status().Update(ctx, saga)
+			}
+			return ctrl.Result{}, err
+		}
+
+		// The purchase is allowed only now, also when the saga is resumed after a restart
+		if !reservation.Spec.Purchase {
+			reservation.Spec.Purchase = true
+			if err := r.Update(ctx, &reservation); err != nil {
+				klog.Errorf("Error when updating Reservation %s: %s", reservation.Name, err)
+				return ctrl.Result{}, err
+			}
+			continue
+		}
+
+		switch reservation.Status.PurchasePhase {
+		case nodecorev1alpha1.PhaseSolved:
+			itemStatus.Contract = reservation.Status.Contract
+			itemStatus.Message = "Purchased"
+			purchased++
+		case nodecorev1alpha1.PhaseFailed:
+			itemStatus.Message = reservation.Status.Phase.Message
+			klog.Infof("PurchaseSaga %s: purchase of %s failed, rolling back", saga.Name, item.Name)
+			saga.SetStep(reservationv1alpha1.SagaStepCompensate, "Purchase of "+item.Name+" failed: "+reservation.Status.Phase.Message)
+			return ctrl.Result{}, r.Status().Update(ctx, saga)
+		}
+	}
+
+	if purchased < len(saga.Spec.Items) {
+		return ctrl.Result{}, r.Status().Update(ctx, saga)
+	}
+
+	klog.Infof("PurchaseSaga %s completed", saga.Name)
+	saga.SetPhase(nodecorev1alpha1.PhaseSolved, "Every Flavour purchased")
+	saga.SetStep(reservationv1alpha1.SagaStepDone, "Every Flavour purchased")
+	return ctrl.Result{}, r.Status().Update(ctx, saga)
+}
+
+// compensate cancels the open transactions and terminates the contracts of the items already processed
+func (r *PurchaseSagaReconciler) compensate(ctx context.Context, saga *reservationv1alpha1.PurchaseSaga) (ctrl.Result, error) {
+	pending := false
+	for i := range saga.Spec.Items {
+		item := &saga.Spec.Items[i]
+		itemStatus := saga.GetItemStatus(item.Name)
+		if itemStatus.Compensated {
+			continue
+		}
+
+		// The compensating actions are retried until they succeed, the saga cannot be left half done
+		done, err := r.compensateItem(ctx, saga, item, itemStatus)
+		if err != nil {
+			klog.Errorf("PurchaseSaga %s: error when compensating %s: %s", saga.Name, item.Name, err)
+			itemStatus.Message = "Compensation failed, retrying: " + err.Error()
+		}
+		itemStatus.Compensated = done
+		if !done {
+			pending = true
+		}
+	}
+
+	if pending {
+		if err := r.Status().Update(ctx, saga); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: flags.RETRY_RESERVATION_INTERVAL}, nil
+	}
+
+	klog.Infof("PurchaseSaga %s rolled back", saga.Name)
+	saga.SetPhase(nodecorev1alpha1.PhaseFailed, "PurchaseSaga rolled back: "+saga.Status.Phase.Message)
+	saga.SetStep(reservationv1alpha1.SagaStepDone, saga.Status.Phase.Message)
+	return ctrl.Result{}, r.Status().Update(ctx, saga)
+}
+
+// compensateItem undoes what the Reservation of the item has obtained. It returns false if the item must be checked again later.
+func (r *PurchaseSagaReconciler) compensateItem(ctx context.Context, saga *reservationv1alpha1.PurchaseSaga,
+	item *reservationv1alpha1.PurchaseSagaItem, itemStatus *reservationv1alpha1.PurchaseSagaItemStatus) (bool, error) {
+	var reservation reservationv1alpha1.Reservation
+	if err := r.Get(ctx, client.ObjectKey{Name: itemStatus.Reservation.Name, Namespace: itemStatus.Reservation.Namespace}, &reservation); err != nil {
+		if errors.IsNotFound(err) || itemStatus.Reservation.Name == "" {
+			itemStatus.Message = "Nothing to compensate"
+			return true, nil
+		}
+		return false, err
+	}
+
+	switch {
+	case reservation.Status.PurchasePhase == nodecorev1alpha1.PhaseSolved:
+		contractName := reservation.Status.Contract.Name
+		reason := "PurchaseSaga " + saga.Name + " rolled back"
+		if _, err := r.Gateway.TerminateContract(ctx, contractName, reason, item.Seller); err != nil {
+			var problem *gateway.ProblemError
+			if !goerrors.As(err, &problem) || problem.Retryable() || problem.Code != models.CONTRACT_NOT_ACTIVE {
+				return false, err
+			}
+		}
+		r.setContractTerminated(ctx, reservation.Status.Contract, reason)
+		itemStatus.Message = "Contract " + contractName + " terminated"
+		return true, nil
+
+	case reservation.Spec.Purchase && reservation.Status.PurchasePhase != nodecorev1alpha1.PhaseFailed &&
+		reservation.Status.ReservePhase != nodecorev1alpha1.PhaseFailed:
+		// The purchase has been enabled and may be in progress, even if its phase has not been updated yet:
+		// its outcome decides what to compensate
+		return false, nil
+
+	case reservation.Status.ReservePhase == nodecorev1alpha1.PhaseSolved && reservation.Status.PurchasePhase != nodecorev1alpha1.PhaseFailed:
+		transactionID := reservation.Status.TransactionID
+		if err := r.Gateway.CancelReservation(ctx, transactionID, item.Seller); err != nil {
+			var problem *gateway.ProblemError
+			if !goerrors.As(err, &problem) || problem.Retryable() ||
+				(problem.Code != models.TRANSACTION_NOT_FOUND && problem.Code != models.TRANSACTION_EXPIRED) {
+				return false, err
+			}
+		}
+		setTransactionPhase(ctx, r.Client, transactionID, nodecorev1alpha1.PhaseCancelled, "PurchaseSaga "+saga.Name+" rolled back")
+		if err := r.Delete(ctx, &reservation); client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+		itemStatus.Message = "Transaction " + transactionID + " cancelled"
+		return true, nil
+
+	case reservation.Status.PurchasePhase == nodecorev1alpha1.PhaseFailed:
+		// The Reservation controller has already released the transaction
+		itemStatus.Message = "Purchase failed, transaction released"
+		return true, nil
+
+	default:
+		// The reserve has failed or has not completed yet: stop it, an open transaction would expire on the seller
+		if err := r.Delete(ctx, &reservation); client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+		itemStatus.Message = "Reservation stopped"
+		return true, nil
+	}
+}
+
+// getOrCreateReservation returns the Reservation of the item, creating it with the purchase disabled if missing
+func (r *PurchaseSagaReconciler) getOrCreateReservation(ctx context.Context, saga *reservationv1alpha1.PurchaseSaga,
+	item *reservationv1alpha1.PurchaseSagaItem) (*reservationv1alpha1.Reservation, error) {
+	reservation := &reservationv1alpha1.Reservation{}
+	key := client.ObjectKey{Name: saga.Name + "-" + item.Name, Namespace: saga.Namespace}
+	err := r.Get(ctx, key, reservation)
+	if err == nil {
+		return reservation, nil
+	}
+	if !errors.IsNotFound(err) {
+		klog.Errorf("Error when getting Reservation %s: %s", key, err)
+		return nil, err
+	}
+
+	// The key of the seller, when not given, is the one published with the Flavour of the PeeringCandidate
+	seller := item.Seller
+	if seller.PublicKey == "" {
+		peeringCandidate := &advertisementv1alpha1.PeeringCandidate{}
+		if err := r.Get(ctx, client.ObjectKey{Name: item.PeeringCandidate.Name, Namespace: item.PeeringCandidate.Namespace}, peeringCandidate); err != nil {
+			klog.Errorf("Error when getting PeeringCandidate %s: %s", item.PeeringCandidate.Name, err)
+			return nil, err
+		}
+		if peeringCandidate.Spec.Flavour.Spec.Owner.NodeID == seller.NodeID {
+			seller.PublicKey = peeringCandidate.Spec.Flavour.Spec.Owner.PublicKey
+		}
+	}
+
+	reservation = &reservationv1alpha1.Reservation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+		},
+		Spec: reservationv1alpha1.ReservationSpec{
+			SolverID:         saga.Spec.SolverID,
+			Buyer:            saga.Spec.Buyer,
+			BuyerClusterID:   saga.Spec.BuyerClusterID,
+			Seller:           seller,
+			Partition:        item.Partition,
+			PeeringCandidate: item.PeeringCandidate,
+			Reserve:          true,
+			Purchase:         false,
+		},
+	}
+	if err := controllerutil.SetControllerReference(saga, reservation, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Create(ctx, reservation); err != nil {
+		klog.Errorf("Error when creating Reservation %s: %s", key, err)
+		return nil, err
+	}
+	klog.Infof("PurchaseSaga %s: Reservation %s created", saga.Name, reservation.Name)
+	return reservation, nil
+}
+
+// setContractTerminated records the termination of the buyer Contract
+func (r *PurchaseSagaReconciler) setContractTerminated(ctx context.Context, ref nodecorev1alpha1.GenericRef, reason string) {
+	var contract reservationv1alpha1.Contract
+	if err := r.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: ref.Namespace}, &contract); err != nil {
+		klog.Errorf("Error when getting Contract %s: %s", ref.Name, err)
+		return
+	}
+	contract.Terminate(reservationv1alpha1.ContractPartyBuyer, reason)
+	if err := r.Status().Update(ctx, &contract); err != nil {
+		klog.Errorf("Error when updating Contract %s status: %s", ref.Name, err)
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PurchaseSagaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&reservationv1alpha1.PurchaseSaga{}).
+		Owns(&reservationv1alpha1.Reservation{}).
+		Complete(r)
+}
@@ -18,6 +18,7 @@ package contractmanager
 
 import (
 	"context"
+	goerrors "errors"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -29,6 +30,9 @@ import (
 	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
 	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
 	"github.com/fluidos-project/node/pkg/rear-controller/gateway"
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
 	"github.com/fluidos-project/node/pkg/utils/namings"
 	"github.com/fluidos-project/node/pkg/utils/resourceforge"
 	"github.com/fluidos-project/node/pkg/utils/tools"
@@ -113,15 +117,24 @@ func (r *ReservationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		case nodecorev1alpha1.PhaseRunning:
 			klog.Infof("Reservation %s: Reserve phase running", reservation.Name)
 			flavourID := namings.RetrieveFlavourNameFromPC(reservation.Spec.PeeringCandidate.Name)
-			res, err := r.Gateway.ReserveFlavour(ctx, &reservation, flavourID)
+
+			// Check that the candidate is still offered by the seller before reserving it
+			var res *models.Transaction
+			_, _, err := r.Gateway.GetFlavourByID(ctx, reservation.Spec.Seller, flavourID, "")
+			if err == nil {
+				res, err = r.Gateway.ReserveFlavour(ctx, &reservation, flavourID)
+			}
 			if err != nil {
 				if res != nil {
 					klog.Infof("Transaction is non correctly set, Retrying...")
 					return ctrl.Result{Requeue: true}, nil
 				}
 				klog.Errorf("Error when reserving flavour for Reservation %s: %s", req.NamespacedName, err)
+				if gateway.IsRetryable(err) {
+					return r.retryReservation(ctx, &reservation, "Reserve failed, retrying: "+err.Error(), err)
+				}
 				reservation.SetReserveStatus(nodecorev1alpha1.PhaseFailed)
-				reservation.SetPhase(nodecorev1alpha1.PhaseFailed, "Reservation failed: error when reserving flavour")
+				reservation.SetPhase(nodecorev1alpha1.PhaseFailed, "Reservation failed: error when reserving flavour: "+err.Error())
 				if err := r.updateReservationStatus(ctx, &reservation); err != nil {
 					klog.Errorf("Error when updating Reservation %s status: %s", req.NamespacedName, err)
 					return ctrl.Result{}, err
@@ -131,14 +144,51 @@ func (r *ReservationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 			klog.Infof("Transaction: %v", res)
 
+			if res.Negotiation != nil && res.Negotiation.State == string(reservationv1alpha1.NegotiationCountered) {
+				next, err := evaluateCounterOffer(&reservation, res)
+				if err != nil {
+					klog.Infof("Negotiation for Reservation %s failed: %s", req.NamespacedName, err)
+					if err := r.Gateway.CancelReservation(ctx, res.TransactionID, reservation.Spec.Seller); err != nil {
+						klog.Errorf("Error when cancelling the reservation of transaction %s: %s", res.TransactionID, err)
+					}
+					reservation.SetReserveStatus(nodecorev1alpha1.PhaseFailed)
+					reservation.SetPhase(nodecorev1alpha1.PhaseFailed, "Reservation failed: negotiation failed: "+err.Error())
+					if err := r.updateReservationStatus(ctx, &reservation); err != nil {
+						klog.Errorf("Error when updating Reservation %s status: %s", req.NamespacedName, err)
+						return ctrl.Result{}, err
+					}
+					return ctrl.Result{}, nil
+				}
+				if next != nil {
+					klog.Infof("Counter-offer of %s %s for Reservation %s, offering %s %s", res.Offer.Amount, res.Offer.Currency,
+						req.NamespacedName, next.Amount, next.Currency)
+					reservation.Status.Offer = next
+					reservation.Status.NegotiationRound = res.Negotiation.Round
+					reservation.SetPhase(nodecorev1alpha1.PhaseRunning, "Negotiating: counter-offer of "+res.Offer.Amount+" "+res.Offer.Currency)
+					if err := r.updateReservationStatus(ctx, &reservation); err != nil {
+						klog.Errorf("Error when updating Reservation %s status: %s", req.NamespacedName, err)
+						return ctrl.Result{}, err
+					}
+					return ctrl.Result{Requeue: true}, nil
+				}
+				klog.Infof("Counter-offer of %s %s for Reservation %s accepted", res.Offer.Amount, res.Offer.Currency, req.NamespacedName)
+			}
+
 			// Create a Transaction CR starting from the transaction object
 			transaction := resourceforge.ForgeTransactionFromObj(res)
+			transaction.Labels = map[string]string{consts.TRANSACTION_ROLE_LABEL: consts.TRANSACTION_ROLE_BUYER}
 
 			if err := r.Create(ctx, transaction); err != nil {
 				klog.Errorf("Error when creating Transaction %s: %s", transaction.Name, err)
 				return ctrl.Result{}, err
 			}
 
+			transaction.SetPhase(nodecorev1alpha1.PhaseReserved, "Transaction reserved")
+			if err := r.Status().Update(ctx, transaction); err != nil {
+				klog.Errorf("Error when updating Transaction %s status: %s", transaction.Name, err)
+				return ctrl.Result{}, err
+			}
+
 			klog.Infof("Transaction %s created", transaction.Name)
 			reservation.Status.TransactionID = res.TransactionID
 			reservation.SetReserveStatus(nodecorev1alpha1.PhaseSolved)
@@ -199,11 +249,24 @@ func (r *ReservationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			}
 
 			transactionID := reservation.Status.TransactionID
-			resPurchase, err := r.Gateway.PurchaseFlavour(ctx, transactionID, reservation.Spec.Seller)
+			resPurchase, token, err := r.Gateway.PurchaseFlavour(ctx, transactionID, reservation.Spec.Seller)
 			if err != nil {
 				klog.Errorf("Error when purchasing flavour for Reservation %s: %s", req.NamespacedName, err)
+				if gateway.IsRetryable(err) {
+					return r.retryReservation(ctx, &reservation, "Purchase failed, retrying: "+err.Error(), err)
+				}
+				var problem *gateway.ProblemError
+				if goerrors.As(err, &problem) && problem.Code == models.TRANSACTION_EXPIRED {
+					setTransactionPhase(ctx, r.Client, transactionID, nodecorev1alpha1.PhaseExpired, "Transaction expired before the purchase")
+				} else {
+					// Release the reservation on the seller, the purchase will not be retried
+					if err := r.Gateway.CancelReservation(ctx, transactionID, reservation.Spec.Seller); err != nil {
+						klog.Errorf("Error when cancelling the reservation of transaction %s: %s", transactionID, err)
+					}
+					setTransactionPhase(ctx, r.Client, transactionID, nodecorev1alpha1.PhaseCancelled, "Purchase failed: "+err.Error())
+				}
 				reservation.SetPurchaseStatus(nodecorev1alpha1.PhaseFailed)
-				reservation.SetPhase(nodecorev1alpha1.PhaseFailed, "Reservation failed: error when purchasing flavour")
+				reservation.SetPhase(nodecorev1alpha1.PhaseFailed, "Reservation failed: error when purchasing flavour: "+err.Error())
 				if err := r.updateReservationStatus(ctx, &reservation); err != nil {
 					klog.Errorf("Error when updating Reservation %s status: %s", req.NamespacedName, err)
 					return ctrl.Result{}, err
@@ -213,6 +276,8 @@ func (r *ReservationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 			klog.Infof("Purchase completed with status %s", resPurchase.Status)
 
+			setTransactionPhase(ctx, r.Client, transactionID, nodecorev1alpha1.PhasePurchased, "Transaction purchased")
+
 			reservation.SetPurchaseStatus(nodecorev1alpha1.PhaseRunning)
 
 			if err := r.Update(ctx, &reservation); err != nil {
@@ -227,11 +292,26 @@ func (r *ReservationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			err = r.Create(ctx, contract)
 			if errors.IsAlreadyExists(err) {
 				klog.Errorf("Error when creating Contract %s: %s", contract.Name, err)
+				if err := r.Get(ctx, client.ObjectKeyFromObject(contract), contract); err != nil {
+					klog.Errorf("Error when getting Contract %s: %s", contract.Name, err)
+					return ctrl.Result{}, err
+				}
 			} else if err != nil {
 				klog.Errorf("Error when creating Contract %s: %s", contract.Name, err)
 				return ctrl.Result{}, err
+			} else {
+				klog.Infof("Contract %s created", contract.Name)
+				contract.SetPhase(nodecorev1alpha1.PhaseActive, "Contract active")
+				if err := r.Status().Update(ctx, contract); err != nil {
+					klog.Errorf("Error when updating Contract %s status: %s", contract.Name, err)
+				}
+			}
+
+			// The Liqo token of the seller is kept in a Secret owned by the Contract
+			if err := r.Create(ctx, resourceforge.ForgeCredentialsSecret(contract, token)); err != nil && !errors.IsAlreadyExists(err) {
+				klog.Errorf("Error when storing the credentials of Contract %s: %s", contract.Name, err)
+				return ctrl.Result{}, err
 			}
-			klog.Infof("Contract %s created", contract.Name)
 
 			reservation.SetPurchaseStatus(nodecorev1alpha1.PhaseSolved)
 			reservation.Status.Contract = nodecorev1alpha1.GenericRef{
@@ -267,6 +347,35 @@ func (r *ReservationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	return ctrl.Result{}, nil
 }
 
+// retryReservation records the reason of a retryable failure in the Reservation status and requeues it
+func (r *ReservationReconciler) retryReservation(ctx context.Context, reservation *reservationv1alpha1.Reservation, message string, err error) (ctrl.Result, error) {
+	reservation.SetPhase(nodecorev1alpha1.PhaseRunning, message)
+	if err := r.updateReservationStatus(ctx, reservation); err != nil {
+		klog.Errorf("Error when updating Reservation %s status: %s", reservation.Name, err)
+		return ctrl.Result{}, err
+	}
+
+	retryAfter := flags.RETRY_RESERVATION_INTERVAL
+	var problem *gateway.ProblemError
+	if goerrors.As(err, &problem) && problem.RetryAfter > 0 {
+		retryAfter = problem.RetryAfter
+	}
+	return ctrl.Result{RequeueAfter: retryAfter}, nil
+}
+
+// setTransactionPhase records the phase of the buyer Transaction
+func setTransactionPhase(ctx context.Context, c client.Client, transactionID string, phase nodecorev1alpha1.Phase, msg string) {
+	var transaction reservationv1alpha1.Transaction
+	if err := c.Get(ctx, client.ObjectKey{Name: transactionID, Namespace: flags.FLUIDOS_NAMESPACE}, &transaction); err != nil {
+		klog.Errorf("Error when getting Transaction %s: %s", transactionID, err)
+		return
+	}
+	transaction.SetPhase(phase, msg)
+	if err := c.Status().Update(ctx, &transaction); err != nil {
+		klog.Errorf("Error when updating Transaction %s status: %s", transactionID, err)
+	}
+}
+
 // updateSolverStatus updates the status of the discovery
 func (r *ReservationReconciler) updateReservationStatus(ctx context.Context, reservation *reservationv1alpha1.Reservation) error {
 	return r.Status().Update(ctx, reservation)
@@ -0,0 +1,361 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractmanager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	advertisementv1alpha1 "github.com/fluidos-project/node/apis/advertisement/v1alpha1"
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/rear-controller/gateway"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/getters"
+	"github.com/fluidos-project/node/pkg/utils/models"
+	"github.com/fluidos-project/node/pkg/utils/parseutil"
+	"github.com/fluidos-project/node/pkg/utils/pricing"
+	"github.com/fluidos-project/node/pkg/utils/resourceforge"
+	"github.com/fluidos-project/node/pkg/utils/tools"
+)
+
+// RequestForQuoteReconciler reconciles a RequestForQuote object.
+// The request is broadcast to every known provider, whose sealed bids are collected until the deadline.
+// The bid with the lowest cost over the requested duration is then awarded and turned into a Reservation
+// offering the price of the bid.
+type RequestForQuoteReconciler struct {
+	client.Client
+	Scheme  *runtime.Scheme
+	Gateway *gateway.Gateway
+}
+
+// clusterRole
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=requestforquotes,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=requestforquotes/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=requestforquotes/finalizers,verbs=update
+//+kubebuilder:rbac:groups=advertisement.fluidos.eu,resources=peeringcandidates,verbs=get;list;watch;create;update;patch
+
+// Reconcile drives the RequestForQuote through its steps. The bids are recorded in the RequestForQuote status
+// before the award, so that the history of the auction is kept for audit.
+func (r *RequestForQuoteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx, "requestforquote", req.NamespacedName)
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	var rfq reservationv1alpha1.RequestForQuote
+	if err := r.Get(ctx, req.NamespacedName, &rfq); client.IgnoreNotFound(err) != nil {
+		klog.Errorf("Error when getting RequestForQuote %s before reconcile: %s", req.NamespacedName, err)
+		return ctrl.Result{}, err
+	} else if err != nil {
+		klog.Infof("RequestForQuote %s not found, probably deleted", req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	switch rfq.Status.Step {
+	case "":
+		return r.start(ctx, &rfq)
+	case reservationv1alpha1.RFQStepBidding:
+		return r.collectBids(ctx, &rfq)
+	case reservationv1alpha1.RFQStepAwarding:
+		return r.award(ctx, &rfq)
+	default:
+		return ctrl.Result{}, nil
+	}
+}
+
+// start validates the request and sets the deadline of the bids
+func (r *RequestForQuoteReconciler) start(ctx context.Context, rfq *reservationv1alpha1.RequestForQuote) (ctrl.Result, error) {
+	klog.Infof("RequestForQuote %s started", rfq.Name)
+
+	window := flags.RFQ_BIDDING_WINDOW
+	var err error
+	if rfq.Spec.BiddingWindow != "" {
+		window, err = pricing.ParsePeriod(rfq.Spec.BiddingWindow)
+	}
+	if err == nil {
+		_, err = pricing.ParsePeriod(rfq.Spec.Duration)
+	}
+	if err != nil {
+		rfq.SetPhase(nodecorev1alpha1.PhaseFailed, "Invalid RequestForQuote: "+err.Error())
+		rfq.SetStep(reservationv1alpha1.RFQStepDone, rfq.Status.Phase.Message)
+		return ctrl.Result{}, r.Status().Update(ctx, rfq)
+	}
+
+	rfq.Status.Deadline = time.Now().Add(window).Format(time.RFC3339)
+	rfq.SetPhase(nodecorev1alpha1.PhaseRunning, "RequestForQuote started")
+	rfq.SetStep(reservationv1alpha1.RFQStepBidding, "Collecting the bids until "+rfq.Status.Deadline)
+	return ctrl.Result{}, r.Status().Update(ctx, rfq)
+}
+
+// collectBids broadcasts the request to the providers and records their bids, waiting for them until the deadline.
+// The request is broadcast once: if the bids of the deadline are already recorded, they are awarded.
+func (r *RequestForQuoteReconciler) collectBids(ctx context.Context, rfq *reservationv1alpha1.RequestForQuote) (ctrl.Result, error) {
+	if len(rfq.Status.Bids) > 0 {
+		rfq.SetStep(reservationv1alpha1.RFQStepAwarding, fmt.Sprintf("%d bids recorded", len(rfq.Status.Bids)))
+		return ctrl.Result{}, r.Status().Update(ctx, rfq)
+	}
+
+	deadline, err := time.Parse(time.RFC3339, rfq.Status.Deadline)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	request := models.RFQRequest{
+		RFQID:    string(rfq.UID),
+		Duration: rfq.Spec.Duration,
+		Currency: rfq.Spec.Currency,
+		Deadline: rfq.Status.Deadline,
+	}
+	if rfq.Spec.Selector != nil {
+		request.Selector = parseutil.ParseFlavourSelector(rfq.Spec.Selector)
+	}
+
+	bidCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	var providers []string
+	for _, provider := range getters.GetLocalProviders(ctx, r.Client) {
+		if provider != "" {
+			providers = append(providers, provider)
+		}
+	}
+
+	klog.Infof("RequestForQuote %s: broadcasting to %d providers", rfq.Name, len(providers))
+
+	// The providers bid independently: none of them sees the bids of the others
+	bids := make([]reservationv1alpha1.Bid, len(providers))
+	var wg sync.WaitGroup
+	for i := range providers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bid, err := r.Gateway.RequestQuote(bidCtx, providers[i], request)
+			bids[i] = forgeBid(providers[i], bid, err)
+		}(i)
+	}
+	wg.Wait()
+
+	received := 0
+	for i := range bids {
+		if bids[i].State == reservationv1alpha1.BidReceived {
+			received++
+		}
+	}
+	rfq.Status.Bids = bids
+
+	klog.Infof("RequestForQuote %s: %d bids received", rfq.Name, received)
+	rfq.SetStep(reservationv1alpha1.RFQStepAwarding, fmt.Sprintf("%d bids received from %d providers", received, len(providers)))
+	return ctrl.Result{}, r.Status().Update(ctx, rfq)
+}
+
+// award awards the valid bid with the lowest cost and creates the Reservation of its Flavour
+func (r *RequestForQuoteReconciler) award(ctx context.Context, rfq *reservationv1alpha1.RequestForQuote) (ctrl.Result, error) {
+	winner := r.selectBid(rfq)
+	if winner == nil {
+		klog.Infof("RequestForQuote %s: no valid bid", rfq.Name)
+		rfq.SetPhase(nodecorev1alpha1.PhaseFailed, "No valid bid received")
+		rfq.SetStep(reservationv1alpha1.RFQStepDone, rfq.Status.Phase.Message)
+		return ctrl.Result{}, r.Status().Update(ctx, rfq)
+	}
+
+	// The Flavour is retrieved again from the provider the request was sent to, to check it is still offered before reserving it
+	provider := winner.Seller
+	provider.IP = winner.Provider
+	flavour, _, err := r.Gateway.GetFlavourByID(ctx, provider, winner.FlavourID, "")
+	if err != nil {
+		if gateway.IsRetryable(err) {
+			klog.Errorf("RequestForQuote %s: error when getting Flavour %s: %s", rfq.Name, winner.FlavourID, err)
+			return ctrl.Result{RequeueAfter: flags.RETRY_RESERVATION_INTERVAL}, nil
+		}
+		// The next best bid is evaluated on the next reconcile
+		winner.State = reservationv1alpha1.BidInvalid
+		winner.Message = "Flavour no longer available: " + err.Error()
+		return ctrl.Result{}, r.Status().Update(ctx, rfq)
+	}
+
+	// The key of the bid is learnt from the bid itself: it must be the key of the owner of the Flavour offered
+	owner := flavour.Spec.Owner
+	if owner.NodeID != winner.Seller.NodeID || owner.PublicKey == "" || owner.PublicKey != winner.Seller.PublicKey {
+		winner.State = reservationv1alpha1.BidInvalid
+		winner.Message = "The bid is not signed by the owner of Flavour " + winner.FlavourID
+		return ctrl.Result{}, r.Status().Update(ctx, rfq)
+	}
+
+	pc, err := r.reservePeeringCandidate(ctx, rfq, flavour)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if pc == nil {
+		winner.State = reservationv1alpha1.BidInvalid
+		winner.Message = "Flavour already reserved by another solver"
+		return ctrl.Result{}, r.Status().Update(ctx, rfq)
+	}
+
+	nodeIdentity := getters.GetNodeIdentity(ctx, r.Client)
+	if nodeIdentity == nil {
+		return ctrl.Result{}, fmt.Errorf("node identity not found")
+	}
+
+	reservation := resourceforge.ForgeReservation(*pc, winner.Partition, *nodeIdentity)
+	reservation.Name = rfq.Name
+	reservation.Namespace = rfq.Namespace
+	reservation.Spec.SolverID = rfq.Spec.SolverID
+	reservation.Spec.Purchase = rfq.Spec.Purchase
+	reservation.Spec.Offer = winner.Price.DeepCopy()
+	reservation.Spec.Seller.IP = winner.Provider
+	if err := controllerutil.SetControllerReference(rfq, reservation, r.Scheme); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.Create(ctx, reservation); err != nil && !errors.IsAlreadyExists(err) {
+		klog.Errorf("Error when creating Reservation %s: %s", reservation.Name, err)
+		return ctrl.Result{}, err
+	}
+
+	for i := range rfq.Status.Bids {
+		if bid := &rfq.Status.Bids[i]; bid.State == reservationv1alpha1.BidReceived && bid != winner {
+			bid.State = reservationv1alpha1.BidLost
+		}
+	}
+	winner.State = reservationv1alpha1.BidAwarded
+	winner.Message = "Reservation " + reservation.Name + " created"
+
+	klog.Infof("RequestForQuote %s: bid of %s awarded at %s %s", rfq.Name, winner.Seller.NodeID, winner.TotalCost, winner.Price.Currency)
+	rfq.Status.PeeringCandidate = nodecorev1alpha1.GenericRef{Name: pc.Name, Namespace: pc.Namespace}
+	rfq.Status.Reservation = nodecorev1alpha1.GenericRef{Name: reservation.Name, Namespace: reservation.Namespace}
+	rfq.SetPhase(nodecorev1alpha1.PhaseSolved, "Bid of "+winner.Seller.NodeID+" awarded")
+	rfq.SetStep(reservationv1alpha1.RFQStepDone, rfq.Status.Phase.Message)
+	return ctrl.Result{}, r.Status().Update(ctx, rfq)
+}
+
+// selectBid evaluates the received bids and returns the one with the lowest total cost, the earliest one winning ties.
+// The bids that cannot be awarded are marked as invalid.
+func (r *RequestForQuoteReconciler) selectBid(rfq *reservationv1alpha1.RequestForQuote) *reservationv1alpha1.Bid {
+	duration, _ := pricing.ParsePeriod(rfq.Spec.Duration)
+
+	var winner *reservationv1alpha1.Bid
+	for i := range rfq.Status.Bids {
+		bid := &rfq.Status.Bids[i]
+		if bid.State != reservationv1alpha1.BidReceived {
+			continue
+		}
+		if bid.Price == nil || bid.Price.Currency != rfq.Spec.Currency {
+			bid.State, bid.Message = reservationv1alpha1.BidInvalid, "The bid is not expressed in "+rfq.Spec.Currency
+			continue
+		}
+		if tools.CheckExpiration(bid.ValidUntil, 0) {
+			bid.State, bid.Message = reservationv1alpha1.BidInvalid, "The bid expired at "+bid.ValidUntil
+			continue
+		}
+
+		amount, err := pricing.ParseAmount(bid.Price.Amount)
+		if err == nil {
+			amount, err = pricing.Prorate(amount, bid.Price.Period, duration)
+		}
+		if err != nil {
+			bid.State, bid.Message = reservationv1alpha1.BidInvalid, "Invalid price: "+err.Error()
+			continue
+		}
+		bid.TotalCost = pricing.FormatAmount(amount)
+
+		if winner == nil {
+			winner = bid
+			continue
+		}
+		best, _ := pricing.ParseAmount(winner.TotalCost)
+		if amount.Cmp(best) < 0 {
+			winner = bid
+		}
+	}
+	return winner
+}
+
+// reservePeeringCandidate creates the PeeringCandidate of the Flavour reserved for the solver of the request.
+// It returns nil if the PeeringCandidate is already reserved by another solver.
+func (r *RequestForQuoteReconciler) reservePeeringCandidate(ctx context.Context, rfq *reservationv1alpha1.RequestForQuote,
+	flavour *nodecorev1alpha1.Flavour) (*advertisementv1alpha1.PeeringCandidate, error) {
+	pc := resourceforge.ForgePeeringCandidate(flavour, rfq.Spec.SolverID, true)
+	err := r.Create(ctx, pc)
+	if err == nil {
+		return pc, nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		klog.Errorf("Error when creating PeeringCandidate %s: %s", pc.Name, err)
+		return nil, err
+	}
+
+	if err := r.Get(ctx, client.ObjectKeyFromObject(pc), pc); err != nil {
+		return nil, err
+	}
+	switch {
+	case pc.Spec.Reserved && pc.Spec.SolverID == rfq.Spec.SolverID:
+		return pc, nil
+	case pc.Spec.Reserved:
+		return nil, nil
+	}
+	pc.Spec.Reserved = true
+	pc.Spec.SolverID = rfq.Spec.SolverID
+	if err := r.Update(ctx, pc); err != nil {
+		klog.Errorf("Error when reserving PeeringCandidate %s: %s", pc.Name, err)
+		return nil, err
+	}
+	return pc, nil
+}
+
+// forgeBid records the outcome of the request for quote sent to the provider
+func forgeBid(provider string, bid *models.Bid, err error) reservationv1alpha1.Bid {
+	if err != nil {
+		return reservationv1alpha1.Bid{
+			Provider:     provider,
+			ReceivedTime: tools.GetTimeNow(),
+			State:        reservationv1alpha1.BidMissing,
+			Message:      err.Error(),
+		}
+	}
+	record := reservationv1alpha1.Bid{
+		Provider: provider,
+		Seller: nodecorev1alpha1.NodeIdentity{
+			NodeID:    bid.Seller.NodeID,
+			IP:        bid.Seller.IP,
+			Domain:    bid.Seller.Domain,
+			PublicKey: bid.Seller.PublicKey,
+		},
+		FlavourID:    bid.Flavour.FlavourID,
+		Price:        parseutil.ParseOfferFromObj(&bid.Price),
+		ValidUntil:   bid.ValidUntil,
+		ReceivedTime: tools.GetTimeNow(),
+		Signature:    parseutil.ParseSignatureFromObj(bid.Signature),
+		State:        reservationv1alpha1.BidReceived,
+		Message:      "Signature verified",
+	}
+	if bid.Partition != nil {
+		record.Partition = parseutil.ParsePartitionFromObj(bid.Partition)
+	}
+	return record
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RequestForQuoteReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&reservationv1alpha1.RequestForQuote{}).
+		Complete(r)
+}
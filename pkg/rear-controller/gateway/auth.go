@@ -0,0 +1,361 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+// Action is an operation of the REAR Gateway that is subject to the authorization policy
+type Action string
+
+const (
+	ActionBrowse   Action = "browse"
+	ActionReserve  Action = "reserve"
+	ActionPurchase Action = "purchase"
+)
+
+const (
+	AUTH_MODE_NONE  = "none"
+	AUTH_MODE_TOKEN = "token"
+	AUTH_MODE_MTLS  = "mtls"
+
+	// policyWildcard allows every authenticated identity
+	policyWildcard = "*"
+)
+
+type identityKey struct{}
+
+// Identity is the authenticated identity of the FLUIDOS Node sending a request
+type Identity struct {
+	Domain string
+	NodeID string
+}
+
+// Authenticator authenticates the requests received by the REAR Gateway
+type Authenticator interface {
+	// Authenticate returns the identity of the caller, nil if the request carries no credentials of this kind
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// Policy contains, for each Action, the domains and the node IDs allowed to perform it
+type Policy struct {
+	rules map[Action][]string
+}
+
+// Allows returns true if the identity is allowed to perform the action.
+// An action without rules is allowed to every authenticated identity.
+func (p *Policy) Allows(action Action, id *Identity) bool {
+	if id == nil {
+		return false
+	}
+	if p == nil {
+		return true
+	}
+	rule, ok := p.rules[action]
+	if !ok {
+		return true
+	}
+	for _, entry := range rule {
+		if entry == policyWildcard || entry == id.Domain || (id.NodeID != "" && entry == id.NodeID) {
+			return true
+		}
+	}
+	return false
+}
+
+// authState holds the tokens and the policy currently in use, reloaded periodically.
+// The tokens are indexed by the FLUIDOS Node they are issued to, as <nodeID>.<domain>.
+type authState struct {
+	lock   sync.RWMutex
+	tokens map[string]string
+	policy *Policy
+}
+
+// tokenAuthenticator authenticates requests carrying an API token issued to a buyer FLUIDOS Node.
+// The identity of the caller is the one the token is bound to.
+type tokenAuthenticator struct {
+	state *authState
+}
+
+func (a *tokenAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, nil
+	}
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found || token == "" {
+		return nil, fmt.Errorf("malformed Authorization header")
+	}
+
+	a.state.lock.RLock()
+	defer a.state.lock.RUnlock()
+	for key, t := range a.state.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			nodeID, domain, _ := strings.Cut(key, ".")
+			return &Identity{Domain: domain, NodeID: nodeID}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid API token")
+}
+
+// mtlsAuthenticator authenticates requests through the verified client certificate.
+// The CommonName is the NodeID and the first Organization is the domain.
+type mtlsAuthenticator struct{}
+
+func (a *mtlsAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil, nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	id := &Identity{NodeID: cert.Subject.CommonName}
+	switch {
+	case len(cert.Subject.Organization) > 0:
+		id.Domain = cert.Subject.Organization[0]
+	case len(cert.DNSNames) > 0:
+		id.Domain = cert.DNSNames[0]
+	default:
+		return nil, fmt.Errorf("client certificate does not contain a domain")
+	}
+	return id, nil
+}
+
+// forgeAuthenticators builds the authenticators enabled by the AUTH_MODE flag
+func forgeAuthenticators(state *authState) ([]Authenticator, error) {
+	var authenticators []Authenticator
+	for _, mode := range strings.Split(flags.AUTH_MODE, ",") {
+		switch strings.TrimSpace(mode) {
+		case AUTH_MODE_NONE, "":
+		case AUTH_MODE_TOKEN:
+			authenticators = append(authenticators, &tokenAuthenticator{state: state})
+		case AUTH_MODE_MTLS:
+			if flags.TLS_CA_FILE == "" {
+				return nil, fmt.Errorf("mTLS authentication requires the client CA file")
+			}
+			// Without a server certificate the Gateway serves plain HTTP, where no client certificate is presented
+			if flags.TLS_CERT_FILE == "" || flags.TLS_KEY_FILE == "" {
+				return nil, fmt.Errorf("mTLS authentication requires the server certificate and key files")
+			}
+			authenticators = append(authenticators, &mtlsAuthenticator{})
+		default:
+			return nil, fmt.Errorf("unknown authentication mode %s", mode)
+		}
+	}
+	return authenticators, nil
+}
+
+// authenticationMiddleware authenticates the request and stores the Identity of the caller in its context
+func (g *Gateway) authenticationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The Liqo clusters authenticate to the Liqo authentication proxy with the token of their Contract
+		if len(g.authenticators) == 0 || strings.HasPrefix(r.URL.Path, LIQO_AUTH_PATH+"/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, a := range g.authenticators {
+			id, err := a.Authenticate(r)
+			if err != nil {
+				klog.Infof("Authentication failed for %s: %s", r.RemoteAddr, err)
+				writeProblem(w, http.StatusUnauthorized, models.UNAUTHORIZED, err.Error())
+				return
+			}
+			if id != nil {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+				return
+			}
+		}
+		klog.Infof("Unauthenticated request from %s", r.RemoteAddr)
+		writeProblem(w, http.StatusUnauthorized, models.UNAUTHORIZED, "Missing credentials")
+	})
+}
+
+// authorize wraps a handler checking that the caller is allowed to perform the action
+func (g *Gateway) authorize(action Action, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(g.authenticators) == 0 {
+			handler(w, r)
+			return
+		}
+		id := getIdentity(r)
+		g.auth.lock.RLock()
+		allowed := g.auth.policy.Allows(action, id)
+		g.auth.lock.RUnlock()
+		if !allowed {
+			klog.Infof("Identity %v is not allowed to %s", id, action)
+			writeProblem(w, http.StatusForbidden, models.FORBIDDEN, fmt.Sprintf("not allowed to %s", action))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// getIdentity returns the authenticated Identity of the request, nil if authentication is disabled
+func getIdentity(r *http.Request) *Identity {
+	id, _ := r.Context().Value(identityKey{}).(*Identity)
+	return id
+}
+
+// checkIdentity checks that the authenticated caller is the buyer declared in the request
+func checkIdentity(r *http.Request, domain, nodeID string) error {
+	id := getIdentity(r)
+	if id == nil {
+		return nil
+	}
+	if id.Domain != domain || id.NodeID != nodeID {
+		return fmt.Errorf("authenticated identity %s/%s does not match %s/%s", id.Domain, id.NodeID, domain, nodeID)
+	}
+	return nil
+}
+
+func (g *Gateway) AuthRefresher(interval time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return wait.PollUntilContextCancel(ctx, interval, true, g.refreshAuth)
+	}
+}
+
+// refreshAuth reloads the API tokens and the authorization policy
+func (g *Gateway) refreshAuth(ctx context.Context) (bool, error) {
+	tokens := make(map[string]string)
+	var secret corev1.Secret
+	err := g.client.Get(ctx, types.NamespacedName{Name: consts.GATEWAY_TOKENS_SECRET_NAME, Namespace: flags.FLUIDOS_NAMESPACE}, &secret)
+	if client.IgnoreNotFound(err) != nil {
+		klog.Errorf("Error when retrieving the API tokens Secret: %s", err)
+		return false, nil
+	}
+	for key, token := range secret.Data {
+		if nodeID, domain, _ := strings.Cut(key, "."); nodeID == "" || domain == "" {
+			klog.Errorf("Ignoring the API token %s: the key must be <nodeID>.<domain>", key)
+			continue
+		}
+		tokens[key] = string(token)
+	}
+
+	policy := &Policy{rules: make(map[Action][]string)}
+	var cm corev1.ConfigMap
+	err = g.client.Get(ctx, types.NamespacedName{Name: consts.GATEWAY_POLICY_CONFIG_MAP_NAME, Namespace: flags.FLUIDOS_NAMESPACE}, &cm)
+	if client.IgnoreNotFound(err) != nil {
+		klog.Errorf("Error when retrieving the authorization policy ConfigMap: %s", err)
+		return false, nil
+	}
+	for _, action := range []Action{ActionBrowse, ActionReserve, ActionPurchase} {
+		value, ok := cm.Data[string(action)]
+		if !ok {
+			continue
+		}
+		policy.rules[action] = []string{}
+		for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' || r == ' ' }) {
+			policy.rules[action] = append(policy.rules[action], entry)
+		}
+	}
+
+	g.auth.lock.Lock()
+	g.auth.tokens = tokens
+	g.auth.policy = policy
+	g.auth.lock.Unlock()
+
+	return false, nil
+}
+
+// getClientToken returns the API token to present to the provider at the given address
+func (g *Gateway) getClientToken(ctx context.Context, addr string) string {
+	var secret corev1.Secret
+	err := g.client.Get(ctx, types.NamespacedName{Name: consts.GATEWAY_CLIENT_TOKENS_SECRET_NAME, Namespace: flags.FLUIDOS_NAMESPACE}, &secret)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			klog.Errorf("Error when retrieving the client API tokens Secret: %s", err)
+		}
+		return ""
+	}
+	host := addr
+	if i := strings.LastIndex(addr, ":"); i != -1 {
+		host = addr[:i]
+	}
+	if token, ok := secret.Data[host]; ok {
+		return string(token)
+	}
+	return string(secret.Data["default"])
+}
+
+// forgeServerTLSConfig builds the TLS configuration of the Gateway, verifying the client certificates if a CA is given
+func forgeServerTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if flags.TLS_CA_FILE == "" {
+		return config, nil
+	}
+	pool, err := loadCertPool(flags.TLS_CA_FILE)
+	if err != nil {
+		return nil, err
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	return config, nil
+}
+
+// forgeClientTLSConfig builds the TLS configuration used to contact the other Gateways
+func forgeClientTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if flags.TLS_CA_FILE != "" {
+		pool, err := loadCertPool(flags.TLS_CA_FILE)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	cert, err := tls.LoadX509KeyPair(flags.TLS_CERT_FILE, flags.TLS_KEY_FILE)
+	if err != nil {
+		return nil, err
+	}
+	config.Certificates = []tls.Certificate{cert}
+	return config, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	ca, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no valid certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+// gatewayScheme returns the URL scheme used to contact the other Gateways
+func gatewayScheme() string {
+	if flags.TLS_CERT_FILE != "" {
+		return "https"
+	}
+	return "http"
+}
@@ -20,6 +20,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"k8s.io/klog/v2"
 
@@ -28,6 +31,8 @@ import (
 	"github.com/fluidos-project/node/pkg/utils/getters"
 	"github.com/fluidos-project/node/pkg/utils/models"
 	"github.com/fluidos-project/node/pkg/utils/parseutil"
+	"github.com/fluidos-project/node/pkg/utils/resourceforge"
+	"github.com/fluidos-project/node/pkg/utils/signatures"
 )
 
 // TODO: move this function into the REAR Gateway package
@@ -40,13 +45,16 @@ func (g *Gateway) ReserveFlavour(ctx context.Context, reservation *reservationv1
 
 	var transaction models.Transaction
 
+	// The seller finds the Contract of the buyer cluster by its Liqo cluster ID, when the cluster peers with it
+	clusterID := reservation.Spec.BuyerClusterID
+	if clusterID == "" {
+		clusterID = g.ClusterID
+	}
+
 	body := models.ReserveRequest{
 		FlavourID: flavourID,
-		Buyer: models.NodeIdentity{
-			NodeID: g.ID.NodeID,
-			IP:     g.ID.IP,
-			Domain: g.ID.Domain,
-		},
+		Buyer:     parseutil.ParseNodeIdentity(*g.ID),
+		ClusterID: clusterID,
 	}
 
 	klog.Infof("Reservation %s for flavour %s", reservation.Name, flavourID)
@@ -55,6 +63,22 @@ func (g *Gateway) ReserveFlavour(ctx context.Context, reservation *reservationv1
 		body.Partition = parseutil.ParsePartition(reservation.Spec.Partition)
 	}
 
+	// The offer of the last negotiation round, if any, replaces the initial offer
+	body.Offer = parseutil.ParseOffer(reservation.Spec.Offer)
+	if reservation.Status.Offer != nil {
+		body.Offer = parseutil.ParseOffer(reservation.Status.Offer)
+	}
+
+	body.Nonce, body.Timestamp, err = forgeNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	body.Signature, err = g.keyPair.Sign(body)
+	if err != nil {
+		return nil, err
+	}
+
 	selectorBytes, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
@@ -62,11 +86,19 @@ func (g *Gateway) ReserveFlavour(ctx context.Context, reservation *reservationv1
 
 	// TODO: this url should be taken from the nodeIdentity of the flavour
 	bodyBytes := bytes.NewBuffer(selectorBytes)
-	url := fmt.Sprintf("http://%s%s%s", reservation.Spec.Seller.IP, RESERVE_FLAVOUR_PATH, flavourID)
 
-	klog.Infof("Sending request to %s", url)
+	klog.Infof("Sending request to %s%s%s", reservation.Spec.Seller.IP, RESERVE_FLAVOUR_PATH, flavourID)
 
-	resp, err := makeRequest("POST", url, bodyBytes)
+	// The same key is used for every attempt of this Reservation, so that retries are replayed by the seller.
+	// Each negotiation round carries a new offer, hence a new key.
+	key := "reserve-" + string(reservation.UID)
+	if reservation.Status.NegotiationRound > 0 {
+		key += "-" + strconv.Itoa(reservation.Status.NegotiationRound)
+	}
+	header := http.Header{}
+	header.Set(IDEMPOTENCY_KEY_HEADER, key)
+
+	resp, err := g.makeRequest("POST", reservation.Spec.Seller.IP, RESERVE_FLAVOUR_PATH+flavourID, bodyBytes, header)
 	if err != nil {
 		return nil, err
 	}
@@ -74,8 +106,8 @@ func (g *Gateway) ReserveFlavour(ctx context.Context, reservation *reservationv1
 
 	// Check if the response status code is 200 (OK)
 	if resp.StatusCode != http.StatusOK {
-		klog.Errorf("Received non-OK response status code: %v", resp)
-		return nil, fmt.Errorf("received non-OK response status code: %d", resp.StatusCode)
+		klog.Errorf("Received non-OK response status code: %d", resp.StatusCode)
+		return nil, decodeProblem(resp)
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&transaction); err != nil {
@@ -92,55 +124,152 @@ func (g *Gateway) ReserveFlavour(ctx context.Context, reservation *reservationv1
 
 	klog.Infof("Flavour %s reserved: transaction ID %s", flavourID, transaction.TransactionID)
 
-	g.addNewTransacion(transaction)
-
 	return &transaction, nil
 }
 
-// PurchaseFlavour purchases a flavour with the given flavourID
-func (g *Gateway) PurchaseFlavour(ctx context.Context, transactionID string, seller nodecorev1alpha1.NodeIdentity) (*models.ResponsePurchase, error) {
+// PurchaseFlavour purchases a flavour with the given flavourID.
+// It returns the Contract bought along with the Liqo token of the seller cluster, delivered sealed by the seller.
+func (g *Gateway) PurchaseFlavour(ctx context.Context, transactionID string, seller nodecorev1alpha1.NodeIdentity) (*models.ResponsePurchase, string, error) {
 	err := checkLiqoReadiness(g.LiqoReady)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	var purchase models.ResponsePurchase
 
-	// Check if the transaction exists
-	transaction, err := g.GetTransaction(transactionID)
+	// The sealing key is derived from the transaction, so that a replayed response can be opened too
+	sealingKey, err := g.keyPair.SealingKey("purchase-" + transactionID)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	body := models.PurchaseRequest{
-		TransactionID: transaction.TransactionID,
+		TransactionID: transactionID,
+		PublicKey:     signatures.EncodePublicKey(sealingKey),
+	}
+
+	body.Nonce, body.Timestamp, err = forgeNonce()
+	if err != nil {
+		return nil, "", err
+	}
+
+	body.Signature, err = g.keyPair.Sign(body)
+	if err != nil {
+		return nil, "", err
 	}
 
 	selectorBytes, err := json.Marshal(body)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	bodyBytes := bytes.NewBuffer(selectorBytes)
-	// TODO: this url should be taken from the nodeIdentity of the flavour
-	url := fmt.Sprintf("http://%s%s%s", seller.IP, PURCHASE_FLAVOUR_PATH, transactionID)
+	header := http.Header{}
+	header.Set(IDEMPOTENCY_KEY_HEADER, "purchase-"+transactionID)
 
-	resp, err := makeRequest("POST", url, bodyBytes)
+	// TODO: this url should be taken from the nodeIdentity of the flavour
+	resp, err := g.makeRequest("POST", seller.IP, PURCHASE_FLAVOUR_PATH+transactionID, bodyBytes, header)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer resp.Body.Close()
 
 	// Check if the response status code is 200 (OK)
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("received non-OK response status code: %d", resp.StatusCode)
+		return nil, "", decodeProblem(resp)
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&purchase); err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	// Verify the seller signature, made with the key published in its Flavour, before accepting the contract
+	if purchase.Contract.Seller.NodeID != seller.NodeID || purchase.Contract.Seller.PublicKey != seller.PublicKey ||
+		purchase.Contract.Buyer.NodeID != g.ID.NodeID || purchase.Contract.Buyer.PublicKey != g.ID.PublicKey {
+		return nil, "", fmt.Errorf("contract %s does not match the expected buyer and seller", purchase.Contract.ContractID)
+	}
+
+	if err := signatures.VerifyContractFrom(&purchase.Contract, purchase.Contract.SellerSignature, seller.PublicKey); err != nil {
+		klog.Errorf("Error verifying the seller signature of contract %s: %s", purchase.Contract.ContractID, err)
+		return nil, "", fmt.Errorf("invalid seller signature on contract %s: %w", purchase.Contract.ContractID, err)
+	}
+
+	token, err := signatures.Open(sealingKey, purchase.Credentials, []byte(purchase.Contract.ContractID))
+	if err != nil {
+		klog.Errorf("Error opening the credentials of contract %s: %s", purchase.Contract.ContractID, err)
+		return nil, "", fmt.Errorf("invalid credentials on contract %s: %w", purchase.Contract.ContractID, err)
 	}
 
-	return &purchase, nil
+	// Counter-sign the contract and deliver the signature to the seller
+	purchase.Contract.BuyerSignature, err = g.keyPair.SignContract(&purchase.Contract)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := g.CountersignContract(ctx, &purchase.Contract, seller); err != nil {
+		klog.Errorf("Error delivering the signature of contract %s: %s", purchase.Contract.ContractID, err)
+		return nil, "", err
+	}
+
+	return &purchase, string(token), nil
+}
+
+// GetFlavourByID retrieves a Flavour from the seller to check that it is still available.
+// If etag matches the current version of the Flavour, nil is returned with the same etag.
+func (g *Gateway) GetFlavourByID(ctx context.Context, seller nodecorev1alpha1.NodeIdentity, flavourID, etag string) (*nodecorev1alpha1.Flavour, string, error) {
+	err := checkLiqoReadiness(g.LiqoReady)
+	if err != nil {
+		return nil, "", err
+	}
+
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+
+	resp, err := g.makeRequest("GET", seller.IP, LIST_FLAVOUR_BY_ID_PATH+flavourID, nil, header)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", decodeProblem(resp)
+	}
+
+	var flavour models.Flavour
+	if err := json.NewDecoder(resp.Body).Decode(&flavour); err != nil {
+		klog.Errorf("Error decoding the response body: %s", err)
+		return nil, "", err
+	}
+
+	return resourceforge.ForgeFlavourFromObj(flavour), resp.Header.Get("ETag"), nil
+}
+
+// CancelReservation cancels the reservation identified by the transactionID, releasing it on the seller
+func (g *Gateway) CancelReservation(ctx context.Context, transactionID string, seller nodecorev1alpha1.NodeIdentity) error {
+	err := checkLiqoReadiness(g.LiqoReady)
+	if err != nil {
+		return err
+	}
+
+	resp, err := g.makeRequest("DELETE", seller.IP, CANCEL_RESERVATION_PATH+transactionID, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return decodeProblem(resp)
+	}
+
+	klog.Infof("Reservation of transaction %s cancelled", transactionID)
+
+	return nil
 }
 
 // SearchFlavour is a function that returns an array of Flavour that fit the Selector by performing a get request to an http server
@@ -161,7 +290,7 @@ func (g *Gateway) DiscoverFlavours(selector *nodecorev1alpha1.FlavourSelector) (
 
 	// Send the POST request to all the servers in the list
 	for _, provider := range providers {
-		flavour, err := discover(s, provider)
+		flavour, err := g.discover(s, provider)
 		if err != nil {
 			klog.Errorf("Error when searching Flavour: %s", err)
 			return nil, err
@@ -173,11 +302,11 @@ func (g *Gateway) DiscoverFlavours(selector *nodecorev1alpha1.FlavourSelector) (
 	return flavoursCR, nil
 }
 
-func discover(s *models.Selector, provider string) (*nodecorev1alpha1.Flavour, error) {
+func (g *Gateway) discover(s *models.Selector, provider string) (*nodecorev1alpha1.Flavour, error) {
 	if s != nil {
-		return searchFlavourWithSelector(s, provider)
+		return g.searchFlavourWithSelector(s, provider)
 	}
-	return searchFlavour(provider)
+	return g.searchFlavour(provider)
 }
 
 func checkLiqoReadiness(b bool) error {
@@ -187,3 +316,182 @@ func checkLiqoReadiness(b bool) error {
 	}
 	return nil
 }
+
+// GetContract retrieves a Contract bought from the seller
+func (g *Gateway) GetContract(ctx context.Context, contractID string, seller nodecorev1alpha1.NodeIdentity) (*models.ResponseContract, error) {
+	var contract models.ResponseContract
+
+	resp, err := g.makeRequest("GET", seller.IP, CONTRACTS_PATH+"/"+contractID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeProblem(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&contract); err != nil {
+		return nil, err
+	}
+
+	return &contract, nil
+}
+
+// ListContracts lists the Contracts bought from the seller
+func (g *Gateway) ListContracts(ctx context.Context, seller nodecorev1alpha1.NodeIdentity) ([]models.ResponseContract, error) {
+	var contracts []models.ResponseContract
+
+	resp, err := g.makeRequest("GET", seller.IP, CONTRACTS_PATH, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeProblem(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&contracts); err != nil {
+		return nil, err
+	}
+
+	return contracts, nil
+}
+
+// GetStatement retrieves from the other party the statement of the Contracts of this FLUIDOS Node over the billing period [from, to)
+func (g *Gateway) GetStatement(ctx context.Context, from, to time.Time, party nodecorev1alpha1.NodeIdentity) (*models.Statement, error) {
+	var statement models.Statement
+
+	query := url.Values{}
+	query.Set("from", from.UTC().Format(time.RFC3339))
+	query.Set("to", to.UTC().Format(time.RFC3339))
+
+	resp, err := g.makeRequest("GET", party.IP, STATEMENTS_PATH+"?"+query.Encode(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeProblem(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&statement); err != nil {
+		return nil, err
+	}
+
+	return &statement, nil
+}
+
+// RenewContract asks the seller to extend a Contract until the given expiration time.
+// The renewed Contract is returned counter-signed by the buyer.
+func (g *Gateway) RenewContract(ctx context.Context, contractID, expirationTime string, seller nodecorev1alpha1.NodeIdentity) (*models.ResponseContract, error) {
+	body := models.RenewContractRequest{
+		ContractID:     contractID,
+		ExpirationTime: expirationTime,
+	}
+
+	var err error
+	body.Nonce, body.Timestamp, err = forgeNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	body.Signature, err = g.keyPair.Sign(body)
+	if err != nil {
+		return nil, err
+	}
+
+	contract, err := g.sendContractRequest(CONTRACTS_PATH+"/"+contractID+RENEW_CONTRACT_SUFFIX, body, seller)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := signatures.VerifyContractFrom(&contract.Contract, contract.Contract.SellerSignature, seller.PublicKey); err != nil {
+		klog.Errorf("Error verifying the seller signature of contract %s: %s", contractID, err)
+		return nil, fmt.Errorf("invalid seller signature on contract %s: %w", contractID, err)
+	}
+
+	contract.Contract.BuyerSignature, err = g.keyPair.SignContract(&contract.Contract)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.CountersignContract(ctx, &contract.Contract, seller); err != nil {
+		klog.Errorf("Error delivering the signature of contract %s: %s", contractID, err)
+		return nil, err
+	}
+
+	return contract, nil
+}
+
+// CountersignContract delivers to the seller the signature of the buyer on the current terms of a Contract
+func (g *Gateway) CountersignContract(ctx context.Context, contract *models.Contract, seller nodecorev1alpha1.NodeIdentity) error {
+	body := models.CountersignContractRequest{
+		ContractID:     contract.ContractID,
+		BuyerSignature: contract.BuyerSignature,
+	}
+
+	var err error
+	body.Nonce, body.Timestamp, err = forgeNonce()
+	if err != nil {
+		return err
+	}
+
+	body.Signature, err = g.keyPair.Sign(body)
+	if err != nil {
+		return err
+	}
+
+	_, err = g.sendContractRequest(CONTRACTS_PATH+"/"+contract.ContractID+COUNTERSIGN_CONTRACT_SUFFIX, body, seller)
+	return err
+}
+
+// TerminateContract asks the other party of a Contract (the seller, or the buyer when called by the seller)
+// to terminate it before its expiration
+func (g *Gateway) TerminateContract(ctx context.Context, contractID, reason string, party nodecorev1alpha1.NodeIdentity) (*models.ResponseContract, error) {
+	body := models.TerminateContractRequest{
+		ContractID: contractID,
+		Reason:     reason,
+	}
+
+	var err error
+	body.Nonce, body.Timestamp, err = forgeNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	body.Signature, err = g.keyPair.Sign(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.sendContractRequest(CONTRACTS_PATH+"/"+contractID+TERMINATE_CONTRACT_SUFFIX, body, party)
+}
+
+// sendContractRequest sends a contract management request to the other party of the Contract
+func (g *Gateway) sendContractRequest(path string, body interface{}, party nodecorev1alpha1.NodeIdentity) (*models.ResponseContract, error) {
+	var contract models.ResponseContract
+
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.makeRequest("POST", party.IP, path, bytes.NewBuffer(bodyBytes), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeProblem(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&contract); err != nil {
+		return nil, err
+	}
+
+	return &contract, nil
+}
@@ -0,0 +1,343 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/rear-controller/metering"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
+	"github.com/fluidos-project/node/pkg/utils/parseutil"
+	"github.com/fluidos-project/node/pkg/utils/signatures"
+)
+
+// getContract is an handler for getting a Contract sold to the caller by its contractID
+func (g *Gateway) getContract(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	contractID := params["contractID"]
+
+	klog.Infof("Processing request for getting Contract %s...", contractID)
+
+	contract, ok := g.getBuyerContract(w, r, contractID)
+	if !ok {
+		return
+	}
+
+	encodeResponse(w, forgeResponseContract(contract))
+}
+
+// listContracts is an handler for listing the Contracts sold to the caller
+func (g *Gateway) listContracts(w http.ResponseWriter, r *http.Request) {
+	klog.Infof("Processing request for listing Contracts...")
+
+	caller, err := g.getCaller(r)
+	if err != nil {
+		klog.Infof("Unable to identify the buyer: %s", err)
+		writeProblem(w, http.StatusUnauthorized, models.UNAUTHORIZED, "Unable to identify the buyer: "+err.Error())
+		return
+	}
+
+	var contractList reservationv1alpha1.ContractList
+	if err := g.client.List(r.Context(), &contractList); err != nil {
+		klog.Errorf("Error when listing Contracts: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error when listing Contracts")
+		return
+	}
+
+	contracts := []models.ResponseContract{}
+	for i := range contractList.Items {
+		contract := &contractList.Items[i]
+		if contract.Spec.Seller.NodeID == g.ID.NodeID && metering.IsParty(contract.Spec.Buyer, caller) {
+			contracts = append(contracts, forgeResponseContract(contract))
+		}
+	}
+
+	klog.Infof("Found %d Contracts", len(contracts))
+
+	encodeResponse(w, contracts)
+}
+
+// renewContract is an handler for renewing a Contract with a new expiration time
+func (g *Gateway) renewContract(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	contractID := params["contractID"]
+	var request models.RenewContractRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, err.Error())
+		return
+	}
+
+	if contractID != request.ContractID {
+		writeProblem(w, http.StatusConflict, models.PARAMETER_MISMATCH, "Mismatch body & param")
+		return
+	}
+
+	contract, ok := g.getBuyerContract(w, r, contractID)
+	if !ok {
+		return
+	}
+
+	// The request must be signed with the key of the buyer pinned in the Contract
+	unsigned := request
+	unsigned.Signature = nil
+	if err := g.checkMessage(request.Nonce, request.Timestamp, unsigned, request.Signature, contract.Spec.Buyer.PublicKey); err != nil {
+		klog.Errorf("Error checking the RenewContractRequest: %s", err)
+		writeProblem(w, http.StatusUnauthorized, models.INVALID_MESSAGE, "Invalid RenewContractRequest: "+err.Error())
+		return
+	}
+
+	expiration, err := time.Parse(time.RFC3339, request.ExpirationTime)
+	if err != nil || !expiration.After(time.Now()) {
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, "Invalid expiration time "+request.ExpirationTime)
+		return
+	}
+
+	if contract.Status.Phase.Phase != nodecorev1alpha1.PhaseActive {
+		writeProblem(w, http.StatusConflict, models.CONTRACT_NOT_ACTIVE, "Contract "+contractID+" is not active")
+		return
+	}
+
+	klog.Infof("Renewing Contract %s until %s", contractID, request.ExpirationTime)
+
+	// The terms changed, so the contract must be signed again by both parties
+	contract.Spec.ExpirationTime = expiration.UTC().Format(time.RFC3339)
+	contract.Spec.BuyerSignature = nil
+	unsignedContract := parseutil.ParseContract(contract)
+	signature, err := g.keyPair.SignContract(&unsignedContract)
+	if err != nil {
+		klog.Errorf("Error signing the Contract: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error signing the Contract")
+		return
+	}
+	contract.Spec.SellerSignature = parseutil.ParseSignatureFromObj(signature)
+
+	if err := g.client.Update(r.Context(), contract); err != nil {
+		klog.Errorf("Error updating the Contract: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error updating the Contract: "+err.Error())
+		return
+	}
+
+	contract.SetPhase(nodecorev1alpha1.PhaseActive, "Contract renewed until "+contract.Spec.ExpirationTime)
+	if err := g.client.Status().Update(r.Context(), contract); err != nil {
+		klog.Errorf("Error updating the Contract status: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error updating the Contract status: "+err.Error())
+		return
+	}
+
+	encodeResponse(w, forgeResponseContract(contract))
+}
+
+// countersignContract is an handler for storing the signature of the buyer on the current terms of a Contract
+func (g *Gateway) countersignContract(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	contractID := params["contractID"]
+	var request models.CountersignContractRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, err.Error())
+		return
+	}
+
+	if contractID != request.ContractID {
+		writeProblem(w, http.StatusConflict, models.PARAMETER_MISMATCH, "Mismatch body & param")
+		return
+	}
+
+	contract, ok := g.getBuyerContract(w, r, contractID)
+	if !ok {
+		return
+	}
+
+	unsigned := request
+	unsigned.Signature = nil
+	if err := g.checkMessage(request.Nonce, request.Timestamp, unsigned, request.Signature, contract.Spec.Buyer.PublicKey); err != nil {
+		klog.Errorf("Error checking the CountersignContractRequest: %s", err)
+		writeProblem(w, http.StatusUnauthorized, models.INVALID_MESSAGE, "Invalid CountersignContractRequest: "+err.Error())
+		return
+	}
+
+	// The countersignature must cover the terms currently signed by the seller
+	terms := parseutil.ParseContract(contract)
+	if err := signatures.VerifyContractFrom(&terms, request.BuyerSignature, contract.Spec.Buyer.PublicKey); err != nil {
+		klog.Errorf("Error verifying the buyer signature of Contract %s: %s", contractID, err)
+		writeProblem(w, http.StatusUnprocessableEntity, models.INVALID_MESSAGE, "Invalid buyer signature: "+err.Error())
+		return
+	}
+
+	klog.Infof("Contract %s countersigned by the buyer", contractID)
+
+	contract.Spec.BuyerSignature = parseutil.ParseSignatureFromObj(request.BuyerSignature)
+	if err := g.client.Update(r.Context(), contract); err != nil {
+		klog.Errorf("Error updating the Contract: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error updating the Contract: "+err.Error())
+		return
+	}
+
+	encodeResponse(w, forgeResponseContract(contract))
+}
+
+// terminateContract is an handler for terminating a Contract before its expiration
+func (g *Gateway) terminateContract(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	contractID := params["contractID"]
+	var request models.TerminateContractRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, err.Error())
+		return
+	}
+
+	if contractID != request.ContractID {
+		writeProblem(w, http.StatusConflict, models.PARAMETER_MISMATCH, "Mismatch body & param")
+		return
+	}
+
+	contract, by, ok := g.getPartyContract(w, r, contractID)
+	if !ok {
+		return
+	}
+
+	// The request must be signed with the key of the calling party pinned in the Contract
+	party := contract.Spec.Buyer
+	if by == reservationv1alpha1.ContractPartySeller {
+		party = contract.Spec.Seller
+	}
+	unsigned := request
+	unsigned.Signature = nil
+	if err := g.checkMessage(request.Nonce, request.Timestamp, unsigned, request.Signature, party.PublicKey); err != nil {
+		klog.Errorf("Error checking the TerminateContractRequest: %s", err)
+		writeProblem(w, http.StatusUnauthorized, models.INVALID_MESSAGE, "Invalid TerminateContractRequest: "+err.Error())
+		return
+	}
+
+	if contract.Status.Phase.Phase == nodecorev1alpha1.PhaseTerminated {
+		encodeResponse(w, forgeResponseContract(contract))
+		return
+	}
+
+	klog.Infof("Terminating Contract %s on request of the %s", contractID, by)
+
+	contract.Terminate(by, request.Reason)
+	if err := g.client.Status().Update(r.Context(), contract); err != nil {
+		klog.Errorf("Error updating the Contract status: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error updating the Contract status: "+err.Error())
+		return
+	}
+
+	encodeResponse(w, forgeResponseContract(contract))
+}
+
+// getPartyContract retrieves a Contract checking that the caller is the other party:
+// the buyer when the local FLUIDOS Node is the seller, the seller when it is the buyer.
+// It returns the role of the caller, or writes the error response and returns false if the Contract cannot be returned.
+func (g *Gateway) getPartyContract(w http.ResponseWriter, r *http.Request, contractID string) (*reservationv1alpha1.Contract, string, bool) {
+	contract := &reservationv1alpha1.Contract{}
+	err := g.client.Get(r.Context(), types.NamespacedName{Name: contractID, Namespace: flags.FLUIDOS_NAMESPACE}, contract)
+	if apierrors.IsNotFound(err) {
+		writeProblem(w, http.StatusNotFound, models.CONTRACT_NOT_FOUND, "Contract "+contractID+" not found")
+		return nil, "", false
+	}
+	if err != nil {
+		klog.Errorf("Error getting the Contract: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error getting the Contract")
+		return nil, "", false
+	}
+
+	caller, err := g.getCaller(r)
+	if err != nil {
+		klog.Infof("Unable to identify the caller: %s", err)
+		writeProblem(w, http.StatusUnauthorized, models.UNAUTHORIZED, "Unable to identify the caller: "+err.Error())
+		return nil, "", false
+	}
+
+	switch {
+	case contract.Spec.Seller.NodeID == g.ID.NodeID && metering.IsParty(contract.Spec.Buyer, caller):
+		return contract, reservationv1alpha1.ContractPartyBuyer, true
+	case contract.Spec.Buyer.NodeID == g.ID.NodeID && metering.IsParty(contract.Spec.Seller, caller):
+		return contract, reservationv1alpha1.ContractPartySeller, true
+	}
+
+	// Do not disclose the existence of contracts of other nodes
+	writeProblem(w, http.StatusNotFound, models.CONTRACT_NOT_FOUND, "Contract "+contractID+" not found")
+	return nil, "", false
+}
+
+// getBuyerContract retrieves a Contract checking that it has been sold to the caller.
+// It writes the error response and returns false if the Contract cannot be returned.
+func (g *Gateway) getBuyerContract(w http.ResponseWriter, r *http.Request, contractID string) (*reservationv1alpha1.Contract, bool) {
+	contract := &reservationv1alpha1.Contract{}
+	err := g.client.Get(r.Context(), types.NamespacedName{Name: contractID, Namespace: flags.FLUIDOS_NAMESPACE}, contract)
+	if apierrors.IsNotFound(err) {
+		writeProblem(w, http.StatusNotFound, models.CONTRACT_NOT_FOUND, "Contract "+contractID+" not found")
+		return nil, false
+	}
+	if err != nil {
+		klog.Errorf("Error getting the Contract: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error getting the Contract")
+		return nil, false
+	}
+
+	caller, err := g.getCaller(r)
+	if err != nil {
+		klog.Infof("Unable to identify the buyer: %s", err)
+		writeProblem(w, http.StatusUnauthorized, models.UNAUTHORIZED, "Unable to identify the buyer: "+err.Error())
+		return nil, false
+	}
+
+	if contract.Spec.Seller.NodeID != g.ID.NodeID || !metering.IsParty(contract.Spec.Buyer, caller) {
+		// Do not disclose the existence of contracts of other buyers
+		writeProblem(w, http.StatusNotFound, models.CONTRACT_NOT_FOUND, "Contract "+contractID+" not found")
+		return nil, false
+	}
+
+	return contract, true
+}
+
+// getCaller returns the identity of the caller: the authenticated one or, when authentication is disabled,
+// the key the request is signed with. The NodeID declared by an unauthenticated caller is never trusted,
+// so such a caller is matched against the keys pinned in the Contracts only.
+func (g *Gateway) getCaller(r *http.Request) (nodecorev1alpha1.NodeIdentity, error) {
+	if id := getIdentity(r); id != nil {
+		return nodecorev1alpha1.NodeIdentity{Domain: id.Domain, NodeID: id.NodeID}, nil
+	}
+	publicKey, err := g.checkRequestSignature(r)
+	if err != nil {
+		return nodecorev1alpha1.NodeIdentity{}, err
+	}
+	return nodecorev1alpha1.NodeIdentity{PublicKey: publicKey}, nil
+}
+
+// forgeResponseContract returns a Contract with its phase.
+// The credentials are left out: they are delivered only sealed for the buyer, with the purchase.
+func forgeResponseContract(contract *reservationv1alpha1.Contract) models.ResponseContract {
+	response := models.ResponseContract{
+		Contract: parseutil.ParseContract(contract),
+		Phase:    string(contract.Status.Phase.Phase),
+		Message:  contract.Status.Phase.Message,
+	}
+	response.Contract.SellerCredentials = models.LiqoCredentials{}
+	return response
+}
@@ -0,0 +1,122 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+const (
+	PROBLEM_CONTENT_TYPE = "application/problem+json"
+	PROBLEM_TYPE_PREFIX  = "https://fluidos.eu/rear/errors/"
+)
+
+// ProblemError is the error returned by the Gateway client when a FLUIDOS Node answers with a problem
+type ProblemError struct {
+	models.Problem
+
+	// RetryAfter is the time the seller asked to wait before retrying, if any
+	RetryAfter time.Duration
+}
+
+func (e *ProblemError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("%s (%d): %s", e.Code, e.Status, e.Detail)
+	}
+	return fmt.Sprintf("%s (%d): %s", e.Code, e.Status, e.Title)
+}
+
+// Retryable returns true if the same request may succeed if sent again later
+func (e *ProblemError) Retryable() bool {
+	switch e.Code {
+	case models.RATE_LIMITED, models.NOT_READY, models.INTERNAL_ERROR:
+		return true
+	case models.UNKNOWN_ERROR:
+		return e.Status >= http.StatusInternalServerError || e.Status == http.StatusTooManyRequests
+	default:
+		return false
+	}
+}
+
+// IsRetryable returns true if err is a ProblemError that may succeed if the request is sent again
+func IsRetryable(err error) bool {
+	var problem *ProblemError
+	if errors.As(err, &problem) {
+		return problem.Retryable()
+	}
+	return false
+}
+
+// writeProblem writes a problem+json error response
+func writeProblem(w http.ResponseWriter, status int, code, detail string) {
+	problem := models.Problem{
+		Type:   PROBLEM_TYPE_PREFIX + strings.ToLower(strings.ReplaceAll(code, "_", "-")),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+
+	resp, err := json.Marshal(problem)
+	if err != nil {
+		klog.Errorf("Error encoding the problem: %s", err)
+		http.Error(w, detail, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", PROBLEM_CONTENT_TYPE)
+	w.WriteHeader(status)
+	w.Write(resp)
+}
+
+// decodeProblem builds the error corresponding to a non-OK response of a FLUIDOS Node
+func decodeProblem(resp *http.Response) error {
+	problem := &ProblemError{}
+
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			problem.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err == nil && strings.HasPrefix(resp.Header.Get("Content-Type"), PROBLEM_CONTENT_TYPE) {
+		if err := json.Unmarshal(body, &problem.Problem); err == nil && problem.Code != "" {
+			return problem
+		}
+	}
+
+	problem.Problem = models.Problem{
+		Title:  http.StatusText(resp.StatusCode),
+		Status: resp.StatusCode,
+		Detail: strings.TrimSpace(string(body)),
+		Code:   models.UNKNOWN_ERROR,
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		problem.Code = models.RATE_LIMITED
+	}
+	return problem
+}
@@ -18,9 +18,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/liqotech/liqo/pkg/auth"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -32,30 +35,48 @@ import (
 	"github.com/fluidos-project/node/pkg/utils/flags"
 	"github.com/fluidos-project/node/pkg/utils/getters"
 	"github.com/fluidos-project/node/pkg/utils/models"
-	"github.com/fluidos-project/node/pkg/utils/tools"
+	"github.com/fluidos-project/node/pkg/utils/signatures"
 )
 
 // clusterRole
 //+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=contracts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=transactions,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=transactions/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=nodecore.fluidos.eu,resources=flavours,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=nodecore.fluidos.eu,resources=flavours/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=nodecore.fluidos.eu,resources=flavours/finalizers,verbs=update
 //+kubebuilder:rbac:groups=core,resources=*,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;delete
 
 const (
 	LIST_FLAVOURS_PATH             = "/api/listflavours"
 	LIST_FLAVOUR_BY_ID_PATH        = "/api/listflavours/"
 	RESERVE_FLAVOUR_PATH           = "/api/reserveflavour/"
 	PURCHASE_FLAVOUR_PATH          = "/api/purchaseflavour/"
+	CANCEL_RESERVATION_PATH        = "/api/cancelreservation/"
+	CONTRACTS_PATH                 = "/api/contracts"
+	RENEW_CONTRACT_SUFFIX          = "/renew"
+	COUNTERSIGN_CONTRACT_SUFFIX    = "/countersign"
+	TERMINATE_CONTRACT_SUFFIX      = "/terminate"
 	LIST_FLAVOURS_BY_SELECTOR_PATH = "/api/listflavours/selector"
+	STATEMENTS_PATH                = "/api/statements"
+	RFQ_PATH                       = "/api/rfq"
+	LIQO_AUTH_PATH                 = "/liqo/auth"
+)
+
+const (
+	// The headers carrying the signature of the requests whose body is not signed
+	NONCE_HEADER     = "X-Fluidos-Nonce"
+	TIMESTAMP_HEADER = "X-Fluidos-Timestamp"
+	SIGNATURE_HEADER = "X-Fluidos-Signature"
 )
 
 type Gateway struct {
 	// NodeIdentity is the identity of the FLUIDOS Node
 	ID *nodecorev1alpha1.NodeIdentity
 
-	// Transactions is a map of Transaction
-	Transactions map[string]models.Transaction
+	// Transactions is the store of the open transactions sold by the FLUIDOS Node
+	Transactions TransactionStore
 
 	// client is the Kubernetes client
 	client client.Client
@@ -65,14 +86,43 @@ type Gateway struct {
 
 	// The Liqo ClusterID
 	ClusterID string
+
+	// keyPair is the keypair used to sign REAR messages and Contracts
+	keyPair *signatures.KeyPair
+
+	// nonces contains the nonces of the REAR messages already received with their expiration time
+	nonces map[string]time.Time
+
+	// noncesLock protects the nonces map
+	noncesLock sync.Mutex
+
+	// auth contains the API tokens and the authorization policy
+	auth *authState
+
+	// authenticators are the authentication methods enabled on the Gateway
+	authenticators []Authenticator
+
+	// limiter applies the rate limits to the Gateway endpoints
+	limiter *rateLimiter
+
+	// idempotency tracks the idempotency keys of the reserve and purchase requests
+	idempotency *idempotencyStore
+
+	// holds serializes the reservations and purchases against the capacity of the Flavours
+	holds *capacityHolds
 }
 
 func NewGateway(c client.Client) *Gateway {
 	return &Gateway{
 		client:       c,
-		Transactions: make(map[string]models.Transaction),
+		Transactions: NewTransactionStore(c),
 		LiqoReady:    false,
 		ClusterID:    "",
+		nonces:       make(map[string]time.Time),
+		auth:         &authState{tokens: make(map[string]string)},
+		limiter:      newRateLimiter(),
+		idempotency:  newIdempotencyStore(),
+		holds:        newCapacityHolds(),
 	}
 }
 
@@ -88,6 +138,31 @@ func (g *Gateway) Start(ctx context.Context) error {
 
 	g.RegisterNodeIdentity(nodeIdentity)
 
+	klog.Info("Getting FLUIDOS Node keypair...")
+
+	keyPair, err := signatures.GetOrCreateKeyPair(ctx, g.client)
+	if err != nil {
+		klog.Errorf("Error getting FLUIDOS Node keypair: %s", err)
+		return err
+	}
+
+	g.keyPair = keyPair
+	// The key is published with the identity, so that the other parties can pin it
+	g.ID.PublicKey = keyPair.EncodedPublicKey()
+
+	klog.Info("Loading the open transactions...")
+
+	if err := g.Transactions.Load(ctx); err != nil {
+		klog.Errorf("Error loading the open transactions: %s", err)
+		return err
+	}
+
+	g.authenticators, err = forgeAuthenticators(g.auth)
+	if err != nil {
+		klog.Errorf("Error configuring the Gateway authentication: %s", err)
+		return err
+	}
+
 	router := mux.NewRouter()
 
 	// middleware for debugging purposes
@@ -96,12 +171,27 @@ func (g *Gateway) Start(ctx context.Context) error {
 	// middleware for readiness
 	router.Use(g.readinessMiddleware)
 
+	// middleware for authentication
+	router.Use(g.authenticationMiddleware)
+
 	// Gateway endpoints
-	router.HandleFunc(LIST_FLAVOURS_PATH, g.getFlavours).Methods("GET")
-	//router.HandleFunc(LIST_FLAVOUR_BY_ID_PATH+"{flavourID}", g.getFlavourByID).Methods("GET")
-	router.HandleFunc(LIST_FLAVOURS_BY_SELECTOR_PATH, g.getFlavoursBySelector).Methods("POST")
-	router.HandleFunc(RESERVE_FLAVOUR_PATH+"{flavourID}", g.reserveFlavour).Methods("POST")
-	router.HandleFunc(PURCHASE_FLAVOUR_PATH+"{transactionID}", g.purchaseFlavour).Methods("POST")
+	router.HandleFunc(LIST_FLAVOURS_PATH, g.rateLimit("listflavours", g.authorize(ActionBrowse, g.getFlavours))).Methods("GET")
+	router.HandleFunc(LIST_FLAVOUR_BY_ID_PATH+"{flavourID}", g.rateLimit("listflavourbyid", g.authorize(ActionBrowse, g.getFlavourByID))).Methods("GET")
+	router.HandleFunc(LIST_FLAVOURS_BY_SELECTOR_PATH, g.rateLimit("listflavoursbyselector", g.authorize(ActionBrowse, g.getFlavoursBySelector))).Methods("POST")
+	router.HandleFunc(RESERVE_FLAVOUR_PATH+"{flavourID}", g.rateLimit("reserveflavour", g.authorize(ActionReserve, g.reserveFlavour))).Methods("POST")
+	router.HandleFunc(PURCHASE_FLAVOUR_PATH+"{transactionID}", g.rateLimit("purchaseflavour", g.authorize(ActionPurchase, g.purchaseFlavour))).Methods("POST")
+	router.HandleFunc(CANCEL_RESERVATION_PATH+"{transactionID}", g.rateLimit("cancelreservation", g.authorize(ActionReserve, g.cancelReservation))).Methods("DELETE")
+	router.HandleFunc(CONTRACTS_PATH, g.rateLimit("listcontracts", g.authorize(ActionPurchase, g.listContracts))).Methods("GET")
+	router.HandleFunc(CONTRACTS_PATH+"/{contractID}", g.rateLimit("getcontract", g.authorize(ActionPurchase, g.getContract))).Methods("GET")
+	router.HandleFunc(CONTRACTS_PATH+"/{contractID}"+RENEW_CONTRACT_SUFFIX, g.rateLimit("renewcontract", g.authorize(ActionPurchase, g.renewContract))).Methods("POST")
+	router.HandleFunc(CONTRACTS_PATH+"/{contractID}"+COUNTERSIGN_CONTRACT_SUFFIX, g.rateLimit("countersigncontract", g.authorize(ActionPurchase, g.countersignContract))).Methods("POST")
+	router.HandleFunc(CONTRACTS_PATH+"/{contractID}"+TERMINATE_CONTRACT_SUFFIX, g.rateLimit("terminatecontract", g.authorize(ActionPurchase, g.terminateContract))).Methods("POST")
+	router.HandleFunc(STATEMENTS_PATH, g.rateLimit("getstatement", g.authorize(ActionPurchase, g.getStatement))).Methods("GET")
+	router.HandleFunc(RFQ_PATH, g.rateLimit("quote", g.authorize(ActionBrowse, g.quote))).Methods("POST")
+
+	// Liqo authentication proxy, authenticating the buyer clusters with the token of their Contracts
+	router.HandleFunc(LIQO_AUTH_PATH+auth.IdsURI, g.rateLimit("liqoids", g.liqoIDs)).Methods("GET")
+	router.HandleFunc(LIQO_AUTH_PATH+auth.CertIdentityURI, g.rateLimit("liqoidentity", g.liqoIdentity)).Methods("POST")
 
 	// Configure the HTTP server
 	srv := &http.Server{
@@ -109,6 +199,16 @@ func (g *Gateway) Start(ctx context.Context) error {
 		Addr:    ":" + flags.HTTP_PORT,
 	}
 
+	if flags.TLS_CERT_FILE != "" {
+		srv.TLSConfig, err = forgeServerTLSConfig()
+		if err != nil {
+			klog.Errorf("Error configuring the Gateway TLS: %s", err)
+			return err
+		}
+		klog.Infof("Starting HTTPS server on port %s", flags.HTTP_PORT)
+		return srv.ListenAndServeTLS(flags.TLS_CERT_FILE, flags.TLS_KEY_FILE)
+	}
+
 	// Start server HTTP
 	klog.Infof("Starting HTTP server on port %s", flags.HTTP_PORT)
 	return srv.ListenAndServe()
@@ -130,7 +230,8 @@ func (g *Gateway) readinessMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !g.LiqoReady {
 			klog.Infof("Liqo not ready yet")
-			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Header().Set("Retry-After", strconv.Itoa(int(flags.LIQO_CHECK_INTERVAL.Seconds())))
+			writeProblem(w, http.StatusServiceUnavailable, models.NOT_READY, "Liqo is not ready yet")
 			return
 		}
 		next.ServeHTTP(w, r)
@@ -146,13 +247,11 @@ func (g *Gateway) CacheRefresher(interval time.Duration) func(ctx context.Contex
 // check expired transactions and remove them from the cache
 func (g *Gateway) refreshCache(ctx context.Context) (bool, error) {
 	klog.Infof("Refreshing cache")
-	for transactionID, transaction := range g.Transactions {
-		if tools.CheckExpiration(transaction.StartTime, flags.EXPIRATION_TRANSACTION) {
-			klog.Infof("Transaction %s expired, removing it from cache...", transactionID)
-			g.removeTransaction(transactionID)
-			return false, nil
-		}
-	}
+	g.Transactions.RemoveExpired(ctx)
+	g.collectTransactions(ctx)
+	g.removeExpiredNonces()
+	g.limiter.removeIdle()
+	g.removeExpiredIdempotencyKeys(ctx)
 	return false, nil
 }
 
@@ -0,0 +1,176 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/models"
+	"github.com/fluidos-project/node/pkg/utils/parseutil"
+	"github.com/fluidos-project/node/pkg/utils/tools"
+)
+
+// capacityHolds serializes the reservations and purchases of the Flavours, so that the capacity
+// of a Flavour held by the open transactions and sold by the Contracts never exceeds its characteristics.
+// An open transaction holds its partition (or the whole Flavour) until it expires, is cancelled or is purchased:
+// a purchased transaction keeps holding the capacity until its Contract is visible to the Gateway.
+type capacityHolds struct {
+	lock      sync.Mutex
+	purchased map[string]models.Transaction
+}
+
+func newCapacityHolds() *capacityHolds {
+	return &capacityHolds{purchased: make(map[string]models.Transaction)}
+}
+
+// forgeHold returns the resources held by a partition of the flavour, or by the whole flavour if the partition is nil
+func forgeHold(characteristics *nodecorev1alpha1.Characteristics, partition *models.Partition) corev1.ResourceList {
+	if partition == nil {
+		return corev1.ResourceList{
+			corev1.ResourceCPU:              characteristics.Cpu,
+			corev1.ResourceMemory:           characteristics.Memory,
+			consts.CHARACTERISTIC_GPU:       characteristics.Gpu,
+			corev1.ResourceEphemeralStorage: characteristics.EphemeralStorage,
+			corev1.ResourceStorage:          characteristics.PersistentStorage,
+		}
+	}
+	return corev1.ResourceList{
+		corev1.ResourceCPU:              partition.Cpu,
+		corev1.ResourceMemory:           partition.Memory,
+		consts.CHARACTERISTIC_GPU:       partition.Gpu,
+		corev1.ResourceEphemeralStorage: partition.EphemeralStorage,
+		corev1.ResourceStorage:          partition.Storage,
+	}
+}
+
+// addHold adds the resources of the hold to the total
+func addHold(total, hold corev1.ResourceList) {
+	for name, quantity := range hold {
+		q := total[name]
+		q.Add(quantity)
+		total[name] = q
+	}
+}
+
+// checkCapacity checks that the flavour has enough capacity left for the requested partition.
+// The Flavour of a node and the pooled Flavour of its pool share the same capacity: the open transactions of either
+// also hold the capacity of the other, until their Contracts are accounted in the characteristics by the local resource manager.
+// It must be called holding the capacityHolds lock.
+func (g *Gateway) checkCapacity(ctx context.Context, flavour *nodecorev1alpha1.Flavour, requested *models.Partition) error {
+	related, err := g.sharingFlavours(ctx, flavour)
+	if err != nil {
+		return err
+	}
+	// holdOf returns the resources held by a transaction on the flavour or on a flavour sharing its capacity
+	holdOf := func(t models.Transaction) corev1.ResourceList {
+		if t.FlavourID == flavour.Name {
+			return forgeHold(&flavour.Spec.Characteristics, t.Partition)
+		}
+		if f, ok := related[t.FlavourID]; ok {
+			return forgeHold(&f.Spec.Characteristics, t.Partition)
+		}
+		return nil
+	}
+
+	held := corev1.ResourceList{}
+
+	for _, t := range g.Transactions.List(ctx) {
+		addHold(held, holdOf(t))
+	}
+
+	var contracts reservationv1alpha1.ContractList
+	if err := g.client.List(ctx, &contracts); err != nil {
+		klog.Errorf("Error when listing Contracts: %s", err)
+		return err
+	}
+
+	sold := make(map[string]bool)
+	for i := range contracts.Items {
+		contract := &contracts.Items[i]
+		sold[contract.Spec.TransactionID] = true
+		if contract.Spec.Seller.NodeID != g.ID.NodeID || contract.Spec.Flavour.Name != flavour.Name {
+			continue
+		}
+		if contract.Status.Phase.Phase == nodecorev1alpha1.PhaseTerminated || tools.IsExpired(contract.Spec.ExpirationTime) {
+			continue
+		}
+		var partition *models.Partition
+		if contract.Spec.Partition != nil {
+			partition = parseutil.ParsePartition(contract.Spec.Partition)
+		}
+		addHold(held, forgeHold(&flavour.Spec.Characteristics, partition))
+	}
+
+	// The Contracts just created may not be in the cache yet: their transactions still hold the capacity
+	for transactionID, t := range g.holds.purchased {
+		if sold[transactionID] {
+			delete(g.holds.purchased, transactionID)
+			continue
+		}
+		addHold(held, holdOf(t))
+	}
+
+	capacity := forgeHold(&flavour.Spec.Characteristics, nil)
+	for name, quantity := range forgeHold(&flavour.Spec.Characteristics, requested) {
+		available := capacity[name].DeepCopy()
+		available.Sub(held[name])
+		if quantity.Cmp(available) > 0 {
+			return fmt.Errorf("flavour %s has not enough %s available (requested %s, available %s)",
+				flavour.Name, name, quantity.String(), available.String())
+		}
+	}
+
+	return nil
+}
+
+// sharingFlavours returns, by name, the Flavours sharing the capacity of the flavour: the pooled Flavour of the pool
+// of a node Flavour, or the node Flavours of the pool of a pooled Flavour
+func (g *Gateway) sharingFlavours(ctx context.Context, flavour *nodecorev1alpha1.Flavour) (map[string]*nodecorev1alpha1.Flavour, error) {
+	var selector client.MatchingLabels
+	if pool, ok := flavour.Labels[consts.FLAVOUR_POOL_LABEL]; ok {
+		selector = client.MatchingLabels{consts.FLAVOUR_NODE_POOL_LABEL: pool}
+	} else if pool, ok := flavour.Labels[consts.FLAVOUR_NODE_POOL_LABEL]; ok {
+		selector = client.MatchingLabels{consts.FLAVOUR_POOL_LABEL: pool}
+	} else {
+		return nil, nil
+	}
+
+	var flavours nodecorev1alpha1.FlavourList
+	if err := g.client.List(ctx, &flavours, client.InNamespace(flavour.Namespace), selector); err != nil {
+		klog.Errorf("Error when listing the Flavours sharing the capacity of Flavour %s: %s", flavour.Name, err)
+		return nil, err
+	}
+
+	related := make(map[string]*nodecorev1alpha1.Flavour, len(flavours.Items))
+	for i := range flavours.Items {
+		related[flavours.Items[i].Name] = &flavours.Items[i]
+	}
+	return related, nil
+}
+
+// recordPurchase keeps the capacity of a purchased transaction held until its Contract is visible.
+// It must be called holding the capacityHolds lock.
+func (g *Gateway) recordPurchase(transaction models.Transaction) {
+	g.holds.purchased[transaction.TransactionID] = transaction
+}
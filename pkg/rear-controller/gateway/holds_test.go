@@ -0,0 +1,308 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+func TestForgeHold(t *testing.T) {
+	characteristics := &nodecorev1alpha1.Characteristics{
+		Cpu:               resource.MustParse("4"),
+		Memory:            resource.MustParse("8Gi"),
+		Gpu:               resource.MustParse("2"),
+		EphemeralStorage:  resource.MustParse("20Gi"),
+		PersistentStorage: resource.MustParse("100Gi"),
+	}
+
+	tests := []struct {
+		name      string
+		partition *models.Partition
+		want      corev1.ResourceList
+	}{
+		{
+			name: "whole flavour",
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("4"),
+				corev1.ResourceMemory:           resource.MustParse("8Gi"),
+				consts.CHARACTERISTIC_GPU:       resource.MustParse("2"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("20Gi"),
+				corev1.ResourceStorage:          resource.MustParse("100Gi"),
+			},
+		},
+		{
+			name:      "partition",
+			partition: &models.Partition{Cpu: resource.MustParse("1"), Memory: resource.MustParse("2Gi"), Gpu: resource.MustParse("1")},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("1"),
+				corev1.ResourceMemory:           resource.MustParse("2Gi"),
+				consts.CHARACTERISTIC_GPU:       resource.MustParse("1"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("0"),
+				corev1.ResourceStorage:          resource.MustParse("0"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := forgeHold(characteristics, tt.partition)
+			if len(got) != len(tt.want) {
+				t.Fatalf("forgeHold() = %v, want %v", got, tt.want)
+			}
+			for name, quantity := range tt.want {
+				if q := got[name]; q.Cmp(quantity) != 0 {
+					t.Errorf("forgeHold()[%s] = %s, want %s", name, q.String(), quantity.String())
+				}
+			}
+		})
+	}
+}
+
+func TestAddHold(t *testing.T) {
+	total := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}
+	addHold(total, corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("1Gi")})
+	addHold(total, nil)
+	addHold(total, corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")})
+
+	want := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1500m"), corev1.ResourceMemory: resource.MustParse("2Gi")}
+	for name, quantity := range want {
+		if q := total[name]; q.Cmp(quantity) != 0 {
+			t.Errorf("addHold()[%s] = %s, want %s", name, q.String(), quantity.String())
+		}
+	}
+}
+
+// heldFlavour returns a Flavour of the seller with the given name and labels, with 4 CPUs and 8Gi of memory
+func heldFlavour(name string, labels map[string]string) *nodecorev1alpha1.Flavour {
+	return &nodecorev1alpha1.Flavour{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: flags.FLUIDOS_NAMESPACE, Labels: labels},
+		Spec: nodecorev1alpha1.FlavourSpec{
+			Characteristics: nodecorev1alpha1.Characteristics{Cpu: resource.MustParse("4"), Memory: resource.MustParse("8Gi")},
+		},
+	}
+}
+
+// soldContract returns a Contract of the seller for a partition of the flavour
+func soldContract(name string, flavour *nodecorev1alpha1.Flavour, seller, cpu, expiration string, phase nodecorev1alpha1.Phase) *reservationv1alpha1.Contract {
+	return &reservationv1alpha1.Contract{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: flags.FLUIDOS_NAMESPACE},
+		Spec: reservationv1alpha1.ContractSpec{
+			Flavour:        *flavour,
+			TransactionID:  name,
+			Partition:      &reservationv1alpha1.Partition{Cpu: resource.MustParse(cpu), Memory: resource.MustParse("1Gi")},
+			Seller:         nodecorev1alpha1.NodeIdentity{NodeID: seller},
+			ExpirationTime: expiration,
+		},
+		Status: reservationv1alpha1.ContractStatus{Phase: nodecorev1alpha1.PhaseStatus{Phase: phase}},
+	}
+}
+
+func TestCheckCapacity(t *testing.T) {
+	flavour := heldFlavour("flavour", nil)
+	other := heldFlavour("other", nil)
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	partition := func(cpu string) *models.Partition {
+		return &models.Partition{Cpu: resource.MustParse(cpu), Memory: resource.MustParse("1Gi")}
+	}
+	transaction := func(id, flavourID string, p *models.Partition) models.Transaction {
+		return models.Transaction{TransactionID: id, FlavourID: flavourID, Partition: p, ExpiresAt: future}
+	}
+
+	tests := []struct {
+		name          string
+		contracts     []client.Object
+		transactions  []models.Transaction
+		purchased     []models.Transaction
+		requested     *models.Partition
+		wantErr       bool
+		wantPurchased int
+	}{
+		{name: "nothing held", requested: partition("4")},
+		{name: "whole flavour free"},
+		{name: "more than the flavour", requested: partition("5"), wantErr: true},
+		{
+			name:         "held by open transactions",
+			transactions: []models.Transaction{transaction("t1", flavour.Name, partition("2")), transaction("t2", flavour.Name, partition("1"))},
+			requested:    partition("2"),
+			wantErr:      true,
+		},
+		{
+			name:         "held by the open transaction of the whole flavour",
+			transactions: []models.Transaction{transaction("t1", flavour.Name, nil)},
+			requested:    partition("1"),
+			wantErr:      true,
+		},
+		{
+			name: "expired transactions release their hold",
+			transactions: []models.Transaction{{TransactionID: "t1", FlavourID: flavour.Name, Partition: partition("4"),
+				ExpiresAt: past}},
+			requested: partition("4"),
+		},
+		{
+			name:         "transactions of other flavours not counted",
+			transactions: []models.Transaction{transaction("t1", other.Name, nil)},
+		},
+		{
+			name:      "sold by active contracts",
+			contracts: []client.Object{soldContract("c1", flavour, "seller", "3", future, nodecorev1alpha1.PhaseActive)},
+			requested: partition("2"),
+			wantErr:   true,
+		},
+		{
+			name: "terminated, expired and foreign contracts not counted",
+			contracts: []client.Object{
+				soldContract("c1", flavour, "seller", "4", future, nodecorev1alpha1.PhaseTerminated),
+				soldContract("c2", flavour, "seller", "4", past, nodecorev1alpha1.PhaseActive),
+				soldContract("c3", flavour, "other", "4", future, nodecorev1alpha1.PhaseActive),
+				soldContract("c4", other, "seller", "4", future, nodecorev1alpha1.PhaseActive),
+			},
+			requested: partition("4"),
+		},
+		{
+			name:          "purchased transactions without contract keep holding",
+			purchased:     []models.Transaction{transaction("t1", flavour.Name, partition("3"))},
+			requested:     partition("2"),
+			wantErr:       true,
+			wantPurchased: 1,
+		},
+		{
+			name:      "purchased transactions with contract released",
+			contracts: []client.Object{soldContract("t1", flavour, "seller", "1", future, nodecorev1alpha1.PhaseActive)},
+			purchased: []models.Transaction{transaction("t1", flavour.Name, partition("1"))},
+			requested: partition("3"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			g := &Gateway{
+				ID:           &nodecorev1alpha1.NodeIdentity{NodeID: "seller"},
+				Transactions: NewMemoryTransactionStore(),
+				client:       newFakeClient(t, append(tt.contracts, flavour.DeepCopy(), other.DeepCopy())...),
+				holds:        newCapacityHolds(),
+			}
+			for _, t := range tt.transactions {
+				_ = g.Transactions.Add(ctx, t)
+			}
+			for _, t := range tt.purchased {
+				g.recordPurchase(t)
+			}
+
+			err := g.checkCapacity(ctx, flavour, tt.requested)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkCapacity() error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(g.holds.purchased) != tt.wantPurchased {
+				t.Errorf("checkCapacity() kept %d purchased transactions, want %d", len(g.holds.purchased), tt.wantPurchased)
+			}
+		})
+	}
+}
+
+func TestCheckCapacitySharedWithPool(t *testing.T) {
+	pool := heldFlavour("pool", map[string]string{consts.FLAVOUR_POOL_LABEL: "gpu"})
+	member := heldFlavour("member", map[string]string{consts.FLAVOUR_NODE_LABEL: "node-1", consts.FLAVOUR_NODE_POOL_LABEL: "gpu"})
+	outsider := heldFlavour("outsider", map[string]string{consts.FLAVOUR_NODE_LABEL: "node-2", consts.FLAVOUR_NODE_POOL_LABEL: "cpu"})
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	partition := func(cpu string) *models.Partition {
+		return &models.Partition{Cpu: resource.MustParse(cpu), Memory: resource.MustParse("1Gi")}
+	}
+	transaction := func(id, flavourID string, p *models.Partition) models.Transaction {
+		return models.Transaction{TransactionID: id, FlavourID: flavourID, Partition: p, ExpiresAt: future}
+	}
+
+	tests := []struct {
+		name         string
+		flavour      *nodecorev1alpha1.Flavour
+		transactions []models.Transaction
+		purchased    []models.Transaction
+		requested    *models.Partition
+		wantErr      bool
+	}{
+		{
+			name:         "member held by the pool",
+			flavour:      member,
+			transactions: []models.Transaction{transaction("t1", pool.Name, partition("3"))},
+			requested:    partition("2"),
+			wantErr:      true,
+		},
+		{
+			name:         "pool held by a member",
+			flavour:      pool,
+			transactions: []models.Transaction{transaction("t1", member.Name, partition("3"))},
+			requested:    partition("2"),
+			wantErr:      true,
+		},
+		{
+			name:      "pool held by the purchase of a member",
+			flavour:   pool,
+			purchased: []models.Transaction{transaction("t1", member.Name, nil)},
+			requested: partition("1"),
+			wantErr:   true,
+		},
+		{
+			name:         "pool not held by the nodes of another pool",
+			flavour:      pool,
+			transactions: []models.Transaction{transaction("t1", outsider.Name, nil)},
+			requested:    partition("4"),
+		},
+		{
+			name:         "nodes of another pool not held by the pool",
+			flavour:      outsider,
+			transactions: []models.Transaction{transaction("t1", pool.Name, nil)},
+			requested:    partition("4"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			g := &Gateway{
+				ID:           &nodecorev1alpha1.NodeIdentity{NodeID: "seller"},
+				Transactions: NewMemoryTransactionStore(),
+				client:       newFakeClient(t, pool.DeepCopy(), member.DeepCopy(), outsider.DeepCopy()),
+				holds:        newCapacityHolds(),
+			}
+			for _, t := range tt.transactions {
+				_ = g.Transactions.Add(ctx, t)
+			}
+			for _, t := range tt.purchased {
+				g.recordPurchase(t)
+			}
+
+			err := g.checkCapacity(ctx, tt.flavour, tt.requested)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkCapacity() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
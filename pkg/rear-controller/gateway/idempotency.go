@@ -0,0 +1,205 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
+	"github.com/fluidos-project/node/pkg/utils/namings"
+)
+
+const (
+	IDEMPOTENCY_KEY_HEADER = "Idempotency-Key"
+
+	idempotencyStatusKey      = "status"
+	idempotencyContentTypeKey = "content-type"
+	idempotencyBodyKey        = "body"
+	idempotencyFingerprintKey = "fingerprint"
+	idempotencySignerKey      = "signer"
+	idempotencyExpirationKey  = "expiration"
+)
+
+// idempotencyStore keeps track of the idempotency keys being processed.
+// The responses are persisted in Secrets, since they can contain the Liqo credentials.
+type idempotencyStore struct {
+	lock     sync.Mutex
+	inFlight map[string]bool
+}
+
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{inFlight: make(map[string]bool)}
+}
+
+// idempotentWriter records the response written by a handler to store it under its idempotency key
+type idempotentWriter struct {
+	http.ResponseWriter
+	g           *Gateway
+	name        string
+	fingerprint string
+	signer      string
+	status      int
+	body        bytes.Buffer
+}
+
+func (iw *idempotentWriter) WriteHeader(status int) {
+	iw.status = status
+	iw.ResponseWriter.WriteHeader(status)
+}
+
+func (iw *idempotentWriter) Write(b []byte) (int, error) {
+	if iw.status == 0 {
+		iw.status = http.StatusOK
+	}
+	iw.body.Write(b)
+	return iw.ResponseWriter.Write(b)
+}
+
+// save persists the recorded response, unless it is a transient error that the buyer should retry
+func (iw *idempotentWriter) save(ctx context.Context) {
+	defer iw.g.idempotency.release(iw.name)
+
+	if iw.status >= http.StatusInternalServerError || iw.status == http.StatusTooManyRequests {
+		return
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      iw.name,
+			Namespace: flags.FLUIDOS_NAMESPACE,
+			Labels:    map[string]string{consts.IDEMPOTENCY_KEY_LABEL: "true"},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			idempotencyStatusKey:      []byte(strconv.Itoa(iw.status)),
+			idempotencyContentTypeKey: []byte(iw.Header().Get("Content-Type")),
+			idempotencyBodyKey:        iw.body.Bytes(),
+			idempotencyFingerprintKey: []byte(iw.fingerprint),
+			idempotencySignerKey:      []byte(iw.signer),
+			idempotencyExpirationKey:  []byte(time.Now().Add(flags.EXPIRATION_IDEMPOTENCY_KEY).Format(time.RFC3339)),
+		},
+	}
+	if err := iw.g.client.Create(ctx, secret); err != nil {
+		klog.Errorf("Error storing the response for idempotency key %s: %s", iw.name, err)
+	}
+}
+
+// release marks the idempotency key as no longer being processed
+func (s *idempotencyStore) release(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.inFlight, name)
+}
+
+// handleIdempotencyKey replays the stored response if the request carries an already used idempotency key.
+// Otherwise, it returns a writer that records the response: the caller must use it and call save once done.
+// The fingerprint identifies the content of the request, excluding the per-attempt fields (nonce, timestamp and signature).
+func (g *Gateway) handleIdempotencyKey(w http.ResponseWriter, r *http.Request, endpoint, buyerID string,
+	fingerprint interface{}, signature *models.Signature) (*idempotentWriter, bool) {
+	key := r.Header.Get(IDEMPOTENCY_KEY_HEADER)
+	if key == "" {
+		return nil, false
+	}
+
+	fp, err := json.Marshal(fingerprint)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error computing the request fingerprint")
+		return nil, true
+	}
+
+	signer := ""
+	if signature != nil {
+		signer = signature.KeyID
+	}
+
+	name := "idempotency-" + namings.ForgeHashString(endpoint+"/"+buyerID+"/"+key, 32)
+
+	g.idempotency.lock.Lock()
+	if g.idempotency.inFlight[name] {
+		g.idempotency.lock.Unlock()
+		writeProblem(w, http.StatusConflict, models.IDEMPOTENCY_KEY_IN_USE, "A request with the same idempotency key is being processed")
+		return nil, true
+	}
+	g.idempotency.inFlight[name] = true
+	g.idempotency.lock.Unlock()
+
+	var secret corev1.Secret
+	err = g.client.Get(r.Context(), types.NamespacedName{Name: name, Namespace: flags.FLUIDOS_NAMESPACE}, &secret)
+	if err == nil {
+		defer g.idempotency.release(name)
+
+		if string(secret.Data[idempotencyFingerprintKey]) != string(fp) || string(secret.Data[idempotencySignerKey]) != signer {
+			writeProblem(w, http.StatusUnprocessableEntity, models.IDEMPOTENCY_KEY_REUSED, "The idempotency key has already been used for a different request")
+			return nil, true
+		}
+
+		klog.Infof("Replaying the response for idempotency key %s", key)
+		status, _ := strconv.Atoi(string(secret.Data[idempotencyStatusKey]))
+		w.Header().Set("Content-Type", string(secret.Data[idempotencyContentTypeKey]))
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(status)
+		w.Write(secret.Data[idempotencyBodyKey])
+		return nil, true
+	}
+	if !apierrors.IsNotFound(err) {
+		g.idempotency.release(name)
+		klog.Errorf("Error getting the response for idempotency key %s: %s", key, err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error checking the idempotency key")
+		return nil, true
+	}
+
+	return &idempotentWriter{
+		ResponseWriter: w,
+		g:              g,
+		name:           name,
+		fingerprint:    string(fp),
+		signer:         signer,
+	}, false
+}
+
+// removeExpiredIdempotencyKeys deletes the stored responses whose idempotency key has expired
+func (g *Gateway) removeExpiredIdempotencyKeys(ctx context.Context) {
+	var secrets corev1.SecretList
+	if err := g.client.List(ctx, &secrets, client.InNamespace(flags.FLUIDOS_NAMESPACE),
+		client.MatchingLabels{consts.IDEMPOTENCY_KEY_LABEL: "true"}); err != nil {
+		klog.Errorf("Error listing the idempotency keys: %s", err)
+		return
+	}
+
+	for i := range secrets.Items {
+		expiration, err := time.Parse(time.RFC3339, string(secrets.Items[i].Data[idempotencyExpirationKey]))
+		if err == nil && time.Now().Before(expiration) {
+			continue
+		}
+		if err := g.client.Delete(ctx, &secrets.Items[i]); client.IgnoreNotFound(err) != nil {
+			klog.Errorf("Error deleting the idempotency key %s: %s", secrets.Items[i].Name, err)
+		}
+	}
+}
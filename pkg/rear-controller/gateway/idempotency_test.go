@@ -0,0 +1,190 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+// idempotentRequest returns a ReserveRequest attempt and its fingerprint, as built by the reserve handler
+func idempotentRequest(flavourID, nonce string) (models.ReserveRequest, models.ReserveRequest) {
+	request := models.ReserveRequest{
+		FlavourID: flavourID,
+		Buyer:     models.NodeIdentity{NodeID: "buyer"},
+		Nonce:     nonce,
+		Timestamp: time.Now().Format(time.RFC3339Nano),
+	}
+	fingerprint := request
+	fingerprint.Nonce, fingerprint.Timestamp = "", ""
+	return request, fingerprint
+}
+
+func TestHandleIdempotencyKey(t *testing.T) {
+	g := &Gateway{client: newFakeClient(t), idempotency: newIdempotencyStore()}
+	signer := &models.Signature{KeyID: "key-1"}
+
+	steps := []struct {
+		name         string
+		key          string
+		buyerID      string
+		flavourID    string
+		nonce        string
+		signature    *models.Signature
+		status       int
+		hold         bool
+		wantWriter   bool
+		wantReplayed bool
+		wantStatus   int
+		wantCode     string
+	}{
+		{name: "no idempotency key", buyerID: "buyer", flavourID: "flavour-1", nonce: "n0", signature: signer, status: http.StatusOK,
+			wantStatus: http.StatusOK},
+		{name: "transient error not stored", key: "k1", buyerID: "buyer", flavourID: "flavour-1", nonce: "n1", signature: signer,
+			status: http.StatusServiceUnavailable, wantWriter: true, wantStatus: http.StatusServiceUnavailable},
+		{name: "rate limited not stored", key: "k1", buyerID: "buyer", flavourID: "flavour-1", nonce: "n2", signature: signer,
+			status: http.StatusTooManyRequests, wantWriter: true, wantStatus: http.StatusTooManyRequests},
+		{name: "first attempt stored", key: "k1", buyerID: "buyer", flavourID: "flavour-1", nonce: "n3", signature: signer,
+			status: http.StatusCreated, wantWriter: true, wantStatus: http.StatusCreated},
+		{name: "retry with a new nonce replayed", key: "k1", buyerID: "buyer", flavourID: "flavour-1", nonce: "n4", signature: signer,
+			wantReplayed: true, wantStatus: http.StatusCreated},
+		{name: "different request", key: "k1", buyerID: "buyer", flavourID: "flavour-2", nonce: "n5", signature: signer,
+			wantStatus: http.StatusUnprocessableEntity, wantCode: models.IDEMPOTENCY_KEY_REUSED},
+		{name: "different signer", key: "k1", buyerID: "buyer", flavourID: "flavour-1", nonce: "n6", signature: &models.Signature{KeyID: "key-2"},
+			wantStatus: http.StatusUnprocessableEntity, wantCode: models.IDEMPOTENCY_KEY_REUSED},
+		{name: "same key of another buyer", key: "k1", buyerID: "other", flavourID: "flavour-1", nonce: "n7", signature: signer,
+			status: http.StatusCreated, wantWriter: true, wantStatus: http.StatusCreated},
+		{name: "key being processed", key: "k2", buyerID: "buyer", flavourID: "flavour-1", nonce: "n8", signature: signer,
+			status: http.StatusCreated, hold: true, wantWriter: true},
+		{name: "concurrent retry", key: "k2", buyerID: "buyer", flavourID: "flavour-1", nonce: "n9", signature: signer,
+			wantStatus: http.StatusConflict, wantCode: models.IDEMPOTENCY_KEY_IN_USE},
+	}
+
+	for _, tt := range steps {
+		t.Run(tt.name, func(t *testing.T) {
+			request, fingerprint := idempotentRequest(tt.flavourID, tt.nonce)
+			r := httptest.NewRequest(http.MethodPost, "/reservations", http.NoBody)
+			if tt.key != "" {
+				r.Header.Set(IDEMPOTENCY_KEY_HEADER, tt.key)
+			}
+			w := httptest.NewRecorder()
+
+			iw, replayed := g.handleIdempotencyKey(w, r, "reserve", tt.buyerID, fingerprint, tt.signature)
+			if replayed != (tt.wantCode != "" || tt.wantReplayed) {
+				t.Fatalf("handleIdempotencyKey() replayed = %t", replayed)
+			}
+			if (iw != nil) != tt.wantWriter {
+				t.Fatalf("handleIdempotencyKey() writer = %v, want writer %t", iw, tt.wantWriter)
+			}
+
+			if !replayed {
+				var rw http.ResponseWriter = w
+				if iw != nil {
+					rw = iw
+				}
+				rw.Header().Set("Content-Type", "application/json")
+				rw.WriteHeader(tt.status)
+				if err := json.NewEncoder(rw).Encode(request); err != nil {
+					t.Fatal(err)
+				}
+				if iw != nil && !tt.hold {
+					iw.save(context.Background())
+				}
+			}
+
+			if tt.hold {
+				return
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Idempotent-Replayed") == "true"; got != tt.wantReplayed {
+				t.Errorf("Idempotent-Replayed = %t, want %t", got, tt.wantReplayed)
+			}
+			if tt.wantReplayed {
+				var stored models.ReserveRequest
+				if err := json.NewDecoder(w.Body).Decode(&stored); err != nil {
+					t.Fatal(err)
+				}
+				if stored.Nonce != "n3" {
+					t.Errorf("replayed nonce = %s, want n3", stored.Nonce)
+				}
+			}
+			if tt.wantCode != "" {
+				var problem models.Problem
+				if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+					t.Fatal(err)
+				}
+				if problem.Code != tt.wantCode {
+					t.Errorf("problem code = %s, want %s", problem.Code, tt.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveExpiredIdempotencyKeys(t *testing.T) {
+	keySecret := func(name, expiration string, labelled bool) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: flags.FLUIDOS_NAMESPACE},
+			Data:       map[string][]byte{idempotencyExpirationKey: []byte(expiration)},
+		}
+		if labelled {
+			secret.Labels = map[string]string{consts.IDEMPOTENCY_KEY_LABEL: "true"}
+		}
+		return secret
+	}
+	past := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	g := &Gateway{client: newFakeClient(t,
+		keySecret("expired", past, true),
+		keySecret("valid", future, true),
+		keySecret("invalid", "tomorrow", true),
+		keySecret("unlabelled", past, false),
+	)}
+	g.removeExpiredIdempotencyKeys(context.Background())
+
+	tests := []struct {
+		name      string
+		wantExist bool
+	}{
+		{name: "expired"},
+		{name: "valid", wantExist: true},
+		{name: "invalid"},
+		{name: "unlabelled", wantExist: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var secret corev1.Secret
+			err := g.client.Get(context.Background(), types.NamespacedName{Name: tt.name, Namespace: flags.FLUIDOS_NAMESPACE}, &secret)
+			if (err == nil) != tt.wantExist {
+				t.Errorf("Secret %s exists = %t, want %t", tt.name, err == nil, tt.wantExist)
+			}
+		})
+	}
+}
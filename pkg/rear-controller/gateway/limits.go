@@ -0,0 +1,240 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
+	"github.com/fluidos-project/node/pkg/utils/parseutil"
+	"github.com/fluidos-project/node/pkg/utils/services"
+)
+
+// limiterIdleTimeout is the time after which an unused limiter is discarded
+const limiterIdleTimeout = 10 * time.Minute
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter keeps a token bucket for each endpoint and caller key (NodeID or source IP)
+type rateLimiter struct {
+	lock     sync.Mutex
+	limiters map[string]*limiterEntry
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{limiters: make(map[string]*limiterEntry)}
+}
+
+// reserve takes a token from the bucket of each key, returning the first key whose bucket is empty and how long the
+// caller has to wait. The tokens are taken only if every bucket has one: otherwise none is consumed.
+func (rl *rateLimiter) reserve(keys []string) (string, time.Duration) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	// The tokens are reserved and cancelled at the same instant: a reservation cancelled after its time to act restores nothing
+	now := time.Now()
+	reservations := make([]*rate.Reservation, 0, len(keys))
+	for _, key := range keys {
+		entry, ok := rl.limiters[key]
+		if !ok {
+			entry = &limiterEntry{limiter: rate.NewLimiter(rate.Limit(flags.RATE_LIMIT), flags.RATE_BURST)}
+			rl.limiters[key] = entry
+		}
+		entry.lastSeen = now
+
+		r := entry.limiter.ReserveN(now, 1)
+		delay := time.Second
+		if r.OK() {
+			delay = r.Delay()
+		}
+		if delay > 0 {
+			r.CancelAt(now)
+			for _, taken := range reservations {
+				taken.CancelAt(now)
+			}
+			return key, delay
+		}
+		reservations = append(reservations, r)
+	}
+	return "", 0
+}
+
+// removeIdle removes the limiters that have not been used recently
+func (rl *rateLimiter) removeIdle() {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	for key, entry := range rl.limiters {
+		if time.Since(entry.lastSeen) > limiterIdleTimeout {
+			delete(rl.limiters, key)
+		}
+	}
+}
+
+// rateLimit wraps a handler applying the per-NodeID and per-source-IP rate limits of the endpoint
+func (g *Gateway) rateLimit(endpoint string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if flags.RATE_LIMIT <= 0 {
+			handler(w, r)
+			return
+		}
+
+		keys := []string{}
+		if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			keys = append(keys, endpoint+"/ip/"+ip)
+		}
+		if id := getIdentity(r); id != nil && id.NodeID != "" {
+			keys = append(keys, endpoint+"/node/"+id.NodeID)
+		}
+
+		if key, delay := g.limiter.reserve(keys); delay > 0 {
+			klog.Infof("Rate limit exceeded for %s", key)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			writeProblem(w, http.StatusTooManyRequests, models.RATE_LIMITED, "Too many requests, retry later")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// checkQuotas checks that a new reservation of the buyer does not exceed the configured quotas.
+// The CPU and memory quotas of the domain account the active Contracts sold to it, its open transactions and the purchased
+// transactions whose Contract is not visible yet, so that reservations each under the quota cannot be bought together.
+// It must be called holding the capacityHolds lock.
+func (g *Gateway) checkQuotas(ctx context.Context, buyer models.NodeIdentity, requested *models.Partition, flavourCpu, flavourMemory resource.Quantity) error {
+	transactions := g.Transactions.List(ctx)
+	if flags.MAX_OPEN_TRANSACTIONS > 0 {
+		open := 0
+		for _, t := range transactions {
+			if t.Buyer.NodeID == buyer.NodeID {
+				open++
+			}
+		}
+		if open >= flags.MAX_OPEN_TRANSACTIONS {
+			return fmt.Errorf("buyer %s has reached the maximum number of open transactions (%d)", buyer.NodeID, flags.MAX_OPEN_TRANSACTIONS)
+		}
+	}
+
+	if flags.MAX_ACTIVE_CONTRACTS <= 0 && flags.MAX_CPU_PER_DOMAIN == "" && flags.MAX_MEMORY_PER_DOMAIN == "" {
+		return nil
+	}
+
+	var contracts reservationv1alpha1.ContractList
+	if err := g.client.List(ctx, &contracts); err != nil {
+		klog.Errorf("Error when listing Contracts: %s", err)
+		return err
+	}
+
+	active := 0
+	cpu := resource.MustParse("0")
+	memory := resource.MustParse("0")
+	sold := make(map[string]bool)
+	for i := range contracts.Items {
+		contract := &contracts.Items[i]
+		sold[contract.Spec.TransactionID] = true
+		if !contract.IsActive() || contract.Spec.Seller.NodeID != g.ID.NodeID {
+			continue
+		}
+		if contract.Spec.Buyer.NodeID == buyer.NodeID {
+			active++
+		}
+		if contract.Spec.Buyer.Domain == buyer.Domain {
+			var partition *models.Partition
+			if contract.Spec.Partition != nil {
+				partition = parseutil.ParsePartition(contract.Spec.Partition)
+			}
+			addUsage(&cpu, &memory, partition, &contract.Spec.Flavour.Spec.Characteristics)
+		}
+	}
+
+	if flags.MAX_ACTIVE_CONTRACTS > 0 && active >= flags.MAX_ACTIVE_CONTRACTS {
+		return fmt.Errorf("buyer %s has reached the maximum number of active contracts (%d)", buyer.NodeID, flags.MAX_ACTIVE_CONTRACTS)
+	}
+
+	for transactionID, t := range g.holds.purchased {
+		if !sold[transactionID] {
+			transactions = append(transactions, t)
+		}
+	}
+	for _, t := range transactions {
+		if t.Buyer.Domain != buyer.Domain {
+			continue
+		}
+		flavour, err := services.GetFlavourByID(t.FlavourID, g.client)
+		if err != nil {
+			// The Flavour of the transaction is gone: it can no longer be purchased
+			continue
+		}
+		addUsage(&cpu, &memory, t.Partition, &flavour.Spec.Characteristics)
+	}
+
+	if requested != nil {
+		cpu.Add(requested.Cpu)
+		memory.Add(requested.Memory)
+	} else {
+		cpu.Add(flavourCpu)
+		memory.Add(flavourMemory)
+	}
+
+	if flags.MAX_CPU_PER_DOMAIN != "" {
+		max, err := resource.ParseQuantity(flags.MAX_CPU_PER_DOMAIN)
+		if err != nil {
+			return err
+		}
+		if cpu.Cmp(max) > 0 {
+			return fmt.Errorf("domain %s would exceed its CPU quota (%s)", buyer.Domain, max.String())
+		}
+	}
+
+	if flags.MAX_MEMORY_PER_DOMAIN != "" {
+		max, err := resource.ParseQuantity(flags.MAX_MEMORY_PER_DOMAIN)
+		if err != nil {
+			return err
+		}
+		if memory.Cmp(max) > 0 {
+			return fmt.Errorf("domain %s would exceed its memory quota (%s)", buyer.Domain, max.String())
+		}
+	}
+
+	return nil
+}
+
+// addUsage adds the CPU and memory of a partition of the flavour, or of the whole flavour if the partition is nil
+func addUsage(cpu, memory *resource.Quantity, partition *models.Partition, characteristics *nodecorev1alpha1.Characteristics) {
+	if partition != nil {
+		cpu.Add(partition.Cpu)
+		memory.Add(partition.Memory)
+		return
+	}
+	cpu.Add(characteristics.Cpu)
+	memory.Add(characteristics.Memory)
+}
@@ -0,0 +1,269 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+// newFakeClient returns a client serving the given Flavours, Contracts and Secrets
+func newFakeClient(t *testing.T, objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := nodecorev1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := reservationv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	oldLimit, oldBurst := flags.RATE_LIMIT, flags.RATE_BURST
+	flags.RATE_LIMIT, flags.RATE_BURST = 0.001, 1
+	t.Cleanup(func() { flags.RATE_LIMIT, flags.RATE_BURST = oldLimit, oldBurst })
+
+	rl := newRateLimiter()
+	steps := []struct {
+		name    string
+		keys    []string
+		wantKey string
+	}{
+		{name: "first request", keys: []string{"ip/a", "node/a"}},
+		{name: "empty ip bucket", keys: []string{"ip/a", "node/b"}, wantKey: "ip/a"},
+		{name: "bucket of the failed request not consumed", keys: []string{"ip/b", "node/b"}},
+		{name: "empty node bucket", keys: []string{"ip/c", "node/a"}, wantKey: "node/a"},
+		{name: "ip bucket of the failed request not consumed", keys: []string{"ip/c"}},
+		{name: "no keys", keys: nil},
+	}
+
+	for _, step := range steps {
+		key, delay := rl.reserve(step.keys)
+		if key != step.wantKey {
+			t.Errorf("%s: reserve() key = %q, want %q", step.name, key, step.wantKey)
+		}
+		if (delay > 0) != (step.wantKey != "") {
+			t.Errorf("%s: reserve() delay = %s", step.name, delay)
+		}
+	}
+}
+
+func TestAddUsage(t *testing.T) {
+	characteristics := &nodecorev1alpha1.Characteristics{Cpu: resource.MustParse("4"), Memory: resource.MustParse("8Gi")}
+
+	tests := []struct {
+		name       string
+		partition  *models.Partition
+		wantCpu    string
+		wantMemory string
+	}{
+		{name: "whole flavour", wantCpu: "5", wantMemory: "9Gi"},
+		{name: "partition", partition: &models.Partition{Cpu: resource.MustParse("500m"), Memory: resource.MustParse("2Gi")},
+			wantCpu: "1500m", wantMemory: "3Gi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu, memory := resource.MustParse("1"), resource.MustParse("1Gi")
+			addUsage(&cpu, &memory, tt.partition, characteristics)
+			if cpu.Cmp(resource.MustParse(tt.wantCpu)) != 0 || memory.Cmp(resource.MustParse(tt.wantMemory)) != 0 {
+				t.Errorf("addUsage() = %s, %s, want %s, %s", cpu.String(), memory.String(), tt.wantCpu, tt.wantMemory)
+			}
+		})
+	}
+}
+
+func TestCheckQuotas(t *testing.T) {
+	seller := nodecorev1alpha1.NodeIdentity{NodeID: "seller", Domain: "seller.eu"}
+	buyer := models.NodeIdentity{NodeID: "buyer", Domain: "buyer.eu"}
+	sibling := nodecorev1alpha1.NodeIdentity{NodeID: "sibling", Domain: "buyer.eu"}
+	flavour := &nodecorev1alpha1.Flavour{
+		ObjectMeta: metav1.ObjectMeta{Name: "flavour", Namespace: flags.FLUIDOS_NAMESPACE},
+		Spec: nodecorev1alpha1.FlavourSpec{
+			Characteristics: nodecorev1alpha1.Characteristics{Cpu: resource.MustParse("4"), Memory: resource.MustParse("8Gi")},
+		},
+	}
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	partition := func(cpu, memory string) *models.Partition {
+		return &models.Partition{Cpu: resource.MustParse(cpu), Memory: resource.MustParse(memory)}
+	}
+	contract := func(name string, b, s nodecorev1alpha1.NodeIdentity, cpu string, expiration string,
+		phase nodecorev1alpha1.Phase) *reservationv1alpha1.Contract {
+		return &reservationv1alpha1.Contract{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: flags.FLUIDOS_NAMESPACE},
+			Spec: reservationv1alpha1.ContractSpec{
+				Flavour:        *flavour,
+				TransactionID:  name,
+				Partition:      &reservationv1alpha1.Partition{Cpu: resource.MustParse(cpu), Memory: resource.MustParse("1Gi")},
+				Buyer:          b,
+				Seller:         s,
+				ExpirationTime: expiration,
+			},
+			Status: reservationv1alpha1.ContractStatus{Phase: nodecorev1alpha1.PhaseStatus{Phase: phase}},
+		}
+	}
+	buyerIdentity := nodecorev1alpha1.NodeIdentity{NodeID: buyer.NodeID, Domain: buyer.Domain}
+	transaction := func(id string, b models.NodeIdentity, p *models.Partition) models.Transaction {
+		return models.Transaction{TransactionID: id, FlavourID: flavour.Name, Buyer: b, Partition: p, ExpiresAt: future}
+	}
+
+	type quotas struct {
+		openTransactions int
+		activeContracts  int
+		cpu              string
+		memory           string
+	}
+	tests := []struct {
+		name         string
+		quotas       quotas
+		contracts    []client.Object
+		transactions []models.Transaction
+		purchased    []models.Transaction
+		requested    *models.Partition
+		wantErr      bool
+	}{
+		{name: "no quotas", requested: partition("8", "16Gi")},
+		{
+			name:         "open transactions under the quota",
+			quotas:       quotas{openTransactions: 2},
+			transactions: []models.Transaction{transaction("t1", buyer, nil)},
+		},
+		{
+			name:   "open transactions at the quota",
+			quotas: quotas{openTransactions: 2},
+			transactions: []models.Transaction{transaction("t1", buyer, nil), transaction("t2", buyer, nil),
+				transaction("t3", models.NodeIdentity{NodeID: "other"}, nil)},
+			wantErr: true,
+		},
+		{
+			name:      "active contracts at the quota",
+			quotas:    quotas{activeContracts: 1},
+			contracts: []client.Object{contract("c1", buyerIdentity, seller, "1", future, nodecorev1alpha1.PhaseActive)},
+			wantErr:   true,
+		},
+		{
+			name:   "terminated, expired and foreign contracts not counted",
+			quotas: quotas{activeContracts: 1},
+			contracts: []client.Object{
+				contract("c1", buyerIdentity, seller, "1", future, nodecorev1alpha1.PhaseTerminated),
+				contract("c2", buyerIdentity, seller, "1", past, nodecorev1alpha1.PhaseActive),
+				contract("c3", buyerIdentity, nodecorev1alpha1.NodeIdentity{NodeID: "other"}, "1", future, nodecorev1alpha1.PhaseActive),
+			},
+		},
+		{
+			name:      "cpu of the domain under the quota",
+			quotas:    quotas{cpu: "4"},
+			contracts: []client.Object{contract("c1", sibling, seller, "2", future, nodecorev1alpha1.PhaseActive)},
+			requested: partition("2", "1Gi"),
+		},
+		{
+			name:      "cpu of the domain over the quota",
+			quotas:    quotas{cpu: "4"},
+			contracts: []client.Object{contract("c1", sibling, seller, "2", future, nodecorev1alpha1.PhaseActive)},
+			requested: partition("3", "1Gi"),
+			wantErr:   true,
+		},
+		{
+			name:         "open transactions of the domain count",
+			quotas:       quotas{cpu: "4"},
+			transactions: []models.Transaction{transaction("t1", models.NodeIdentity{NodeID: "sibling", Domain: buyer.Domain}, partition("3", "1Gi"))},
+			requested:    partition("2", "1Gi"),
+			wantErr:      true,
+		},
+		{
+			name:      "purchased transactions without contract count",
+			quotas:    quotas{cpu: "4"},
+			purchased: []models.Transaction{transaction("t1", buyer, partition("3", "1Gi"))},
+			requested: partition("2", "1Gi"),
+			wantErr:   true,
+		},
+		{
+			name:      "purchased transactions with contract counted once",
+			quotas:    quotas{cpu: "4"},
+			contracts: []client.Object{contract("t1", buyerIdentity, seller, "2", future, nodecorev1alpha1.PhaseActive)},
+			purchased: []models.Transaction{transaction("t1", buyer, partition("2", "1Gi"))},
+			requested: partition("2", "1Gi"),
+		},
+		{
+			name:         "whole flavour requested",
+			quotas:       quotas{cpu: "4"},
+			transactions: []models.Transaction{transaction("t1", buyer, partition("1", "1Gi"))},
+			wantErr:      true,
+		},
+		{
+			name:         "other domains not counted",
+			quotas:       quotas{cpu: "4"},
+			transactions: []models.Transaction{transaction("t1", models.NodeIdentity{NodeID: "other", Domain: "other.eu"}, nil)},
+		},
+		{
+			name:      "memory of the domain over the quota",
+			quotas:    quotas{memory: "4Gi"},
+			contracts: []client.Object{contract("c1", sibling, seller, "1", future, nodecorev1alpha1.PhaseActive)},
+			requested: partition("1", "3500Mi"),
+			wantErr:   true,
+		},
+		{name: "invalid cpu quota", quotas: quotas{cpu: "many"}, requested: partition("1", "1Gi"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := quotas{flags.MAX_OPEN_TRANSACTIONS, flags.MAX_ACTIVE_CONTRACTS, flags.MAX_CPU_PER_DOMAIN, flags.MAX_MEMORY_PER_DOMAIN}
+			flags.MAX_OPEN_TRANSACTIONS, flags.MAX_ACTIVE_CONTRACTS = tt.quotas.openTransactions, tt.quotas.activeContracts
+			flags.MAX_CPU_PER_DOMAIN, flags.MAX_MEMORY_PER_DOMAIN = tt.quotas.cpu, tt.quotas.memory
+			t.Cleanup(func() {
+				flags.MAX_OPEN_TRANSACTIONS, flags.MAX_ACTIVE_CONTRACTS = old.openTransactions, old.activeContracts
+				flags.MAX_CPU_PER_DOMAIN, flags.MAX_MEMORY_PER_DOMAIN = old.cpu, old.memory
+			})
+
+			ctx := context.Background()
+			g := &Gateway{
+				ID:           &seller,
+				Transactions: NewMemoryTransactionStore(),
+				client:       newFakeClient(t, append(tt.contracts, flavour.DeepCopy())...),
+				holds:        newCapacityHolds(),
+			}
+			for _, t := range tt.transactions {
+				_ = g.Transactions.Add(ctx, t)
+			}
+			for _, t := range tt.purchased {
+				g.recordPurchase(t)
+			}
+
+			err := g.checkQuotas(ctx, buyer, tt.requested, flavour.Spec.Characteristics.Cpu, flavour.Spec.Characteristics.Memory)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkQuotas() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
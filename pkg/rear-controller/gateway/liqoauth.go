@@ -0,0 +1,150 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/liqotech/liqo/pkg/auth"
+	foreigncluster "github.com/liqotech/liqo/pkg/utils/foreignCluster"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+// maxLiqoAuthBody is the maximum size of an identity request forwarded to the Liqo authentication service
+const maxLiqoAuthBody = 1 << 20
+
+// LiqoAuthEndpoint returns the URL of the Liqo authentication proxy of the Gateway, given to the buyers as the endpoint to peer with
+func (g *Gateway) LiqoAuthEndpoint() string {
+	return fmt.Sprintf("%s://%s%s", gatewayScheme(), g.ID.IP, LIQO_AUTH_PATH)
+}
+
+// liqoIDs forwards to the Liqo authentication service the request for the identity of the local cluster
+func (g *Gateway) liqoIDs(w http.ResponseWriter, r *http.Request) {
+	g.forwardLiqoAuth(w, r, http.MethodGet, auth.IdsURI, nil)
+}
+
+// liqoIdentity forwards to the Liqo authentication service the identity request of a buyer cluster holding the token of
+// an active Contract bought by that cluster. The token of the Contract is replaced by the token of the local cluster.
+func (g *Gateway) liqoIdentity(w http.ResponseWriter, r *http.Request) {
+	var request auth.CertificateIdentityRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxLiqoAuthBody)).Decode(&request); err != nil {
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, err.Error())
+		return
+	}
+
+	clusterID := request.ClusterIdentity.ClusterID
+	contract, err := g.contractByToken(r.Context(), clusterID, request.DestinationClusterToken)
+	if err != nil {
+		klog.Infof("Identity request of cluster %s rejected: %s", clusterID, err)
+		writeProblem(w, http.StatusUnauthorized, models.UNAUTHORIZED, err.Error())
+		return
+	}
+	klog.Infof("Identity request of cluster %s accepted with the token of Contract %s", clusterID, contract.Name)
+
+	homeToken, err := auth.GetToken(r.Context(), g.client, liqoNamespace)
+	if err != nil {
+		klog.Errorf("Error getting the Liqo token: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error getting the Liqo token")
+		return
+	}
+	request.DestinationClusterToken = homeToken
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, err.Error())
+		return
+	}
+	g.forwardLiqoAuth(w, r, http.MethodPost, auth.CertIdentityURI, body)
+}
+
+// contractByToken returns the Contract sold to the buyer cluster the token has been issued for.
+// The token is rejected once the Contract has expired, has been terminated or its token has been revoked.
+func (g *Gateway) contractByToken(ctx context.Context, clusterID, token string) (*reservationv1alpha1.Contract, error) {
+	if clusterID == "" || token == "" {
+		return nil, fmt.Errorf("missing cluster ID or token")
+	}
+
+	var contracts reservationv1alpha1.ContractList
+	if err := g.client.List(ctx, &contracts, client.MatchingFields{"spec.buyerClusterID": clusterID}); err != nil {
+		return nil, err
+	}
+
+	tokenID := forgeTokenID(token)
+	for i := range contracts.Items {
+		contract := &contracts.Items[i]
+		if subtle.ConstantTimeCompare([]byte(contract.Spec.SellerCredentials.TokenID), []byte(tokenID)) != 1 {
+			continue
+		}
+		if !contract.IsActive() || contract.Status.TokenRevocationTime != "" {
+			return nil, fmt.Errorf("the token of Contract %s has been revoked", contract.Name)
+		}
+		if expiration, err := time.Parse(time.RFC3339, contract.Spec.ExpirationTime); err == nil && time.Now().After(expiration) {
+			return nil, fmt.Errorf("the token of Contract %s has expired", contract.Name)
+		}
+		return contract, nil
+	}
+
+	return nil, fmt.Errorf("no Contract of cluster %s matches the token", clusterID)
+}
+
+// forwardLiqoAuth forwards a request to the Liqo authentication service of the local cluster and copies back its response
+func (g *Gateway) forwardLiqoAuth(w http.ResponseWriter, r *http.Request, method, path string, body []byte) {
+	authURL, err := foreigncluster.GetHomeAuthURL(r.Context(), g.client, liqoNamespace)
+	if err != nil {
+		klog.Errorf("Error getting the URL of the Liqo authentication service: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error getting the URL of the Liqo authentication service")
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), method, authURL+path, bytes.NewReader(body))
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, err.Error())
+		return
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// The Liqo authentication service serves a self-signed certificate, as Liqo peers accept by default
+	httpClient := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		klog.Errorf("Error forwarding the request to the Liqo authentication service: %s", err)
+		writeProblem(w, http.StatusBadGateway, models.INTERNAL_ERROR, "The Liqo authentication service is unreachable")
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		klog.Errorf("Error copying the response of the Liqo authentication service: %s", err)
+	}
+}
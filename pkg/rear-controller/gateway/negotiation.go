@@ -0,0 +1,167 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
+	"github.com/fluidos-project/node/pkg/utils/parseutil"
+	"github.com/fluidos-project/node/pkg/utils/pricing"
+	"github.com/fluidos-project/node/pkg/utils/services"
+)
+
+var (
+	// errInvalidOffer is returned when the offer of the buyer cannot be compared with the price of the flavour
+	errInvalidOffer = errors.New("invalid offer")
+	// errNegotiationFailed is returned when the offer is rejected and no further round is allowed
+	errNegotiationFailed = errors.New("negotiation failed")
+)
+
+// negotiate evaluates the offer of the buyer for the transaction according to the pricing policy of the seller.
+// The offer is accepted if it is not below the list price discounted by MAX_DISCOUNT, otherwise a counter-offer is made
+// until MAX_NEGOTIATION_ROUNDS or NEGOTIATION_TIMEOUT are reached. The resulting offer and state are set in the transaction,
+// along with the rounds the buyer has left after a counter-offer.
+func (g *Gateway) negotiate(ctx context.Context, transaction *models.Transaction, offer *models.Offer) error {
+	negotiation := &models.Negotiation{
+		Round:    1,
+		Deadline: time.Now().Add(flags.NEGOTIATION_TIMEOUT).Format(time.RFC3339),
+	}
+	if transaction.Negotiation != nil {
+		negotiation.Round = transaction.Negotiation.Round + 1
+		negotiation.Deadline = transaction.Negotiation.Deadline
+	}
+
+	if deadline, err := time.Parse(time.RFC3339, negotiation.Deadline); err == nil && time.Now().After(deadline) {
+		return fmt.Errorf("%w: the negotiation timed out at %s", errNegotiationFailed, negotiation.Deadline)
+	}
+	if negotiation.Round > flags.MAX_NEGOTIATION_ROUNDS {
+		return fmt.Errorf("%w: the maximum number of rounds (%d) was reached", errNegotiationFailed, flags.MAX_NEGOTIATION_ROUNDS)
+	}
+
+	flavour, err := services.GetFlavourByID(transaction.FlavourID, g.client)
+	if err != nil || flavour == nil {
+		return fmt.Errorf("flavour %s not found", transaction.FlavourID)
+	}
+
+	offered, commitment, err := normalizeOffer(flavour, offer)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errInvalidOffer, err)
+	}
+
+	// A transaction without partition buys the whole flavour
+	var partition *reservationv1alpha1.Partition
+	if transaction.Partition != nil {
+		partition = parseutil.ParsePartitionFromObj(transaction.Partition)
+	}
+	list, floor, err := floorPrice(flavour, partition, commitment)
+	if err != nil {
+		return err
+	}
+
+	agreed := &models.Offer{
+		Currency: flavour.Spec.Price.Currency,
+		Period:   flavour.Spec.Price.Period,
+		Duration: offer.Duration,
+	}
+
+	switch {
+	case offered.Cmp(floor) >= 0:
+		negotiation.State = string(reservationv1alpha1.NegotiationAccepted)
+		agreed.Amount = pricing.FormatAmount(offered)
+	case negotiation.Round < flags.MAX_NEGOTIATION_ROUNDS:
+		// The counter-offer approaches the floor price as the rounds go by
+		counter := new(big.Rat).Sub(list, floor)
+		counter.Mul(counter, big.NewRat(int64(flags.MAX_NEGOTIATION_ROUNDS-negotiation.Round), int64(flags.MAX_NEGOTIATION_ROUNDS)))
+		counter.Add(counter, floor)
+		negotiation.State = string(reservationv1alpha1.NegotiationCountered)
+		negotiation.RoundsLeft = flags.MAX_NEGOTIATION_ROUNDS - negotiation.Round
+		agreed.Amount = pricing.FormatAmount(counter)
+	default:
+		return fmt.Errorf("%w: the offer %s %s is below the minimum price", errNegotiationFailed, offer.Amount, offer.Currency)
+	}
+
+	klog.Infof("Negotiation of transaction %s, round %d: %s %s/%s", transaction.TransactionID, negotiation.Round,
+		negotiation.State, agreed.Amount, agreed.Period)
+
+	transaction.Offer = agreed
+	transaction.Negotiation = negotiation
+	return nil
+}
+
+// floorPrice returns the list price of the partition of the flavour for the given commitment
+// and the lowest price the seller accepts, that is the list price discounted by MAX_DISCOUNT
+func floorPrice(flavour *nodecorev1alpha1.Flavour, partition *reservationv1alpha1.Partition, commitment time.Duration) (list, floor *big.Rat, err error) {
+	list, err = pricing.PartitionPrice(&flavour.Spec, partition, commitment)
+	if err != nil {
+		return nil, nil, err
+	}
+	maxDiscount, err := pricing.ParseAmount(flags.MAX_DISCOUNT)
+	if err != nil {
+		return nil, nil, err
+	}
+	floor = new(big.Rat).Mul(list, new(big.Rat).Quo(new(big.Rat).Sub(big.NewRat(100, 1), maxDiscount), big.NewRat(100, 1)))
+	return list, floor, nil
+}
+
+// normalizeOffer returns the amount offered per price period of the flavour and the commitment requested by the offer
+func normalizeOffer(flavour *nodecorev1alpha1.Flavour, offer *models.Offer) (*big.Rat, time.Duration, error) {
+	if offer.Currency != flavour.Spec.Price.Currency {
+		return nil, 0, fmt.Errorf("the currency %q differs from the currency of the flavour %q", offer.Currency, flavour.Spec.Price.Currency)
+	}
+
+	amount, err := pricing.ParseAmount(offer.Amount)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if offer.Period != "" {
+		if amount, err = pricing.Rescale(amount, offer.Period, flavour.Spec.Price.Period); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var commitment time.Duration
+	if offer.Duration != "" {
+		if commitment, err = pricing.ParsePeriod(offer.Duration); err != nil {
+			return nil, 0, fmt.Errorf("invalid duration: %w", err)
+		}
+	}
+
+	return amount, commitment, nil
+}
+
+// writeNegotiationProblem writes the problem related to a negotiation error
+func writeNegotiationProblem(w http.ResponseWriter, err error) {
+	klog.Infof("Negotiation not concluded: %s", err)
+	switch {
+	case errors.Is(err, errInvalidOffer):
+		writeProblem(w, http.StatusUnprocessableEntity, models.OFFER_INVALID, err.Error())
+	case errors.Is(err, errNegotiationFailed):
+		writeProblem(w, http.StatusConflict, models.NEGOTIATION_FAILED, err.Error())
+	default:
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error negotiating the price")
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
+	"github.com/fluidos-project/node/pkg/utils/pricing"
+)
+
+// setNegotiationFlags sets the negotiation policy of the seller for the duration of a test
+func setNegotiationFlags(t *testing.T, rounds int, maxDiscount string) {
+	oldRounds, oldDiscount, oldTimeout := flags.MAX_NEGOTIATION_ROUNDS, flags.MAX_DISCOUNT, flags.NEGOTIATION_TIMEOUT
+	flags.MAX_NEGOTIATION_ROUNDS, flags.MAX_DISCOUNT, flags.NEGOTIATION_TIMEOUT = rounds, maxDiscount, time.Minute
+	t.Cleanup(func() {
+		flags.MAX_NEGOTIATION_ROUNDS, flags.MAX_DISCOUNT, flags.NEGOTIATION_TIMEOUT = oldRounds, oldDiscount, oldTimeout
+	})
+}
+
+func pricedFlavour(amount, currency, period string) *nodecorev1alpha1.Flavour {
+	return &nodecorev1alpha1.Flavour{
+		ObjectMeta: metav1.ObjectMeta{Name: "flavour", Namespace: flags.FLUIDOS_NAMESPACE},
+		Spec: nodecorev1alpha1.FlavourSpec{
+			Characteristics: nodecorev1alpha1.Characteristics{Cpu: resource.MustParse("4"), Memory: resource.MustParse("16Gi")},
+			Price:           nodecorev1alpha1.Price{Amount: amount, Currency: currency, Period: period},
+		},
+	}
+}
+
+func TestNormalizeOffer(t *testing.T) {
+	flavour := pricedFlavour("10", "EUR", "PT1H")
+
+	tests := []struct {
+		name           string
+		offer          models.Offer
+		wantAmount     string
+		wantCommitment time.Duration
+		wantErr        bool
+	}{
+		{name: "same period", offer: models.Offer{Amount: "8", Currency: "EUR"}, wantAmount: "8.000000"},
+		{name: "rescaled period", offer: models.Offer{Amount: "48", Currency: "EUR", Period: "P1D"}, wantAmount: "2.000000"},
+		{name: "period by name", offer: models.Offer{Amount: "0.5", Currency: "EUR", Period: "PT30M"}, wantAmount: "1.000000"},
+		{
+			name:           "commitment",
+			offer:          models.Offer{Amount: "8", Currency: "EUR", Duration: "P1M"},
+			wantAmount:     "8.000000",
+			wantCommitment: 30 * 24 * time.Hour,
+		},
+		{name: "different currency", offer: models.Offer{Amount: "8", Currency: "USD"}, wantErr: true},
+		{name: "invalid amount", offer: models.Offer{Amount: "-8", Currency: "EUR"}, wantErr: true},
+		{name: "invalid period", offer: models.Offer{Amount: "8", Currency: "EUR", Period: "often"}, wantErr: true},
+		{name: "invalid duration", offer: models.Offer{Amount: "8", Currency: "EUR", Duration: "100ms"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, commitment, err := normalizeOffer(flavour, &tt.offer)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("normalizeOffer() = %s, want an error", pricing.FormatAmount(amount))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeOffer() error = %s", err)
+			}
+			if pricing.FormatAmount(amount) != tt.wantAmount || commitment != tt.wantCommitment {
+				t.Errorf("normalizeOffer() = %s, %s, want %s, %s", pricing.FormatAmount(amount), commitment,
+					tt.wantAmount, tt.wantCommitment)
+			}
+		})
+	}
+}
+
+func TestFloorPrice(t *testing.T) {
+	tests := []struct {
+		name        string
+		amount      string
+		maxDiscount string
+		wantList    string
+		wantFloor   string
+		wantErr     bool
+	}{
+		{name: "no discount", amount: "10", maxDiscount: "0", wantList: "10.000000", wantFloor: "10.000000"},
+		{name: "unset discount", amount: "10", maxDiscount: "", wantList: "10.000000", wantFloor: "10.000000"},
+		{name: "discount", amount: "10", maxDiscount: "20", wantList: "10.000000", wantFloor: "8.000000"},
+		{name: "decimal discount", amount: "3", maxDiscount: "12.5", wantList: "3.000000", wantFloor: "2.625000"},
+		{name: "invalid discount", amount: "10", maxDiscount: "some", wantErr: true},
+		{name: "invalid amount", amount: "free", maxDiscount: "20", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setNegotiationFlags(t, 3, tt.maxDiscount)
+			list, floor, err := floorPrice(pricedFlavour(tt.amount, "EUR", "PT1H"), nil, 0)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("floorPrice() = %s, %s, want an error", pricing.FormatAmount(list), pricing.FormatAmount(floor))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("floorPrice() error = %s", err)
+			}
+			if pricing.FormatAmount(list) != tt.wantList || pricing.FormatAmount(floor) != tt.wantFloor {
+				t.Errorf("floorPrice() = %s, %s, want %s, %s", pricing.FormatAmount(list), pricing.FormatAmount(floor),
+					tt.wantList, tt.wantFloor)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := nodecorev1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	g := &Gateway{client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pricedFlavour("10", "EUR", "PT1H")).Build()}
+	past := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	future := time.Now().Add(time.Minute).Format(time.RFC3339)
+
+	tests := []struct {
+		name           string
+		flavourID      string
+		partition      *models.Partition
+		previous       *models.Negotiation
+		offer          models.Offer
+		wantState      reservationv1alpha1.NegotiationState
+		wantRound      int
+		wantRoundsLeft int
+		wantAmount     string
+		wantErr        error
+	}{
+		{
+			name:      "accepted above the floor",
+			offer:     models.Offer{Amount: "9", Currency: "EUR"},
+			wantState: reservationv1alpha1.NegotiationAccepted, wantRound: 1, wantAmount: "9.000000",
+		},
+		{
+			name:      "accepted at the floor",
+			offer:     models.Offer{Amount: "192", Currency: "EUR", Period: "P1D"},
+			wantState: reservationv1alpha1.NegotiationAccepted, wantRound: 1, wantAmount: "8.000000",
+		},
+		{
+			name:      "accepted for a partition",
+			partition: &models.Partition{Cpu: resource.MustParse("2"), Memory: resource.MustParse("4Gi")},
+			offer:     models.Offer{Amount: "4", Currency: "EUR"},
+			wantState: reservationv1alpha1.NegotiationAccepted, wantRound: 1, wantAmount: "4.000000",
+		},
+		{
+			name:      "countered for a partition",
+			partition: &models.Partition{Cpu: resource.MustParse("1"), Memory: resource.MustParse("8Gi")},
+			offer:     models.Offer{Amount: "3", Currency: "EUR"},
+			wantState: reservationv1alpha1.NegotiationCountered, wantRound: 1, wantRoundsLeft: 2, wantAmount: "4.666667",
+		},
+		{
+			name:      "countered in the first round",
+			offer:     models.Offer{Amount: "5", Currency: "EUR"},
+			wantState: reservationv1alpha1.NegotiationCountered, wantRound: 1, wantRoundsLeft: 2, wantAmount: "9.333333",
+		},
+		{
+			name:      "counter-offer approaching the floor",
+			previous:  &models.Negotiation{Round: 1, Deadline: future},
+			offer:     models.Offer{Amount: "5", Currency: "EUR"},
+			wantState: reservationv1alpha1.NegotiationCountered, wantRound: 2, wantRoundsLeft: 1, wantAmount: "8.666667",
+		},
+		{
+			name:      "accepted in the last round",
+			previous:  &models.Negotiation{Round: 2, Deadline: future},
+			offer:     models.Offer{Amount: "8.5", Currency: "EUR"},
+			wantState: reservationv1alpha1.NegotiationAccepted, wantRound: 3, wantAmount: "8.500000",
+		},
+		{
+			name:     "rejected in the last round",
+			previous: &models.Negotiation{Round: 2, Deadline: future},
+			offer:    models.Offer{Amount: "5", Currency: "EUR"},
+			wantErr:  errNegotiationFailed,
+		},
+		{
+			name:     "rounds exhausted",
+			previous: &models.Negotiation{Round: 3, Deadline: future},
+			offer:    models.Offer{Amount: "10", Currency: "EUR"},
+			wantErr:  errNegotiationFailed,
+		},
+		{
+			name:     "timed out",
+			previous: &models.Negotiation{Round: 1, Deadline: past},
+			offer:    models.Offer{Amount: "10", Currency: "EUR"},
+			wantErr:  errNegotiationFailed,
+		},
+		{
+			name:    "invalid offer",
+			offer:   models.Offer{Amount: "10", Currency: "USD"},
+			wantErr: errInvalidOffer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setNegotiationFlags(t, 3, "20")
+			transaction := &models.Transaction{TransactionID: "transaction", FlavourID: "flavour",
+				Partition: tt.partition, Negotiation: tt.previous}
+			err := g.negotiate(context.Background(), transaction, &tt.offer)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("negotiate() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("negotiate() error = %s", err)
+			}
+			negotiation := transaction.Negotiation
+			if negotiation.State != string(tt.wantState) || negotiation.Round != tt.wantRound || negotiation.RoundsLeft != tt.wantRoundsLeft {
+				t.Errorf("negotiate() = %s round %d (%d left), want %s round %d (%d left)", negotiation.State,
+					negotiation.Round, negotiation.RoundsLeft, tt.wantState, tt.wantRound, tt.wantRoundsLeft)
+			}
+			if transaction.Offer.Amount != tt.wantAmount || transaction.Offer.Currency != "EUR" || transaction.Offer.Period != "PT1H" {
+				t.Errorf("negotiate() offer = %+v, want %s EUR/PT1H", *transaction.Offer, tt.wantAmount)
+			}
+			if tt.previous != nil && negotiation.Deadline != tt.previous.Deadline {
+				t.Errorf("negotiate() deadline = %s, want %s", negotiation.Deadline, tt.previous.Deadline)
+			}
+		})
+	}
+
+	t.Run("flavour not found", func(t *testing.T) {
+		setNegotiationFlags(t, 3, "20")
+		transaction := &models.Transaction{TransactionID: "transaction", FlavourID: "missing"}
+		if err := g.negotiate(context.Background(), transaction, &models.Offer{Amount: "10", Currency: "EUR"}); err == nil {
+			t.Errorf("negotiate() of a missing flavour succeeded")
+		}
+	})
+}
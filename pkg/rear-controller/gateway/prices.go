@@ -0,0 +1,74 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"k8s.io/klog/v2"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/models"
+	"github.com/fluidos-project/node/pkg/utils/parseutil"
+	"github.com/fluidos-project/node/pkg/utils/pricing"
+)
+
+// forgeComputedPrice computes the price of a partition of the flavour, or of the whole flavour if the partition is nil
+func forgeComputedPrice(flavour *nodecorev1alpha1.Flavour, partition *reservationv1alpha1.Partition) *models.ComputedPrice {
+	amount, err := pricing.PartitionPrice(&flavour.Spec, partition, 0)
+	if err != nil {
+		klog.Errorf("Error computing the price of Flavour %s: %s", flavour.Name, err)
+		return nil
+	}
+
+	computed := &models.ComputedPrice{
+		Amount:   pricing.FormatAmount(amount),
+		Currency: flavour.Spec.Price.Currency,
+		Period:   flavour.Spec.Price.Period,
+	}
+	if partition != nil {
+		computed.Partition = parseutil.ParsePartition(partition)
+	}
+	return computed
+}
+
+// forgeSelectorPartition returns the smallest partition of the flavour requested by the selector,
+// or nil if the flavour is not partitionable or the selector does not request any resource
+func forgeSelectorPartition(flavour *nodecorev1alpha1.Flavour, selector *models.Selector) *reservationv1alpha1.Partition {
+	if flavour.Spec.Policy.Partitionable == nil {
+		return nil
+	}
+
+	switch {
+	case selector.MatchSelector != nil:
+		return &reservationv1alpha1.Partition{
+			Architecture:     selector.Architecture,
+			Cpu:              selector.MatchSelector.Cpu,
+			Memory:           selector.MatchSelector.Memory,
+			Gpu:              selector.MatchSelector.Gpu,
+			EphemeralStorage: selector.MatchSelector.EphemeralStorage,
+			Storage:          selector.MatchSelector.Storage,
+		}
+	case selector.RangeSelector != nil:
+		return &reservationv1alpha1.Partition{
+			Architecture:     selector.Architecture,
+			Cpu:              selector.RangeSelector.MinCpu,
+			Memory:           selector.RangeSelector.MinMemory,
+			Gpu:              selector.RangeSelector.MinGpu,
+			EphemeralStorage: selector.RangeSelector.MinEph,
+			Storage:          selector.RangeSelector.MinStorage,
+		}
+	}
+	return nil
+}
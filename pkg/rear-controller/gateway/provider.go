@@ -17,10 +17,14 @@ package gateway
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -29,11 +33,14 @@ import (
 	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
 	"github.com/fluidos-project/node/pkg/utils/common"
 	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/getters"
 	"github.com/fluidos-project/node/pkg/utils/models"
 	"github.com/fluidos-project/node/pkg/utils/namings"
 	"github.com/fluidos-project/node/pkg/utils/parseutil"
+	"github.com/fluidos-project/node/pkg/utils/pricing"
 	"github.com/fluidos-project/node/pkg/utils/resourceforge"
 	"github.com/fluidos-project/node/pkg/utils/services"
+	"github.com/fluidos-project/node/pkg/utils/signatures"
 	"github.com/fluidos-project/node/pkg/utils/tools"
 )
 
@@ -48,7 +55,7 @@ func (g *Gateway) getFlavours(w http.ResponseWriter, r *http.Request) {
 	flavours, err := services.GetAllFlavours(g.client)
 	if err != nil {
 		klog.Errorf("Error getting all the Flavour CRs: %s", err)
-		http.Error(w, "Error getting all the Flavour CRs", http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error getting all the Flavour CRs")
 		return
 	}
 
@@ -64,7 +71,7 @@ func (g *Gateway) getFlavours(w http.ResponseWriter, r *http.Request) {
 	klog.Infof("Available Flavours: %d", len(flavours))
 	if len(flavours) == 0 {
 		klog.Infof("No available Flavours found")
-		http.Error(w, "No Flavours found", http.StatusNotFound)
+		writeProblem(w, http.StatusNotFound, models.FLAVOUR_NOT_FOUND, "No Flavours found")
 		return
 	}
 
@@ -79,7 +86,8 @@ func (g *Gateway) getFlavours(w http.ResponseWriter, r *http.Request) {
 	}
 
 	klog.Infof("Flavour %s selected - Parsing...", selected.Name)
-	parsed := parseutil.ParseFlavour(selected)
+	parsed := g.parseFlavour(&selected)
+	parsed.ComputedPrice = forgeComputedPrice(&selected, nil)
 
 	klog.Infof("Flavour parsed: %v", parsed)
 
@@ -88,33 +96,49 @@ func (g *Gateway) getFlavours(w http.ResponseWriter, r *http.Request) {
 }
 
 // getFlavourByID gets the flavour CR from the cluster that matches the flavourID
-/* func (g *Gateway) getFlavourByID(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
+func (g *Gateway) getFlavourByID(w http.ResponseWriter, r *http.Request) {
 	// Get the flavourID from the URL
 	params := mux.Vars(r)
 	flavourID := params["flavourID"]
 
+	klog.Infof("Processing request for getting Flavour %s...", flavourID)
+
 	// Get the Flavour that matches the flavourID
 	flavour, err := services.GetFlavourByID(flavourID, g.client)
 	if err != nil {
-		http.Error(w, "Error getting the Flavour by ID", http.StatusInternalServerError)
+		if apierrors.IsNotFound(err) {
+			writeProblem(w, http.StatusNotFound, models.FLAVOUR_NOT_FOUND, "Flavour "+flavourID+" not found")
+			return
+		}
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error getting the Flavour by ID")
 		return
 	}
 
-	if flavour == nil {
-		http.Error(w, "No Flavour found", http.StatusNotFound)
+	if !flavour.Spec.OptionalFields.Availability {
+		writeProblem(w, http.StatusNotFound, models.FLAVOUR_NOT_FOUND, "Flavour "+flavourID+" is not available")
 		return
 	}
 
-	flavourParsed := parseutil.ParseFlavour(*flavour)
+	flavourParsed := g.parseFlavour(flavour)
+	flavourParsed.ComputedPrice = forgeComputedPrice(flavour, nil)
 
 	klog.Infof("Flavour found is: %s", flavourParsed.FlavourID)
 
-	// Encode the FlavourList as JSON and write it to the response writer
-	encodeResponse(w, flavourParsed)
+	etag, err := forgeETag(flavourParsed)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error computing the Flavour ETag")
+		return
+	}
+	w.Header().Set("ETag", etag)
 
-} */
+	if matchETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	// Encode the Flavour as JSON and write it to the response writer
+	encodeResponse(w, flavourParsed)
+}
 
 // getFlavourBySelectorHandler gets the flavour CRs from the cluster that match the selector
 func (g *Gateway) getFlavoursBySelector(w http.ResponseWriter, r *http.Request) {
@@ -125,7 +149,7 @@ func (g *Gateway) getFlavoursBySelector(w http.ResponseWriter, r *http.Request)
 	// Read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, err.Error())
 		return
 	}
 
@@ -133,14 +157,14 @@ func (g *Gateway) getFlavoursBySelector(w http.ResponseWriter, r *http.Request)
 	selector, err := buildSelector(body)
 	if err != nil {
 		klog.Errorf("Error building the selector: %s", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, err.Error())
 		return
 	}
 
 	flavours, err := services.GetAllFlavours(g.client)
 	if err != nil {
 		klog.Errorf("Error getting all the Flavour CRs: %s", err)
-		http.Error(w, "Error getting all the Flavour CRs", http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error getting all the Flavour CRs")
 		return
 	}
 
@@ -156,21 +180,21 @@ func (g *Gateway) getFlavoursBySelector(w http.ResponseWriter, r *http.Request)
 	klog.Infof("Available Flavours: %d", len(flavours))
 	if len(flavours) == 0 {
 		klog.Infof("No available Flavours found")
-		http.Error(w, "No Flavours found", http.StatusNotFound)
+		writeProblem(w, http.StatusNotFound, models.FLAVOUR_NOT_FOUND, "No Flavours found")
 		return
 	}
 
 	klog.Infof("Checking selector syntax...")
 	if err := common.CheckSelector(selector); err != nil {
 		klog.Errorf("Error checking the selector syntax: %s", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, err.Error())
 		return
 	}
 
 	klog.Infof("Filtering Flavours by selector...")
 	flavoursSelected, err := common.FilterFlavoursBySelector(flavours, selector)
 	if err != nil {
-		http.Error(w, "Error getting the Flavours by selector", http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error getting the Flavours by selector")
 		return
 	}
 
@@ -178,7 +202,7 @@ func (g *Gateway) getFlavoursBySelector(w http.ResponseWriter, r *http.Request)
 
 	if len(flavoursSelected) == 0 {
 		klog.Infof("No matching Flavours found")
-		http.Error(w, "No Flavours found", http.StatusNotFound)
+		writeProblem(w, http.StatusNotFound, models.FLAVOUR_NOT_FOUND, "No Flavours found")
 		return
 	}
 
@@ -193,7 +217,8 @@ func (g *Gateway) getFlavoursBySelector(w http.ResponseWriter, r *http.Request)
 	}
 
 	klog.Infof("Flavour %s selected - Parsing...", selected.Name)
-	parsed := parseutil.ParseFlavour(selected)
+	parsed := g.parseFlavour(&selected)
+	parsed.ComputedPrice = forgeComputedPrice(&selected, forgeSelectorPartition(&selected, selector))
 
 	klog.Infof("Flavour parsed: %v", parsed)
 
@@ -211,22 +236,86 @@ func (g *Gateway) reserveFlavour(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		klog.Errorf("Error decoding the ReserveRequest: %s", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, err.Error())
 		return
 	}
 
 	if flavourID != request.FlavourID {
 		klog.Infof("Mismatch body & param: %s != %s", flavourID, request.FlavourID)
-		http.Error(w, "Mismatch body & param", http.StatusConflict)
+		writeProblem(w, http.StatusConflict, models.PARAMETER_MISMATCH, "Mismatch body & param")
+		return
+	}
+
+	if request.ClusterID == "" {
+		klog.Infof("ReserveRequest of %s without the Liqo cluster ID", request.Buyer.NodeID)
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, "Missing the Liqo cluster ID of the buyer")
+		return
+	}
+
+	unsigned := request
+	unsigned.Signature = nil
+	// The key of the buyer is learnt from its first request and pinned in the transaction and in the Contract
+	if err := g.checkMessage(request.Nonce, request.Timestamp, unsigned, request.Signature, request.Buyer.PublicKey); err != nil {
+		klog.Errorf("Error checking the ReserveRequest: %s", err)
+		writeProblem(w, http.StatusUnauthorized, models.INVALID_MESSAGE, "Invalid ReserveRequest: "+err.Error())
+		return
+	}
+
+	if err := checkIdentity(r, request.Buyer.Domain, request.Buyer.NodeID); err != nil {
+		klog.Errorf("Error checking the buyer identity: %s", err)
+		writeProblem(w, http.StatusForbidden, models.FORBIDDEN, err.Error())
 		return
 	}
 
+	fingerprint := unsigned
+	fingerprint.Nonce, fingerprint.Timestamp = "", ""
+	iw, replayed := g.handleIdempotencyKey(w, r, "reserve", request.Buyer.NodeID, fingerprint, request.Signature)
+	if replayed {
+		return
+	}
+	if iw != nil {
+		defer iw.save(context.Background())
+		w = iw
+	}
+
+	// Reservations and purchases are serialized to keep the capacity holds consistent
+	g.holds.lock.Lock()
+	defer g.holds.lock.Unlock()
+
 	// Check if the Transaction already exists
-	t, found := g.SearchTransaction(request.Buyer.NodeID, flavourID)
+	t, found := g.Transactions.Search(r.Context(), request.Buyer.NodeID, flavourID)
 	if found {
+		// The transaction is bound to the key of the buyer that opened it, not to the NodeID it claims
+		if t.Buyer.PublicKey != request.Buyer.PublicKey {
+			klog.Infof("Transaction %s has been reserved with a different key of buyer %s", t.TransactionID, request.Buyer.NodeID)
+			writeProblem(w, http.StatusForbidden, models.FORBIDDEN, "Transaction "+t.TransactionID+" has been reserved by a different buyer key")
+			return
+		}
+		// The transaction holds the capacity of the partition it has been reserved for
+		if !samePartition(t.Partition, request.Partition) {
+			klog.Infof("Transaction %s has been reserved for a different partition", t.TransactionID)
+			writeProblem(w, http.StatusConflict, models.PARTITION_INVALID,
+				"Transaction "+t.TransactionID+" is open for a different partition of the Flavour, cancel it before reserving again")
+			return
+		}
 		t.StartTime = tools.GetTimeNow()
+		t.ExpiresAt = time.Now().Add(flags.EXPIRATION_TRANSACTION).Format(time.RFC3339)
+		if request.Offer != nil {
+			if err := g.negotiate(r.Context(), &t, request.Offer); err != nil {
+				if errors.Is(err, errNegotiationFailed) {
+					if err := g.Transactions.Close(r.Context(), t.TransactionID, nodecorev1alpha1.PhaseCancelled); err != nil {
+						klog.Errorf("Error removing the Transaction %s: %s", t.TransactionID, err)
+					}
+				}
+				writeNegotiationProblem(w, err)
+				return
+			}
+		}
 		transaction = t
-		g.addNewTransacion(t)
+		if err := g.Transactions.Add(r.Context(), t); err != nil {
+			writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error storing the transaction")
+			return
+		}
 	}
 
 	if !found {
@@ -234,22 +323,51 @@ func (g *Gateway) reserveFlavour(w http.ResponseWriter, r *http.Request) {
 
 		flavour, _ := services.GetFlavourByID(flavourID, g.client)
 		if flavour == nil {
-			http.Error(w, "Flavour not found", http.StatusNotFound)
+			writeProblem(w, http.StatusNotFound, models.FLAVOUR_NOT_FOUND, "Flavour "+flavourID+" not found")
+			return
+		}
+
+		if err := common.CheckPartition(flavour, request.Partition); err != nil {
+			klog.Infof("Invalid partition: %s", err)
+			writeProblem(w, http.StatusUnprocessableEntity, models.PARTITION_INVALID, err.Error())
+			return
+		}
+
+		if err := g.checkQuotas(r.Context(), request.Buyer, request.Partition, flavour.Spec.Characteristics.Cpu, flavour.Spec.Characteristics.Memory); err != nil {
+			klog.Infof("Quota exceeded: %s", err)
+			writeProblem(w, http.StatusForbidden, models.QUOTA_EXCEEDED, err.Error())
+			return
+		}
+
+		if err := g.checkCapacity(r.Context(), flavour, request.Partition); err != nil {
+			klog.Infof("Capacity not available: %s", err)
+			writeProblem(w, http.StatusConflict, models.CAPACITY_UNAVAILABLE, err.Error())
 			return
 		}
 
 		// Create a new transaction ID
 		transactionID, err := namings.ForgeTransactionID()
 		if err != nil {
-			http.Error(w, "Error generating transaction ID", http.StatusInternalServerError)
+			writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error generating transaction ID")
 			return
 		}
 
 		// Create a new transaction
-		transaction := resourceforge.ForgeTransactionObj(transactionID, request)
+		transaction = resourceforge.ForgeTransactionObj(transactionID, request)
+
+		// Negotiate the price if the buyer made an offer
+		if request.Offer != nil {
+			if err := g.negotiate(r.Context(), &transaction, request.Offer); err != nil {
+				writeNegotiationProblem(w, err)
+				return
+			}
+		}
 
-		// Add the transaction to the transactions map
-		g.addNewTransacion(transaction)
+		// Add the transaction to the transaction store
+		if err := g.Transactions.Add(r.Context(), transaction); err != nil {
+			writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error storing the transaction")
+			return
+		}
 	}
 
 	klog.Infof("Transaction %s reserved", transaction.TransactionID)
@@ -257,6 +375,15 @@ func (g *Gateway) reserveFlavour(w http.ResponseWriter, r *http.Request) {
 	encodeResponse(w, transaction)
 }
 
+// samePartition checks if two partitions request the same resources
+func samePartition(a, b *models.Partition) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Architecture == b.Architecture && a.Cpu.Cmp(b.Cpu) == 0 && a.Memory.Cmp(b.Memory) == 0 &&
+		a.EphemeralStorage.Cmp(b.EphemeralStorage) == 0 && a.Gpu.Cmp(b.Gpu) == 0 && a.Storage.Cmp(b.Storage) == 0
+}
+
 // purchaseFlavour is an handler for purchasing a Flavour
 func (g *Gateway) purchaseFlavour(w http.ResponseWriter, r *http.Request) {
 	// Get the flavourID value from the URL parameters
@@ -265,35 +392,80 @@ func (g *Gateway) purchaseFlavour(w http.ResponseWriter, r *http.Request) {
 	var purchase models.PurchaseRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&purchase); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, err.Error())
 		return
 	}
 
 	if transactionID != purchase.TransactionID {
 		klog.Infof("Mismatch body & param")
-		http.Error(w, "Mismatch body & param", http.StatusConflict)
+		writeProblem(w, http.StatusConflict, models.PARAMETER_MISMATCH, "Mismatch body & param")
+		return
+	}
+
+	if purchase.PublicKey == "" {
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, "The public key to seal the credentials with is missing")
+		return
+	}
+
+	// The request must be signed by the buyer that reserved the transaction
+	buyer, err := g.transactionBuyer(r.Context(), purchase.TransactionID)
+	if errors.Is(err, ErrTransactionExpired) {
+		klog.Infof("Transaction %s expired", purchase.TransactionID)
+		writeProblem(w, http.StatusGone, models.TRANSACTION_EXPIRED, "Transaction "+purchase.TransactionID+" expired")
 		return
 	}
+	if err != nil {
+		klog.Errorf("Error getting the buyer of the Transaction: %s", err)
+		writeProblem(w, http.StatusNotFound, models.TRANSACTION_NOT_FOUND, "Transaction "+purchase.TransactionID+" not found")
+		return
+	}
+
+	unsigned := purchase
+	unsigned.Signature = nil
+	if err := g.checkMessage(purchase.Nonce, purchase.Timestamp, unsigned, purchase.Signature, buyer.PublicKey); err != nil {
+		klog.Errorf("Error checking the PurchaseRequest: %s", err)
+		writeProblem(w, http.StatusUnauthorized, models.INVALID_MESSAGE, "Invalid PurchaseRequest: "+err.Error())
+		return
+	}
+
+	fingerprint := unsigned
+	fingerprint.Nonce, fingerprint.Timestamp = "", ""
+	iw, replayed := g.handleIdempotencyKey(w, r, "purchase", buyer.NodeID, fingerprint, purchase.Signature)
+	if replayed {
+		return
+	}
+	if iw != nil {
+		defer iw.save(context.Background())
+		w = iw
+	}
 
 	klog.Infof("Purchasing request for transaction %s", purchase.TransactionID)
 
-	// Retrieve the transaction from the transactions map
-	transaction, err := g.GetTransaction(purchase.TransactionID)
+	// Reservations and purchases are serialized to keep the capacity holds consistent
+	g.holds.lock.Lock()
+	defer g.holds.lock.Unlock()
+
+	// Retrieve the transaction from the transaction store
+	transaction, err := g.Transactions.Get(r.Context(), purchase.TransactionID)
+	if errors.Is(err, ErrTransactionExpired) {
+		klog.Infof("Transaction %s expired", purchase.TransactionID)
+		writeProblem(w, http.StatusGone, models.TRANSACTION_EXPIRED, "Transaction "+purchase.TransactionID+" expired")
+		return
+	}
 	if err != nil {
 		klog.Errorf("Error getting the Transaction: %s", err)
-		http.Error(w, "Error getting the Transaction", http.StatusInternalServerError)
+		writeProblem(w, http.StatusNotFound, models.TRANSACTION_NOT_FOUND, "Transaction "+purchase.TransactionID+" not found")
 		return
 	}
 
-	klog.Infof("Flavour requested: %s", transaction.FlavourID)
-
-	if tools.CheckExpiration(transaction.StartTime, flags.EXPIRATION_TRANSACTION) {
-		klog.Infof("Transaction %s expired", transaction.TransactionID)
-		http.Error(w, "Error: transaction Timeout", http.StatusRequestTimeout)
-		g.removeTransaction(transaction.TransactionID)
+	if err := checkIdentity(r, transaction.Buyer.Domain, transaction.Buyer.NodeID); err != nil {
+		klog.Errorf("Error checking the buyer identity: %s", err)
+		writeProblem(w, http.StatusForbidden, models.FORBIDDEN, err.Error())
 		return
 	}
 
+	klog.Infof("Flavour requested: %s", transaction.FlavourID)
+
 	var contractList reservationv1alpha1.ContractList
 	var contract reservationv1alpha1.Contract
 
@@ -301,7 +473,7 @@ func (g *Gateway) purchaseFlavour(w http.ResponseWriter, r *http.Request) {
 	if err := g.client.List(context.Background(), &contractList, client.MatchingFields{"spec.transactionID": purchase.TransactionID}); err != nil {
 		if client.IgnoreNotFound(err) != nil {
 			klog.Errorf("Error when listing Contracts: %s", err)
-			http.Error(w, "Error when listing Contracts", http.StatusInternalServerError)
+			writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error when listing Contracts")
 			return
 		}
 	}
@@ -309,54 +481,210 @@ func (g *Gateway) purchaseFlavour(w http.ResponseWriter, r *http.Request) {
 	if len(contractList.Items) > 0 {
 		klog.Infof("Contract already exists for transaction %s", purchase.TransactionID)
 		contract = contractList.Items[0]
-		// Create a contract object to be returned with the response
-		contractObject := parseutil.ParseContract(&contract)
-		// create a response purchase
-		responsePurchase := resourceforge.ForgeResponsePurchaseObj(contractObject)
-		// Respond with the response purchase as JSON
-		encodeResponse(w, responsePurchase)
+		g.respondPurchase(w, r, &contract, purchase.PublicKey)
 		return
 	}
 
 	klog.Infof("Performing purchase of flavour %s...", transaction.FlavourID)
 
-	// Remove the transaction from the transactions map
-	g.removeTransaction(transaction.TransactionID)
-
-	klog.Infof("Flavour %s successfully purchased!", transaction.FlavourID)
-
 	// Get the flavour sold for creating the contract
 	flavourSold, err := services.GetFlavourByID(transaction.FlavourID, g.client)
 	if err != nil {
 		klog.Errorf("Error getting the Flavour by ID: %s", err)
-		http.Error(w, "Error getting the Flavour by ID", http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error getting the Flavour by ID")
 		return
 	}
 
+	// The term of the Contract is the duration of the agreed offer: a term the buyer did not ask for is never signed
+	if offer := transaction.Offer; offer != nil && offer.Duration != "" {
+		if _, err := pricing.ParsePeriod(offer.Duration); err != nil {
+			klog.Infof("Invalid duration of the offer of transaction %s: %s", transaction.TransactionID, err)
+			writeProblem(w, http.StatusUnprocessableEntity, models.OFFER_INVALID, "Invalid duration: "+err.Error())
+			return
+		}
+	}
+
 	liqoCredentials, err := GetLiqoCredentials(context.Background(), g.client)
 	if err != nil {
 		klog.Errorf("Error getting Liqo Credentials: %s", err)
-		http.Error(w, "Error getting Liqo Credentials", http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error getting Liqo Credentials")
 		return
 	}
 
+	// The buyer cluster authenticates with a token of its own, accepted by the Liqo authentication proxy of the Gateway
+	token, tokenID, err := IssueContractToken()
+	if err != nil {
+		klog.Errorf("Error issuing the Liqo token: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error issuing the Liqo token")
+		return
+	}
+	liqoCredentials.Endpoint = g.LiqoAuthEndpoint()
+	liqoCredentials.TokenID = tokenID
+
 	// Create a new contract
 	klog.Infof("Creating a new contract...")
 	contract = *resourceforge.ForgeContract(*flavourSold, transaction, liqoCredentials)
+	contract.Spec.Seller.PublicKey = g.ID.PublicKey
+
+	// Sign the canonicalised contract
+	unsignedContract := parseutil.ParseContract(&contract)
+	signature, err := g.keyPair.SignContract(&unsignedContract)
+	if err != nil {
+		klog.Errorf("Error signing the Contract: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error signing the Contract")
+		return
+	}
+	contract.Spec.SellerSignature = parseutil.ParseSignatureFromObj(signature)
+
 	err = g.client.Create(context.Background(), &contract)
 	if err != nil {
 		klog.Errorf("Error creating the Contract: %s", err)
-		http.Error(w, "Error creating the Contract: "+err.Error(), http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error creating the Contract: "+err.Error())
 		return
 	}
 
 	klog.Infof("Contract created!")
 
+	// The Liqo token is kept in a Secret owned by the Contract
+	if err := g.client.Create(context.Background(), resourceforge.ForgeCredentialsSecret(&contract, token)); err != nil {
+		klog.Errorf("Error creating the credentials Secret of Contract %s: %s", contract.Name, err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error storing the Liqo credentials")
+		return
+	}
+
+	// The capacity held by the transaction is now sold by the Contract
+	g.recordPurchase(transaction)
+	if err := g.Transactions.Close(r.Context(), transaction.TransactionID, nodecorev1alpha1.PhasePurchased); err != nil {
+		klog.Errorf("Error removing the Transaction %s: %s", transaction.TransactionID, err)
+	}
+
+	klog.Infof("Flavour %s successfully purchased!", transaction.FlavourID)
+
+	contract.SetPhase(nodecorev1alpha1.PhaseActive, "Contract active")
+	if err := g.client.Status().Update(context.Background(), &contract); err != nil {
+		klog.Errorf("Error updating the Contract status: %s", err)
+	}
+
+	g.respondPurchase(w, r, &contract, purchase.PublicKey)
+}
+
+// transactionBuyer returns the buyer of a transaction, either still open or already purchased
+func (g *Gateway) transactionBuyer(ctx context.Context, transactionID string) (models.NodeIdentity, error) {
+	transaction, err := g.Transactions.Get(ctx, transactionID)
+	if !errors.Is(err, ErrTransactionNotFound) {
+		return transaction.Buyer, err
+	}
+
+	// The purchase of a transaction already closed is replayed from its Contract
+	var contractList reservationv1alpha1.ContractList
+	if err := g.client.List(ctx, &contractList, client.MatchingFields{"spec.transactionID": transactionID}); err != nil {
+		return models.NodeIdentity{}, err
+	}
+	if len(contractList.Items) == 0 {
+		return models.NodeIdentity{}, ErrTransactionNotFound
+	}
+	return parseutil.ParseNodeIdentity(contractList.Items[0].Spec.Buyer), nil
+}
+
+// respondPurchase responds with the Contract purchased and its Liqo token, sealed for the key of the buyer
+func (g *Gateway) respondPurchase(w http.ResponseWriter, r *http.Request, contract *reservationv1alpha1.Contract, publicKey string) {
+	token, err := g.contractToken(r.Context(), contract)
+	if err != nil {
+		klog.Errorf("Error getting the Liqo token of Contract %s: %s", contract.Name, err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error getting Liqo Credentials")
+		return
+	}
+
+	credentials, err := signatures.Seal(publicKey, []byte(token), []byte(contract.Name))
+	if err != nil {
+		klog.Errorf("Error sealing the Liqo credentials of Contract %s: %s", contract.Name, err)
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, "Unable to seal the credentials: "+err.Error())
+		return
+	}
+
 	// Create a contract object to be returned with the response
-	contractObject := parseutil.ParseContract(&contract)
+	contractObject := parseutil.ParseContract(contract)
 	// create a response purchase
-	responsePurchase := resourceforge.ForgeResponsePurchaseObj(contractObject)
+	responsePurchase := resourceforge.ForgeResponsePurchaseObj(contractObject, credentials)
 
 	// Respond with the response purchase as JSON
 	encodeResponse(w, responsePurchase)
 }
+
+// contractToken returns the Liqo token of a Contract sold, issuing a new one if its Secret has been lost.
+// The token of a Contract no longer active has been revoked and is not issued again.
+func (g *Gateway) contractToken(ctx context.Context, contract *reservationv1alpha1.Contract) (string, error) {
+	token, err := getters.GetContractToken(ctx, g.client, contract)
+	if !apierrors.IsNotFound(err) {
+		return token, err
+	}
+	if !contract.IsActive() || contract.Status.TokenRevocationTime != "" {
+		return "", fmt.Errorf("the Liqo token of Contract %s has been revoked", contract.Name)
+	}
+
+	klog.Infof("Credentials Secret of Contract %s not found, issuing a new Liqo token", contract.Name)
+	token, tokenID, err := IssueContractToken()
+	if err != nil {
+		return "", err
+	}
+	if err := g.client.Create(ctx, resourceforge.ForgeCredentialsSecret(contract, token)); err != nil && !apierrors.IsAlreadyExists(err) {
+		return "", err
+	}
+	contract.Spec.SellerCredentials.TokenID = tokenID
+	if err := g.client.Update(ctx, contract); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// cancelReservation is an handler for cancelling a reservation by its transactionID
+func (g *Gateway) cancelReservation(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	transactionID := params["transactionID"]
+
+	klog.Infof("Cancelling request for transaction %s", transactionID)
+
+	// A cancellation is serialized with the reservations and purchases, so it cannot interleave with a purchase of the transaction
+	g.holds.lock.Lock()
+	defer g.holds.lock.Unlock()
+
+	transaction, err := g.Transactions.Get(r.Context(), transactionID)
+	if errors.Is(err, ErrTransactionExpired) {
+		klog.Infof("Transaction %s expired", transactionID)
+		writeProblem(w, http.StatusGone, models.TRANSACTION_EXPIRED, "Transaction "+transactionID+" expired")
+		return
+	}
+	if err != nil {
+		klog.Errorf("Error getting the Transaction: %s", err)
+		writeProblem(w, http.StatusNotFound, models.TRANSACTION_NOT_FOUND, "Transaction "+transactionID+" not found")
+		return
+	}
+
+	if err := checkIdentity(r, transaction.Buyer.Domain, transaction.Buyer.NodeID); err != nil {
+		klog.Errorf("Error checking the buyer identity: %s", err)
+		writeProblem(w, http.StatusForbidden, models.FORBIDDEN, err.Error())
+		return
+	}
+
+	// The request must be signed with the key of the buyer pinned in the transaction
+	publicKey, err := g.checkRequestSignature(r)
+	if err != nil {
+		klog.Errorf("Error checking the signature of the cancellation of transaction %s: %s", transactionID, err)
+		writeProblem(w, http.StatusUnauthorized, models.INVALID_MESSAGE, "Invalid cancellation request: "+err.Error())
+		return
+	}
+	if publicKey != transaction.Buyer.PublicKey {
+		klog.Infof("Cancellation of transaction %s not signed by its buyer", transactionID)
+		writeProblem(w, http.StatusForbidden, models.FORBIDDEN, "Transaction "+transactionID+" has been reserved by a different buyer key")
+		return
+	}
+
+	if err := g.Transactions.Close(r.Context(), transactionID, nodecorev1alpha1.PhaseCancelled); err != nil {
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error removing the transaction")
+		return
+	}
+
+	klog.Infof("Transaction %s cancelled", transactionID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,60 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+func TestSamePartition(t *testing.T) {
+	partition := func(cpu, memory string) *models.Partition {
+		return &models.Partition{Architecture: "amd64", Cpu: resource.MustParse(cpu), Memory: resource.MustParse(memory)}
+	}
+	withGpu := partition("2", "4Gi")
+	withGpu.Gpu = resource.MustParse("1")
+	otherArchitecture := partition("2", "4Gi")
+	otherArchitecture.Architecture = "arm64"
+
+	tests := []struct {
+		name string
+		a    *models.Partition
+		b    *models.Partition
+		want bool
+	}{
+		{name: "both whole flavours", want: true},
+		{name: "whole flavour and partition", b: partition("2", "4Gi")},
+		{name: "same resources", a: partition("2", "4Gi"), b: partition("2", "4Gi"), want: true},
+		{name: "same resources in other units", a: partition("2", "4Gi"), b: partition("2000m", "4096Mi"), want: true},
+		{name: "different CPU", a: partition("2", "4Gi"), b: partition("1", "4Gi")},
+		{name: "different memory", a: partition("2", "4Gi"), b: partition("2", "2Gi")},
+		{name: "different GPU", a: partition("2", "4Gi"), b: withGpu},
+		{name: "different architecture", a: partition("2", "4Gi"), b: otherArchitecture},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := samePartition(tt.a, tt.b); got != tt.want {
+				t.Errorf("samePartition() = %t, want %t", got, tt.want)
+			}
+			if got := samePartition(tt.b, tt.a); got != tt.want {
+				t.Errorf("samePartition() swapped = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/common"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
+	"github.com/fluidos-project/node/pkg/utils/parseutil"
+	"github.com/fluidos-project/node/pkg/utils/pricing"
+	"github.com/fluidos-project/node/pkg/utils/services"
+	"github.com/fluidos-project/node/pkg/utils/signatures"
+)
+
+// quote is an handler for answering a request for quote with a sealed bid.
+// The bid offers the available Flavour matching the request at the lowest cost over the requested duration,
+// priced at the lowest price the seller accepts when negotiating, and it is signed by the seller.
+func (g *Gateway) quote(w http.ResponseWriter, r *http.Request) {
+	var request models.RFQRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		klog.Errorf("Error decoding the RFQRequest: %s", err)
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, err.Error())
+		return
+	}
+
+	unsigned := request
+	unsigned.Signature = nil
+	if err := g.checkMessage(request.Nonce, request.Timestamp, unsigned, request.Signature, request.Buyer.PublicKey); err != nil {
+		klog.Errorf("Error checking the RFQRequest: %s", err)
+		writeProblem(w, http.StatusUnauthorized, models.INVALID_MESSAGE, "Invalid RFQRequest: "+err.Error())
+		return
+	}
+
+	if err := checkIdentity(r, request.Buyer.Domain, request.Buyer.NodeID); err != nil {
+		klog.Errorf("Error checking the buyer identity: %s", err)
+		writeProblem(w, http.StatusForbidden, models.FORBIDDEN, err.Error())
+		return
+	}
+
+	klog.Infof("Request for quote %s received from %s", request.RFQID, request.Buyer.NodeID)
+
+	deadline, err := time.Parse(time.RFC3339, request.Deadline)
+	if err != nil || time.Now().After(deadline) {
+		writeProblem(w, http.StatusConflict, models.RFQ_CLOSED, "Request for quote "+request.RFQID+" closed")
+		return
+	}
+
+	duration, err := pricing.ParsePeriod(request.Duration)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, err.Error())
+		return
+	}
+
+	if request.Selector != nil {
+		if err := common.CheckSelector(request.Selector); err != nil {
+			writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, err.Error())
+			return
+		}
+	}
+
+	flavours, err := services.GetAllFlavours(g.client)
+	if err != nil {
+		klog.Errorf("Error getting all the Flavour CRs: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error getting all the Flavour CRs")
+		return
+	}
+
+	var candidates []nodecorev1alpha1.Flavour
+	for i := range flavours {
+		if flavours[i].Spec.OptionalFields.Availability && flavours[i].Spec.Price.Currency == request.Currency {
+			candidates = append(candidates, flavours[i])
+		}
+	}
+	if request.Selector != nil && len(candidates) > 0 {
+		if candidates, err = common.FilterFlavoursBySelector(candidates, request.Selector); err != nil {
+			writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error getting the Flavours by selector")
+			return
+		}
+	}
+
+	// The capacity is checked holding the lock of the holds, as the reservations do
+	g.holds.lock.Lock()
+	defer g.holds.lock.Unlock()
+
+	var best *nodecorev1alpha1.Flavour
+	var bestPartition *reservationv1alpha1.Partition
+	var bestPrice, bestCost *big.Rat
+	for i := range candidates {
+		flavour := &candidates[i]
+		var partition *reservationv1alpha1.Partition
+		if request.Selector != nil {
+			partition = forgeSelectorPartition(flavour, request.Selector)
+		}
+		var requested *models.Partition
+		if partition != nil {
+			requested = parseutil.ParsePartition(partition)
+		}
+		if err := g.checkCapacity(r.Context(), flavour, requested); err != nil {
+			continue
+		}
+
+		_, price, err := floorPrice(flavour, partition, duration)
+		if err != nil {
+			klog.Errorf("Error computing the price of Flavour %s: %s", flavour.Name, err)
+			continue
+		}
+		cost, err := pricing.Prorate(price, flavour.Spec.Price.Period, duration)
+		if err != nil {
+			klog.Errorf("Error computing the cost of Flavour %s: %s", flavour.Name, err)
+			continue
+		}
+		if best == nil || cost.Cmp(bestCost) < 0 {
+			best, bestPartition, bestPrice, bestCost = flavour, partition, price, cost
+		}
+	}
+
+	if best == nil {
+		klog.Infof("No Flavour to bid for the request for quote %s", request.RFQID)
+		writeProblem(w, http.StatusNotFound, models.FLAVOUR_NOT_FOUND, "No Flavour matches the request for quote")
+		return
+	}
+
+	bid := models.Bid{
+		RFQID:   request.RFQID,
+		Seller:  parseutil.ParseNodeIdentity(*g.ID),
+		Flavour: g.parseFlavour(best),
+		Price: models.Offer{
+			Amount:   pricing.FormatAmount(bestPrice),
+			Currency: best.Spec.Price.Currency,
+			Period:   best.Spec.Price.Period,
+			Duration: request.Duration,
+		},
+		ValidUntil: time.Now().Add(flags.BID_VALIDITY).Format(time.RFC3339),
+	}
+	if bestPartition != nil {
+		bid.Partition = parseutil.ParsePartition(bestPartition)
+	}
+
+	bid.Signature, err = g.keyPair.Sign(bid)
+	if err != nil {
+		klog.Errorf("Error signing the Bid: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error signing the Bid")
+		return
+	}
+
+	klog.Infof("Bid for the request for quote %s: Flavour %s at %s %s/%s", request.RFQID, best.Name,
+		bid.Price.Amount, bid.Price.Currency, bid.Price.Period)
+
+	encodeResponse(w, bid)
+}
+
+// RequestQuote sends the request for quote to the provider and returns its sealed bid, once its signature is verified.
+// The request is abandoned when the context is done.
+func (g *Gateway) RequestQuote(ctx context.Context, provider string, request models.RFQRequest) (*models.Bid, error) {
+	err := checkLiqoReadiness(g.LiqoReady)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Buyer = parseutil.ParseNodeIdentity(*g.ID)
+
+	request.Nonce, request.Timestamp, err = forgeNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	request.Signature, err = g.keyPair.Sign(request)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.makeRequestWithContext(ctx, "POST", provider, RFQ_PATH, bytes.NewBuffer(body), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeProblem(resp)
+	}
+
+	var bid models.Bid
+	if err := json.NewDecoder(resp.Body).Decode(&bid); err != nil {
+		return nil, err
+	}
+
+	if bid.RFQID != request.RFQID {
+		return nil, fmt.Errorf("bid for the request for quote %s instead of %s", bid.RFQID, request.RFQID)
+	}
+
+	unsigned := bid
+	unsigned.Signature = nil
+	// The provider is known by its address only: its key is learnt from the bid, which must be signed with it
+	if err := signatures.VerifyFrom(unsigned, bid.Signature, bid.Seller.PublicKey); err != nil {
+		return nil, fmt.Errorf("invalid signature of the bid: %w", err)
+	}
+
+	return &bid, nil
+}
@@ -16,6 +16,7 @@ package gateway
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -23,11 +24,12 @@ import (
 	"k8s.io/klog/v2"
 
 	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/flags"
 	"github.com/fluidos-project/node/pkg/utils/models"
 	"github.com/fluidos-project/node/pkg/utils/resourceforge"
 )
 
-func searchFlavourWithSelector(selector *models.Selector, addr string) (*nodecorev1alpha1.Flavour, error) {
+func (g *Gateway) searchFlavourWithSelector(selector *models.Selector, addr string) (*nodecorev1alpha1.Flavour, error) {
 	var flavour models.Flavour
 
 	// Marshal the selector into JSON bytes
@@ -37,16 +39,15 @@ func searchFlavourWithSelector(selector *models.Selector, addr string) (*nodecor
 	}
 
 	body := bytes.NewBuffer(selectorBytes)
-	url := fmt.Sprintf("http://%s%s", addr, LIST_FLAVOURS_BY_SELECTOR_PATH)
 
-	resp, err := makeRequest("POST", url, body)
+	resp, err := g.makeRequest("POST", addr, LIST_FLAVOURS_BY_SELECTOR_PATH, body, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	// Check if the response status code is 200 (OK)
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("received non-OK response status code: %d", resp.StatusCode)
+		return nil, decodeProblem(resp)
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&flavour); err != nil {
@@ -59,19 +60,17 @@ func searchFlavourWithSelector(selector *models.Selector, addr string) (*nodecor
 	return flavourCR, nil
 }
 
-func searchFlavour(addr string) (*nodecorev1alpha1.Flavour, error) {
+func (g *Gateway) searchFlavour(addr string) (*nodecorev1alpha1.Flavour, error) {
 	var flavour models.Flavour
 
-	url := fmt.Sprintf("http://%s%s", addr, LIST_FLAVOURS_PATH)
-
-	resp, err := makeRequest("GET", url, nil)
+	resp, err := g.makeRequest("GET", addr, LIST_FLAVOURS_PATH, nil, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	// Check if the response status code is 200 (OK)
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("received non-OK response status code: %d", resp.StatusCode)
+		return nil, decodeProblem(resp)
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&flavour); err != nil {
@@ -84,15 +83,32 @@ func searchFlavour(addr string) (*nodecorev1alpha1.Flavour, error) {
 	return flavourCR, nil
 }
 
-func makeRequest(method, url string, body *bytes.Buffer) (*http.Response, error) {
+// makeRequest sends a request with the given additional header to the Gateway at the given address, authenticating it with the configured credentials
+func (g *Gateway) makeRequest(method, addr, path string, body *bytes.Buffer, header http.Header) (*http.Response, error) {
+	return g.makeRequestWithContext(context.Background(), method, addr, path, body, header)
+}
+
+// makeRequestWithContext is like makeRequest, the request being cancelled with the given context
+func (g *Gateway) makeRequestWithContext(ctx context.Context, method, addr, path string, body *bytes.Buffer, header http.Header) (*http.Response, error) {
 
 	httpClient := &http.Client{}
 
+	if flags.TLS_CERT_FILE != "" {
+		tlsConfig, err := forgeClientTLSConfig()
+		if err != nil {
+			klog.Errorf("Error configuring the client TLS: %s", err)
+			return nil, err
+		}
+		httpClient.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+
 	if body == nil {
 		body = bytes.NewBuffer([]byte{})
 	}
 
-	req, err := http.NewRequest(method, url, body)
+	url := fmt.Sprintf("%s://%s%s", gatewayScheme(), addr, path)
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		klog.Errorf("Error creating the request: %s", err)
 		return nil, err
@@ -103,6 +119,23 @@ func makeRequest(method, url string, body *bytes.Buffer) (*http.Response, error)
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	if token := g.getClientToken(req.Context(), addr); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	// The request is signed, so that the other Gateway can identify the caller by its key without authentication
+	if g.keyPair != nil {
+		if err := g.signRequest(req); err != nil {
+			klog.Errorf("Error signing the request: %s", err)
+			return nil, err
+		}
+	}
+
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		klog.Errorf("Error sending the request: %s", err.Error())
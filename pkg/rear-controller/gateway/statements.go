@@ -0,0 +1,106 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/rear-controller/metering"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+var errInvalidPeriod = errors.New("the end of the billing period must follow its beginning")
+
+// getStatement is an handler for getting the statement of the caller over a billing period.
+// The period is the calendar month given with the period query parameter (e.g. 2023-10), the from and to
+// query parameters (RFC3339), or the current month. The statement is returned as JSON, or as CSV with format=csv.
+func (g *Gateway) getStatement(w http.ResponseWriter, r *http.Request) {
+	klog.Infof("Processing request for getting a statement...")
+
+	caller, err := g.getCaller(r)
+	if err != nil {
+		klog.Infof("Unable to identify the caller: %s", err)
+		writeProblem(w, http.StatusUnauthorized, models.UNAUTHORIZED, "Unable to identify the caller: "+err.Error())
+		return
+	}
+
+	from, to, err := parseBillingPeriod(r)
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, models.BAD_REQUEST, err.Error())
+		return
+	}
+
+	var records reservationv1alpha1.UsageRecordList
+	if err := g.client.List(r.Context(), &records, client.InNamespace(flags.FLUIDOS_NAMESPACE)); err != nil {
+		klog.Errorf("Error when listing UsageRecords: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error when listing UsageRecords")
+		return
+	}
+
+	statement := metering.BuildStatement(records.Items, caller, from, to)
+
+	if r.URL.Query().Get("format") != "csv" {
+		encodeResponse(w, statement)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := metering.WriteCSV(&buf, &statement); err != nil {
+		klog.Errorf("Error when writing the statement: %s", err)
+		writeProblem(w, http.StatusInternalServerError, models.INTERNAL_ERROR, "Error when writing the statement")
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// parseBillingPeriod returns the billing period requested through the query parameters
+func parseBillingPeriod(r *http.Request) (from, to time.Time, err error) {
+	query := r.URL.Query()
+
+	if period := query.Get("period"); period != "" {
+		month, err := time.Parse("2006-01", period)
+		if err != nil {
+			return from, to, err
+		}
+		from, to = metering.BillingPeriod(month)
+		return from, to, nil
+	}
+
+	from, to = metering.BillingPeriod(time.Now())
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, err
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, err
+		}
+	}
+	if !to.After(from) {
+		return from, to, errInvalidPeriod
+	}
+	return from, to, nil
+}
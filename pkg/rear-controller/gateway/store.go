@@ -0,0 +1,330 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
+	"github.com/fluidos-project/node/pkg/utils/namings"
+	"github.com/fluidos-project/node/pkg/utils/parseutil"
+	"github.com/fluidos-project/node/pkg/utils/resourceforge"
+	"github.com/fluidos-project/node/pkg/utils/tools"
+)
+
+const (
+	TRANSACTION_STORE_MEMORY = "memory"
+	TRANSACTION_STORE_CRD    = "crd"
+)
+
+var (
+	// ErrTransactionNotFound is returned when a transaction is not in the store
+	ErrTransactionNotFound = fmt.Errorf("transaction not found")
+
+	// ErrTransactionExpired is returned when a transaction has expired but has not been removed yet
+	ErrTransactionExpired = fmt.Errorf("transaction expired")
+)
+
+// TransactionStore keeps the open transactions of the seller FLUIDOS Node.
+// Implementations must be safe for concurrent use and must not return expired transactions.
+type TransactionStore interface {
+	// Load rebuilds the store from its backend, dropping the expired transactions
+	Load(ctx context.Context) error
+
+	// Get returns the open transaction with the given ID, or ErrTransactionExpired if it has expired
+	Get(ctx context.Context, transactionID string) (models.Transaction, error)
+
+	// Search returns the open transaction of the buyer for the flavour, if any
+	Search(ctx context.Context, buyerID, flavourID string) (models.Transaction, bool)
+
+	// List returns all the open transactions
+	List(ctx context.Context) []models.Transaction
+
+	// Add adds or replaces a transaction
+	Add(ctx context.Context, transaction models.Transaction) error
+
+	// Close removes an open transaction, recording the phase it ended in (Purchased or Cancelled)
+	Close(ctx context.Context, transactionID string, phase nodecorev1alpha1.Phase) error
+
+	// RemoveExpired closes the expired transactions and returns them
+	RemoveExpired(ctx context.Context) []models.Transaction
+}
+
+// NewTransactionStore returns the TransactionStore selected by the TRANSACTION_STORE flag
+func NewTransactionStore(c client.Client) TransactionStore {
+	if flags.TRANSACTION_STORE == TRANSACTION_STORE_MEMORY {
+		return NewMemoryTransactionStore()
+	}
+	return NewCRDTransactionStore(c)
+}
+
+// isExpired checks if the transaction can no longer be purchased
+func isExpired(transaction *models.Transaction) bool {
+	if transaction.ExpiresAt != "" {
+		return tools.IsExpired(transaction.ExpiresAt)
+	}
+	return tools.CheckExpiration(transaction.StartTime, flags.EXPIRATION_TRANSACTION)
+}
+
+// MemoryTransactionStore is a TransactionStore that keeps the transactions in memory
+type MemoryTransactionStore struct {
+	lock         sync.RWMutex
+	transactions map[string]models.Transaction
+}
+
+// NewMemoryTransactionStore creates an empty MemoryTransactionStore
+func NewMemoryTransactionStore() *MemoryTransactionStore {
+	return &MemoryTransactionStore{transactions: make(map[string]models.Transaction)}
+}
+
+func (s *MemoryTransactionStore) Load(ctx context.Context) error {
+	return nil
+}
+
+func (s *MemoryTransactionStore) Get(ctx context.Context, transactionID string) (models.Transaction, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	transaction, exists := s.transactions[transactionID]
+	if !exists {
+		return models.Transaction{}, ErrTransactionNotFound
+	}
+	if isExpired(&transaction) {
+		return models.Transaction{}, ErrTransactionExpired
+	}
+	return transaction, nil
+}
+
+func (s *MemoryTransactionStore) Search(ctx context.Context, buyerID, flavourID string) (models.Transaction, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, t := range s.transactions {
+		if t.Buyer.NodeID == buyerID && t.FlavourID == flavourID && !isExpired(&t) {
+			return t, true
+		}
+	}
+	return models.Transaction{}, false
+}
+
+func (s *MemoryTransactionStore) List(ctx context.Context) []models.Transaction {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	transactions := make([]models.Transaction, 0, len(s.transactions))
+	for _, t := range s.transactions {
+		if !isExpired(&t) {
+			transactions = append(transactions, t)
+		}
+	}
+	return transactions
+}
+
+func (s *MemoryTransactionStore) Add(ctx context.Context, transaction models.Transaction) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.transactions[transaction.TransactionID] = transaction
+	return nil
+}
+
+func (s *MemoryTransactionStore) Close(ctx context.Context, transactionID string, phase nodecorev1alpha1.Phase) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.transactions, transactionID)
+	return nil
+}
+
+func (s *MemoryTransactionStore) RemoveExpired(ctx context.Context) []models.Transaction {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var expired []models.Transaction
+	for transactionID, t := range s.transactions {
+		if isExpired(&t) {
+			klog.Infof("Transaction %s expired, removing it from the store...", transactionID)
+			expired = append(expired, t)
+			delete(s.transactions, transactionID)
+		}
+	}
+	return expired
+}
+
+// CRDTransactionStore is a TransactionStore that persists the transactions as Transaction CRs,
+// named after the transaction ID with the seller- prefix.
+// The open transactions are cached in memory and the cache is rebuilt from the CRs by Load.
+// Closed transactions are kept, with their final phase, until they are garbage collected.
+type CRDTransactionStore struct {
+	client client.Client
+	cache  *MemoryTransactionStore
+}
+
+// NewCRDTransactionStore creates a CRDTransactionStore backed by the given client
+func NewCRDTransactionStore(c client.Client) *CRDTransactionStore {
+	return &CRDTransactionStore{client: c, cache: NewMemoryTransactionStore()}
+}
+
+func (s *CRDTransactionStore) Load(ctx context.Context) error {
+	var transactionList reservationv1alpha1.TransactionList
+	if err := s.client.List(ctx, &transactionList, client.InNamespace(flags.FLUIDOS_NAMESPACE),
+		client.MatchingLabels{consts.TRANSACTION_ROLE_LABEL: consts.TRANSACTION_ROLE_SELLER}); err != nil {
+		klog.Errorf("Error when listing Transactions: %s", err)
+		return err
+	}
+
+	for i := range transactionList.Items {
+		if transactionList.Items[i].IsFinished() {
+			continue
+		}
+		transaction := parseutil.ParseTransaction(&transactionList.Items[i])
+		transaction.TransactionID = namings.RetrieveTransactionIDFromSellerTransaction(transactionList.Items[i].Name)
+		if isExpired(&transaction) {
+			klog.Infof("Transaction %s expired while the Gateway was down", transaction.TransactionID)
+			if err := s.setPhase(ctx, transaction.TransactionID, nodecorev1alpha1.PhaseExpired); err != nil {
+				klog.Errorf("Error when updating Transaction %s: %s", transaction.TransactionID, err)
+			}
+			continue
+		}
+		_ = s.cache.Add(ctx, transaction)
+	}
+
+	klog.Infof("Loaded %d open transactions", len(s.cache.List(ctx)))
+	return nil
+}
+
+func (s *CRDTransactionStore) Get(ctx context.Context, transactionID string) (models.Transaction, error) {
+	return s.cache.Get(ctx, transactionID)
+}
+
+func (s *CRDTransactionStore) Search(ctx context.Context, buyerID, flavourID string) (models.Transaction, bool) {
+	return s.cache.Search(ctx, buyerID, flavourID)
+}
+
+func (s *CRDTransactionStore) List(ctx context.Context) []models.Transaction {
+	return s.cache.List(ctx)
+}
+
+func (s *CRDTransactionStore) Add(ctx context.Context, transaction models.Transaction) error {
+	s.cache.lock.Lock()
+	defer s.cache.lock.Unlock()
+
+	transactionCR := resourceforge.ForgeTransactionFromObj(&transaction)
+	transactionCR.Name = namings.ForgeSellerTransactionName(transaction.TransactionID)
+	transactionCR.Labels = map[string]string{consts.TRANSACTION_ROLE_LABEL: consts.TRANSACTION_ROLE_SELLER}
+
+	var existing reservationv1alpha1.Transaction
+	err := s.client.Get(ctx, client.ObjectKeyFromObject(transactionCR), &existing)
+	switch {
+	case client.IgnoreNotFound(err) != nil:
+		return err
+	case err != nil:
+		if err := s.client.Create(ctx, transactionCR); err != nil {
+			klog.Errorf("Error when creating Transaction %s: %s", transaction.TransactionID, err)
+			return err
+		}
+		transactionCR.SetPhase(nodecorev1alpha1.PhaseReserved, "Transaction reserved")
+		if err := s.client.Status().Update(ctx, transactionCR); err != nil {
+			klog.Errorf("Error when updating Transaction %s status: %s", transaction.TransactionID, err)
+			return err
+		}
+	default:
+		existing.Spec = transactionCR.Spec
+		if err := s.client.Update(ctx, &existing); err != nil {
+			klog.Errorf("Error when updating Transaction %s: %s", transaction.TransactionID, err)
+			return err
+		}
+	}
+
+	s.cache.transactions[transaction.TransactionID] = transaction
+	return nil
+}
+
+func (s *CRDTransactionStore) Close(ctx context.Context, transactionID string, phase nodecorev1alpha1.Phase) error {
+	s.cache.lock.Lock()
+	defer s.cache.lock.Unlock()
+
+	if err := s.setPhase(ctx, transactionID, phase); err != nil {
+		return err
+	}
+	delete(s.cache.transactions, transactionID)
+	return nil
+}
+
+func (s *CRDTransactionStore) RemoveExpired(ctx context.Context) []models.Transaction {
+	expired := s.cache.RemoveExpired(ctx)
+	for i := range expired {
+		if err := s.setPhase(ctx, expired[i].TransactionID, nodecorev1alpha1.PhaseExpired); err != nil {
+			klog.Errorf("Error when updating Transaction %s: %s", expired[i].TransactionID, err)
+		}
+	}
+	return expired
+}
+
+// setPhase records the phase of the Transaction CR
+func (s *CRDTransactionStore) setPhase(ctx context.Context, transactionID string, phase nodecorev1alpha1.Phase) error {
+	var transactionCR reservationv1alpha1.Transaction
+	name := namings.ForgeSellerTransactionName(transactionID)
+	if err := s.client.Get(ctx, client.ObjectKey{Name: name, Namespace: flags.FLUIDOS_NAMESPACE}, &transactionCR); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		klog.Errorf("Error when getting Transaction %s: %s", transactionID, err)
+		return err
+	}
+	transactionCR.SetPhase(phase, "Transaction "+strings.ToLower(string(phase)))
+	if err := s.client.Status().Update(ctx, &transactionCR); err != nil {
+		klog.Errorf("Error when updating Transaction %s status: %s", transactionID, err)
+		return err
+	}
+	return nil
+}
+
+// collectTransactions expires the reserved transactions of the buyer that were not purchased in time
+// and deletes the Transaction CRs, of both the buyer and the seller, finished for longer than RETENTION_TRANSACTION.
+func (g *Gateway) collectTransactions(ctx context.Context) {
+	var transactionList reservationv1alpha1.TransactionList
+	if err := g.client.List(ctx, &transactionList, client.InNamespace(flags.FLUIDOS_NAMESPACE)); err != nil {
+		klog.Errorf("Error when listing Transactions: %s", err)
+		return
+	}
+
+	for i := range transactionList.Items {
+		transaction := &transactionList.Items[i]
+
+		if transaction.Labels[consts.TRANSACTION_ROLE_LABEL] == consts.TRANSACTION_ROLE_BUYER &&
+			transaction.Status.Phase.Phase == nodecorev1alpha1.PhaseReserved && tools.IsExpired(transaction.Spec.ExpirationTime) {
+			klog.Infof("Transaction %s expired", transaction.Name)
+			transaction.SetPhase(nodecorev1alpha1.PhaseExpired, "Transaction expired")
+			if err := g.client.Status().Update(ctx, transaction); err != nil {
+				klog.Errorf("Error when updating Transaction %s status: %s", transaction.Name, err)
+			}
+			continue
+		}
+
+		if !transaction.IsFinished() || !tools.CheckExpiration(transaction.Status.Phase.LastChangeTime, flags.RETENTION_TRANSACTION) {
+			continue
+		}
+		klog.Infof("Transaction %s finished, removing it...", transaction.Name)
+		if err := g.client.Delete(ctx, transaction); client.IgnoreNotFound(err) != nil {
+			klog.Errorf("Error when deleting Transaction %s: %s", transaction.Name, err)
+		}
+	}
+}
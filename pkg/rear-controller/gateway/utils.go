@@ -16,17 +16,27 @@ package gateway
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/liqotech/liqo/pkg/auth"
 	"github.com/liqotech/liqo/pkg/utils"
 	foreigncluster "github.com/liqotech/liqo/pkg/utils/foreignCluster"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
 	reservationsv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/flags"
 	"github.com/fluidos-project/node/pkg/utils/models"
+	"github.com/fluidos-project/node/pkg/utils/namings"
+	"github.com/fluidos-project/node/pkg/utils/parseutil"
+	"github.com/fluidos-project/node/pkg/utils/signatures"
 )
 
 const (
@@ -48,38 +58,150 @@ func buildSelector(body []byte) (*models.Selector, error) {
 	return &selector, nil
 }
 
-// getTransaction returns a transaction from the transactions map
-func (g *Gateway) GetTransaction(transactionID string) (models.Transaction, error) {
-	transaction, exists := g.Transactions[transactionID]
-	if !exists {
-		return models.Transaction{}, fmt.Errorf("Transaction not found")
+// forgeNonce returns a new nonce and the current timestamp to be set in a REAR message
+func forgeNonce() (nonce, timestamp string, err error) {
+	nonce, err = namings.ForgeRandomString()
+	if err != nil {
+		return "", "", err
 	}
-	return transaction, nil
+	return nonce, time.Now().UTC().Format(time.RFC3339), nil
 }
 
-// SearchTransaction returns a transaction from the transactions map
-func (g *Gateway) SearchTransaction(buyerID string, flavourID string) (models.Transaction, bool) {
-	for _, t := range g.Transactions {
-		if t.Buyer.NodeID == buyerID && t.FlavourID == flavourID {
-			return t, true
-		}
+// checkMessage verifies that a REAR message is fresh, has not been received yet and is signed by the expected sender,
+// identified by its base64 encoded public key. The unsigned parameter is the message without its signature.
+func (g *Gateway) checkMessage(nonce, timestamp string, unsigned interface{}, signature *models.Signature, publicKey string) error {
+	if nonce == "" {
+		return fmt.Errorf("nonce is missing")
+	}
+
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+
+	if time.Since(t) > flags.EXPIRATION_MESSAGE || time.Until(t) > flags.EXPIRATION_MESSAGE {
+		return fmt.Errorf("timestamp %s is outside the accepted window", timestamp)
+	}
+
+	if err := signatures.VerifyFrom(unsigned, signature, publicKey); err != nil {
+		return err
+	}
+
+	g.noncesLock.Lock()
+	defer g.noncesLock.Unlock()
+
+	if _, found := g.nonces[nonce]; found {
+		return fmt.Errorf("nonce %s has already been used", nonce)
+	}
+	g.nonces[nonce] = t.Add(flags.EXPIRATION_MESSAGE)
+
+	return nil
+}
+
+// requestTarget is the part of a REAR request signed in its headers: it identifies the caller of the requests
+// whose body is not signed, such as the ones to get the Contracts and the statements
+type requestTarget struct {
+	Method    string `json:"method"`
+	Target    string `json:"target"`
+	Nonce     string `json:"nonce"`
+	Timestamp string `json:"timestamp"`
+}
+
+// signRequest signs the method and the target of a request, setting the signature in its headers
+func (g *Gateway) signRequest(req *http.Request) error {
+	nonce, timestamp, err := forgeNonce()
+	if err != nil {
+		return err
+	}
+
+	signature, err := g.keyPair.Sign(requestTarget{Method: req.Method, Target: req.URL.RequestURI(), Nonce: nonce, Timestamp: timestamp})
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(signature)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(NONCE_HEADER, nonce)
+	req.Header.Set(TIMESTAMP_HEADER, timestamp)
+	req.Header.Set(SIGNATURE_HEADER, base64.StdEncoding.EncodeToString(encoded))
+	return nil
+}
+
+// checkRequestSignature verifies the signature in the headers of a request and returns the key of the caller.
+// The key is the one declared by the caller: the signature proves that the caller holds it.
+func (g *Gateway) checkRequestSignature(r *http.Request) (string, error) {
+	encoded := r.Header.Get(SIGNATURE_HEADER)
+	if encoded == "" {
+		return "", fmt.Errorf("request signature is missing")
 	}
-	return models.Transaction{}, false
+
+	var signature models.Signature
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || json.Unmarshal(decoded, &signature) != nil {
+		return "", fmt.Errorf("malformed request signature")
+	}
+
+	target := requestTarget{
+		Method:    r.Method,
+		Target:    r.URL.RequestURI(),
+		Nonce:     r.Header.Get(NONCE_HEADER),
+		Timestamp: r.Header.Get(TIMESTAMP_HEADER),
+	}
+	if err := g.checkMessage(target.Nonce, target.Timestamp, target, &signature, signature.PublicKey); err != nil {
+		return "", err
+	}
+
+	return signature.PublicKey, nil
 }
 
-// addNewTransacion add a new transaction to the transactions map
-func (g *Gateway) addNewTransacion(transaction models.Transaction) {
-	g.Transactions[transaction.TransactionID] = transaction
+// parseFlavour parses a Flavour CR to be published, setting the key of the FLUIDOS Node on the Flavours it owns
+func (g *Gateway) parseFlavour(flavour *nodecorev1alpha1.Flavour) models.Flavour {
+	parsed := parseutil.ParseFlavour(*flavour)
+	if parsed.Owner.NodeID == g.ID.NodeID {
+		parsed.Owner.PublicKey = g.ID.PublicKey
+	}
+	return parsed
 }
 
-// removeTransaction removes a transaction from the transactions map
-func (g *Gateway) removeTransaction(transactionID string) {
-	delete(g.Transactions, transactionID)
+// removeExpiredNonces removes the nonces that can no longer be replayed
+func (g *Gateway) removeExpiredNonces() {
+	g.noncesLock.Lock()
+	defer g.noncesLock.Unlock()
+
+	for nonce, expiration := range g.nonces {
+		if time.Now().After(expiration) {
+			delete(g.nonces, nonce)
+		}
+	}
 }
 
 // handleError handles errors by sending an error response
 func handleError(w http.ResponseWriter, err error, statusCode int) {
-	http.Error(w, err.Error(), statusCode)
+	writeProblem(w, statusCode, models.INTERNAL_ERROR, err.Error())
+}
+
+// forgeETag computes the entity tag of a response as the hash of its JSON encoding
+func forgeETag(data interface{}) (string, error) {
+	resp, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(resp)
+	return "\"" + hex.EncodeToString(hash[:]) + "\"", nil
+}
+
+// matchETag checks if the If-None-Match header matches the entity tag
+func matchETag(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
 }
 
 // encodeResponse encodes the response as JSON and writes it to the response writer
@@ -88,6 +210,7 @@ func encodeResponse(w http.ResponseWriter, data interface{}) {
 	resp, err := json.Marshal(data)
 	if err != nil {
 		handleError(w, err, http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -95,12 +218,9 @@ func encodeResponse(w http.ResponseWriter, data interface{}) {
 	w.Write(resp)
 }
 
+// GetLiqoCredentials returns the identity of the local Liqo cluster and the URL of its authentication service.
+// The token is issued per Contract by IssueContractToken.
 func GetLiqoCredentials(ctx context.Context, cl client.Client) (*reservationsv1alpha1.LiqoCredentials, error) {
-	localToken, err := auth.GetToken(ctx, cl, liqoNamespace)
-	if err != nil {
-		return nil, err
-	}
-
 	clusterIdentity, err := utils.GetClusterIdentityWithControllerClient(ctx, cl, liqoNamespace)
 	if err != nil {
 		return nil, err
@@ -121,6 +241,20 @@ func GetLiqoCredentials(ctx context.Context, cl client.Client) (*reservationsv1a
 		ClusterName: clusterIdentity.ClusterName,
 		ClusterID:   clusterIdentity.ClusterID,
 		Endpoint:    authEP,
-		Token:       localToken,
 	}, nil
 }
+
+// IssueContractToken issues a random Liqo token for a single Contract, returning it with its ID
+func IssueContractToken() (token, tokenID string, err error) {
+	token, err = auth.GenerateToken()
+	if err != nil {
+		return "", "", err
+	}
+	return token, forgeTokenID(token), nil
+}
+
+// forgeTokenID returns the identifier of a Liqo token, that is its SHA-256 fingerprint
+func forgeTokenID(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:16])
+}
@@ -17,32 +17,55 @@ package grpc
 import (
 	context "context"
 	"fmt"
-	"log"
 	"net"
+	"sync"
 
 	resourcemonitors "github.com/liqotech/liqo/pkg/liqo-controller-manager/resource-request-controller/resource-monitors"
 	grpc "google.golang.org/grpc"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
 	"github.com/fluidos-project/node/pkg/utils/flags"
 )
 
+// clusterRole
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=contracts,verbs=get;list;watch
+
+// subscriberBuffer is the number of notifications queued for a subscriber before they are dropped
+const subscriberBuffer = 64
+
+// grpcServer implements the Liqo ResourceReader service, offering to each buyer cluster the resources of its active Contracts
 type grpcServer struct {
 	Server *grpc.Server
 	client client.Client
-	//contractHandler connector.ContractHandler
-	stream resourcemonitors.ResourceReader_SubscribeServer
-	resourcemonitors.ResourceReaderServer
+
+	lock sync.Mutex
+	// subscribers are the streams of the Liqo controller managers subscribed to the changes
+	subscribers map[chan *resourcemonitors.ClusterIdentity]struct{}
+	// offers are the resources last read by Liqo for each cluster
+	offers map[string]corev1.ResourceList
+	// clusters are the buyer clusters of the Contracts watched
+	clusters map[types.NamespacedName]string
+
+	resourcemonitors.UnimplementedResourceReaderServer
 }
 
 func NewGrpcServer(cl client.Client) *grpcServer {
-	return &grpcServer{
-		Server: grpc.NewServer(),
-		client: cl,
+	s := &grpcServer{
+		Server:      grpc.NewServer(),
+		client:      cl,
+		subscribers: make(map[chan *resourcemonitors.ClusterIdentity]struct{}),
+		offers:      make(map[string]corev1.ResourceList),
+		clusters:    make(map[types.NamespacedName]string),
 	}
+	resourcemonitors.RegisterResourceReaderServer(s.Server, s)
+	return s
 }
 
 func (s *grpcServer) Start(ctx context.Context) error {
@@ -56,76 +79,160 @@ func (s *grpcServer) Start(ctx context.Context) error {
 		return fmt.Errorf("gRPC failed to listen: %v", err)
 	}
 
+	go func() {
+		<-ctx.Done()
+		s.Server.GracefulStop()
+	}()
+
 	klog.Infof("gRPC Server Listening on %s", grpcUrl)
 	// gRPC Server start listener
 	return s.Server.Serve(lis)
 }
 
-/* func (s *grpcServer) RegisterContractHandler(ch connector.ContractHandler) {
-	s.contractHandler = ch
-} */
-
-func (s *grpcServer) ReadResources(ctx context.Context, req *resourcemonitors.ClusterIdentity) (*resourcemonitors.ResourceList, error) {
-	readResponse := &resourcemonitors.ResourceList{Resources: map[string]*resource.Quantity{}}
-
-	log.Printf("ReadResource for clusterID %s", req.ClusterID)
-	resources, err := s.GetOfferResourcesByClusterID(req.ClusterID)
+// ReadResources returns the resources offered to the cluster, that is the resources of its active Contracts
+func (s *grpcServer) ReadResources(ctx context.Context, req *resourcemonitors.ClusterIdentity) (*resourcemonitors.PoolResourceList, error) {
+	klog.Infof("ReadResources for clusterID %s", req.ClusterID)
+	resources, err := s.GetOfferResourcesByClusterID(ctx, req.ClusterID)
 	if err != nil {
-		// TODO: maybe should be resurned an empty resource list
 		return nil, err
 	}
 
-	log.Printf("Retrieved resources for clusterID %s: %v", req.ClusterID, resources)
-	for key, value := range *resources {
-		readResponse.Resources[key.String()] = &value
+	s.lock.Lock()
+	s.offers[req.ClusterID] = resources
+	s.lock.Unlock()
+
+	klog.Infof("Retrieved resources for clusterID %s: %v", req.ClusterID, resources)
+	list := &resourcemonitors.ResourceList{Resources: map[string]*resource.Quantity{}}
+	for key, value := range resources {
+		quantity := value.DeepCopy()
+		list.Resources[key.String()] = &quantity
 	}
 
-	return readResponse, nil
+	return &resourcemonitors.PoolResourceList{ResourceLists: []*resourcemonitors.ResourceList{list}}, nil
 }
 
+// Subscribe streams to a Liqo controller manager the clusters whose offer changed, until it disconnects.
+// Several controller managers can be subscribed at the same time.
 func (s *grpcServer) Subscribe(req *resourcemonitors.Empty, srv resourcemonitors.ResourceReader_SubscribeServer) error {
-	// Implement here your logic
-	s.stream = srv
-	ctx := srv.Context()
-
-	fmt.Println("Subscribe")
-
-	_ = s.NotifyChange(context.Background(), &resourcemonitors.ClusterIdentity{ClusterID: resourcemonitors.AllClusterIDs})
+	ch := make(chan *resourcemonitors.ClusterIdentity, subscriberBuffer)
+	s.lock.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		delete(s.subscribers, ch)
+		s.lock.Unlock()
+	}()
+
+	klog.Info("Liqo controller manager subscribed")
+
+	// The offers to every cluster must be read by the new subscriber
+	if err := srv.Send(&resourcemonitors.ClusterIdentity{ClusterID: resourcemonitors.AllClusterIDs}); err != nil {
+		return err
+	}
 
 	for {
 		select {
-		case <-ctx.Done():
-			klog.Info("liqo controller manager disconnected")
+		case <-srv.Context().Done():
+			klog.Info("Liqo controller manager disconnected")
 			return nil
+		case clusterID := <-ch:
+			if err := srv.Send(clusterID); err != nil {
+				klog.Errorf("Error notifying the Liqo controller manager: %s", err)
+				return err
+			}
 		}
 	}
-
 }
 
+// NotifyChange notifies every subscriber that the offer to the cluster changed
 func (s *grpcServer) NotifyChange(ctx context.Context, req *resourcemonitors.ClusterIdentity) error {
-	// Implement here your logic
-	if s.stream == nil {
-		return fmt.Errorf("you must first subscribe a controller manager to notify a change")
-	} else {
-		_ = s.stream.Send(req)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.subscribers) == 0 {
+		klog.Infof("No Liqo controller manager subscribed, the change of cluster %s is not notified", req.ClusterID)
+		return nil
+	}
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- req:
+		default:
+			klog.Errorf("The notifications of a Liqo controller manager are not consumed, dropping the change of cluster %s", req.ClusterID)
+		}
 	}
 	return nil
 }
 
+// RemoveCluster forgets the offer last read by a cluster Liqo no longer peers with. The offer follows the state of the
+// Contracts only: the active Contracts of the cluster are offered again if it peers again, until they expire or are terminated.
 func (s *grpcServer) RemoveCluster(ctx context.Context, req *resourcemonitors.ClusterIdentity) (*resourcemonitors.Empty, error) {
-	// Implement here your logic
-	return nil, fmt.Errorf("Not implemented")
+	klog.Infof("Removing the offer to cluster %s", req.ClusterID)
+	s.lock.Lock()
+	delete(s.offers, req.ClusterID)
+	s.lock.Unlock()
+	return &resourcemonitors.Empty{}, nil
+}
+
+func (s *grpcServer) GetOfferResourcesByClusterID(ctx context.Context, clusterID string) (corev1.ResourceList, error) {
+	klog.Infof("Getting resources for cluster ID: %s", clusterID)
+	return getContractResourcesByClusterID(ctx, s.client, clusterID)
 }
 
-func (s *grpcServer) GetOfferResourcesByClusterID(clusterID string) (*corev1.ResourceList, error) {
-	log.Printf("Getting resources for cluster ID: %s", clusterID)
-	resources, err := getContractResourcesByClusterID(s.client, clusterID)
+// UpdatePeeringOffer notifies the subscribers if the resources offered to the cluster differ from the ones last read by Liqo
+func (s *grpcServer) UpdatePeeringOffer(ctx context.Context, clusterID string) {
+	resources, err := s.GetOfferResourcesByClusterID(ctx, clusterID)
 	if err != nil {
-		return nil, err
+		klog.Errorf("Error getting the resources offered to cluster %s: %s", clusterID, err)
+	}
+
+	s.lock.Lock()
+	offer, ok := s.offers[clusterID]
+	s.lock.Unlock()
+	if err == nil && ok && equality.Semantic.DeepEqual(offer, resources) {
+		return
 	}
-	return resources, nil
+
+	klog.Infof("The resources offered to cluster %s changed", clusterID)
+	_ = s.NotifyChange(ctx, &resourcemonitors.ClusterIdentity{ClusterID: clusterID})
+}
+
+// Reconcile watches the Contracts: when one is created, expires, is terminated or is deleted, the change of the offer
+// to its buyer cluster is notified to Liqo
+func (s *grpcServer) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var contract reservationv1alpha1.Contract
+	if err := s.client.Get(ctx, req.NamespacedName, &contract); client.IgnoreNotFound(err) != nil {
+		klog.Errorf("Error when getting Contract %s: %s", req.NamespacedName, err)
+		return ctrl.Result{}, err
+	} else if err != nil {
+		s.lock.Lock()
+		clusterID, ok := s.clusters[req.NamespacedName]
+		delete(s.clusters, req.NamespacedName)
+		s.lock.Unlock()
+		if ok {
+			s.UpdatePeeringOffer(ctx, clusterID)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	clusterID := contract.Spec.BuyerClusterID
+	if clusterID == "" {
+		return ctrl.Result{}, nil
+	}
+	s.lock.Lock()
+	s.clusters[req.NamespacedName] = clusterID
+	s.lock.Unlock()
+
+	s.UpdatePeeringOffer(ctx, clusterID)
+	return ctrl.Result{}, nil
 }
 
-func (s *grpcServer) UpdatePeeringOffer(clusterID string) {
-	_ = s.NotifyChange(context.Background(), &resourcemonitors.ClusterIdentity{ClusterID: clusterID})
+// SetupWithManager sets up the Contract watcher with the Manager.
+func (s *grpcServer) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("contract-watcher").
+		For(&reservationv1alpha1.Contract{}).
+		Complete(s)
 }
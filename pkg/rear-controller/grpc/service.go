@@ -16,51 +16,55 @@ package grpc
 
 import (
 	"context"
-	"fmt"
+	"math/big"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
 	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
 )
 
-func getContractResourcesByClusterID(cl client.Client, clusterID string) (*corev1.ResourceList, error) {
+// getContractResourcesByClusterID returns the resources of the active Contracts bought by the cluster.
+// A cluster without active Contracts is offered no resources.
+func getContractResourcesByClusterID(ctx context.Context, cl client.Client, clusterID string) (corev1.ResourceList, error) {
 	var contracts reservationv1alpha1.ContractList
 
-	if err := cl.List(context.Background(), &contracts, client.MatchingFields{"spec.clusterID": clusterID}); err != nil {
-		if client.IgnoreNotFound(err) != nil {
-			klog.Errorf("Error when listing Contracts: %s", err)
-			return nil, err
+	if err := cl.List(ctx, &contracts, client.MatchingFields{"spec.buyerClusterID": clusterID}); err != nil {
+		klog.Errorf("Error when listing Contracts: %s", err)
+		return nil, err
+	}
+
+	// Only the active contracts feed resources to Liqo
+	active := contracts.Items[:0]
+	for i := range contracts.Items {
+		if contracts.Items[i].IsActive() {
+			active = append(active, contracts.Items[i])
 		}
 	}
+	contracts.Items = active
 
 	if len(contracts.Items) == 0 {
-		klog.Errorf("No contracts found for cluster %s", clusterID)
-		return nil, fmt.Errorf("No contracts found for cluster %s", clusterID)
+		klog.Infof("No active contracts found for cluster %s", clusterID)
+		return corev1.ResourceList{}, nil
 	}
 
-	if len(contracts.Items) > 1 {
-		resources := multipleContractLogic(contracts.Items)
-		return resources, nil
+	resources := corev1.ResourceList{}
+	for i := range contracts.Items {
+		addResources(resources, mapContractResources(&contracts.Items[i]))
 	}
 
-	contract := contracts.Items[0]
-
-	return mapQuantityToResourceList(contract.Spec.Partition), nil
+	return resources, nil
 }
 
-func multipleContractLogic(contracts []reservationv1alpha1.Contract) *corev1.ResourceList {
-	resources := &corev1.ResourceList{}
-	for _, contract := range contracts {
-		resources = addResources(*resources, contract.Spec.Partition)
-	}
-	return resources
-}
-
-// This function adds the resources of a contract to the existing resourceList
-func addResources(resources corev1.ResourceList, partition *reservationv1alpha1.Partition) *corev1.ResourceList {
-	for key, value := range *mapQuantityToResourceList(partition) {
+// addResources adds the resources of a contract to the existing resourceList
+func addResources(resources, added corev1.ResourceList) {
+	for key, value := range added {
 		if prevRes, ok := resources[key]; !ok {
 			resources[key] = value
 		} else {
@@ -68,14 +72,106 @@ func addResources(resources corev1.ResourceList, partition *reservationv1alpha1.
 			resources[key] = prevRes
 		}
 	}
-	return &resources
 }
 
-func mapQuantityToResourceList(partition *reservationv1alpha1.Partition) *corev1.ResourceList {
-	return &corev1.ResourceList{
-		corev1.ResourceCPU:              partition.Cpu,
-		corev1.ResourceMemory:           partition.Memory,
-		corev1.ResourceEphemeralStorage: partition.EphemeralStorage,
-		corev1.ResourceStorage:          partition.Storage,
+// mapContractResources returns the resources offered to Liqo for a contract: its partition, or the whole flavour if it has no partition.
+// A partition is offered the share of the pods limit of its flavour matching its share of the CPU (or of the memory, for a flavour
+// without CPU), so that the partitions of a flavour are not offered more pods than the flavour; the extended resources are only
+// offered with the whole flavour.
+func mapContractResources(contract *reservationv1alpha1.Contract) corev1.ResourceList {
+	characteristics := &contract.Spec.Flavour.Spec.Characteristics
+	resources := corev1.ResourceList{}
+
+	var quantities map[string]resource.Quantity
+	if partition := contract.Spec.Partition; partition != nil {
+		quantities = map[string]resource.Quantity{
+			consts.CHARACTERISTIC_CPU:                partition.Cpu,
+			consts.CHARACTERISTIC_MEMORY:             partition.Memory,
+			consts.CHARACTERISTIC_GPU:                partition.Gpu,
+			consts.CHARACTERISTIC_EPHEMERAL_STORAGE:  partition.EphemeralStorage,
+			consts.CHARACTERISTIC_PERSISTENT_STORAGE: partition.Storage,
+			consts.CHARACTERISTIC_PODS:               partitionPods(partition, characteristics),
+		}
+	} else {
+		quantities = map[string]resource.Quantity{
+			consts.CHARACTERISTIC_CPU:                characteristics.Cpu,
+			consts.CHARACTERISTIC_MEMORY:             characteristics.Memory,
+			consts.CHARACTERISTIC_GPU:                characteristics.Gpu,
+			consts.CHARACTERISTIC_EPHEMERAL_STORAGE:  characteristics.EphemeralStorage,
+			consts.CHARACTERISTIC_PERSISTENT_STORAGE: characteristics.PersistentStorage,
+			consts.CHARACTERISTIC_PODS:               characteristics.Pods,
+		}
+		for name, quantity := range characteristics.ExtendedResources {
+			resources[corev1.ResourceName(name)] = quantity.DeepCopy()
+		}
+	}
+
+	// The GPUs are offered under the resource name they are allocated with, unless overridden
+	var gpuName corev1.ResourceName = consts.DEFAULT_GPU_RESOURCE_NAME
+	if characteristics.GpuDetails != nil && characteristics.GpuDetails.ResourceName != "" {
+		gpuName = corev1.ResourceName(characteristics.GpuDetails.ResourceName)
+	}
+
+	// The characteristics take precedence over the extended resources with the same name
+	names := resourceNames(gpuName)
+	for characteristic, quantity := range quantities {
+		name, ok := names[characteristic]
+		if !ok || name == "" || quantity.IsZero() {
+			continue
+		}
+		resources[name] = quantity.DeepCopy()
+	}
+
+	return resources
+}
+
+// partitionPods returns the pods of the flavour in proportion to the CPU of the partition, or to its memory if the flavour has no CPU.
+// The share is rounded down, so the pods of the partitions never add up to more than the pods of the flavour.
+func partitionPods(partition *reservationv1alpha1.Partition, characteristics *nodecorev1alpha1.Characteristics) resource.Quantity {
+	part, whole := partition.Cpu.MilliValue(), characteristics.Cpu.MilliValue()
+	if whole <= 0 {
+		part, whole = partition.Memory.Value(), characteristics.Memory.Value()
+	}
+	if whole <= 0 || part <= 0 {
+		return resource.Quantity{}
+	}
+	if part > whole {
+		part = whole
+	}
+
+	pods := new(big.Int).Mul(big.NewInt(characteristics.Pods.Value()), big.NewInt(part))
+	pods.Quo(pods, big.NewInt(whole))
+	return *resource.NewQuantity(pods.Int64(), resource.DecimalSI)
+}
+
+// resourceNames returns the Liqo resource names of the flavour characteristics, offering the GPUs under gpuName.
+// The defaults are overridden by the comma-separated characteristic=name pairs of the LIQO_RESOURCE_NAMES flag:
+// a characteristic mapped to an empty name is not offered.
+func resourceNames(gpuName corev1.ResourceName) map[string]corev1.ResourceName {
+	names := map[string]corev1.ResourceName{
+		consts.CHARACTERISTIC_CPU:                corev1.ResourceCPU,
+		consts.CHARACTERISTIC_MEMORY:             corev1.ResourceMemory,
+		consts.CHARACTERISTIC_GPU:                gpuName,
+		consts.CHARACTERISTIC_EPHEMERAL_STORAGE:  corev1.ResourceEphemeralStorage,
+		consts.CHARACTERISTIC_PERSISTENT_STORAGE: corev1.ResourceStorage,
+		consts.CHARACTERISTIC_PODS:               corev1.ResourcePods,
+	}
+
+	if flags.LIQO_RESOURCE_NAMES == "" {
+		return names
+	}
+
+	for _, pair := range strings.Split(flags.LIQO_RESOURCE_NAMES, ",") {
+		characteristic, name, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			continue
+		}
+		characteristic = strings.TrimSpace(characteristic)
+		if _, ok := names[characteristic]; !ok {
+			klog.Warningf("Unknown flavour characteristic %s in the Liqo resource names", characteristic)
+			continue
+		}
+		names[characteristic] = corev1.ResourceName(strings.TrimSpace(name))
 	}
+	return names
 }
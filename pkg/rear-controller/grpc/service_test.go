@@ -0,0 +1,125 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+)
+
+func TestPartitionPods(t *testing.T) {
+	characteristics := &nodecorev1alpha1.Characteristics{
+		Cpu:    resource.MustParse("4"),
+		Memory: resource.MustParse("8Gi"),
+		Pods:   resource.MustParse("110"),
+	}
+	memoryOnly := &nodecorev1alpha1.Characteristics{
+		Memory: resource.MustParse("8Gi"),
+		Pods:   resource.MustParse("110"),
+	}
+
+	tests := []struct {
+		name            string
+		cpu             string
+		memory          string
+		characteristics *nodecorev1alpha1.Characteristics
+		want            int64
+	}{
+		{name: "whole flavour", cpu: "4", memory: "8Gi", characteristics: characteristics, want: 110},
+		{name: "half of the CPU", cpu: "2", memory: "1Gi", characteristics: characteristics, want: 55},
+		{name: "share rounded down", cpu: "1", memory: "8Gi", characteristics: characteristics, want: 27},
+		{name: "millicores", cpu: "100m", memory: "1Gi", characteristics: characteristics, want: 2},
+		{name: "partition larger than the flavour", cpu: "8", memory: "16Gi", characteristics: characteristics, want: 110},
+		{name: "no CPU in the partition", cpu: "0", memory: "8Gi", characteristics: characteristics, want: 0},
+		{name: "memory of a flavour without CPU", cpu: "1", memory: "2Gi", characteristics: memoryOnly, want: 27},
+		{name: "no CPU nor memory in the flavour", cpu: "1", memory: "1Gi", characteristics: &nodecorev1alpha1.Characteristics{
+			Pods: resource.MustParse("110")}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			partition := &reservationv1alpha1.Partition{Cpu: resource.MustParse(tt.cpu), Memory: resource.MustParse(tt.memory)}
+			if got := partitionPods(partition, tt.characteristics); got.Value() != tt.want {
+				t.Errorf("partitionPods() = %s, want %d", got.String(), tt.want)
+			}
+		})
+	}
+
+	// The pods of the partitions of a flavour never add up to more than its pods
+	var total int64
+	for _, cpu := range []string{"1500m", "1500m", "1"} {
+		pods := partitionPods(&reservationv1alpha1.Partition{Cpu: resource.MustParse(cpu)}, characteristics)
+		total += pods.Value()
+	}
+	if total > characteristics.Pods.Value() {
+		t.Errorf("partitionPods() total = %d, want at most %d", total, characteristics.Pods.Value())
+	}
+}
+
+func TestResourceNames(t *testing.T) {
+	gpuName := corev1.ResourceName("nvidia.com/gpu")
+
+	tests := []struct {
+		name  string
+		flag  string
+		want  map[string]corev1.ResourceName
+		unset []string
+	}{
+		{name: "defaults", want: map[string]corev1.ResourceName{
+			consts.CHARACTERISTIC_CPU:  corev1.ResourceCPU,
+			consts.CHARACTERISTIC_GPU:  gpuName,
+			consts.CHARACTERISTIC_PODS: corev1.ResourcePods,
+		}},
+		{name: "renamed characteristic", flag: consts.CHARACTERISTIC_GPU + " = example.com/gpu", want: map[string]corev1.ResourceName{
+			consts.CHARACTERISTIC_GPU: "example.com/gpu",
+			consts.CHARACTERISTIC_CPU: corev1.ResourceCPU,
+		}},
+		{name: "characteristic not offered", flag: consts.CHARACTERISTIC_PODS + "=," + consts.CHARACTERISTIC_MEMORY + "=memory",
+			want: map[string]corev1.ResourceName{
+				consts.CHARACTERISTIC_PODS:   "",
+				consts.CHARACTERISTIC_MEMORY: corev1.ResourceMemory,
+			}},
+		{name: "unknown characteristic and malformed pair", flag: "unknown=foo,cpu", want: map[string]corev1.ResourceName{
+			consts.CHARACTERISTIC_CPU: corev1.ResourceCPU,
+		}, unset: []string{"unknown"}},
+	}
+
+	old := flags.LIQO_RESOURCE_NAMES
+	t.Cleanup(func() { flags.LIQO_RESOURCE_NAMES = old })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags.LIQO_RESOURCE_NAMES = tt.flag
+			names := resourceNames(gpuName)
+			for characteristic, want := range tt.want {
+				if got := names[characteristic]; got != want {
+					t.Errorf("resourceNames()[%s] = %s, want %s", characteristic, got, want)
+				}
+			}
+			for _, characteristic := range tt.unset {
+				if _, ok := names[characteristic]; ok {
+					t.Errorf("resourceNames() contains %s", characteristic)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/pricing"
+)
+
+// clusterRole
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=contracts,verbs=get;list;watch
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=usagerecords,verbs=get;list;watch;create;delete
+
+// Meter accrues the cost of the Contracts of the FLUIDOS Node, recording it in UsageRecords
+type Meter struct {
+	client client.Client
+}
+
+// NewMeter creates a new Meter
+func NewMeter(c client.Client) *Meter {
+	return &Meter{client: c}
+}
+
+// Metering returns a function that meters the Contracts every interval
+func (m *Meter) Metering(interval time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return wait.PollUntilContextCancel(ctx, interval, true, m.meter)
+	}
+}
+
+// meter creates a UsageRecord for every Contract that has been active since its last UsageRecord
+func (m *Meter) meter(ctx context.Context) (bool, error) {
+	klog.Infof("Metering Contracts")
+
+	var contracts reservationv1alpha1.ContractList
+	if err := m.client.List(ctx, &contracts, client.InNamespace(flags.FLUIDOS_NAMESPACE)); err != nil {
+		klog.Errorf("Error when listing Contracts: %s", err)
+		return false, nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for i := range contracts.Items {
+		if err := m.meterContract(ctx, &contracts.Items[i], now); err != nil {
+			klog.Errorf("Error when metering Contract %s: %s", contracts.Items[i].Name, err)
+		}
+	}
+
+	if flags.USAGE_RECORD_RETENTION > 0 {
+		if err := m.collect(ctx, now); err != nil {
+			klog.Errorf("Error when collecting the UsageRecords: %s", err)
+		}
+	}
+
+	return false, nil
+}
+
+// collect deletes the UsageRecords of the billing periods closed for longer than USAGE_RECORD_RETENTION
+func (m *Meter) collect(ctx context.Context, now time.Time) error {
+	var records reservationv1alpha1.UsageRecordList
+	if err := m.client.List(ctx, &records, client.InNamespace(flags.FLUIDOS_NAMESPACE)); err != nil {
+		return err
+	}
+
+	cutoff, _ := BillingPeriod(now.Add(-flags.USAGE_RECORD_RETENTION))
+	for _, record := range ExpiredRecords(records.Items, cutoff) {
+		if err := m.client.Delete(ctx, record); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		klog.Infof("UsageRecord %s of Contract %s deleted", record.Name, record.Spec.ContractID)
+	}
+	return nil
+}
+
+// ExpiredRecords returns the UsageRecords ending before the cutoff. The last UsageRecord of each Contract is kept,
+// as the next metering window starts from its end.
+func ExpiredRecords(records []reservationv1alpha1.UsageRecord, cutoff time.Time) []*reservationv1alpha1.UsageRecord {
+	last := make(map[string]time.Time)
+	for i := range records {
+		end, err := time.Parse(time.RFC3339, records[i].Spec.EndTime)
+		if err == nil && end.After(last[records[i].Spec.ContractID]) {
+			last[records[i].Spec.ContractID] = end
+		}
+	}
+
+	var expired []*reservationv1alpha1.UsageRecord
+	for i := range records {
+		end, err := time.Parse(time.RFC3339, records[i].Spec.EndTime)
+		if err != nil || end.After(cutoff) || !end.Before(last[records[i].Spec.ContractID]) {
+			continue
+		}
+		expired = append(expired, &records[i])
+	}
+	return expired
+}
+
+// meterContract records the usage of the Contract from the end of its last UsageRecord until now
+func (m *Meter) meterContract(ctx context.Context, contract *reservationv1alpha1.Contract, now time.Time) error {
+	start := ActiveSince(contract)
+	end := ActiveUntil(contract, now)
+
+	last, err := m.lastMetered(ctx, contract.Name)
+	if err != nil {
+		return err
+	}
+	if last.After(start) {
+		start = last
+	}
+
+	if !end.After(start) {
+		return nil
+	}
+
+	price, period, currency, err := contractPrice(contract)
+	if err != nil {
+		return err
+	}
+	active := end.Sub(start)
+	cost, err := pricing.Prorate(price, period, active)
+	if err != nil {
+		return err
+	}
+	share := pricing.Share(&contract.Spec.Flavour.Spec.Characteristics, contract.Spec.Partition)
+
+	record := &reservationv1alpha1.UsageRecord{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", contract.Name, end.Unix()),
+			Namespace: flags.FLUIDOS_NAMESPACE,
+			Labels:    map[string]string{consts.CONTRACT_LABEL: contract.Name},
+		},
+		Spec: reservationv1alpha1.UsageRecordSpec{
+			ContractID:    contract.Name,
+			FlavourID:     contract.Spec.Flavour.Name,
+			Buyer:         contract.Spec.Buyer,
+			Seller:        contract.Spec.Seller,
+			StartTime:     start.Format(time.RFC3339),
+			EndTime:       end.Format(time.RFC3339),
+			ActiveSeconds: int64(active / time.Second),
+			Share:         share.FloatString(pricing.AMOUNT_DECIMALS),
+			Cost: reservationv1alpha1.Cost{
+				Amount:   pricing.FormatAmount(cost),
+				Currency: currency,
+			},
+		},
+	}
+
+	if err := m.client.Create(ctx, record); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	klog.Infof("Contract %s accrued %s %s from %s to %s", contract.Name, record.Spec.Cost.Amount,
+		record.Spec.Cost.Currency, record.Spec.StartTime, record.Spec.EndTime)
+	return nil
+}
+
+// lastMetered returns the end of the last UsageRecord of the Contract, or the zero time if it has not been metered yet
+func (m *Meter) lastMetered(ctx context.Context, contractID string) (time.Time, error) {
+	var records reservationv1alpha1.UsageRecordList
+	if err := m.client.List(ctx, &records, client.InNamespace(flags.FLUIDOS_NAMESPACE),
+		client.MatchingLabels{consts.CONTRACT_LABEL: contractID}); err != nil {
+		return time.Time{}, err
+	}
+
+	var last time.Time
+	for i := range records.Items {
+		end, err := time.Parse(time.RFC3339, records.Items[i].Spec.EndTime)
+		if err == nil && end.After(last) {
+			last = end
+		}
+	}
+	return last, nil
+}
+
+// ActiveSince returns the time at which the Contract became active
+func ActiveSince(contract *reservationv1alpha1.Contract) time.Time {
+	if start, err := time.Parse(time.RFC3339, contract.Status.Phase.StartTime); err == nil {
+		return start
+	}
+	return contract.CreationTimestamp.Time
+}
+
+// ActiveUntil returns the time until which the Contract has been active: now, unless it has already expired or ended
+func ActiveUntil(contract *reservationv1alpha1.Contract, now time.Time) time.Time {
+	end := now
+	if expiration, err := time.Parse(time.RFC3339, contract.Spec.ExpirationTime); err == nil && expiration.Before(end) {
+		end = expiration
+	}
+	if contract.Status.Phase.Phase == nodecorev1alpha1.PhaseTerminated || contract.Status.Phase.Phase == nodecorev1alpha1.PhaseInactive {
+		if ended, err := time.Parse(time.RFC3339, contract.Status.Phase.EndTime); err == nil && ended.Before(end) {
+			end = ended
+		} else if changed, err := time.Parse(time.RFC3339, contract.Status.Phase.LastChangeTime); err == nil && changed.Before(end) {
+			end = changed
+		}
+	}
+	return end
+}
+
+// contractPrice returns the price of the Contract with its period and currency: the price agreed by the parties,
+// if negotiated, or the price of the partition according to the pricing of the flavour
+func contractPrice(contract *reservationv1alpha1.Contract) (price *big.Rat, period, currency string, err error) {
+	if agreed := contract.Spec.AgreedPrice; agreed != nil {
+		price, err = pricing.ParseAmount(agreed.Amount)
+		return price, agreed.Period, agreed.Currency, err
+	}
+
+	var commitment time.Duration
+	if expiration, err := time.Parse(time.RFC3339, contract.Spec.ExpirationTime); err == nil {
+		commitment = expiration.Sub(ActiveSince(contract))
+	}
+	price, err = pricing.PartitionPrice(&contract.Spec.Flavour.Spec, contract.Spec.Partition, commitment)
+	return price, contract.Spec.Flavour.Spec.Price.Period, contract.Spec.Flavour.Spec.Price.Currency, err
+}
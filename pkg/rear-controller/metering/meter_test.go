@@ -0,0 +1,154 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/pricing"
+)
+
+func TestExpiredRecords(t *testing.T) {
+	cutoff := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	records := []reservationv1alpha1.UsageRecord{
+		usageRecord("c1", "2024-01-10T00:00:00Z", "2024-01-10T01:00:00Z", 3600, "1", "EUR"),
+		usageRecord("c1", "2024-01-10T01:00:00Z", "2024-02-29T23:00:00Z", 3600, "1", "EUR"),
+		usageRecord("c1", "2024-02-29T23:00:00Z", "2024-03-01T00:00:00Z", 3600, "1", "EUR"),
+		usageRecord("c1", "2024-03-01T00:00:00Z", "2024-03-02T00:00:00Z", 3600, "1", "EUR"),
+		// The last record of a Contract is kept, however old
+		usageRecord("c2", "2024-01-10T00:00:00Z", "2024-01-10T01:00:00Z", 3600, "1", "EUR"),
+		usageRecord("c2", "2024-01-10T01:00:00Z", "2024-01-10T02:00:00Z", 3600, "1", "EUR"),
+		usageRecord("c3", "2024-01-10T01:00:00Z", "later", 3600, "1", "EUR"),
+	}
+
+	expired := ExpiredRecords(records, cutoff)
+
+	want := []string{"c1-2024-01-10T01:00:00Z", "c1-2024-02-29T23:00:00Z", "c1-2024-03-01T00:00:00Z", "c2-2024-01-10T01:00:00Z"}
+	if len(expired) != len(want) {
+		t.Fatalf("ExpiredRecords() returned %d records, want %v", len(expired), want)
+	}
+	for i := range want {
+		if expired[i].Name != want[i] {
+			t.Errorf("ExpiredRecords()[%d] = %s, want %s", i, expired[i].Name, want[i])
+		}
+	}
+}
+
+func TestActiveUntil(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		expiration string
+		phase      nodecorev1alpha1.PhaseStatus
+		want       time.Time
+	}{
+		{name: "active", expiration: "2024-04-01T00:00:00Z", phase: nodecorev1alpha1.PhaseStatus{Phase: nodecorev1alpha1.PhaseActive}, want: now},
+		{name: "without expiration", phase: nodecorev1alpha1.PhaseStatus{Phase: nodecorev1alpha1.PhaseActive}, want: now},
+		{name: "expired", expiration: "2024-03-05T00:00:00Z", want: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{
+			name:       "terminated",
+			expiration: "2024-04-01T00:00:00Z",
+			phase:      nodecorev1alpha1.PhaseStatus{Phase: nodecorev1alpha1.PhaseTerminated, EndTime: "2024-03-08T00:00:00Z"},
+			want:       time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "terminated after the expiration",
+			expiration: "2024-03-05T00:00:00Z",
+			phase:      nodecorev1alpha1.PhaseStatus{Phase: nodecorev1alpha1.PhaseTerminated, EndTime: "2024-03-08T00:00:00Z"},
+			want:       time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "inactive without end time",
+			phase: nodecorev1alpha1.PhaseStatus{Phase: nodecorev1alpha1.PhaseInactive, LastChangeTime: "2024-03-09T00:00:00Z"},
+			want:  time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var contract reservationv1alpha1.Contract
+			contract.Spec.ExpirationTime = tt.expiration
+			contract.Status.Phase = tt.phase
+			if got := ActiveUntil(&contract, now); !got.Equal(tt.want) {
+				t.Errorf("ActiveUntil() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContractPrice(t *testing.T) {
+	flavour := nodecorev1alpha1.Flavour{Spec: nodecorev1alpha1.FlavourSpec{
+		Characteristics: nodecorev1alpha1.Characteristics{Cpu: resource.MustParse("4"), Memory: resource.MustParse("8Gi")},
+		Price: nodecorev1alpha1.Price{Amount: "10", Currency: "EUR", Period: "PT1H",
+			CommitmentTiers: []nodecorev1alpha1.CommitmentTier{{MinDuration: "P1M", Discount: "10"}}},
+	}}
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		agreed       *reservationv1alpha1.Offer
+		partition    *reservationv1alpha1.Partition
+		expiration   string
+		wantPrice    string
+		wantPeriod   string
+		wantCurrency string
+	}{
+		{name: "flavour price", wantPrice: "10.000000", wantPeriod: "PT1H", wantCurrency: "EUR"},
+		{
+			name:      "partition price",
+			partition: &reservationv1alpha1.Partition{Cpu: resource.MustParse("1"), Memory: resource.MustParse("1Gi")},
+			wantPrice: "2.500000", wantPeriod: "PT1H", wantCurrency: "EUR",
+		},
+		{
+			name:       "commitment discount",
+			expiration: start.Add(60 * 24 * time.Hour).Format(time.RFC3339),
+			wantPrice:  "9.000000", wantPeriod: "PT1H", wantCurrency: "EUR",
+		},
+		{
+			name:      "agreed price",
+			agreed:    &reservationv1alpha1.Offer{Amount: "200", Currency: "USD", Period: "P1D"},
+			wantPrice: "200.000000", wantPeriod: "P1D", wantCurrency: "USD",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contract := &reservationv1alpha1.Contract{
+				ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(start)},
+				Spec: reservationv1alpha1.ContractSpec{
+					Flavour:        flavour,
+					Partition:      tt.partition,
+					ExpirationTime: tt.expiration,
+					AgreedPrice:    tt.agreed,
+				},
+			}
+			price, period, currency, err := contractPrice(contract)
+			if err != nil {
+				t.Fatalf("contractPrice() error = %s", err)
+			}
+			if pricing.FormatAmount(price) != tt.wantPrice || period != tt.wantPeriod || currency != tt.wantCurrency {
+				t.Errorf("contractPrice() = %s %s/%s, want %s %s/%s", pricing.FormatAmount(price), currency, period,
+					tt.wantPrice, tt.wantCurrency, tt.wantPeriod)
+			}
+		})
+	}
+}
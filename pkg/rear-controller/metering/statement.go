@@ -0,0 +1,186 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"encoding/csv"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/models"
+	"github.com/fluidos-project/node/pkg/utils/parseutil"
+	"github.com/fluidos-project/node/pkg/utils/pricing"
+)
+
+// BuildStatement builds the statement of the given FLUIDOS Node over the billing period [from, to),
+// as buyer or seller of the metered Contracts. The UsageRecords partially overlapping the period are prorated.
+func BuildStatement(records []reservationv1alpha1.UsageRecord, node nodecorev1alpha1.NodeIdentity, from, to time.Time) models.Statement {
+	type line struct {
+		models.StatementLine
+		amount *big.Rat
+	}
+	lines := make(map[string]*line)
+
+	for i := range records {
+		record := &records[i].Spec
+
+		role, counterparty := "", nodecorev1alpha1.NodeIdentity{}
+		switch {
+		case IsParty(record.Buyer, node):
+			role, counterparty = reservationv1alpha1.ContractPartyBuyer, record.Seller
+		case IsParty(record.Seller, node):
+			role, counterparty = reservationv1alpha1.ContractPartySeller, record.Buyer
+		default:
+			continue
+		}
+
+		// A node identified by its key only is named after the party it is in the Contracts
+		if node.NodeID == "" {
+			if role == reservationv1alpha1.ContractPartyBuyer {
+				node.Domain, node.NodeID = record.Buyer.Domain, record.Buyer.NodeID
+			} else {
+				node.Domain, node.NodeID = record.Seller.Domain, record.Seller.NodeID
+			}
+		}
+
+		start, errStart := time.Parse(time.RFC3339, record.StartTime)
+		end, errEnd := time.Parse(time.RFC3339, record.EndTime)
+		if errStart != nil || errEnd != nil || !end.After(start) {
+			continue
+		}
+		overlapStart, overlapEnd := maxTime(start, from), minTime(end, to)
+		if !overlapEnd.After(overlapStart) {
+			continue
+		}
+
+		amount, err := pricing.ParseAmount(record.Cost.Amount)
+		if err != nil {
+			klog.Errorf("Error parsing the cost of UsageRecord %s: %s", records[i].Name, err)
+			continue
+		}
+		amount.Mul(amount, big.NewRat(int64(overlapEnd.Sub(overlapStart)/time.Second), int64(end.Sub(start)/time.Second)))
+		active := record.ActiveSeconds * int64(overlapEnd.Sub(overlapStart)/time.Second) / int64(end.Sub(start)/time.Second)
+
+		key := record.ContractID + "/" + role
+		l, ok := lines[key]
+		if !ok {
+			l = &line{
+				StatementLine: models.StatementLine{
+					ContractID:   record.ContractID,
+					FlavourID:    record.FlavourID,
+					Role:         role,
+					Counterparty: parseutil.ParseNodeIdentity(counterparty),
+					Cost:         models.Cost{Currency: record.Cost.Currency},
+				},
+				amount: new(big.Rat),
+			}
+			lines[key] = l
+		}
+		l.ActiveSeconds += active
+		l.amount.Add(l.amount, amount)
+	}
+
+	statement := models.Statement{
+		Domain: node.Domain,
+		NodeID: node.NodeID,
+		From:   from.UTC().Format(time.RFC3339),
+		To:     to.UTC().Format(time.RFC3339),
+		Lines:  []models.StatementLine{},
+		Totals: []models.Cost{},
+	}
+
+	totals := make(map[string]*big.Rat)
+	for _, l := range lines {
+		l.Cost.Amount = pricing.FormatAmount(l.amount)
+		statement.Lines = append(statement.Lines, l.StatementLine)
+		if _, ok := totals[l.Cost.Currency]; !ok {
+			totals[l.Cost.Currency] = new(big.Rat)
+		}
+		totals[l.Cost.Currency].Add(totals[l.Cost.Currency], l.amount)
+	}
+	sort.Slice(statement.Lines, func(i, j int) bool {
+		if statement.Lines[i].ContractID != statement.Lines[j].ContractID {
+			return statement.Lines[i].ContractID < statement.Lines[j].ContractID
+		}
+		return statement.Lines[i].Role < statement.Lines[j].Role
+	})
+
+	for currency, amount := range totals {
+		statement.Totals = append(statement.Totals, models.Cost{Amount: pricing.FormatAmount(amount), Currency: currency})
+	}
+	sort.Slice(statement.Totals, func(i, j int) bool { return statement.Totals[i].Currency < statement.Totals[j].Currency })
+
+	return statement
+}
+
+// WriteCSV writes the lines of the statement as CSV, with a header row
+func WriteCSV(w io.Writer, statement *models.Statement) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"from", "to", "contractID", "flavourID", "role", "counterpartyNodeID",
+		"counterpartyDomain", "activeSeconds", "amount", "currency"}); err != nil {
+		return err
+	}
+	for _, l := range statement.Lines {
+		if err := writer.Write([]string{statement.From, statement.To, l.ContractID, l.FlavourID, l.Role, l.Counterparty.NodeID,
+			l.Counterparty.Domain, strconv.FormatInt(l.ActiveSeconds, 10), l.Cost.Amount, l.Cost.Currency}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// BillingPeriod returns the calendar month, in UTC, containing t
+func BillingPeriod(t time.Time) (from, to time.Time) {
+	t = t.UTC()
+	from = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return from, from.AddDate(0, 1, 0)
+}
+
+// IsParty checks if the given FLUIDOS Node is the given party of a Contract.
+// A node identified by its public key is matched by the key only, otherwise by its domain and NodeID.
+func IsParty(party, node nodecorev1alpha1.NodeIdentity) bool {
+	if node.PublicKey != "" {
+		return party.PublicKey == node.PublicKey
+	}
+	if node.NodeID != "" && party.NodeID != node.NodeID {
+		return false
+	}
+	if node.Domain != "" && party.Domain != node.Domain {
+		return false
+	}
+	return node.Domain != "" || node.NodeID != ""
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
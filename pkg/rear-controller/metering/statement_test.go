@@ -0,0 +1,211 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metering
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+var (
+	buyer  = nodecorev1alpha1.NodeIdentity{Domain: "buyer.eu", NodeID: "buyer", PublicKey: "buyer-key"}
+	seller = nodecorev1alpha1.NodeIdentity{Domain: "seller.eu", NodeID: "seller", PublicKey: "seller-key"}
+)
+
+// usageRecord returns a UsageRecord of the Contract between the buyer and the seller
+func usageRecord(contractID, start, end string, activeSeconds int64, amount, currency string) reservationv1alpha1.UsageRecord {
+	var record reservationv1alpha1.UsageRecord
+	record.Name = contractID + "-" + end
+	record.Spec = reservationv1alpha1.UsageRecordSpec{
+		ContractID:    contractID,
+		FlavourID:     "flavour",
+		Buyer:         buyer,
+		Seller:        seller,
+		StartTime:     start,
+		EndTime:       end,
+		ActiveSeconds: activeSeconds,
+		Cost:          reservationv1alpha1.Cost{Amount: amount, Currency: currency},
+	}
+	return record
+}
+
+func TestBillingPeriod(t *testing.T) {
+	tests := []struct {
+		name     string
+		t        time.Time
+		wantFrom string
+		wantTo   string
+	}{
+		{name: "middle of the month", t: time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC),
+			wantFrom: "2024-03-01T00:00:00Z", wantTo: "2024-04-01T00:00:00Z"},
+		{name: "start of the month", t: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			wantFrom: "2024-03-01T00:00:00Z", wantTo: "2024-04-01T00:00:00Z"},
+		{name: "end of the year", t: time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC),
+			wantFrom: "2024-12-01T00:00:00Z", wantTo: "2025-01-01T00:00:00Z"},
+		{name: "leap february", t: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+			wantFrom: "2024-02-01T00:00:00Z", wantTo: "2024-03-01T00:00:00Z"},
+		{name: "other time zone", t: time.Date(2024, 4, 1, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60)),
+			wantFrom: "2024-03-01T00:00:00Z", wantTo: "2024-04-01T00:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := BillingPeriod(tt.t)
+			if from.Format(time.RFC3339) != tt.wantFrom || to.Format(time.RFC3339) != tt.wantTo {
+				t.Errorf("BillingPeriod() = %s, %s, want %s, %s", from.Format(time.RFC3339), to.Format(time.RFC3339),
+					tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestIsParty(t *testing.T) {
+	tests := []struct {
+		name string
+		node nodecorev1alpha1.NodeIdentity
+		want bool
+	}{
+		{name: "same key", node: nodecorev1alpha1.NodeIdentity{PublicKey: "buyer-key"}, want: true},
+		{name: "different key", node: nodecorev1alpha1.NodeIdentity{Domain: "buyer.eu", NodeID: "buyer", PublicKey: "other-key"}},
+		{name: "same domain and node", node: nodecorev1alpha1.NodeIdentity{Domain: "buyer.eu", NodeID: "buyer"}, want: true},
+		{name: "same node", node: nodecorev1alpha1.NodeIdentity{NodeID: "buyer"}, want: true},
+		{name: "same domain", node: nodecorev1alpha1.NodeIdentity{Domain: "buyer.eu"}, want: true},
+		{name: "different node", node: nodecorev1alpha1.NodeIdentity{Domain: "buyer.eu", NodeID: "other"}},
+		{name: "different domain", node: nodecorev1alpha1.NodeIdentity{Domain: "other.eu", NodeID: "buyer"}},
+		{name: "empty identity", node: nodecorev1alpha1.NodeIdentity{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsParty(buyer, tt.node); got != tt.want {
+				t.Errorf("IsParty() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildStatement(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	records := []reservationv1alpha1.UsageRecord{
+		// Within the period
+		usageRecord("c1", "2024-03-10T00:00:00Z", "2024-03-10T01:00:00Z", 3600, "2", "EUR"),
+		usageRecord("c1", "2024-03-10T01:00:00Z", "2024-03-10T02:00:00Z", 3600, "2", "EUR"),
+		// Half before the period
+		usageRecord("c2", "2024-02-29T23:00:00Z", "2024-03-01T01:00:00Z", 7200, "4", "EUR"),
+		// A quarter after the period
+		usageRecord("c3", "2024-03-31T23:00:00Z", "2024-04-01T00:20:00Z", 4800, "8", "USD"),
+		// Outside the period
+		usageRecord("c4", "2024-02-01T00:00:00Z", "2024-02-02T00:00:00Z", 86400, "100", "EUR"),
+		// Invalid records
+		usageRecord("c5", "2024-03-10T02:00:00Z", "2024-03-10T01:00:00Z", 3600, "1", "EUR"),
+		usageRecord("c6", "2024-03-10T01:00:00Z", "yesterday", 3600, "1", "EUR"),
+		usageRecord("c7", "2024-03-10T01:00:00Z", "2024-03-10T02:00:00Z", 3600, "free", "EUR"),
+	}
+	other := usageRecord("c8", "2024-03-10T00:00:00Z", "2024-03-10T01:00:00Z", 3600, "50", "EUR")
+	other.Spec.Buyer = nodecorev1alpha1.NodeIdentity{Domain: "other.eu", NodeID: "other"}
+	records = append(records, other)
+
+	tests := []struct {
+		name       string
+		node       nodecorev1alpha1.NodeIdentity
+		wantNode   string
+		wantRole   string
+		wantLines  []models.StatementLine
+		wantTotals []models.Cost
+	}{
+		{
+			name:     "buyer by key",
+			node:     nodecorev1alpha1.NodeIdentity{PublicKey: "buyer-key"},
+			wantNode: "buyer",
+			wantLines: []models.StatementLine{
+				{ContractID: "c1", Role: reservationv1alpha1.ContractPartyBuyer, ActiveSeconds: 7200, Cost: models.Cost{Amount: "4.000000", Currency: "EUR"}},
+				{ContractID: "c2", Role: reservationv1alpha1.ContractPartyBuyer, ActiveSeconds: 3600, Cost: models.Cost{Amount: "2.000000", Currency: "EUR"}},
+				{ContractID: "c3", Role: reservationv1alpha1.ContractPartyBuyer, ActiveSeconds: 3600, Cost: models.Cost{Amount: "6.000000", Currency: "USD"}},
+			},
+			wantTotals: []models.Cost{{Amount: "6.000000", Currency: "EUR"}, {Amount: "6.000000", Currency: "USD"}},
+		},
+		{
+			name:     "seller by domain",
+			node:     nodecorev1alpha1.NodeIdentity{Domain: "seller.eu", NodeID: "seller"},
+			wantNode: "seller",
+			wantLines: []models.StatementLine{
+				{ContractID: "c1", Role: reservationv1alpha1.ContractPartySeller, ActiveSeconds: 7200, Cost: models.Cost{Amount: "4.000000", Currency: "EUR"}},
+				{ContractID: "c2", Role: reservationv1alpha1.ContractPartySeller, ActiveSeconds: 3600, Cost: models.Cost{Amount: "2.000000", Currency: "EUR"}},
+				{ContractID: "c3", Role: reservationv1alpha1.ContractPartySeller, ActiveSeconds: 3600, Cost: models.Cost{Amount: "6.000000", Currency: "USD"}},
+				{ContractID: "c8", Role: reservationv1alpha1.ContractPartySeller, ActiveSeconds: 3600, Cost: models.Cost{Amount: "50.000000", Currency: "EUR"}},
+			},
+			wantTotals: []models.Cost{{Amount: "56.000000", Currency: "EUR"}, {Amount: "6.000000", Currency: "USD"}},
+		},
+		{
+			name:       "not a party",
+			node:       nodecorev1alpha1.NodeIdentity{PublicKey: "unknown-key"},
+			wantLines:  []models.StatementLine{},
+			wantTotals: []models.Cost{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement := BuildStatement(records, tt.node, from, to)
+			if statement.NodeID != tt.wantNode || statement.From != "2024-03-01T00:00:00Z" || statement.To != "2024-04-01T00:00:00Z" {
+				t.Errorf("BuildStatement() = node %q from %s to %s", statement.NodeID, statement.From, statement.To)
+			}
+			if len(statement.Lines) != len(tt.wantLines) {
+				t.Fatalf("BuildStatement() lines = %+v, want %+v", statement.Lines, tt.wantLines)
+			}
+			for i, want := range tt.wantLines {
+				got := statement.Lines[i]
+				if got.ContractID != want.ContractID || got.Role != want.Role || got.ActiveSeconds != want.ActiveSeconds || got.Cost != want.Cost {
+					t.Errorf("BuildStatement() line %d = %+v, want %+v", i, got, want)
+				}
+			}
+			if len(statement.Totals) != len(tt.wantTotals) {
+				t.Fatalf("BuildStatement() totals = %+v, want %+v", statement.Totals, tt.wantTotals)
+			}
+			for i, want := range tt.wantTotals {
+				if statement.Totals[i] != want {
+					t.Errorf("BuildStatement() total %d = %+v, want %+v", i, statement.Totals[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	statement := models.Statement{
+		From: "2024-03-01T00:00:00Z",
+		To:   "2024-04-01T00:00:00Z",
+		Lines: []models.StatementLine{{ContractID: "c1", FlavourID: "flavour", Role: reservationv1alpha1.ContractPartyBuyer,
+			Counterparty: models.NodeIdentity{NodeID: "seller", Domain: "seller.eu"}, ActiveSeconds: 60,
+			Cost: models.Cost{Amount: "1.500000", Currency: "EUR"}}},
+	}
+
+	var b bytes.Buffer
+	if err := WriteCSV(&b, &statement); err != nil {
+		t.Fatalf("WriteCSV() error = %s", err)
+	}
+	want := "from,to,contractID,flavourID,role,counterpartyNodeID,counterpartyDomain,activeSeconds,amount,currency\n" +
+		"2024-03-01T00:00:00Z,2024-04-01T00:00:00Z,c1,flavour," + reservationv1alpha1.ContractPartyBuyer + ",seller,seller.eu,60,1.500000,EUR\n"
+	if got := b.String(); got != want {
+		t.Errorf("WriteCSV() = %q, want %q", got, want)
+	}
+}
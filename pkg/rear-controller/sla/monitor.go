@@ -0,0 +1,358 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	foreigncluster "github.com/liqotech/liqo/pkg/utils/foreignCluster"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/rear-controller/gateway"
+	"github.com/fluidos-project/node/pkg/rear-controller/metering"
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/getters"
+	"github.com/fluidos-project/node/pkg/utils/pricing"
+)
+
+// clusterRole
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=contracts,verbs=get;list;watch
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=contracts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=reservation.fluidos.eu,resources=usagerecords,verbs=get;list;watch
+//+kubebuilder:rbac:groups=discovery.liqo.io,resources=foreignclusters,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+// periodLayout is the layout of the monthly periods the availability is measured on
+const periodLayout = "2006-01"
+
+var hundred = big.NewRat(100, 1)
+
+// Monitor probes the sellers of the Contracts bought by the FLUIDOS Node and checks their compliance with the SLA terms
+type Monitor struct {
+	client   client.Client
+	gateway  *gateway.Gateway
+	recorder record.EventRecorder
+}
+
+// NewMonitor creates a new Monitor
+func NewMonitor(c client.Client, gw *gateway.Gateway, recorder record.EventRecorder) *Monitor {
+	return &Monitor{client: c, gateway: gw, recorder: recorder}
+}
+
+// Monitoring returns a function that probes the Contracts with SLA terms every interval
+func (m *Monitor) Monitoring(interval time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return wait.PollUntilContextCancel(ctx, interval, false, m.monitor)
+	}
+}
+
+// monitor probes every active Contract with SLA terms bought by the FLUIDOS Node
+func (m *Monitor) monitor(ctx context.Context) (bool, error) {
+	identity := getters.GetNodeIdentity(ctx, m.client)
+	if identity == nil {
+		klog.Infof("Node identity not available yet, skipping the SLA probes")
+		return false, nil
+	}
+
+	var contracts reservationv1alpha1.ContractList
+	if err := m.client.List(ctx, &contracts, client.InNamespace(flags.FLUIDOS_NAMESPACE)); err != nil {
+		klog.Errorf("Error when listing Contracts: %s", err)
+		return false, nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for i := range contracts.Items {
+		contract := &contracts.Items[i]
+		if contract.Spec.SLA == nil || !contract.IsActive() || contract.Spec.Buyer.NodeID != identity.NodeID {
+			continue
+		}
+		if err := m.monitorContract(ctx, contract, now); err != nil {
+			klog.Errorf("Error when monitoring the SLA of Contract %s: %s", contract.Name, err)
+		}
+	}
+
+	return false, nil
+}
+
+// monitorContract probes the seller of the Contract and updates the compliance of the Contract with its SLA terms
+func (m *Monitor) monitorContract(ctx context.Context, contract *reservationv1alpha1.Contract, now time.Time) error {
+	healthy, probeErr, err := m.probe(ctx, contract)
+	if err != nil {
+		return err
+	}
+
+	status := contract.Status.SLA
+	if status == nil {
+		status = &reservationv1alpha1.SLAStatus{Period: now.Format(periodLayout), Healthy: true}
+	}
+
+	// The time elapsed since the last probe is attributed to the state observed by the last probe
+	last, err := time.Parse(time.RFC3339, status.LastProbeTime)
+	if err != nil {
+		last = now
+	}
+	if since := metering.ActiveSince(contract); last.Before(since) {
+		last = since
+	}
+	for last.Before(now) {
+		_, periodEnd := metering.BillingPeriod(last)
+		end := now
+		if periodEnd.Before(end) {
+			end = periodEnd
+		}
+		if status.Period != last.Format(periodLayout) {
+			status.Period, status.ObservedSeconds, status.DownSeconds = last.Format(periodLayout), 0, 0
+		}
+		status.ObservedSeconds += int64(end.Sub(last) / time.Second)
+		if !status.Healthy {
+			status.DownSeconds += int64(end.Sub(last) / time.Second)
+		}
+		m.checkAvailability(contract, status, end)
+		last = end
+	}
+	if status.Period != now.Format(periodLayout) {
+		status.Period, status.ObservedSeconds, status.DownSeconds = now.Format(periodLayout), 0, 0
+	}
+
+	switch {
+	case !healthy && status.DownSince == "":
+		status.DownSince = now.Format(time.RFC3339)
+		m.recorder.Event(contract, corev1.EventTypeWarning, "SLAOutage", "The purchased resources are unavailable: "+probeErr.Error())
+	case healthy && status.DownSince != "":
+		m.recorder.Event(contract, corev1.EventTypeNormal, "SLARestored", "The purchased resources are available again, after an outage since "+status.DownSince)
+		status.DownSince = ""
+	}
+	m.checkTimeToRestore(contract, status, now)
+
+	status.Healthy = healthy
+	status.LastProbeTime = now.Format(time.RFC3339)
+	status.LastError = ""
+	if probeErr != nil {
+		status.LastError = probeErr.Error()
+	}
+	if status.ObservedSeconds > 0 {
+		availability := big.NewRat(status.ObservedSeconds-status.DownSeconds, status.ObservedSeconds)
+		status.Availability = availability.Mul(availability, hundred).FloatString(3)
+	}
+
+	if err := m.updateCredits(ctx, contract, status); err != nil {
+		klog.Errorf("Error when computing the SLA credits of Contract %s: %s", contract.Name, err)
+	}
+
+	contract.Status.SLA = status
+	return m.client.Status().Update(ctx, contract)
+}
+
+// probe checks that the seller's Gateway reports the Contract as active and that the peering with the seller cluster is healthy.
+// A failure of the local cluster, which cannot be blamed on the seller, is returned as an error and skips the probe.
+func (m *Monitor) probe(ctx context.Context, contract *reservationv1alpha1.Contract) (healthy bool, probeErr, err error) {
+	remote, err := m.gateway.GetContract(ctx, contract.Name, contract.Spec.Seller)
+	if err != nil {
+		return false, fmt.Errorf("the seller's Gateway is unreachable: %w", err), nil
+	}
+	if remote.Phase != string(nodecorev1alpha1.PhaseActive) {
+		return false, fmt.Errorf("the seller reports the contract as %s", remote.Phase), nil
+	}
+
+	clusterID := contract.Spec.SellerCredentials.ClusterID
+	fc, err := foreigncluster.GetForeignClusterByID(ctx, m.client, clusterID)
+	if apierrors.IsNotFound(err) {
+		// No peering has been established with the purchased resources yet
+		return true, nil, nil
+	} else if err != nil {
+		return false, nil, err
+	}
+	if !foreigncluster.IsOutgoingEnabled(fc) {
+		return true, nil, nil
+	}
+
+	switch {
+	case !foreigncluster.IsOutgoingJoined(fc):
+		return false, errors.New("the outgoing peering with cluster " + clusterID + " is not established"), nil
+	case !foreigncluster.IsNetworkingEstablishedOrExternal(fc):
+		return false, errors.New("the network with cluster " + clusterID + " is not established"), nil
+	case !foreigncluster.IsAPIServerReady(fc):
+		return false, errors.New("the API server of cluster " + clusterID + " is not ready"), nil
+	}
+	return true, nil, nil
+}
+
+// checkAvailability records a violation when the downtime of the period exceeds the budget allowed by the availability target
+// over the time the Contract is active in the period. The credit of the violation follows the availability it implies.
+func (m *Monitor) checkAvailability(contract *reservationv1alpha1.Contract, status *reservationv1alpha1.SLAStatus, at time.Time) {
+	target, err := pricing.ParseAmount(contract.Spec.SLA.Availability)
+	if err != nil || target.Sign() == 0 || status.DownSeconds == 0 {
+		return
+	}
+
+	from, to := metering.BillingPeriod(at)
+	if since := metering.ActiveSince(contract); since.After(from) {
+		from = since
+	}
+	if expiration, err := time.Parse(time.RFC3339, contract.Spec.ExpirationTime); err == nil && expiration.Before(to) {
+		to = expiration
+	}
+	expected := int64(to.Sub(from) / time.Second)
+	if expected <= 0 {
+		return
+	}
+
+	// availability is the best availability the period can still reach
+	availability := big.NewRat(expected-status.DownSeconds, expected)
+	availability.Mul(availability, hundred)
+	if availability.Cmp(target) >= 0 {
+		return
+	}
+	credit := availabilityCredit(contract.Spec.SLA, availability)
+	message := fmt.Sprintf("The availability is at most %s%%, below the target of %s%%", availability.FloatString(3), contract.Spec.SLA.Availability)
+
+	for i := range status.Violations {
+		v := &status.Violations[i]
+		if v.Type == reservationv1alpha1.SLAViolationAvailability && v.Period == status.Period {
+			if v.Credit != credit {
+				v.Credit, v.Message = credit, message
+				m.recorder.Event(contract, corev1.EventTypeWarning, "SLAViolation", v.Message)
+			}
+			return
+		}
+	}
+
+	violation := reservationv1alpha1.SLAViolation{
+		Type:      reservationv1alpha1.SLAViolationAvailability,
+		Period:    status.Period,
+		StartTime: at.Format(time.RFC3339),
+		Message:   message,
+		Credit:    credit,
+	}
+	status.Violations = append(status.Violations, violation)
+	m.recorder.Event(contract, corev1.EventTypeWarning, "SLAViolation", violation.Message)
+}
+
+// checkTimeToRestore records a violation, once per outage, when the current outage lasts longer than the maximum time to restore
+func (m *Monitor) checkTimeToRestore(contract *reservationv1alpha1.Contract, status *reservationv1alpha1.SLAStatus, now time.Time) {
+	if status.DownSince == "" || contract.Spec.SLA.MaxTimeToRestore == "" {
+		return
+	}
+	maxTimeToRestore, err := pricing.ParsePeriod(contract.Spec.SLA.MaxTimeToRestore)
+	if err != nil {
+		klog.Errorf("Invalid maximum time to restore of Contract %s: %s", contract.Name, err)
+		return
+	}
+	downSince, err := time.Parse(time.RFC3339, status.DownSince)
+	if err != nil || now.Sub(downSince) <= maxTimeToRestore {
+		return
+	}
+
+	for i := range status.Violations {
+		if status.Violations[i].Type == reservationv1alpha1.SLAViolationTimeToRestore && status.Violations[i].StartTime == status.DownSince {
+			return
+		}
+	}
+
+	violation := reservationv1alpha1.SLAViolation{
+		Type:      reservationv1alpha1.SLAViolationTimeToRestore,
+		Period:    now.Format(periodLayout),
+		StartTime: status.DownSince,
+		Message:   fmt.Sprintf("The outage started at %s lasts longer than %s", status.DownSince, contract.Spec.SLA.MaxTimeToRestore),
+		Credit:    contract.Spec.SLA.RestoreCredit,
+	}
+	status.Violations = append(status.Violations, violation)
+	m.recorder.Event(contract, corev1.EventTypeWarning, "SLAViolation", violation.Message)
+}
+
+// availabilityCredit returns the credit of the lowest availability threshold crossed
+func availabilityCredit(sla *nodecorev1alpha1.SLA, availability *big.Rat) string {
+	var lowest *big.Rat
+	credit := ""
+	for _, c := range sla.AvailabilityCredits {
+		threshold, err := pricing.ParseAmount(c.BelowAvailability)
+		if err != nil || availability.Cmp(threshold) >= 0 {
+			continue
+		}
+		if lowest == nil || threshold.Cmp(lowest) < 0 {
+			lowest, credit = threshold, c.Credit
+		}
+	}
+	return credit
+}
+
+// updateCredits computes, for every period with violations, the credit owed by the seller on the cost metered in the period
+func (m *Monitor) updateCredits(ctx context.Context, contract *reservationv1alpha1.Contract, status *reservationv1alpha1.SLAStatus) error {
+	percentages := make(map[string]*big.Rat)
+	for _, v := range status.Violations {
+		credit, err := pricing.ParseAmount(v.Credit)
+		if err != nil {
+			continue
+		}
+		if _, ok := percentages[v.Period]; !ok {
+			percentages[v.Period] = new(big.Rat)
+		}
+		percentages[v.Period].Add(percentages[v.Period], credit)
+	}
+	if len(percentages) == 0 {
+		return nil
+	}
+
+	var records reservationv1alpha1.UsageRecordList
+	if err := m.client.List(ctx, &records, client.InNamespace(flags.FLUIDOS_NAMESPACE),
+		client.MatchingLabels{consts.CONTRACT_LABEL: contract.Name}); err != nil {
+		return err
+	}
+
+	credits := make([]reservationv1alpha1.SLACreditNote, 0, len(percentages))
+	for period, percentage := range percentages {
+		start, err := time.Parse(periodLayout, period)
+		if err != nil {
+			continue
+		}
+		if percentage.Cmp(hundred) > 0 {
+			percentage.Set(hundred)
+		}
+
+		from, to := metering.BillingPeriod(start)
+		statement := metering.BuildStatement(records.Items, contract.Spec.Buyer, from, to)
+		note := reservationv1alpha1.SLACreditNote{
+			Period:     period,
+			Percentage: percentage.FloatString(3),
+			Amount:     reservationv1alpha1.Cost{Amount: pricing.FormatAmount(new(big.Rat)), Currency: contract.Spec.Flavour.Spec.Price.Currency},
+		}
+		if len(statement.Totals) > 0 {
+			cost, err := pricing.ParseAmount(statement.Totals[0].Amount)
+			if err != nil {
+				return err
+			}
+			cost.Mul(cost, percentage).Quo(cost, hundred)
+			note.Amount = reservationv1alpha1.Cost{Amount: pricing.FormatAmount(cost), Currency: statement.Totals[0].Currency}
+		}
+		credits = append(credits, note)
+	}
+	sort.Slice(credits, func(i, j int) bool { return credits[i].Period < credits[j].Period })
+
+	status.Credits = credits
+	return nil
+}
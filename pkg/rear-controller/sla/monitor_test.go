@@ -0,0 +1,281 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sla
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+)
+
+var testSLA = &nodecorev1alpha1.SLA{
+	Availability:     "99.9",
+	MaxTimeToRestore: "PT1H",
+	RestoreCredit:    "5",
+	AvailabilityCredits: []nodecorev1alpha1.SLACredit{
+		{BelowAvailability: "99.9", Credit: "10"},
+		{BelowAvailability: "95", Credit: "50"},
+		{BelowAvailability: "99", Credit: "25"},
+		{BelowAvailability: "lots", Credit: "100"},
+	},
+}
+
+// slaContract returns a Contract with the test SLA terms, active since the given time
+func slaContract(since time.Time, expiration string) *reservationv1alpha1.Contract {
+	return &reservationv1alpha1.Contract{
+		ObjectMeta: metav1.ObjectMeta{Name: "contract", Namespace: flags.FLUIDOS_NAMESPACE, CreationTimestamp: metav1.NewTime(since)},
+		Spec: reservationv1alpha1.ContractSpec{
+			Buyer:          nodecorev1alpha1.NodeIdentity{Domain: "buyer.eu", NodeID: "buyer"},
+			Seller:         nodecorev1alpha1.NodeIdentity{Domain: "seller.eu", NodeID: "seller"},
+			ExpirationTime: expiration,
+			SLA:            testSLA,
+			Flavour: nodecorev1alpha1.Flavour{Spec: nodecorev1alpha1.FlavourSpec{
+				Price: nodecorev1alpha1.Price{Amount: "1", Currency: "EUR", Period: "PT1H"}}},
+		},
+	}
+}
+
+func TestAvailabilityCredit(t *testing.T) {
+	tests := []struct {
+		availability *big.Rat
+		want         string
+	}{
+		{availability: big.NewRat(100, 1), want: ""},
+		{availability: big.NewRat(999, 10), want: ""},
+		{availability: big.NewRat(995, 10), want: "10"},
+		{availability: big.NewRat(99, 1), want: "10"},
+		{availability: big.NewRat(98, 1), want: "25"},
+		{availability: big.NewRat(90, 1), want: "50"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.availability.FloatString(1), func(t *testing.T) {
+			if got := availabilityCredit(testSLA, tt.availability); got != tt.want {
+				t.Errorf("availabilityCredit() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAvailability(t *testing.T) {
+	march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		since       time.Time
+		expiration  string
+		target      string
+		downSeconds int64
+		violations  []reservationv1alpha1.SLAViolation
+		wantCredit  string
+		wantEvent   bool
+	}{
+		{name: "no downtime", since: march, target: "99.9"},
+		{name: "within the budget of the month", since: march, target: "99.9", downSeconds: 2000},
+		{name: "budget of the month exceeded", since: march, target: "99.9", downSeconds: 3600, wantCredit: "10", wantEvent: true},
+		{name: "long outage", since: march, target: "99.9", downSeconds: 86400, wantCredit: "25", wantEvent: true},
+		{name: "very long outage", since: march, target: "99.9", downSeconds: 86400 * 3, wantCredit: "50", wantEvent: true},
+		{name: "budget of a contract started in the month", since: march.AddDate(0, 0, 15), target: "99.9",
+			downSeconds: 2000, wantCredit: "10", wantEvent: true},
+		{name: "budget of a contract expiring in the month", since: march, expiration: "2024-03-11T00:00:00Z", target: "99.9",
+			downSeconds: 2000, wantCredit: "10", wantEvent: true},
+		{name: "no availability target", since: march, downSeconds: 86400},
+		{
+			name: "violation of the period updated", since: march, target: "99.9", downSeconds: 86400,
+			violations: []reservationv1alpha1.SLAViolation{{Type: reservationv1alpha1.SLAViolationAvailability, Period: "2024-03",
+				StartTime: "2024-03-02T00:00:00Z", Credit: "10"}},
+			wantCredit: "25", wantEvent: true,
+		},
+		{
+			name: "violation of the period unchanged", since: march, target: "99.9", downSeconds: 3600,
+			violations: []reservationv1alpha1.SLAViolation{{Type: reservationv1alpha1.SLAViolationAvailability, Period: "2024-03",
+				StartTime: "2024-03-02T00:00:00Z", Credit: "10"}},
+			wantCredit: "10",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contract := slaContract(tt.since, tt.expiration)
+			sla := *testSLA
+			sla.Availability = tt.target
+			contract.Spec.SLA = &sla
+			status := &reservationv1alpha1.SLAStatus{Period: "2024-03", DownSeconds: tt.downSeconds,
+				Violations: append([]reservationv1alpha1.SLAViolation(nil), tt.violations...)}
+			recorder := record.NewFakeRecorder(10)
+			m := &Monitor{recorder: recorder}
+
+			m.checkAvailability(contract, status, at)
+
+			if tt.wantCredit == "" {
+				if len(status.Violations) != 0 {
+					t.Errorf("checkAvailability() recorded %+v, want no violation", status.Violations)
+				}
+			} else if len(status.Violations) != 1 || status.Violations[0].Credit != tt.wantCredit ||
+				status.Violations[0].Type != reservationv1alpha1.SLAViolationAvailability {
+				t.Errorf("checkAvailability() recorded %+v, want one availability violation with credit %s", status.Violations, tt.wantCredit)
+			}
+			if got := len(recorder.Events) > 0; got != tt.wantEvent {
+				t.Errorf("checkAvailability() event recorded = %v, want %v", got, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestCheckTimeToRestore(t *testing.T) {
+	now := time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		downSince        string
+		maxTimeToRestore string
+		violations       []reservationv1alpha1.SLAViolation
+		wantViolations   int
+	}{
+		{name: "healthy", maxTimeToRestore: "PT1H"},
+		{name: "short outage", downSince: "2024-03-20T11:30:00Z", maxTimeToRestore: "PT1H"},
+		{name: "long outage", downSince: "2024-03-20T10:00:00Z", maxTimeToRestore: "PT1H", wantViolations: 1},
+		{
+			name: "long outage already recorded", downSince: "2024-03-20T10:00:00Z", maxTimeToRestore: "PT1H",
+			violations:     []reservationv1alpha1.SLAViolation{{Type: reservationv1alpha1.SLAViolationTimeToRestore, StartTime: "2024-03-20T10:00:00Z"}},
+			wantViolations: 1,
+		},
+		{
+			name: "new long outage", downSince: "2024-03-20T10:00:00Z", maxTimeToRestore: "PT1H",
+			violations:     []reservationv1alpha1.SLAViolation{{Type: reservationv1alpha1.SLAViolationTimeToRestore, StartTime: "2024-03-19T10:00:00Z"}},
+			wantViolations: 2,
+		},
+		{name: "no maximum time to restore", downSince: "2024-03-20T10:00:00Z"},
+		{name: "invalid maximum time to restore", downSince: "2024-03-20T10:00:00Z", maxTimeToRestore: "soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contract := slaContract(now.AddDate(0, 0, -30), "")
+			sla := *testSLA
+			sla.MaxTimeToRestore = tt.maxTimeToRestore
+			contract.Spec.SLA = &sla
+			status := &reservationv1alpha1.SLAStatus{Period: "2024-03", DownSince: tt.downSince,
+				Violations: append([]reservationv1alpha1.SLAViolation(nil), tt.violations...)}
+			m := &Monitor{recorder: record.NewFakeRecorder(10)}
+
+			m.checkTimeToRestore(contract, status, now)
+
+			if len(status.Violations) != tt.wantViolations {
+				t.Fatalf("checkTimeToRestore() recorded %+v, want %d violations", status.Violations, tt.wantViolations)
+			}
+			if tt.wantViolations > len(tt.violations) {
+				v := status.Violations[len(status.Violations)-1]
+				if v.Type != reservationv1alpha1.SLAViolationTimeToRestore || v.StartTime != tt.downSince ||
+					v.Credit != "5" || v.Period != "2024-03" {
+					t.Errorf("checkTimeToRestore() recorded %+v", v)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateCredits(t *testing.T) {
+	contract := slaContract(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), "")
+	usage := func(name, start, end, amount string) client.Object {
+		return &reservationv1alpha1.UsageRecord{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: flags.FLUIDOS_NAMESPACE,
+				Labels: map[string]string{consts.CONTRACT_LABEL: contract.Name}},
+			Spec: reservationv1alpha1.UsageRecordSpec{
+				ContractID: contract.Name,
+				Buyer:      contract.Spec.Buyer,
+				Seller:     contract.Spec.Seller,
+				StartTime:  start,
+				EndTime:    end,
+				Cost:       reservationv1alpha1.Cost{Amount: amount, Currency: "EUR"},
+			},
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	if err := reservationv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		usage("february", "2024-02-01T00:00:00Z", "2024-03-01T00:00:00Z", "50"),
+		usage("march", "2024-03-01T00:00:00Z", "2024-03-20T00:00:00Z", "100"),
+	).Build()
+	m := &Monitor{client: cl}
+
+	tests := []struct {
+		name        string
+		violations  []reservationv1alpha1.SLAViolation
+		wantCredits []reservationv1alpha1.SLACreditNote
+	}{
+		{name: "no violations"},
+		{
+			name: "credits per period",
+			violations: []reservationv1alpha1.SLAViolation{
+				{Period: "2024-03", Credit: "10"},
+				{Period: "2024-02", Credit: "25"},
+				{Period: "2024-03", Credit: "5"},
+				{Period: "2024-03", Credit: ""},
+			},
+			wantCredits: []reservationv1alpha1.SLACreditNote{
+				{Period: "2024-02", Percentage: "25.000", Amount: reservationv1alpha1.Cost{Amount: "12.500000", Currency: "EUR"}},
+				{Period: "2024-03", Percentage: "15.000", Amount: reservationv1alpha1.Cost{Amount: "15.000000", Currency: "EUR"}},
+			},
+		},
+		{
+			name:       "credit capped to the cost",
+			violations: []reservationv1alpha1.SLAViolation{{Period: "2024-03", Credit: "80"}, {Period: "2024-03", Credit: "50"}},
+			wantCredits: []reservationv1alpha1.SLACreditNote{
+				{Period: "2024-03", Percentage: "100.000", Amount: reservationv1alpha1.Cost{Amount: "100.000000", Currency: "EUR"}},
+			},
+		},
+		{
+			name:       "period not metered",
+			violations: []reservationv1alpha1.SLAViolation{{Period: "2024-04", Credit: "10"}},
+			wantCredits: []reservationv1alpha1.SLACreditNote{
+				{Period: "2024-04", Percentage: "10.000", Amount: reservationv1alpha1.Cost{Amount: "0.000000", Currency: "EUR"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &reservationv1alpha1.SLAStatus{Violations: tt.violations}
+			if err := m.updateCredits(context.Background(), contract, status); err != nil {
+				t.Fatalf("updateCredits() error = %s", err)
+			}
+			if len(status.Credits) != len(tt.wantCredits) {
+				t.Fatalf("updateCredits() = %+v, want %+v", status.Credits, tt.wantCredits)
+			}
+			for i := range tt.wantCredits {
+				if status.Credits[i] != tt.wantCredits[i] {
+					t.Errorf("updateCredits()[%d] = %+v, want %+v", i, status.Credits[i], tt.wantCredits[i])
+				}
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ package common
 
 import (
 	"fmt"
+	"strings"
 
 	"k8s.io/klog/v2"
 
@@ -78,6 +79,17 @@ func FilterFlavour(selector *models.Selector, f nodecorev1alpha1.Flavour) bool {
 			klog.Infof("MatchSelector GPU: %d - Flavour GPU: %d", selector.MatchSelector.Gpu, f.Spec.Characteristics.Gpu)
 			return false
 		}
+
+		if selector.MatchSelector.GpuModel != "" && !gpuModelMatches(f.Spec.Characteristics.GpuDetails, selector.MatchSelector.GpuModel) {
+			klog.Infof("MatchSelector GPU model: %s - Flavour %s has different GPUs", selector.MatchSelector.GpuModel, f.Name)
+			return false
+		}
+
+		if !selector.MatchSelector.GpuMemory.IsZero() && (f.Spec.Characteristics.GpuDetails == nil ||
+			f.Spec.Characteristics.GpuDetails.Memory.Cmp(selector.MatchSelector.GpuMemory) != 0) {
+			klog.Infof("MatchSelector GPU memory: %s - Flavour %s has different GPUs", selector.MatchSelector.GpuMemory.String(), f.Name)
+			return false
+		}
 	}
 
 	if selector.RangeSelector != nil && selector.MatchSelector == nil {
@@ -125,11 +137,31 @@ func FilterFlavour(selector *models.Selector, f nodecorev1alpha1.Flavour) bool {
 		if selector.RangeSelector.MaxGpu.CmpInt64(0) != 0 && f.Spec.Characteristics.Gpu.Cmp(selector.RangeSelector.MaxGpu) > 0 {
 			return false
 		}
+
+		if selector.RangeSelector.GpuModel != "" && !gpuModelMatches(f.Spec.Characteristics.GpuDetails, selector.RangeSelector.GpuModel) {
+			return false
+		}
+
+		if !selector.RangeSelector.MinGpuMemory.IsZero() && (f.Spec.Characteristics.GpuDetails == nil ||
+			f.Spec.Characteristics.GpuDetails.Memory.Cmp(selector.RangeSelector.MinGpuMemory) < 0) {
+			return false
+		}
+
+		if !selector.RangeSelector.MaxGpuMemory.IsZero() && (f.Spec.Characteristics.GpuDetails == nil ||
+			f.Spec.Characteristics.GpuDetails.Memory.Cmp(selector.RangeSelector.MaxGpuMemory) > 0) {
+			return false
+		}
 	}
 
 	return true
 }
 
+// gpuModelMatches reports whether the model of the GPUs contains the requested model, regardless of the case
+// (e.g. A100 matches NVIDIA-A100-SXM4-80GB)
+func gpuModelMatches(gpu *nodecorev1alpha1.GPU, model string) bool {
+	return gpu != nil && gpu.Model != "" && strings.Contains(strings.ToLower(gpu.Model), strings.ToLower(model))
+}
+
 // FilterPeeringCandidate filters the peering candidate based on the solver's flavour selector
 func FilterPeeringCandidate(selector *nodecorev1alpha1.FlavourSelector, pc *advertisementv1alpha1.PeeringCandidate) bool {
 	s := parseutil.ParseFlavourSelector(selector)
@@ -209,3 +241,29 @@ func ReservationStatusCheck(solver *nodecorev1alpha1.Solver, reservation *reserv
 		solver.SetReservationStatus(nodecorev1alpha1.PhaseIdle)
 	}
 }
+
+// CheckPartition checks that the partition can be obtained from the Flavour
+func CheckPartition(flavour *nodecorev1alpha1.Flavour, partition *models.Partition) error {
+	if partition == nil {
+		return nil
+	}
+
+	partitionable := flavour.Spec.Policy.Partitionable
+	if partitionable == nil {
+		return fmt.Errorf("flavour %s is not partitionable", flavour.Name)
+	}
+
+	if partition.Architecture != "" && partition.Architecture != flavour.Spec.Characteristics.Architecture {
+		return fmt.Errorf("architecture %s does not match the flavour architecture %s", partition.Architecture, flavour.Spec.Characteristics.Architecture)
+	}
+
+	if partition.Cpu.Cmp(partitionable.CpuMin) < 0 || partition.Cpu.Cmp(flavour.Spec.Characteristics.Cpu) > 0 {
+		return fmt.Errorf("cpu %s is outside the range [%s, %s]", partition.Cpu.String(), partitionable.CpuMin.String(), flavour.Spec.Characteristics.Cpu.String())
+	}
+
+	if partition.Memory.Cmp(partitionable.MemoryMin) < 0 || partition.Memory.Cmp(flavour.Spec.Characteristics.Memory) > 0 {
+		return fmt.Errorf("memory %s is outside the range [%s, %s]", partition.Memory.String(), partitionable.MemoryMin.String(), flavour.Spec.Characteristics.Memory.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+func TestFilterFlavourGPU(t *testing.T) {
+	a100 := &nodecorev1alpha1.GPU{Vendor: "nvidia.com", Model: "NVIDIA-A100-SXM4-80GB", Memory: resource.MustParse("80Gi")}
+	t4 := &nodecorev1alpha1.GPU{Vendor: "nvidia.com", Model: "Tesla-T4", Memory: resource.MustParse("16Gi")}
+	unknown := &nodecorev1alpha1.GPU{Vendor: "nvidia.com"}
+
+	tests := []struct {
+		name     string
+		gpu      *nodecorev1alpha1.GPU
+		selector models.Selector
+		want     bool
+	}{
+		{"no GPU requirement", nil, models.Selector{MatchSelector: &models.MatchSelector{}}, true},
+		{"match model substring", a100, models.Selector{MatchSelector: &models.MatchSelector{GpuModel: "a100"}}, true},
+		{"match different model", t4, models.Selector{MatchSelector: &models.MatchSelector{GpuModel: "A100"}}, false},
+		{"match model of unknown GPUs", unknown, models.Selector{MatchSelector: &models.MatchSelector{GpuModel: "A100"}}, false},
+		{"match model without GPUs", nil, models.Selector{MatchSelector: &models.MatchSelector{GpuModel: "A100"}}, false},
+		{"match memory", a100, models.Selector{MatchSelector: &models.MatchSelector{GpuMemory: resource.MustParse("80Gi")}}, true},
+		{"match different memory", t4, models.Selector{MatchSelector: &models.MatchSelector{GpuMemory: resource.MustParse("80Gi")}}, false},
+		{"match memory without GPUs", nil, models.Selector{MatchSelector: &models.MatchSelector{GpuMemory: resource.MustParse("16Gi")}}, false},
+		{"range model", t4, models.Selector{RangeSelector: &models.RangeSelector{GpuModel: "t4"}}, true},
+		{"range different model", a100, models.Selector{RangeSelector: &models.RangeSelector{GpuModel: "T4"}}, false},
+		{"range memory within bounds", t4, models.Selector{RangeSelector: &models.RangeSelector{
+			MinGpuMemory: resource.MustParse("8Gi"), MaxGpuMemory: resource.MustParse("32Gi")}}, true},
+		{"range memory below minimum", t4, models.Selector{RangeSelector: &models.RangeSelector{MinGpuMemory: resource.MustParse("32Gi")}}, false},
+		{"range memory above maximum", a100, models.Selector{RangeSelector: &models.RangeSelector{MaxGpuMemory: resource.MustParse("32Gi")}}, false},
+		{"range memory of unknown GPUs", unknown, models.Selector{RangeSelector: &models.RangeSelector{MinGpuMemory: resource.MustParse("8Gi")}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f nodecorev1alpha1.Flavour
+			f.Name = "flavour"
+			f.Spec.Characteristics.Architecture = "amd64"
+			f.Spec.Characteristics.GpuDetails = tt.gpu
+			tt.selector.Architecture = "amd64"
+			if got := FilterFlavour(&tt.selector, f); got != tt.want {
+				t.Errorf("FilterFlavour() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -15,8 +15,36 @@
 package consts
 
 const (
-	NETWORK_CONFIG_MAP_NAME       = "fluidos-network-manager-config"
-	NODE_IDENTITY_CONFIG_MAP_NAME = "fluidos-network-manager-identity"
-	LIQO_CLUSTERID_CONFIGMAP_NAME = "liqo-clusterid-configmap"
-	LIQO_NAMESPACE                = "liqo"
+	NETWORK_CONFIG_MAP_NAME           = "fluidos-network-manager-config"
+	NODE_IDENTITY_CONFIG_MAP_NAME     = "fluidos-network-manager-identity"
+	LIQO_CLUSTERID_CONFIGMAP_NAME     = "liqo-clusterid-configmap"
+	LIQO_NAMESPACE                    = "liqo"
+	NODE_KEYPAIR_SECRET_NAME          = "fluidos-node-keypair"
+	PRIVATE_KEY_SECRET_KEY            = "private-key"
+	PUBLIC_KEY_SECRET_KEY             = "public-key"
+	GATEWAY_POLICY_CONFIG_MAP_NAME    = "fluidos-gateway-policy"
+	GATEWAY_TOKENS_SECRET_NAME        = "fluidos-gateway-tokens"
+	GATEWAY_CLIENT_TOKENS_SECRET_NAME = "fluidos-gateway-client-tokens"
+	IDEMPOTENCY_KEY_LABEL             = "fluidos.eu/idempotency-key"
+	TRANSACTION_ROLE_LABEL            = "reservation.fluidos.eu/role"
+	TRANSACTION_ROLE_SELLER           = "seller"
+	TRANSACTION_ROLE_BUYER            = "buyer"
+	CONTRACT_LABEL                    = "reservation.fluidos.eu/contract"
+	CONTRACT_TERMINATE_ANNOTATION     = "reservation.fluidos.eu/terminate"
+	CONTRACT_CREDENTIALS_SUFFIX       = "-credentials"
+	LIQO_TOKEN_SECRET_KEY             = "token"
+	FLAVOUR_NODE_LABEL                = "nodecore.fluidos.eu/node"
+	FLAVOUR_POOL_LABEL                = "nodecore.fluidos.eu/pool"
+	FLAVOUR_NODE_POOL_LABEL           = "nodecore.fluidos.eu/node-pool"
+)
+
+// Flavour characteristics that can be mapped to the resource names offered to Liqo
+const (
+	CHARACTERISTIC_CPU                = "cpu"
+	CHARACTERISTIC_MEMORY             = "memory"
+	CHARACTERISTIC_GPU                = "gpu"
+	CHARACTERISTIC_EPHEMERAL_STORAGE  = "ephemeral-storage"
+	CHARACTERISTIC_PERSISTENT_STORAGE = "persistent-storage"
+	CHARACTERISTIC_PODS               = "pods"
+	DEFAULT_GPU_RESOURCE_NAME         = "nvidia.com/gpu"
 )
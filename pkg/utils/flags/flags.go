@@ -23,12 +23,17 @@ var (
 
 // EXPIRATION flags
 var (
-	EXPIRATION_PHASE_RUNNING = 2 * time.Minute
-	EXPIRATION_SOLVER        = 5 * time.Minute
-	EXPIRATION_TRANSACTION   = 20 * time.Second
-	EXPIRATION_CONTRACT      = 365 * 24 * time.Hour
-	REFRESH_CACHE_INTERVAL   = 20 * time.Second
-	LIQO_CHECK_INTERVAL      = 20 * time.Second
+	EXPIRATION_PHASE_RUNNING   = 2 * time.Minute
+	EXPIRATION_SOLVER          = 5 * time.Minute
+	EXPIRATION_TRANSACTION     = 20 * time.Second
+	EXPIRATION_CONTRACT        = 365 * 24 * time.Hour
+	REFRESH_CACHE_INTERVAL     = 20 * time.Second
+	LIQO_CHECK_INTERVAL        = 20 * time.Second
+	EXPIRATION_MESSAGE         = 1 * time.Minute
+	REFRESH_AUTH_INTERVAL      = 30 * time.Second
+	RETRY_RESERVATION_INTERVAL = 10 * time.Second
+	EXPIRATION_IDEMPOTENCY_KEY = 24 * time.Hour
+	RETENTION_TRANSACTION      = 24 * time.Hour
 )
 
 var (
@@ -37,15 +42,85 @@ var (
 	RESOURCE_NODE_LABEL string
 )
 
+// Gateway security flags
 var (
-	RESOURCE_TYPE string
-	AMOUNT        string
-	CURRENCY      string
-	PERIOD        string
-	CPU_MIN       string
-	MEMORY_MIN    string
-	CPU_STEP      string
-	MEMORY_STEP   string
-	MIN_COUNT     int64
-	MAX_COUNT     int64
+	AUTH_MODE     string
+	TLS_CERT_FILE string
+	TLS_KEY_FILE  string
+	TLS_CA_FILE   string
+)
+
+// Gateway limits flags. A zero value disables the related limit.
+var (
+	RATE_LIMIT            float64
+	RATE_BURST            int
+	MAX_OPEN_TRANSACTIONS int
+	MAX_ACTIVE_CONTRACTS  int
+	MAX_CPU_PER_DOMAIN    string
+	MAX_MEMORY_PER_DOMAIN string
+)
+
+// Contract lifecycle flags
+var (
+	CONTRACT_EXPIRATION_WARNING time.Duration
+	AUTO_RENEW_CONTRACTS        bool
+)
+
+// CREDENTIALS_READER_ROLE is the Role scoped by the REAR Controller to the Secrets storing the Liqo credentials of the Contracts
+var CREDENTIALS_READER_ROLE string
+
+// METERING_INTERVAL is the interval at which the cost accrued by the Contracts is recorded in UsageRecords
+var METERING_INTERVAL time.Duration
+
+// USAGE_RECORD_RETENTION is how long the UsageRecords are kept after the end of their billing period (0 to keep them forever)
+var USAGE_RECORD_RETENTION time.Duration
+
+// SLA_PROBE_INTERVAL is the interval at which the sellers of the Contracts with SLA terms are probed
+var SLA_PROBE_INTERVAL time.Duration
+
+// Price negotiation flags
+var (
+	MAX_DISCOUNT           string
+	MAX_NEGOTIATION_ROUNDS int
+	NEGOTIATION_TIMEOUT    time.Duration
+)
+
+// Request for quote flags
+var (
+	BID_VALIDITY       time.Duration
+	RFQ_BIDDING_WINDOW time.Duration
+)
+
+// LIQO_RESOURCE_NAMES contains the comma-separated characteristic=name pairs mapping the flavour characteristics to the resource names offered to Liqo
+var LIQO_RESOURCE_NAMES string
+
+// TRANSACTION_STORE is the backend of the REAR Gateway transaction store (crd or memory)
+var TRANSACTION_STORE string
+
+var (
+	RESOURCE_TYPE           string
+	AMOUNT                  string
+	CURRENCY                string
+	PERIOD                  string
+	CPU_RATE                string
+	MEMORY_RATE             string
+	GPU_RATE                string
+	STORAGE_RATE            string
+	SLA_AVAILABILITY        string
+	SLA_MAX_TIME_TO_RESTORE string
+	SLA_RESTORE_CREDIT      string
+	SLA_CREDITS             string
+	CPU_MIN                 string
+	MEMORY_MIN              string
+	CPU_STEP                string
+	MEMORY_STEP             string
+	MIN_COUNT               int64
+	MAX_COUNT               int64
+)
+
+// Flavour reconciliation flags of the local resource manager
+var (
+	NODE_METRICS_REFRESH_INTERVAL time.Duration
+	FLAVOUR_UPDATE_THRESHOLD      float64
+	POOL_NODE_LABEL               string
 )
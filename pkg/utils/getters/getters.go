@@ -16,6 +16,7 @@ package getters
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
@@ -24,6 +25,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
 	"github.com/fluidos-project/node/pkg/utils/consts"
 	"github.com/fluidos-project/node/pkg/utils/flags"
 )
@@ -64,3 +66,25 @@ func GetLocalProviders(ctx context.Context, cl client.Client) []string {
 	}
 	return strings.Split(cm.Data["local"], ",")
 }
+
+// GetContractToken retrieves the Liqo token of the seller cluster of a Contract from the Secret it references.
+// The contracts not migrated yet still hold the token in their spec.
+func GetContractToken(ctx context.Context, cl client.Client, contract *reservationv1alpha1.Contract) (string, error) {
+	ref := contract.Spec.SellerCredentials.TokenSecretRef
+	if ref == nil {
+		if contract.Spec.SellerCredentials.Token == "" {
+			return "", fmt.Errorf("contract %s has no Liqo token", contract.Name)
+		}
+		return contract.Spec.SellerCredentials.Token, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := cl.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: contract.Namespace}, secret); err != nil {
+		return "", err
+	}
+	token, ok := secret.Data[consts.LIQO_TOKEN_SECRET_KEY]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no Liqo token", ref.Name)
+	}
+	return string(token), nil
+}
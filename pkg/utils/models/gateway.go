@@ -17,12 +17,26 @@ package models
 // PurchaseRequest is the request model for purchasing a Flavour
 type PurchaseRequest struct {
 	TransactionID string `json:"transactionID"`
+	// PublicKey is the base64 encoded X25519 key the credentials of the Contract are sealed for
+	PublicKey string     `json:"publicKey"`
+	Nonce     string     `json:"nonce"`
+	Timestamp string     `json:"timestamp"`
+	Signature *Signature `json:"signature,omitempty"`
 }
 
 // ResponsePurchase contain information after purchase a Flavour
 type ResponsePurchase struct {
-	Contract Contract `json:"contract"`
-	Status   string   `json:"status"`
+	Contract    Contract    `json:"contract"`
+	Credentials *SealedData `json:"credentials,omitempty"`
+	Status      string      `json:"status"`
+}
+
+// SealedData is data encrypted for the holder of an X25519 key
+type SealedData struct {
+	// PublicKey is the base64 encoded ephemeral X25519 key of the sender
+	PublicKey  string `json:"publicKey"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
 }
 
 // ReserveRequest is the request model for reserving a Flavour
@@ -31,4 +45,66 @@ type ReserveRequest struct {
 	Buyer     NodeIdentity `json:"buyerID"`
 	ClusterID string       `json:"clusterID"`
 	Partition *Partition   `json:"partition,omitempty"`
+	Offer     *Offer       `json:"offer,omitempty"`
+	Nonce     string       `json:"nonce"`
+	Timestamp string       `json:"timestamp"`
+	Signature *Signature   `json:"signature,omitempty"`
+}
+
+// ResponseContract contains a Contract and its current phase
+type ResponseContract struct {
+	Contract Contract `json:"contract"`
+	Phase    string   `json:"phase"`
+	Message  string   `json:"message,omitempty"`
+}
+
+// RenewContractRequest is the request model for renewing a Contract with a new expiration time
+type RenewContractRequest struct {
+	ContractID     string     `json:"contractID"`
+	ExpirationTime string     `json:"expirationTime"`
+	Nonce          string     `json:"nonce"`
+	Timestamp      string     `json:"timestamp"`
+	Signature      *Signature `json:"signature,omitempty"`
+}
+
+// CountersignContractRequest is the request model for delivering the signature of the buyer on a Contract to the seller
+type CountersignContractRequest struct {
+	ContractID     string     `json:"contractID"`
+	BuyerSignature *Signature `json:"buyerSignature"`
+	Nonce          string     `json:"nonce"`
+	Timestamp      string     `json:"timestamp"`
+	Signature      *Signature `json:"signature,omitempty"`
+}
+
+// TerminateContractRequest is the request model for terminating a Contract before its expiration
+type TerminateContractRequest struct {
+	ContractID string     `json:"contractID"`
+	Reason     string     `json:"reason,omitempty"`
+	Nonce      string     `json:"nonce"`
+	Timestamp  string     `json:"timestamp"`
+	Signature  *Signature `json:"signature,omitempty"`
+}
+
+// RFQRequest is the request for quote broadcast by the buyer to the providers
+type RFQRequest struct {
+	RFQID     string       `json:"rfqID"`
+	Buyer     NodeIdentity `json:"buyerID"`
+	Selector  *Selector    `json:"selector,omitempty"`
+	Duration  string       `json:"duration"`
+	Currency  string       `json:"currency"`
+	Deadline  string       `json:"deadline"`
+	Nonce     string       `json:"nonce"`
+	Timestamp string       `json:"timestamp"`
+	Signature *Signature   `json:"signature,omitempty"`
+}
+
+// Bid is the sealed bid of a provider in response to a request for quote, signed by the provider
+type Bid struct {
+	RFQID      string       `json:"rfqID"`
+	Seller     NodeIdentity `json:"seller"`
+	Flavour    Flavour      `json:"flavour"`
+	Partition  *Partition   `json:"partition,omitempty"`
+	Price      Offer        `json:"price"`
+	ValidUntil string       `json:"validUntil"`
+	Signature  *Signature   `json:"signature,omitempty"`
 }
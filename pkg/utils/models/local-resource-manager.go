@@ -29,9 +29,13 @@ type NodeInfo struct {
 
 // ResourceMetrics represents resources of a certain node
 type ResourceMetrics struct {
-	CPUTotal         resource.Quantity `json:"totalCPU"`
-	CPUAvailable     resource.Quantity `json:"availableCPU"`
-	MemoryTotal      resource.Quantity `json:"totalMemory"`
-	MemoryAvailable  resource.Quantity `json:"availableMemory"`
-	EphemeralStorage resource.Quantity `json:"ephemeralStorage"`
+	CPUTotal          resource.Quantity            `json:"totalCPU"`
+	CPUAvailable      resource.Quantity            `json:"availableCPU"`
+	MemoryTotal       resource.Quantity            `json:"totalMemory"`
+	MemoryAvailable   resource.Quantity            `json:"availableMemory"`
+	EphemeralStorage  resource.Quantity            `json:"ephemeralStorage"`
+	Pods              resource.Quantity            `json:"pods"`
+	GPU               resource.Quantity            `json:"gpu"`
+	GPUDetails        *GPU                         `json:"gpuDetails,omitempty"`
+	ExtendedResources map[string]resource.Quantity `json:"extendedResources,omitempty"`
 }
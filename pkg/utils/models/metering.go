@@ -0,0 +1,41 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+// Statement is the statement of the costs accrued by the Contracts of a FLUIDOS Node over a billing period
+type Statement struct {
+	Domain string          `json:"domain,omitempty"`
+	NodeID string          `json:"nodeID,omitempty"`
+	From   string          `json:"from"`
+	To     string          `json:"to"`
+	Lines  []StatementLine `json:"lines"`
+	Totals []Cost          `json:"totals"`
+}
+
+// StatementLine is the cost accrued by a single Contract within the billing period of a Statement
+type StatementLine struct {
+	ContractID    string       `json:"contractID"`
+	FlavourID     string       `json:"flavourID"`
+	Role          string       `json:"role"`
+	Counterparty  NodeIdentity `json:"counterparty"`
+	ActiveSeconds int64        `json:"activeSeconds"`
+	Cost          Cost         `json:"cost"`
+}
+
+// Cost is an amount of money in a given currency
+type Cost struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
@@ -29,18 +29,31 @@ type Flavour struct {
 	Policy          Policy          `json:"policy"`
 	Owner           NodeIdentity    `json:"owner"`
 	Price           Price           `json:"price"`
+	SLA             *SLA            `json:"sla,omitempty"`
 	ExpirationTime  time.Time       `json:"expirationTime"`
 	OptionalFields  OptionalFields  `json:"optionalFields"`
+	ComputedPrice   *ComputedPrice  `json:"computedPrice,omitempty"`
 }
 
 // Characteristics represents the characteristics of a Flavour, such as CPU and RAM.
 type Characteristics struct {
-	CPU               resource.Quantity `json:"cpu,omitempty"`
-	Memory            resource.Quantity `json:"memory,omitempty"`
-	PersistentStorage resource.Quantity `json:"storage,omitempty"`
-	EphemeralStorage  resource.Quantity `json:"ephemeralStorage,omitempty"`
-	Gpu               resource.Quantity `json:"gpu,omitempty"`
-	Architecture      string            `json:"architecture,omitempty"`
+	CPU               resource.Quantity            `json:"cpu,omitempty"`
+	Memory            resource.Quantity            `json:"memory,omitempty"`
+	PersistentStorage resource.Quantity            `json:"storage,omitempty"`
+	EphemeralStorage  resource.Quantity            `json:"ephemeralStorage,omitempty"`
+	Gpu               resource.Quantity            `json:"gpu,omitempty"`
+	GpuDetails        *GPU                         `json:"gpuDetails,omitempty"`
+	Pods              resource.Quantity            `json:"pods,omitempty"`
+	ExtendedResources map[string]resource.Quantity `json:"extendedResources,omitempty"`
+	Architecture      string                       `json:"architecture,omitempty"`
+}
+
+// GPU represents the vendor, model and memory of the GPUs of a Flavour.
+type GPU struct {
+	Vendor       string            `json:"vendor,omitempty"`
+	Model        string            `json:"model,omitempty"`
+	Memory       resource.Quantity `json:"memory,omitempty"`
+	ResourceName string            `json:"resourceName,omitempty"`
 }
 
 // Policy represents the policy associated with a Flavour, which can be either Partitionable or Aggregatable.
@@ -63,18 +76,64 @@ type Aggregatable struct {
 	MaxCount int `json:"maxCount"`
 }
 
-// NodeIdentity represents the owner of a Flavour, with associated ID, IP, and domain name.
+// NodeIdentity represents the owner of a Flavour, with associated ID, IP, domain name and signing key.
 type NodeIdentity struct {
-	NodeID string `json:"ID"`
-	IP     string `json:"IP"`
-	Domain string `json:"domain"`
+	NodeID    string `json:"ID"`
+	IP        string `json:"IP"`
+	Domain    string `json:"domain"`
+	PublicKey string `json:"publicKey,omitempty"`
 }
 
 // Price represents the price of a Flavour, with the amount, currency, and period associated.
 type Price struct {
-	Amount   string `json:"amount"`
-	Currency string `json:"currency"`
-	Period   string `json:"period"`
+	Amount          string           `json:"amount"`
+	Currency        string           `json:"currency"`
+	Period          string           `json:"period"`
+	UnitRates       *UnitRates       `json:"unitRates,omitempty"`
+	VolumeTiers     []VolumeTier     `json:"volumeTiers,omitempty"`
+	CommitmentTiers []CommitmentTier `json:"commitmentTiers,omitempty"`
+}
+
+// UnitRates represents the prices per unit of the resources of a Flavour.
+type UnitRates struct {
+	Cpu     string `json:"cpu,omitempty"`
+	Memory  string `json:"memory,omitempty"`
+	Gpu     string `json:"gpu,omitempty"`
+	Storage string `json:"storage,omitempty"`
+}
+
+// VolumeTier represents a discount applied from a minimum amount.
+type VolumeTier struct {
+	MinAmount string `json:"minAmount"`
+	Discount  string `json:"discount"`
+}
+
+// CommitmentTier represents a discount applied from a minimum duration.
+type CommitmentTier struct {
+	MinDuration string `json:"minDuration"`
+	Discount    string `json:"discount"`
+}
+
+// ComputedPrice represents the price of a Flavour, or of one of its partitions, computed by the seller.
+type ComputedPrice struct {
+	Amount    string     `json:"amount"`
+	Currency  string     `json:"currency"`
+	Period    string     `json:"period"`
+	Partition *Partition `json:"partition,omitempty"`
+}
+
+// SLA represents the service level terms offered with a Flavour.
+type SLA struct {
+	Availability        string      `json:"availability,omitempty"`
+	MaxTimeToRestore    string      `json:"maxTimeToRestore,omitempty"`
+	AvailabilityCredits []SLACredit `json:"availabilityCredits,omitempty"`
+	RestoreCredit       string      `json:"restoreCredit,omitempty"`
+}
+
+// SLACredit represents a credit granted when the monthly availability falls below a threshold.
+type SLACredit struct {
+	BelowAvailability string `json:"belowAvailability"`
+	Credit            string `json:"credit"`
 }
 
 // OptionalFields represents the optional fields of a Flavour, such as availability.
@@ -97,6 +156,8 @@ type MatchSelector struct {
 	Storage          resource.Quantity `json:"storage,omitempty"`
 	EphemeralStorage resource.Quantity `json:"ephemeralStorage,omitempty"`
 	Gpu              resource.Quantity `json:"gpu,omitempty"`
+	GpuModel         string            `json:"gpuModel,omitempty"`
+	GpuMemory        resource.Quantity `json:"gpuMemory,omitempty"`
 }
 
 // RangeSelector represents the criteria for selecting Flavours through a range.
@@ -111,4 +172,8 @@ type RangeSelector struct {
 	MaxStorage resource.Quantity `json:"maxStorage,omitempty"`
 	MaxEph     resource.Quantity `json:"maxEph,omitempty"`
 	MaxGpu     resource.Quantity `json:"maxGpu,omitempty"`
+
+	GpuModel     string            `json:"gpuModel,omitempty"`
+	MinGpuMemory resource.Quantity `json:"minGpuMemory,omitempty"`
+	MaxGpuMemory resource.Quantity `json:"maxGpuMemory,omitempty"`
 }
@@ -0,0 +1,51 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+// Error codes returned by the REAR Gateway
+const (
+	BAD_REQUEST            = "BAD_REQUEST"
+	UNAUTHORIZED           = "UNAUTHORIZED"
+	FORBIDDEN              = "FORBIDDEN"
+	INVALID_MESSAGE        = "INVALID_MESSAGE"
+	FLAVOUR_NOT_FOUND      = "FLAVOUR_NOT_FOUND"
+	TRANSACTION_NOT_FOUND  = "TRANSACTION_NOT_FOUND"
+	TRANSACTION_EXPIRED    = "TRANSACTION_EXPIRED"
+	PARTITION_INVALID      = "PARTITION_INVALID"
+	CONTRACT_NOT_FOUND     = "CONTRACT_NOT_FOUND"
+	CONTRACT_NOT_ACTIVE    = "CONTRACT_NOT_ACTIVE"
+	PARAMETER_MISMATCH     = "PARAMETER_MISMATCH"
+	QUOTA_EXCEEDED         = "QUOTA_EXCEEDED"
+	CAPACITY_UNAVAILABLE   = "CAPACITY_UNAVAILABLE"
+	OFFER_INVALID          = "OFFER_INVALID"
+	NEGOTIATION_FAILED     = "NEGOTIATION_FAILED"
+	RFQ_CLOSED             = "RFQ_CLOSED"
+	IDEMPOTENCY_KEY_IN_USE = "IDEMPOTENCY_KEY_IN_USE"
+	IDEMPOTENCY_KEY_REUSED = "IDEMPOTENCY_KEY_REUSED"
+	RATE_LIMITED           = "RATE_LIMITED"
+	NOT_READY              = "NOT_READY"
+	INTERNAL_ERROR         = "INTERNAL_ERROR"
+	UNKNOWN_ERROR          = "UNKNOWN_ERROR"
+)
+
+// Problem is an error response of the REAR Gateway, following RFC 7807
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
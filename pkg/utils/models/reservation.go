@@ -34,6 +34,25 @@ type Transaction struct {
 	Buyer         NodeIdentity `json:"buyer"`
 	ClusterID     string       `json:"clusterID"`
 	StartTime     string       `json:"startTime"`
+	ExpiresAt     string       `json:"expiresAt,omitempty"`
+	Offer         *Offer       `json:"offer,omitempty"`
+	Negotiation   *Negotiation `json:"negotiation,omitempty"`
+}
+
+// Offer represents a price, and the terms it refers to, proposed during a negotiation
+type Offer struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+	Period   string `json:"period,omitempty"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// Negotiation represents the state of the price negotiation of a Transaction
+type Negotiation struct {
+	State      string `json:"state"`
+	Round      int    `json:"round"`
+	Deadline   string `json:"deadline"`
+	RoundsLeft int    `json:"roundsLeft"`
 }
 
 // Contract represents a Contract object with its characteristics
@@ -46,14 +65,26 @@ type Contract struct {
 	Seller            NodeIdentity      `json:"seller"`
 	SellerCredentials LiqoCredentials   `json:"sellerCredentials"`
 	ExpirationTime    string            `json:"expirationTime,omitempty"`
+	AgreedPrice       *Offer            `json:"agreedPrice,omitempty"`
+	SLA               *SLA              `json:"sla,omitempty"`
 	ExtraInformation  map[string]string `json:"extraInformation,omitempty"`
 	Partition         *Partition        `json:"partition,omitempty"`
+	SellerSignature   *Signature        `json:"sellerSignature,omitempty"`
+	BuyerSignature    *Signature        `json:"buyerSignature,omitempty"`
 }
 
 // LiqoCredentials contains the credentials of a Liqo cluster to enstablish a peering.
+// The token is never part of a Contract: it is delivered sealed with the response to the purchase.
 type LiqoCredentials struct {
 	ClusterID   string `json:"clusterID"`
 	ClusterName string `json:"clusterName"`
-	Token       string `json:"token"`
 	Endpoint    string `json:"endpoint"`
+	TokenID     string `json:"tokenID,omitempty"`
+}
+
+// Signature contains the signature of a REAR message or of a Contract and the key used to produce it.
+type Signature struct {
+	KeyID     string `json:"keyID"`
+	PublicKey string `json:"publicKey"`
+	Value     string `json:"value"`
 }
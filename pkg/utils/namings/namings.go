@@ -22,8 +22,6 @@ import (
 	"strings"
 	"time"
 
-	"k8s.io/klog/v2"
-
 	"github.com/fluidos-project/node/pkg/utils/flags"
 )
 
@@ -43,14 +41,15 @@ func ForgeReservationName(solverID string) string {
 	return fmt.Sprintf("reservation-%s", solverID)
 }
 
-// ForgeFlavourName returns the name of the flavour following the pattern Domain-Type-rand(4)
-func ForgeFlavourName(WorkerID, domain string) string {
-	rand, err := ForgeRandomString()
-	if err != nil {
-		klog.Errorf("Error when generating random string: %s", err)
-	}
+// ForgeFlavourName returns the name of the flavour of a worker node following the pattern Domain-Type-hash(8).
+// The name is stable, so that a node keeps the same Flavour across restarts of the local resource manager.
+func ForgeFlavourName(workerName, domain string) string {
+	return domain + "-" + flags.RESOURCE_TYPE + "-" + ForgeHashString(workerName, 8)
+}
 
-	return domain + "-" + flags.RESOURCE_TYPE + "-" + ForgeHashString(WorkerID+rand, 8)
+// ForgePoolFlavourName returns the name of the flavour of a pool of worker nodes following the pattern Domain-Type-pool-hash(8)
+func ForgePoolFlavourName(pool, domain string) string {
+	return domain + "-" + flags.RESOURCE_TYPE + "-pool-" + ForgeHashString(pool, 8)
 }
 
 // ForgeDiscoveryName returns the name of the discovery following the pattern solverID-discovery
@@ -66,6 +65,16 @@ func RetrieveSolverNameFromReservation(reservationName string) string {
 	return strings.TrimPrefix(reservationName, "reservation-")
 }
 
+// ForgeSellerTransactionName returns the name of the Transaction CR of a transaction sold. It is prefixed,
+// so that it does not collide with the Transaction CR of the buyer when a FLUIDOS Node buys from itself.
+func ForgeSellerTransactionName(transactionID string) string {
+	return "seller-" + transactionID
+}
+
+func RetrieveTransactionIDFromSellerTransaction(transactionName string) string {
+	return strings.TrimPrefix(transactionName, "seller-")
+}
+
 // ForgeTransactionID Generates a unique transaction ID using the current timestamp
 func ForgeTransactionID() (string, error) {
 	// Convert the random bytes to a hexadecimal string
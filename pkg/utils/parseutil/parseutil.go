@@ -24,9 +24,10 @@ import (
 
 // ParseFlavourSelector parses FlavourSelector into a Selector
 func ParseFlavourSelector(selector *nodecorev1alpha1.FlavourSelector) (s *models.Selector) {
-
-	s.Architecture = selector.Architecture
-	s.FlavourType = string(selector.FlavourType)
+	s = &models.Selector{
+		Architecture: selector.Architecture,
+		FlavourType:  string(selector.FlavourType),
+	}
 
 	if selector.MatchSelector != nil {
 		s.MatchSelector = &models.MatchSelector{
@@ -35,6 +36,8 @@ func ParseFlavourSelector(selector *nodecorev1alpha1.FlavourSelector) (s *models
 			EphemeralStorage: selector.MatchSelector.EphemeralStorage,
 			Storage:          selector.MatchSelector.Storage,
 			Gpu:              selector.MatchSelector.Gpu,
+			GpuModel:         selector.MatchSelector.GpuModel,
+			GpuMemory:        selector.MatchSelector.GpuMemory,
 		}
 	}
 
@@ -50,6 +53,10 @@ func ParseFlavourSelector(selector *nodecorev1alpha1.FlavourSelector) (s *models
 			MaxEph:     selector.RangeSelector.MaxEph,
 			MaxStorage: selector.RangeSelector.MaxStorage,
 			MaxGpu:     selector.RangeSelector.MaxGpu,
+
+			GpuModel:     selector.RangeSelector.GpuModel,
+			MinGpuMemory: selector.RangeSelector.MinGpuMemory,
+			MaxGpuMemory: selector.RangeSelector.MaxGpuMemory,
 		}
 	}
 
@@ -77,11 +84,150 @@ func ParsePartitionFromObj(partition *models.Partition) *reservationv1alpha1.Par
 	}
 }
 
+// ParsePrice creates a Price model from a Price object
+func ParsePrice(price nodecorev1alpha1.Price) models.Price {
+	p := models.Price{
+		Amount:   price.Amount,
+		Currency: price.Currency,
+		Period:   price.Period,
+	}
+	if price.UnitRates != nil {
+		p.UnitRates = &models.UnitRates{
+			Cpu:     price.UnitRates.Cpu,
+			Memory:  price.UnitRates.Memory,
+			Gpu:     price.UnitRates.Gpu,
+			Storage: price.UnitRates.Storage,
+		}
+	}
+	for _, tier := range price.VolumeTiers {
+		p.VolumeTiers = append(p.VolumeTiers, models.VolumeTier{MinAmount: tier.MinAmount, Discount: tier.Discount})
+	}
+	for _, tier := range price.CommitmentTiers {
+		p.CommitmentTiers = append(p.CommitmentTiers, models.CommitmentTier{MinDuration: tier.MinDuration, Discount: tier.Discount})
+	}
+	return p
+}
+
+// ParsePriceFromObj creates a Price object from a Price model
+func ParsePriceFromObj(price models.Price) nodecorev1alpha1.Price {
+	p := nodecorev1alpha1.Price{
+		Amount:   price.Amount,
+		Currency: price.Currency,
+		Period:   price.Period,
+	}
+	if price.UnitRates != nil {
+		p.UnitRates = &nodecorev1alpha1.UnitRates{
+			Cpu:     price.UnitRates.Cpu,
+			Memory:  price.UnitRates.Memory,
+			Gpu:     price.UnitRates.Gpu,
+			Storage: price.UnitRates.Storage,
+		}
+	}
+	for _, tier := range price.VolumeTiers {
+		p.VolumeTiers = append(p.VolumeTiers, nodecorev1alpha1.VolumeTier{MinAmount: tier.MinAmount, Discount: tier.Discount})
+	}
+	for _, tier := range price.CommitmentTiers {
+		p.CommitmentTiers = append(p.CommitmentTiers, nodecorev1alpha1.CommitmentTier{MinDuration: tier.MinDuration, Discount: tier.Discount})
+	}
+	return p
+}
+
+// ParseSLA creates an SLA model from an SLA object
+func ParseSLA(sla *nodecorev1alpha1.SLA) *models.SLA {
+	if sla == nil {
+		return nil
+	}
+	parsed := &models.SLA{
+		Availability:     sla.Availability,
+		MaxTimeToRestore: sla.MaxTimeToRestore,
+		RestoreCredit:    sla.RestoreCredit,
+	}
+	for _, credit := range sla.AvailabilityCredits {
+		parsed.AvailabilityCredits = append(parsed.AvailabilityCredits, models.SLACredit{
+			BelowAvailability: credit.BelowAvailability,
+			Credit:            credit.Credit,
+		})
+	}
+	return parsed
+}
+
+// ParseSLAFromObj creates an SLA object from an SLA model
+func ParseSLAFromObj(sla *models.SLA) *nodecorev1alpha1.SLA {
+	if sla == nil {
+		return nil
+	}
+	parsed := &nodecorev1alpha1.SLA{
+		Availability:     sla.Availability,
+		MaxTimeToRestore: sla.MaxTimeToRestore,
+		RestoreCredit:    sla.RestoreCredit,
+	}
+	for _, credit := range sla.AvailabilityCredits {
+		parsed.AvailabilityCredits = append(parsed.AvailabilityCredits, nodecorev1alpha1.SLACredit{
+			BelowAvailability: credit.BelowAvailability,
+			Credit:            credit.Credit,
+		})
+	}
+	return parsed
+}
+
+// ParseOffer creates an Offer model from an Offer object
+func ParseOffer(offer *reservationv1alpha1.Offer) *models.Offer {
+	if offer == nil {
+		return nil
+	}
+	return &models.Offer{
+		Amount:   offer.Amount,
+		Currency: offer.Currency,
+		Period:   offer.Period,
+		Duration: offer.Duration,
+	}
+}
+
+// ParseOfferFromObj creates an Offer object from an Offer model
+func ParseOfferFromObj(offer *models.Offer) *reservationv1alpha1.Offer {
+	if offer == nil {
+		return nil
+	}
+	return &reservationv1alpha1.Offer{
+		Amount:   offer.Amount,
+		Currency: offer.Currency,
+		Period:   offer.Period,
+		Duration: offer.Duration,
+	}
+}
+
+// ParseNegotiation creates a Negotiation model from a Negotiation object
+func ParseNegotiation(negotiation *reservationv1alpha1.Negotiation) *models.Negotiation {
+	if negotiation == nil {
+		return nil
+	}
+	return &models.Negotiation{
+		State:      string(negotiation.State),
+		Round:      negotiation.Round,
+		Deadline:   negotiation.Deadline,
+		RoundsLeft: negotiation.RoundsLeft,
+	}
+}
+
+// ParseNegotiationFromObj creates a Negotiation object from a Negotiation model
+func ParseNegotiationFromObj(negotiation *models.Negotiation) *reservationv1alpha1.Negotiation {
+	if negotiation == nil {
+		return nil
+	}
+	return &reservationv1alpha1.Negotiation{
+		State:      reservationv1alpha1.NegotiationState(negotiation.State),
+		Round:      negotiation.Round,
+		Deadline:   negotiation.Deadline,
+		RoundsLeft: negotiation.RoundsLeft,
+	}
+}
+
 func ParseNodeIdentity(node nodecorev1alpha1.NodeIdentity) models.NodeIdentity {
 	return models.NodeIdentity{
-		NodeID: node.NodeID,
-		IP:     node.IP,
-		Domain: node.Domain,
+		NodeID:    node.NodeID,
+		IP:        node.IP,
+		Domain:    node.Domain,
+		PublicKey: node.PublicKey,
 	}
 }
 
@@ -97,6 +243,9 @@ func ParseFlavour(flavour nodecorev1alpha1.Flavour) models.Flavour {
 			PersistentStorage: flavour.Spec.Characteristics.PersistentStorage,
 			EphemeralStorage:  flavour.Spec.Characteristics.EphemeralStorage,
 			Gpu:               flavour.Spec.Characteristics.Gpu,
+			GpuDetails:        ParseGPU(flavour.Spec.Characteristics.GpuDetails),
+			Pods:              flavour.Spec.Characteristics.Pods,
+			ExtendedResources: flavour.Spec.Characteristics.ExtendedResources,
 		},
 		Owner: ParseNodeIdentity(flavour.Spec.Owner),
 		Policy: models.Policy{
@@ -121,11 +270,8 @@ func ParseFlavour(flavour nodecorev1alpha1.Flavour) models.Flavour {
 				return nil
 			}(),
 		},
-		Price: models.Price{
-			Amount:   flavour.Spec.Price.Amount,
-			Currency: flavour.Spec.Price.Currency,
-			Period:   flavour.Spec.Price.Period,
-		},
+		Price: ParsePrice(flavour.Spec.Price),
+		SLA:   ParseSLA(flavour.Spec.SLA),
 		OptionalFields: models.OptionalFields{
 			Availability: flavour.Spec.OptionalFields.Availability,
 			WorkerID:     flavour.Spec.OptionalFields.WorkerID,
@@ -151,9 +297,59 @@ func ParseContract(contract *reservationv1alpha1.Contract) models.Contract {
 		SellerCredentials: models.LiqoCredentials{
 			ClusterID:   contract.Spec.SellerCredentials.ClusterID,
 			ClusterName: contract.Spec.SellerCredentials.ClusterName,
-			Token:       contract.Spec.SellerCredentials.Token,
 			Endpoint:    contract.Spec.SellerCredentials.Endpoint,
+			TokenID:     contract.Spec.SellerCredentials.TokenID,
 		},
+		ExpirationTime:   contract.Spec.ExpirationTime,
+		AgreedPrice:      ParseOffer(contract.Spec.AgreedPrice),
+		SLA:              ParseSLA(contract.Spec.SLA),
+		ExtraInformation: contract.Spec.ExtraInformation,
+		SellerSignature:  ParseSignature(contract.Spec.SellerSignature),
+		BuyerSignature:   ParseSignature(contract.Spec.BuyerSignature),
+	}
+}
+
+// ParseTransaction creates a Transaction Object from a Transaction CR
+func ParseTransaction(transaction *reservationv1alpha1.Transaction) models.Transaction {
+	return models.Transaction{
+		TransactionID: transaction.Name,
+		FlavourID:     transaction.Spec.FlavourID,
+		Partition: func() *models.Partition {
+			if transaction.Spec.Partition != nil {
+				return ParsePartition(transaction.Spec.Partition)
+			}
+			return nil
+		}(),
+		Buyer:       ParseNodeIdentity(transaction.Spec.Buyer),
+		ClusterID:   transaction.Spec.ClusterID,
+		StartTime:   transaction.Spec.StartTime,
+		ExpiresAt:   transaction.Spec.ExpirationTime,
+		Offer:       ParseOffer(transaction.Spec.Offer),
+		Negotiation: ParseNegotiation(transaction.Spec.Negotiation),
+	}
+}
+
+// ParseSignature creates a Signature Object from the Signature of a Contract CR
+func ParseSignature(signature *reservationv1alpha1.Signature) *models.Signature {
+	if signature == nil {
+		return nil
+	}
+	return &models.Signature{
+		KeyID:     signature.KeyID,
+		PublicKey: signature.PublicKey,
+		Value:     signature.Value,
+	}
+}
+
+// ParseSignatureFromObj creates the Signature of a Contract CR from a Signature Object
+func ParseSignatureFromObj(signature *models.Signature) *reservationv1alpha1.Signature {
+	if signature == nil {
+		return nil
+	}
+	return &reservationv1alpha1.Signature{
+		KeyID:     signature.KeyID,
+		PublicKey: signature.PublicKey,
+		Value:     signature.Value,
 	}
 }
 
@@ -164,3 +360,16 @@ func ParseQuantityFromString(s string) resource.Quantity {
 	}
 	return i
 }
+
+// ParseGPU creates a GPU Object from the GPU details of a Flavour CR
+func ParseGPU(gpu *nodecorev1alpha1.GPU) *models.GPU {
+	if gpu == nil {
+		return nil
+	}
+	return &models.GPU{
+		Vendor:       gpu.Vendor,
+		Model:        gpu.Model,
+		Memory:       gpu.Memory,
+		ResourceName: gpu.ResourceName,
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pricing
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+)
+
+// AMOUNT_DECIMALS is the number of decimals of the computed amounts
+const AMOUNT_DECIMALS = 6
+
+const (
+	day   = 24 * time.Hour
+	week  = 7 * day
+	month = 30 * day
+	year  = 365 * day
+	gib   = 1 << 30
+)
+
+var isoPeriod = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// periodNames maps the names of the usual periods to their ISO-8601 duration
+var periodNames = map[string]string{
+	"hour": "PT1H", "hourly": "PT1H",
+	"day": "P1D", "daily": "P1D",
+	"week": "P1W", "weekly": "P1W",
+	"month": "P1M", "monthly": "P1M",
+	"year": "P1Y", "yearly": "P1Y", "annually": "P1Y",
+}
+
+// NormalizePeriod returns the ISO-8601 duration of a period given by name (e.g. hourly), or the period itself
+func NormalizePeriod(period string) string {
+	if iso, ok := periodNames[strings.ToLower(strings.TrimSpace(period))]; ok {
+		return iso
+	}
+	return strings.TrimSpace(period)
+}
+
+// ParsePeriod returns the duration of an ISO-8601 period (e.g. P1M, PT1H). The names of the usual periods
+// (e.g. hourly) and Go durations (e.g. 1h) are accepted too. Months are 30 days long and years 365 days long.
+// Periods shorter than a second are rejected, as the costs are prorated by the second.
+func ParsePeriod(period string) (time.Duration, error) {
+	d, err := parsePeriod(period)
+	if err != nil {
+		return 0, err
+	}
+	if d < time.Second {
+		return 0, fmt.Errorf("invalid period %q: shorter than a second", period)
+	}
+	return d, nil
+}
+
+func parsePeriod(period string) (time.Duration, error) {
+	period = NormalizePeriod(period)
+
+	if match := isoPeriod.FindStringSubmatch(strings.ToUpper(period)); match != nil {
+		units := []time.Duration{year, month, week, day, time.Hour, time.Minute, time.Second}
+		var d time.Duration
+		for i, unit := range units {
+			if match[i+1] == "" {
+				continue
+			}
+			n, err := strconv.ParseInt(match[i+1], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid period %q: %w", period, err)
+			}
+			d += time.Duration(n) * unit
+		}
+		if d > 0 {
+			return d, nil
+		}
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid period %q", period)
+	}
+	return d, nil
+}
+
+// ParseAmount parses a decimal amount. An empty amount is zero.
+func ParseAmount(amount string) (*big.Rat, error) {
+	if strings.TrimSpace(amount) == "" {
+		return new(big.Rat), nil
+	}
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok || r.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
+	return r, nil
+}
+
+// FormatAmount formats an amount with AMOUNT_DECIMALS decimals
+func FormatAmount(amount *big.Rat) string {
+	return amount.FloatString(AMOUNT_DECIMALS)
+}
+
+// Share returns the share of the Flavour bought with a partition, as the largest share of the CPU, memory and GPUs
+// of the Flavour it takes. A nil partition buys the whole Flavour.
+func Share(characteristics *nodecorev1alpha1.Characteristics, partition *reservationv1alpha1.Partition) *big.Rat {
+	share := big.NewRat(1, 1)
+	if partition == nil {
+		return share
+	}
+
+	share.SetInt64(0)
+	for _, r := range []struct{ part, total resource.Quantity }{
+		{partition.Cpu, characteristics.Cpu},
+		{partition.Memory, characteristics.Memory},
+		{partition.Gpu, characteristics.Gpu},
+	} {
+		if r.total.IsZero() {
+			continue
+		}
+		ratio := big.NewRat(r.part.MilliValue(), r.total.MilliValue())
+		if ratio.Cmp(share) > 0 {
+			share = ratio
+		}
+	}
+
+	if share.Cmp(big.NewRat(1, 1)) > 0 {
+		share.SetInt64(1)
+	}
+	return share
+}
+
+// PartitionPrice returns the price, per period of the Flavour price, of a partition of the Flavour
+// (the whole Flavour if the partition is nil) bought for the given commitment (zero if unknown).
+func PartitionPrice(flavour *nodecorev1alpha1.FlavourSpec, partition *reservationv1alpha1.Partition, commitment time.Duration) (*big.Rat, error) {
+	price := &flavour.Price
+
+	amount, err := ParseAmount(price.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if price.UnitRates == nil {
+		amount.Mul(amount, Share(&flavour.Characteristics, partition))
+	} else {
+		cpu, memory, gpu, storage := flavour.Characteristics.Cpu, flavour.Characteristics.Memory,
+			flavour.Characteristics.Gpu, flavour.Characteristics.PersistentStorage
+		if partition != nil {
+			cpu, memory, gpu, storage = partition.Cpu, partition.Memory, partition.Gpu, partition.Storage
+		}
+		for _, r := range []struct {
+			rate     string
+			quantity *big.Rat
+		}{
+			{price.UnitRates.Cpu, big.NewRat(cpu.MilliValue(), 1000)},
+			{price.UnitRates.Memory, big.NewRat(memory.Value(), gib)},
+			{price.UnitRates.Gpu, big.NewRat(gpu.MilliValue(), 1000)},
+			{price.UnitRates.Storage, big.NewRat(storage.Value(), gib)},
+		} {
+			rate, err := ParseAmount(r.rate)
+			if err != nil {
+				return nil, err
+			}
+			amount.Add(amount, rate.Mul(rate, r.quantity))
+		}
+	}
+
+	// The volume tier with the highest minimum amount reached applies
+	var volumeDiscount, volumeMin *big.Rat
+	for _, tier := range price.VolumeTiers {
+		min, err := ParseAmount(tier.MinAmount)
+		if err != nil {
+			return nil, err
+		}
+		if amount.Cmp(min) < 0 || (volumeMin != nil && min.Cmp(volumeMin) <= 0) {
+			continue
+		}
+		if volumeDiscount, err = ParseAmount(tier.Discount); err != nil {
+			return nil, err
+		}
+		volumeMin = min
+	}
+	applyDiscount(amount, volumeDiscount)
+
+	// The commitment tier with the longest minimum duration reached applies
+	var commitmentDiscount *big.Rat
+	var commitmentMin time.Duration
+	for _, tier := range price.CommitmentTiers {
+		min, err := ParsePeriod(tier.MinDuration)
+		if err != nil {
+			return nil, err
+		}
+		if commitment < min || (commitmentDiscount != nil && min <= commitmentMin) {
+			continue
+		}
+		if commitmentDiscount, err = ParseAmount(tier.Discount); err != nil {
+			return nil, err
+		}
+		commitmentMin = min
+	}
+	applyDiscount(amount, commitmentDiscount)
+
+	return amount, nil
+}
+
+// applyDiscount applies a percentage discount to the amount
+func applyDiscount(amount, discount *big.Rat) {
+	if discount == nil || discount.Sign() == 0 {
+		return
+	}
+	factor := new(big.Rat).Sub(big.NewRat(100, 1), discount)
+	if factor.Sign() < 0 {
+		factor.SetInt64(0)
+	}
+	amount.Mul(amount, factor.Quo(factor, big.NewRat(100, 1)))
+}
+
+// Prorate returns the part of an amount due for the given time, the amount referring to the given period
+func Prorate(amount *big.Rat, period string, active time.Duration) (*big.Rat, error) {
+	d, err := ParsePeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Mul(amount, big.NewRat(int64(active/time.Second), int64(d/time.Second))), nil
+}
+
+// Rescale converts an amount referring to a period into the equivalent amount referring to another period
+func Rescale(amount *big.Rat, from, to string) (*big.Rat, error) {
+	fromDuration, err := ParsePeriod(from)
+	if err != nil {
+		return nil, err
+	}
+	toDuration, err := ParsePeriod(to)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Mul(amount, big.NewRat(int64(toDuration), int64(fromDuration))), nil
+}
@@ -0,0 +1,316 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pricing
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
+	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+)
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		period  string
+		want    time.Duration
+		wantErr bool
+	}{
+		{period: "PT1H", want: time.Hour},
+		{period: "P1D", want: 24 * time.Hour},
+		{period: "P1W", want: 7 * 24 * time.Hour},
+		{period: "P1M", want: 30 * 24 * time.Hour},
+		{period: "P1Y", want: 365 * 24 * time.Hour},
+		{period: "P1DT12H30M", want: 36*time.Hour + 30*time.Minute},
+		{period: "pt15m", want: 15 * time.Minute},
+		{period: "PT1S", want: time.Second},
+		{period: "hourly", want: time.Hour},
+		{period: " Monthly ", want: 30 * 24 * time.Hour},
+		{period: "annually", want: 365 * 24 * time.Hour},
+		{period: "90m", want: 90 * time.Minute},
+		{period: "1s", want: time.Second},
+		{period: "500ms", wantErr: true},
+		{period: "1ns", wantErr: true},
+		{period: "PT0S", wantErr: true},
+		{period: "P", wantErr: true},
+		{period: "-1h", wantErr: true},
+		{period: "", wantErr: true},
+		{period: "fortnightly", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.period, func(t *testing.T) {
+			got, err := ParsePeriod(tt.period)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParsePeriod(%q) = %s, want an error", tt.period, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePeriod(%q) error = %s", tt.period, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePeriod(%q) = %s, want %s", tt.period, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  string
+		period  string
+		active  time.Duration
+		want    string
+		wantErr bool
+	}{
+		{name: "whole period", amount: "10", period: "PT1H", active: time.Hour, want: "10.000000"},
+		{name: "half period", amount: "10", period: "PT1H", active: 30 * time.Minute, want: "5.000000"},
+		{name: "several periods", amount: "2.5", period: "P1D", active: 72 * time.Hour, want: "7.500000"},
+		{name: "by the second", amount: "3600", period: "PT1H", active: 1500 * time.Millisecond, want: "1.000000"},
+		{name: "nothing active", amount: "10", period: "P1M", active: 0, want: "0.000000"},
+		{name: "month", amount: "30", period: "monthly", active: 24 * time.Hour, want: "1.000000"},
+		{name: "invalid period", amount: "10", period: "PT0.5S", wantErr: true},
+		{name: "sub-second period", amount: "10", period: "100ms", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, err := ParseAmount(tt.amount)
+			if err != nil {
+				t.Fatalf("ParseAmount(%q) error = %s", tt.amount, err)
+			}
+			got, err := Prorate(amount, tt.period, tt.active)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Prorate() = %s, want an error", FormatAmount(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Prorate() error = %s", err)
+			}
+			if FormatAmount(got) != tt.want {
+				t.Errorf("Prorate() = %s, want %s", FormatAmount(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		amount  string
+		want    string
+		wantErr bool
+	}{
+		{amount: "", want: "0.000000"},
+		{amount: " 12.5 ", want: "12.500000"},
+		{amount: "0.0000015", want: "0.000002"},
+		{amount: "-1", wantErr: true},
+		{amount: "ten", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.amount, func(t *testing.T) {
+			got, err := ParseAmount(tt.amount)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseAmount(%q) = %s, want an error", tt.amount, FormatAmount(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAmount(%q) error = %s", tt.amount, err)
+			}
+			if FormatAmount(got) != tt.want {
+				t.Errorf("ParseAmount(%q) = %s, want %s", tt.amount, FormatAmount(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestShare(t *testing.T) {
+	characteristics := &nodecorev1alpha1.Characteristics{
+		Cpu:    resource.MustParse("4"),
+		Memory: resource.MustParse("16Gi"),
+	}
+
+	tests := []struct {
+		name      string
+		partition *reservationv1alpha1.Partition
+		want      *big.Rat
+	}{
+		{name: "whole flavour", want: big.NewRat(1, 1)},
+		{name: "cpu share", partition: &reservationv1alpha1.Partition{
+			Cpu: resource.MustParse("1"), Memory: resource.MustParse("2Gi")}, want: big.NewRat(1, 4)},
+		{name: "memory share", partition: &reservationv1alpha1.Partition{
+			Cpu: resource.MustParse("500m"), Memory: resource.MustParse("8Gi")}, want: big.NewRat(1, 2)},
+		{name: "gpu of a flavour without gpus", partition: &reservationv1alpha1.Partition{
+			Cpu: resource.MustParse("1"), Gpu: resource.MustParse("1")}, want: big.NewRat(1, 4)},
+		{name: "larger than the flavour", partition: &reservationv1alpha1.Partition{
+			Cpu: resource.MustParse("8")}, want: big.NewRat(1, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Share(characteristics, tt.partition); got.Cmp(tt.want) != 0 {
+				t.Errorf("Share() = %s, want %s", got.RatString(), tt.want.RatString())
+			}
+		})
+	}
+}
+
+func TestPartitionPrice(t *testing.T) {
+	characteristics := nodecorev1alpha1.Characteristics{
+		Cpu:    resource.MustParse("4"),
+		Memory: resource.MustParse("16Gi"),
+		Gpu:    resource.MustParse("2"),
+	}
+	partition := &reservationv1alpha1.Partition{
+		Cpu:    resource.MustParse("2"),
+		Memory: resource.MustParse("4Gi"),
+		Gpu:    resource.MustParse("1"),
+	}
+
+	tests := []struct {
+		name       string
+		price      nodecorev1alpha1.Price
+		partition  *reservationv1alpha1.Partition
+		commitment time.Duration
+		want       string
+		wantErr    bool
+	}{
+		{name: "whole flavour", price: nodecorev1alpha1.Price{Amount: "10"}, want: "10.000000"},
+		{name: "partition share", price: nodecorev1alpha1.Price{Amount: "10"}, partition: partition, want: "5.000000"},
+		{
+			name: "unit rates",
+			price: nodecorev1alpha1.Price{Amount: "1", UnitRates: &nodecorev1alpha1.UnitRates{
+				Cpu: "0.5", Memory: "0.25", Gpu: "3"}},
+			partition: partition,
+			want:      "6.000000",
+		},
+		{
+			name: "unit rates of the whole flavour",
+			price: nodecorev1alpha1.Price{UnitRates: &nodecorev1alpha1.UnitRates{
+				Cpu: "0.5", Memory: "0.25", Gpu: "3"}},
+			want: "12.000000",
+		},
+		{
+			name: "highest volume tier reached",
+			price: nodecorev1alpha1.Price{Amount: "100", VolumeTiers: []nodecorev1alpha1.VolumeTier{
+				{MinAmount: "50", Discount: "10"}, {MinAmount: "100", Discount: "20"}, {MinAmount: "200", Discount: "50"}}},
+			want: "80.000000",
+		},
+		{
+			name: "volume tier not reached",
+			price: nodecorev1alpha1.Price{Amount: "10", VolumeTiers: []nodecorev1alpha1.VolumeTier{
+				{MinAmount: "50", Discount: "10"}}},
+			partition: partition,
+			want:      "5.000000",
+		},
+		{
+			name: "longest commitment tier reached",
+			price: nodecorev1alpha1.Price{Amount: "100", CommitmentTiers: []nodecorev1alpha1.CommitmentTier{
+				{MinDuration: "P1M", Discount: "10"}, {MinDuration: "P1W", Discount: "5"}, {MinDuration: "P1Y", Discount: "30"}}},
+			commitment: 60 * 24 * time.Hour,
+			want:       "90.000000",
+		},
+		{
+			name: "volume and commitment tiers",
+			price: nodecorev1alpha1.Price{Amount: "100",
+				VolumeTiers:     []nodecorev1alpha1.VolumeTier{{MinAmount: "100", Discount: "50"}},
+				CommitmentTiers: []nodecorev1alpha1.CommitmentTier{{MinDuration: "P1D", Discount: "10"}}},
+			commitment: 24 * time.Hour,
+			want:       "45.000000",
+		},
+		{
+			name: "discount over 100 percent",
+			price: nodecorev1alpha1.Price{Amount: "100", VolumeTiers: []nodecorev1alpha1.VolumeTier{
+				{MinAmount: "1", Discount: "150"}}},
+			want: "0.000000",
+		},
+		{name: "invalid amount", price: nodecorev1alpha1.Price{Amount: "free"}, wantErr: true},
+		{
+			name: "invalid commitment tier",
+			price: nodecorev1alpha1.Price{Amount: "1", CommitmentTiers: []nodecorev1alpha1.CommitmentTier{
+				{MinDuration: "soon", Discount: "10"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flavour := &nodecorev1alpha1.FlavourSpec{Characteristics: characteristics, Price: tt.price}
+			got, err := PartitionPrice(flavour, tt.partition, tt.commitment)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("PartitionPrice() = %s, want an error", FormatAmount(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PartitionPrice() error = %s", err)
+			}
+			if FormatAmount(got) != tt.want {
+				t.Errorf("PartitionPrice() = %s, want %s", FormatAmount(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestRescale(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  string
+		from    string
+		to      string
+		want    string
+		wantErr bool
+	}{
+		{name: "same period", amount: "10", from: "PT1H", to: "hourly", want: "10.000000"},
+		{name: "longer period", amount: "1", from: "PT1H", to: "P1D", want: "24.000000"},
+		{name: "shorter period", amount: "30", from: "P1M", to: "P1D", want: "1.000000"},
+		{name: "go durations", amount: "6", from: "1h", to: "10m", want: "1.000000"},
+		{name: "invalid source period", amount: "10", from: "soon", to: "PT1H", wantErr: true},
+		{name: "invalid target period", amount: "10", from: "PT1H", to: "10ms", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, err := ParseAmount(tt.amount)
+			if err != nil {
+				t.Fatalf("ParseAmount(%q) error = %s", tt.amount, err)
+			}
+			got, err := Rescale(amount, tt.from, tt.to)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Rescale() = %s, want an error", FormatAmount(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Rescale() error = %s", err)
+			}
+			if FormatAmount(got) != tt.want {
+				t.Errorf("Rescale() = %s, want %s", FormatAmount(got), tt.want)
+			}
+		})
+	}
+}
@@ -15,17 +15,22 @@
 package resourceforge
 
 import (
+	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	advertisementv1alpha1 "github.com/fluidos-project/node/apis/advertisement/v1alpha1"
 	nodecorev1alpha1 "github.com/fluidos-project/node/apis/nodecore/v1alpha1"
 	reservationv1alpha1 "github.com/fluidos-project/node/apis/reservation/v1alpha1"
+	"github.com/fluidos-project/node/pkg/utils/consts"
 	"github.com/fluidos-project/node/pkg/utils/flags"
 	"github.com/fluidos-project/node/pkg/utils/models"
 	"github.com/fluidos-project/node/pkg/utils/namings"
 	"github.com/fluidos-project/node/pkg/utils/parseutil"
+	"github.com/fluidos-project/node/pkg/utils/pricing"
 	"github.com/fluidos-project/node/pkg/utils/tools"
 )
 
@@ -87,9 +92,10 @@ func ForgeReservation(peeringCandidate advertisementv1alpha1.PeeringCandidate, p
 			SolverID: solverID,
 			Buyer:    ni,
 			Seller: nodecorev1alpha1.NodeIdentity{
-				Domain: peeringCandidate.Spec.Flavour.Spec.Owner.Domain,
-				NodeID: peeringCandidate.Spec.Flavour.Spec.Owner.NodeID,
-				IP:     peeringCandidate.Spec.Flavour.Spec.Owner.IP,
+				Domain:    peeringCandidate.Spec.Flavour.Spec.Owner.Domain,
+				NodeID:    peeringCandidate.Spec.Flavour.Spec.Owner.NodeID,
+				IP:        peeringCandidate.Spec.Flavour.Spec.Owner.IP,
+				PublicKey: peeringCandidate.Spec.Flavour.Spec.Owner.PublicKey,
 			},
 			PeeringCandidate: nodecorev1alpha1.GenericRef{
 				Name:      peeringCandidate.Name,
@@ -106,22 +112,28 @@ func ForgeReservation(peeringCandidate advertisementv1alpha1.PeeringCandidate, p
 }
 
 // ForgeContract creates a Contract CR
+// The Liqo token is not part of the Contract: it is stored in the Secret forged by ForgeCredentialsSecret.
 func ForgeContract(flavour nodecorev1alpha1.Flavour, transaction models.Transaction, lc *reservationv1alpha1.LiqoCredentials) *reservationv1alpha1.Contract {
+	name := namings.ForgeContractName(flavour.Name)
+	credentials := *lc
+	credentials.Token = ""
+	credentials.TokenSecretRef = forgeTokenSecretRef(name)
 	return &reservationv1alpha1.Contract{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      namings.ForgeContractName(flavour.Name),
+			Name:      name,
 			Namespace: flags.FLUIDOS_NAMESPACE,
 		},
 		Spec: reservationv1alpha1.ContractSpec{
 			Flavour: flavour,
 			Buyer: nodecorev1alpha1.NodeIdentity{
-				Domain: transaction.Buyer.Domain,
-				IP:     transaction.Buyer.IP,
-				NodeID: transaction.Buyer.NodeID,
+				Domain:    transaction.Buyer.Domain,
+				IP:        transaction.Buyer.IP,
+				NodeID:    transaction.Buyer.NodeID,
+				PublicKey: transaction.Buyer.PublicKey,
 			},
 			BuyerClusterID:    transaction.ClusterID,
 			Seller:            flavour.Spec.Owner,
-			SellerCredentials: *lc,
+			SellerCredentials: credentials,
 			TransactionID:     transaction.TransactionID,
 			Partition: func() *reservationv1alpha1.Partition {
 				if transaction.Partition != nil {
@@ -129,7 +141,9 @@ func ForgeContract(flavour nodecorev1alpha1.Flavour, transaction models.Transact
 				}
 				return nil
 			}(),
-			ExpirationTime: time.Now().Add(flags.EXPIRATION_CONTRACT).Format(time.RFC3339),
+			ExpirationTime: forgeContractExpiration(transaction.Offer),
+			AgreedPrice:    parseutil.ParseOfferFromObj(transaction.Offer),
+			SLA:            flavour.Spec.SLA.DeepCopy(),
 		},
 		Status: reservationv1alpha1.ContractStatus{
 			Phase: nodecorev1alpha1.PhaseStatus{
@@ -140,12 +154,110 @@ func ForgeContract(flavour nodecorev1alpha1.Flavour, transaction models.Transact
 	}
 }
 
+// forgeContractExpiration returns the expiration time of a new Contract: after the duration agreed in the offer, if any,
+// or after EXPIRATION_CONTRACT. The duration of the offer must have been validated by the seller.
+func forgeContractExpiration(offer *models.Offer) string {
+	duration := flags.EXPIRATION_CONTRACT
+	if offer != nil && offer.Duration != "" {
+		if d, err := pricing.ParsePeriod(offer.Duration); err == nil {
+			duration = d
+		}
+	}
+	return time.Now().Add(duration).Format(time.RFC3339)
+}
+
+// ForgeCredentialsSecret creates the Secret, owned by the Contract, that stores the Liqo token of the seller cluster
+func ForgeCredentialsSecret(contract *reservationv1alpha1.Contract, token string) *corev1.Secret {
+	ref := contract.Spec.SellerCredentials.TokenSecretRef
+	if ref == nil {
+		ref = forgeTokenSecretRef(contract.Name)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ref.Name,
+			Namespace: contract.Namespace,
+			Labels:    map[string]string{consts.CONTRACT_LABEL: contract.Name},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(contract, reservationv1alpha1.GroupVersion.WithKind("Contract")),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			consts.LIQO_TOKEN_SECRET_KEY: []byte(token),
+		},
+	}
+}
+
+// forgeTokenSecretRef returns the reference to the Secret storing the Liqo token of a Contract
+func forgeTokenSecretRef(contractName string) *nodecorev1alpha1.GenericRef {
+	return &nodecorev1alpha1.GenericRef{
+		Name:      contractName + consts.CONTRACT_CREDENTIALS_SUFFIX,
+		Namespace: flags.FLUIDOS_NAMESPACE,
+	}
+}
+
+// ForgeFlavourFromPool creates a new flavour custom resource from the aggregated metrics of a pool of nodes.
+// The nodes of the pool must share the same architecture.
+func ForgeFlavourFromPool(pool string, nodes []models.NodeInfo, ni nodecorev1alpha1.NodeIdentity) *nodecorev1alpha1.Flavour {
+	aggregate := models.NodeInfo{Name: pool}
+	for i := range nodes {
+		aggregate.Architecture = nodes[i].Architecture
+		metrics := &nodes[i].ResourceMetrics
+		aggregate.ResourceMetrics.CPUTotal.Add(metrics.CPUTotal)
+		aggregate.ResourceMetrics.CPUAvailable.Add(metrics.CPUAvailable)
+		aggregate.ResourceMetrics.MemoryTotal.Add(metrics.MemoryTotal)
+		aggregate.ResourceMetrics.MemoryAvailable.Add(metrics.MemoryAvailable)
+		aggregate.ResourceMetrics.EphemeralStorage.Add(metrics.EphemeralStorage)
+		aggregate.ResourceMetrics.Pods.Add(metrics.Pods)
+		aggregate.ResourceMetrics.GPUDetails = mergeGPU(aggregate.ResourceMetrics.GPUDetails, metrics.GPUDetails)
+		if metrics.GPUDetails == nil || metrics.GPUDetails.ResourceName == aggregate.ResourceMetrics.GPUDetails.ResourceName {
+			aggregate.ResourceMetrics.GPU.Add(metrics.GPU)
+		}
+		for name, quantity := range metrics.ExtendedResources {
+			if aggregate.ResourceMetrics.ExtendedResources == nil {
+				aggregate.ResourceMetrics.ExtendedResources = make(map[string]resource.Quantity)
+			}
+			q := aggregate.ResourceMetrics.ExtendedResources[name]
+			q.Add(quantity)
+			aggregate.ResourceMetrics.ExtendedResources[name] = q
+		}
+	}
+
+	flavour := ForgeFlavourFromMetrics(aggregate, ni)
+	flavour.Name = namings.ForgePoolFlavourName(pool, ni.Domain)
+	flavour.Labels = map[string]string{consts.FLAVOUR_POOL_LABEL: pool}
+	return flavour
+}
+
+// mergeGPU merges the GPU details of the nodes of a pool: the model and memory are kept only when all the GPUs share them
+func mergeGPU(pool, node *models.GPU) *models.GPU {
+	if pool == nil || node == nil {
+		if pool == nil && node != nil {
+			gpu := *node
+			return &gpu
+		}
+		return pool
+	}
+	if pool.Vendor != node.Vendor || pool.ResourceName != node.ResourceName {
+		// GPUs of different vendors: only the GPUs of the first vendor are offered
+		return pool
+	}
+	if pool.Model != node.Model {
+		pool.Model = ""
+	}
+	if pool.Memory.Cmp(node.Memory) != 0 {
+		pool.Memory = resource.Quantity{}
+	}
+	return pool
+}
+
 // ForgeFlavourFromMetrics creates a new flavour custom resource from the metrics of the node
 func ForgeFlavourFromMetrics(node models.NodeInfo, ni nodecorev1alpha1.NodeIdentity) (flavour *nodecorev1alpha1.Flavour) {
 	return &nodecorev1alpha1.Flavour{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      namings.ForgeFlavourName(node.UID, ni.Domain),
+			Name:      namings.ForgeFlavourName(node.Name, ni.Domain),
 			Namespace: flags.FLUIDOS_NAMESPACE,
+			Labels:    map[string]string{consts.FLAVOUR_NODE_LABEL: node.Name},
 		},
 		Spec: nodecorev1alpha1.FlavourSpec{
 			ProviderID: ni.NodeID,
@@ -156,7 +268,10 @@ func ForgeFlavourFromMetrics(node models.NodeInfo, ni nodecorev1alpha1.NodeIdent
 				Memory:            node.ResourceMetrics.MemoryAvailable,
 				EphemeralStorage:  node.ResourceMetrics.EphemeralStorage,
 				PersistentStorage: parseutil.ParseQuantityFromString("0"),
-				Gpu:               parseutil.ParseQuantityFromString("0"),
+				Gpu:               node.ResourceMetrics.GPU,
+				GpuDetails:        ForgeGPU(node.ResourceMetrics.GPUDetails),
+				Pods:              node.ResourceMetrics.Pods,
+				ExtendedResources: node.ResourceMetrics.ExtendedResources,
 			},
 			Policy: nodecorev1alpha1.Policy{
 				Partitionable: &nodecorev1alpha1.Partitionable{
@@ -171,11 +286,8 @@ func ForgeFlavourFromMetrics(node models.NodeInfo, ni nodecorev1alpha1.NodeIdent
 				},
 			},
 			Owner: ni,
-			Price: nodecorev1alpha1.Price{
-				Amount:   flags.AMOUNT,
-				Currency: flags.CURRENCY,
-				Period:   flags.PERIOD,
-			},
+			Price: forgePrice(),
+			SLA:   forgeSLA(),
 			OptionalFields: nodecorev1alpha1.OptionalFields{
 				Availability: true,
 				WorkerID:     node.UID,
@@ -184,6 +296,48 @@ func ForgeFlavourFromMetrics(node models.NodeInfo, ni nodecorev1alpha1.NodeIdent
 	}
 }
 
+// forgePrice creates the Price of the Flavours of this node from the flags of the local resource manager
+func forgePrice() nodecorev1alpha1.Price {
+	price := nodecorev1alpha1.Price{
+		Amount:   flags.AMOUNT,
+		Currency: strings.ToUpper(flags.CURRENCY),
+		Period:   pricing.NormalizePeriod(flags.PERIOD),
+	}
+	if flags.CPU_RATE != "" || flags.MEMORY_RATE != "" || flags.GPU_RATE != "" || flags.STORAGE_RATE != "" {
+		price.UnitRates = &nodecorev1alpha1.UnitRates{
+			Cpu:     flags.CPU_RATE,
+			Memory:  flags.MEMORY_RATE,
+			Gpu:     flags.GPU_RATE,
+			Storage: flags.STORAGE_RATE,
+		}
+	}
+	return price
+}
+
+// forgeSLA creates the SLA terms of the Flavours of this node from the flags of the local resource manager,
+// or nil if no term is offered. The availability credits are given as threshold=credit pairs (e.g. 99.9=10,99=25).
+func forgeSLA() *nodecorev1alpha1.SLA {
+	if flags.SLA_AVAILABILITY == "" && flags.SLA_MAX_TIME_TO_RESTORE == "" {
+		return nil
+	}
+	sla := &nodecorev1alpha1.SLA{
+		Availability:     flags.SLA_AVAILABILITY,
+		MaxTimeToRestore: pricing.NormalizePeriod(flags.SLA_MAX_TIME_TO_RESTORE),
+		RestoreCredit:    flags.SLA_RESTORE_CREDIT,
+	}
+	for _, pair := range strings.Split(flags.SLA_CREDITS, ",") {
+		threshold, credit, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			continue
+		}
+		sla.AvailabilityCredits = append(sla.AvailabilityCredits, nodecorev1alpha1.SLACredit{
+			BelowAvailability: strings.TrimSpace(threshold),
+			Credit:            strings.TrimSpace(credit),
+		})
+	}
+	return sla
+}
+
 // FORGER FUNCTIONS FROM OBJECTS
 
 // ForgeTransaction creates a new transaction
@@ -195,6 +349,7 @@ func ForgeTransactionObj(ID string, req models.ReserveRequest) models.Transactio
 		FlavourID:     req.FlavourID,
 		Partition:     req.Partition,
 		StartTime:     tools.GetTimeNow(),
+		ExpiresAt:     time.Now().Add(flags.EXPIRATION_TRANSACTION).Format(time.RFC3339),
 	}
 }
 
@@ -208,8 +363,8 @@ func ForgeContractObj(contract *reservationv1alpha1.Contract) models.Contract {
 		SellerCredentials: models.LiqoCredentials{
 			ClusterID:   contract.Spec.SellerCredentials.ClusterID,
 			ClusterName: contract.Spec.SellerCredentials.ClusterName,
-			Token:       contract.Spec.SellerCredentials.Token,
 			Endpoint:    contract.Spec.SellerCredentials.Endpoint,
+			TokenID:     contract.Spec.SellerCredentials.TokenID,
 		},
 		Partition: func() *models.Partition {
 			if contract.Spec.Partition != nil {
@@ -219,20 +374,25 @@ func ForgeContractObj(contract *reservationv1alpha1.Contract) models.Contract {
 		}(),
 		TransactionID:  contract.Spec.TransactionID,
 		ExpirationTime: contract.Spec.ExpirationTime,
+		AgreedPrice:    parseutil.ParseOffer(contract.Spec.AgreedPrice),
+		SLA:            parseutil.ParseSLA(contract.Spec.SLA),
 		ExtraInformation: func() map[string]string {
 			if contract.Spec.ExtraInformation != nil {
 				return contract.Spec.ExtraInformation
 			}
 			return nil
 		}(),
+		SellerSignature: parseutil.ParseSignature(contract.Spec.SellerSignature),
+		BuyerSignature:  parseutil.ParseSignature(contract.Spec.BuyerSignature),
 	}
 }
 
 // ForgeResponsePurchaseObj creates a new response purchase
-func ForgeResponsePurchaseObj(contract models.Contract) models.ResponsePurchase {
+func ForgeResponsePurchaseObj(contract models.Contract, credentials *models.SealedData) models.ResponsePurchase {
 	return models.ResponsePurchase{
-		Contract: contract,
-		Status:   "Completed",
+		Contract:    contract,
+		Credentials: credentials,
+		Status:      "Completed",
 	}
 }
 
@@ -246,21 +406,24 @@ func ForgeContractFromObj(contract models.Contract) *reservationv1alpha1.Contrac
 		Spec: reservationv1alpha1.ContractSpec{
 			Flavour: *ForgeFlavourFromObj(contract.Flavour),
 			Buyer: nodecorev1alpha1.NodeIdentity{
-				Domain: contract.Buyer.Domain,
-				IP:     contract.Buyer.IP,
-				NodeID: contract.Buyer.NodeID,
+				Domain:    contract.Buyer.Domain,
+				IP:        contract.Buyer.IP,
+				NodeID:    contract.Buyer.NodeID,
+				PublicKey: contract.Buyer.PublicKey,
 			},
 			BuyerClusterID: contract.BuyerClusterID,
 			Seller: nodecorev1alpha1.NodeIdentity{
-				NodeID: contract.Seller.NodeID,
-				IP:     contract.Seller.IP,
-				Domain: contract.Seller.Domain,
+				NodeID:    contract.Seller.NodeID,
+				IP:        contract.Seller.IP,
+				Domain:    contract.Seller.Domain,
+				PublicKey: contract.Seller.PublicKey,
 			},
 			SellerCredentials: reservationv1alpha1.LiqoCredentials{
-				ClusterID:   contract.SellerCredentials.ClusterID,
-				ClusterName: contract.SellerCredentials.ClusterName,
-				Token:       contract.SellerCredentials.Token,
-				Endpoint:    contract.SellerCredentials.Endpoint,
+				ClusterID:      contract.SellerCredentials.ClusterID,
+				ClusterName:    contract.SellerCredentials.ClusterName,
+				Endpoint:       contract.SellerCredentials.Endpoint,
+				TokenSecretRef: forgeTokenSecretRef(contract.ContractID),
+				TokenID:        contract.SellerCredentials.TokenID,
 			},
 			TransactionID: contract.TransactionID,
 			Partition: func() *reservationv1alpha1.Partition {
@@ -270,12 +433,16 @@ func ForgeContractFromObj(contract models.Contract) *reservationv1alpha1.Contrac
 				return nil
 			}(),
 			ExpirationTime: contract.ExpirationTime,
+			AgreedPrice:    parseutil.ParseOfferFromObj(contract.AgreedPrice),
+			SLA:            parseutil.ParseSLAFromObj(contract.SLA),
 			ExtraInformation: func() map[string]string {
 				if contract.ExtraInformation != nil {
 					return contract.ExtraInformation
 				}
 				return nil
 			}(),
+			SellerSignature: parseutil.ParseSignatureFromObj(contract.SellerSignature),
+			BuyerSignature:  parseutil.ParseSignatureFromObj(contract.BuyerSignature),
 		},
 		Status: reservationv1alpha1.ContractStatus{
 			Phase: nodecorev1alpha1.PhaseStatus{
@@ -294,12 +461,14 @@ func ForgeTransactionFromObj(reservation *models.Transaction) *reservationv1alph
 			Namespace: flags.FLUIDOS_NAMESPACE,
 		},
 		Spec: reservationv1alpha1.TransactionSpec{
-			FlavourID: reservation.FlavourID,
-			StartTime: reservation.StartTime,
+			FlavourID:      reservation.FlavourID,
+			StartTime:      reservation.StartTime,
+			ExpirationTime: reservation.ExpiresAt,
 			Buyer: nodecorev1alpha1.NodeIdentity{
-				Domain: reservation.Buyer.Domain,
-				IP:     reservation.Buyer.IP,
-				NodeID: reservation.Buyer.NodeID,
+				Domain:    reservation.Buyer.Domain,
+				IP:        reservation.Buyer.IP,
+				NodeID:    reservation.Buyer.NodeID,
+				PublicKey: reservation.Buyer.PublicKey,
 			},
 			ClusterID: reservation.ClusterID,
 			Partition: func() *reservationv1alpha1.Partition {
@@ -308,6 +477,8 @@ func ForgeTransactionFromObj(reservation *models.Transaction) *reservationv1alph
 				}
 				return nil
 			}(),
+			Offer:       parseutil.ParseOfferFromObj(reservation.Offer),
+			Negotiation: parseutil.ParseNegotiationFromObj(reservation.Negotiation),
 		},
 	}
 }
@@ -329,6 +500,9 @@ func ForgeFlavourFromObj(flavour models.Flavour) *nodecorev1alpha1.Flavour {
 				EphemeralStorage:  flavour.Characteristics.EphemeralStorage,
 				PersistentStorage: flavour.Characteristics.PersistentStorage,
 				Gpu:               flavour.Characteristics.Gpu,
+				GpuDetails:        ForgeGPU(flavour.Characteristics.GpuDetails),
+				Pods:              flavour.Characteristics.Pods,
+				ExtendedResources: flavour.Characteristics.ExtendedResources,
 			},
 			Policy: nodecorev1alpha1.Policy{
 				// Check if flavour.Partitionable is not nil before setting Partitionable
@@ -354,15 +528,13 @@ func ForgeFlavourFromObj(flavour models.Flavour) *nodecorev1alpha1.Flavour {
 				}(),
 			},
 			Owner: nodecorev1alpha1.NodeIdentity{
-				Domain: flavour.Owner.Domain,
-				IP:     flavour.Owner.IP,
-				NodeID: flavour.Owner.NodeID,
-			},
-			Price: nodecorev1alpha1.Price{
-				Amount:   flavour.Price.Amount,
-				Currency: flavour.Price.Currency,
-				Period:   flavour.Price.Period,
+				Domain:    flavour.Owner.Domain,
+				IP:        flavour.Owner.IP,
+				NodeID:    flavour.Owner.NodeID,
+				PublicKey: flavour.Owner.PublicKey,
 			},
+			Price: parseutil.ParsePriceFromObj(flavour.Price),
+			SLA:   parseutil.ParseSLAFromObj(flavour.SLA),
 		},
 	}
 	return f
@@ -378,3 +550,16 @@ func ForgePartition(selector *nodecorev1alpha1.FlavourSelector) *reservationv1al
 		Gpu:              selector.RangeSelector.MinGpu,
 	}
 }
+
+// ForgeGPU creates the GPU details of a Flavour CR from a GPU Object
+func ForgeGPU(gpu *models.GPU) *nodecorev1alpha1.GPU {
+	if gpu == nil {
+		return nil
+	}
+	return &nodecorev1alpha1.GPU{
+		Vendor:       gpu.Vendor,
+		Model:        gpu.Model,
+		Memory:       gpu.Memory,
+		ResourceName: gpu.ResourceName,
+	}
+}
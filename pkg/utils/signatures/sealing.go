@@ -0,0 +1,133 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signatures
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+// sealingInfo separates the keys derived for sealing from any other use of the same secrets
+const sealingInfo = "fluidos-sealing"
+
+// SealingKey derives the X25519 key the FLUIDOS Node receives sealed data with in the given context (e.g. a purchase).
+// The key is deterministic, so that a retried request can open the response replayed by the other party.
+func (kp *KeyPair) SealingKey(context string) (*ecdh.PrivateKey, error) {
+	mac := hmac.New(sha256.New, kp.PrivateKey.Seed())
+	mac.Write([]byte(sealingInfo + "/" + context))
+	return ecdh.X25519().NewPrivateKey(mac.Sum(nil))
+}
+
+// EncodePublicKey returns the base64 encoding of the public part of a sealing key
+func EncodePublicKey(key *ecdh.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(key.PublicKey().Bytes())
+}
+
+// Seal encrypts the data for the holder of the given base64 encoded X25519 public key, binding it to the additional data
+func Seal(publicKey string, data, additionalData []byte) (*models.SealedData, error) {
+	raw, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key encoding")
+	}
+	recipient, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := forgeAEAD(ephemeral, recipient, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return &models.SealedData{
+		PublicKey:  base64.StdEncoding.EncodeToString(ephemeral.PublicKey().Bytes()),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, data, additionalData)),
+	}, nil
+}
+
+// Open decrypts the data sealed for the given key, checking that it is bound to the additional data
+func Open(key *ecdh.PrivateKey, sealed *models.SealedData, additionalData []byte) ([]byte, error) {
+	if sealed == nil {
+		return nil, fmt.Errorf("sealed data is missing")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(sealed.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key encoding")
+	}
+	sender, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(sealed.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce encoding")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(sealed.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext encoding")
+	}
+
+	aead, err := forgeAEAD(key, sender, sender, key.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce size")
+	}
+
+	data, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the sealed data: %w", err)
+	}
+	return data, nil
+}
+
+// forgeAEAD derives the AES-256-GCM cipher shared by the sender and the recipient of sealed data
+func forgeAEAD(private *ecdh.PrivateKey, peer, sender, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	shared, err := private.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, shared)
+	mac.Write([]byte(sealingInfo))
+	mac.Write(sender.Bytes())
+	mac.Write(recipient.Bytes())
+
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,200 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signatures
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fluidos-project/node/pkg/utils/consts"
+	"github.com/fluidos-project/node/pkg/utils/flags"
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+// KeyPair is the signing keypair of a FLUIDOS Node
+type KeyPair struct {
+	KeyID      string
+	PublicKey  ed25519.PublicKey
+	PrivateKey ed25519.PrivateKey
+}
+
+// GetOrCreateKeyPair retrieves the signing keypair of the FLUIDOS Node from its Secret, creating it if it does not exist yet
+func GetOrCreateKeyPair(ctx context.Context, cl client.Client) (*KeyPair, error) {
+	secret := &corev1.Secret{}
+	err := cl.Get(ctx, types.NamespacedName{
+		Name:      consts.NODE_KEYPAIR_SECRET_NAME,
+		Namespace: flags.FLUIDOS_NAMESPACE,
+	}, secret)
+	if client.IgnoreNotFound(err) != nil {
+		klog.Errorf("Error when getting the keypair Secret: %s", err)
+		return nil, err
+	}
+
+	if err == nil {
+		privateKey := secret.Data[consts.PRIVATE_KEY_SECRET_KEY]
+		if len(privateKey) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("invalid private key in Secret %s", consts.NODE_KEYPAIR_SECRET_NAME)
+		}
+		return forgeKeyPair(ed25519.PrivateKey(privateKey)), nil
+	}
+
+	klog.Infof("Keypair Secret not found, generating a new keypair...")
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	kp := forgeKeyPair(privateKey)
+
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      consts.NODE_KEYPAIR_SECRET_NAME,
+			Namespace: flags.FLUIDOS_NAMESPACE,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			consts.PRIVATE_KEY_SECRET_KEY: kp.PrivateKey,
+			consts.PUBLIC_KEY_SECRET_KEY:  kp.PublicKey,
+		},
+	}
+	if err := cl.Create(ctx, secret); err != nil {
+		klog.Errorf("Error when creating the keypair Secret: %s", err)
+		return nil, err
+	}
+
+	klog.Infof("Keypair %s created", kp.KeyID)
+	return kp, nil
+}
+
+// ForgeKeyID returns the identifier of a public key, that is its SHA-256 fingerprint
+func ForgeKeyID(publicKey ed25519.PublicKey) string {
+	hash := sha256.Sum256(publicKey)
+	return hex.EncodeToString(hash[:16])
+}
+
+// EncodedPublicKey returns the base64 encoded public key of the keypair, as published in the NodeIdentity
+func (kp *KeyPair) EncodedPublicKey() string {
+	return base64.StdEncoding.EncodeToString(kp.PublicKey)
+}
+
+// Sign signs the canonical JSON encoding of the given object
+func (kp *KeyPair) Sign(obj interface{}) (*models.Signature, error) {
+	payload, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Signature{
+		KeyID:     kp.KeyID,
+		PublicKey: kp.EncodedPublicKey(),
+		Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(kp.PrivateKey, payload)),
+	}, nil
+}
+
+// Verify checks that the signature matches the canonical JSON encoding of the given object
+// and that it has been produced by the key it declares
+func Verify(obj interface{}, signature *models.Signature) error {
+	if signature == nil {
+		return fmt.Errorf("signature is missing")
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(signature.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key")
+	}
+
+	if ForgeKeyID(publicKey) != signature.KeyID {
+		return fmt.Errorf("key ID %s does not match the public key", signature.KeyID)
+	}
+
+	value, err := base64.StdEncoding.DecodeString(signature.Value)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding")
+	}
+
+	payload, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, payload, value) {
+		return fmt.Errorf("signature verification failed")
+	}
+
+	return nil
+}
+
+// VerifyFrom checks the signature as Verify does and that it has been produced by the expected signer,
+// identified by its base64 encoded public key. A signature made by any other key is rejected.
+func VerifyFrom(obj interface{}, signature *models.Signature, publicKey string) error {
+	if publicKey == "" {
+		return fmt.Errorf("the public key of the signer is unknown")
+	}
+
+	if signature == nil {
+		return fmt.Errorf("signature is missing")
+	}
+
+	if signature.PublicKey != publicKey {
+		return fmt.Errorf("key %s is not the key of the expected signer", signature.KeyID)
+	}
+
+	return Verify(obj, signature)
+}
+
+// SignContract signs the terms of a Contract
+func (kp *KeyPair) SignContract(contract *models.Contract) (*models.Signature, error) {
+	return kp.Sign(ContractTerms(contract))
+}
+
+// VerifyContract checks the given signature against the terms of a Contract
+func VerifyContract(contract *models.Contract, signature *models.Signature) error {
+	return Verify(ContractTerms(contract), signature)
+}
+
+// VerifyContractFrom checks the given signature against the terms of a Contract and the key of the expected signer
+func VerifyContractFrom(contract *models.Contract, signature *models.Signature, publicKey string) error {
+	return VerifyFrom(ContractTerms(contract), signature, publicKey)
+}
+
+// ContractTerms returns the canonical form of a Contract that is signed by the seller and the buyer.
+// The credentials and the signatures are not part of the terms.
+func ContractTerms(contract *models.Contract) models.Contract {
+	terms := *contract
+	terms.SellerCredentials = models.LiqoCredentials{}
+	terms.SellerSignature = nil
+	terms.BuyerSignature = nil
+	return terms
+}
+
+func forgeKeyPair(privateKey ed25519.PrivateKey) *KeyPair {
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	return &KeyPair{
+		KeyID:      ForgeKeyID(publicKey),
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2022-2023 FLUIDOS Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signatures
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluidos-project/node/pkg/utils/models"
+)
+
+// testKeyPair returns a deterministic keypair derived from the given seed byte
+func testKeyPair(seed byte) *KeyPair {
+	return forgeKeyPair(ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize)))
+}
+
+func TestVerify(t *testing.T) {
+	kp, other := testKeyPair(1), testKeyPair(2)
+	message := models.ReserveRequest{FlavourID: "flavour-1", Nonce: "n1", Timestamp: "2023-01-01T00:00:00Z"}
+
+	signature, err := kp.Sign(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSignature, err := other.Sign(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := message
+	tampered.FlavourID = "flavour-2"
+
+	tests := []struct {
+		name      string
+		obj       interface{}
+		signature *models.Signature
+		wantErr   bool
+	}{
+		{name: "valid signature", obj: message, signature: signature},
+		{name: "pointer to the same message", obj: &message, signature: signature},
+		{name: "tampered message", obj: tampered, signature: signature, wantErr: true},
+		{name: "missing signature", obj: message, wantErr: true},
+		{name: "key ID of another key", obj: message, wantErr: true,
+			signature: &models.Signature{KeyID: other.KeyID, PublicKey: signature.PublicKey, Value: signature.Value}},
+		{name: "value made by another key", obj: message, wantErr: true,
+			signature: &models.Signature{KeyID: signature.KeyID, PublicKey: signature.PublicKey, Value: otherSignature.Value}},
+		{name: "invalid public key", obj: message, wantErr: true,
+			signature: &models.Signature{KeyID: signature.KeyID, PublicKey: "not-base64", Value: signature.Value}},
+		{name: "invalid value encoding", obj: message, wantErr: true,
+			signature: &models.Signature{KeyID: signature.KeyID, PublicKey: signature.PublicKey, Value: "not-base64"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.obj, tt.signature); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyFrom(t *testing.T) {
+	kp, other := testKeyPair(1), testKeyPair(2)
+	message := models.PurchaseRequest{TransactionID: "transaction-1", Nonce: "n1"}
+
+	signature, err := kp.Sign(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSignature, err := other.Sign(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		signature *models.Signature
+		publicKey string
+		wantErr   bool
+	}{
+		{name: "expected signer", signature: signature, publicKey: kp.EncodedPublicKey()},
+		{name: "valid signature of another key", signature: otherSignature, publicKey: kp.EncodedPublicKey(), wantErr: true},
+		{name: "unknown signer", signature: signature, wantErr: true},
+		{name: "missing signature", publicKey: kp.EncodedPublicKey(), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyFrom(message, tt.signature, tt.publicKey); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyFrom() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyContract(t *testing.T) {
+	seller, buyer := testKeyPair(1), testKeyPair(2)
+	contract := &models.Contract{
+		ContractID:        "contract-1",
+		TransactionID:     "transaction-1",
+		Buyer:             models.NodeIdentity{NodeID: "buyer", PublicKey: buyer.EncodedPublicKey()},
+		Seller:            models.NodeIdentity{NodeID: "seller", PublicKey: seller.EncodedPublicKey()},
+		SellerCredentials: models.LiqoCredentials{ClusterID: "cluster-1", Endpoint: "https://seller"},
+	}
+
+	signature, err := seller.SignContract(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buyerSignature, err := buyer.SignContract(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *models.Contract)
+		wantErr bool
+	}{
+		{name: "unchanged terms", modify: func(c *models.Contract) {}},
+		{name: "signatures attached", modify: func(c *models.Contract) {
+			c.SellerSignature, c.BuyerSignature = signature, buyerSignature
+		}},
+		{name: "credentials changed", modify: func(c *models.Contract) {
+			c.SellerCredentials = models.LiqoCredentials{ClusterID: "cluster-2", TokenID: "token"}
+		}},
+		{name: "buyer changed", wantErr: true, modify: func(c *models.Contract) { c.Buyer.NodeID = "other" }},
+		{name: "expiration changed", wantErr: true, modify: func(c *models.Contract) { c.ExpirationTime = "2030-01-01T00:00:00Z" }},
+		{name: "partition added", wantErr: true, modify: func(c *models.Contract) { c.Partition = &models.Partition{} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := *contract
+			tt.modify(&c)
+			if err := VerifyContractFrom(&c, signature, seller.EncodedPublicKey()); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyContractFrom() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err := VerifyContract(&c, buyerSignature); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyContract() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := VerifyContractFrom(contract, buyerSignature, seller.EncodedPublicKey()); err == nil {
+		t.Errorf("VerifyContractFrom() accepted the signature of the buyer as the one of the seller")
+	}
+	if contract.SellerCredentials.ClusterID != "cluster-1" {
+		t.Errorf("ContractTerms() modified the Contract")
+	}
+}
+
+func TestGetOrCreateKeyPair(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	created, err := GetOrCreateKeyPair(context.Background(), cl)
+	if err != nil {
+		t.Fatalf("GetOrCreateKeyPair() error = %s", err)
+	}
+	if created.KeyID != ForgeKeyID(created.PublicKey) {
+		t.Errorf("GetOrCreateKeyPair() key ID = %s, want %s", created.KeyID, ForgeKeyID(created.PublicKey))
+	}
+
+	loaded, err := GetOrCreateKeyPair(context.Background(), cl)
+	if err != nil {
+		t.Fatalf("GetOrCreateKeyPair() error = %s", err)
+	}
+	if loaded.KeyID != created.KeyID || !bytes.Equal(loaded.PrivateKey, created.PrivateKey) {
+		t.Errorf("GetOrCreateKeyPair() = %s, want the stored keypair %s", loaded.KeyID, created.KeyID)
+	}
+}
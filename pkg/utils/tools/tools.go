@@ -34,3 +34,16 @@ func CheckExpiration(timestamp string, expTime time.Duration) bool {
 	}
 	return time.Since(t) > expTime
 }
+
+// IsExpired checks if the expiration time has passed. An empty expiration time never expires.
+func IsExpired(expirationTime string) bool {
+	if expirationTime == "" {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339, expirationTime)
+	if err != nil {
+		klog.Errorf("Error parsing the expiration time: %s", err)
+		return false
+	}
+	return time.Now().After(t)
+}